@@ -3,7 +3,7 @@
 
 INSERT INTO workflow_definitions (id, name, version, description, config) VALUES
 (
-    'w1000000-0000-0000-0000-000000000001',
+    'c1000000-0000-0000-0000-000000000001',
     'pr-review',
     '1.0',
     'Comprehensive pull request review workflow with code review and security analysis',
@@ -54,7 +54,7 @@ INSERT INTO workflow_definitions (id, name, version, description, config) VALUES
     }'
 ),
 (
-    'w1000000-0000-0000-0000-000000000002',
+    'c1000000-0000-0000-0000-000000000002',
     'code-generation',
     '1.0',
     'AI-assisted code generation workflow with review and testing',
@@ -99,7 +99,7 @@ INSERT INTO workflow_definitions (id, name, version, description, config) VALUES
     }'
 ),
 (
-    'w1000000-0000-0000-0000-000000000003',
+    'c1000000-0000-0000-0000-000000000003',
     'security-audit',
     '1.0',
     'Comprehensive security audit workflow',
@@ -3,7 +3,7 @@
 
 INSERT INTO policy_bundles (id, name, version, description, active, rules) VALUES
 (
-    'p1000000-0000-0000-0000-000000000001',
+    'b1000000-0000-0000-0000-000000000001',
     'default-security',
     '1.0',
     'Default security policies for workflow execution',
@@ -33,7 +33,7 @@ INSERT INTO policy_bundles (id, name, version, description, active, rules) VALUE
     ]'
 ),
 (
-    'p1000000-0000-0000-0000-000000000002',
+    'b1000000-0000-0000-0000-000000000002',
     'strict-governance',
     '1.0',
     'Strict governance policies for regulated environments',
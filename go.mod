@@ -16,6 +16,7 @@ require (
 	github.com/felixgeelhaar/fortify v1.1.3-0.20260103140816-333b8e495bdf
 	github.com/felixgeelhaar/mcp-go v1.4.0
 	github.com/felixgeelhaar/statekit v1.0.1
+	github.com/itchyny/gojq v0.12.17
 	github.com/jackc/pgx/v5 v5.8.0
 	github.com/rabbitmq/amqp091-go v1.10.0
 )
@@ -31,6 +32,7 @@ require (
 	github.com/gobwas/glob v0.2.3 // indirect
 	github.com/gorilla/mux v1.8.1 // indirect
 	github.com/gorilla/websocket v1.5.3 // indirect
+	github.com/itchyny/timefmt-go v0.1.6 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
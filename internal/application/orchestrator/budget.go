@@ -0,0 +1,248 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/governance"
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// budgetTracker accounts the usage of a run against the budgets of the run,
+// its workflow and its steps while the run executes. The budgets of the run
+// and its steps limit the run alone; the budget of the workflow is shared
+// by all its runs and also counts the usage of the other runs. Workers
+// record the usage of each agent call as it returns, so that steps running
+// concurrently stop before their next call once they used up a budget.
+type budgetTracker struct {
+	def   *workflow.WorkflowDefinition
+	run   *workflow.Budget
+	spent workflow.Usage // Usage of the other runs of the workflow
+
+	mu    sync.Mutex
+	used  workflow.Usage
+	steps map[string]workflow.Usage // Usage per step definition
+}
+
+// newBudgetTracker creates a tracker starting from the usage the steps of
+// the run recorded so far and the usage spent by the other runs of its
+// workflow.
+func newBudgetTracker(run *workflow.WorkflowRun, def *workflow.WorkflowDefinition, spent workflow.Usage) *budgetTracker {
+	t := &budgetTracker{
+		def:   def,
+		run:   run.Budget,
+		spent: spent,
+		used:  run.Usage(""),
+		steps: make(map[string]workflow.Usage),
+	}
+	for _, step := range run.Steps {
+		name := step.DefinitionName()
+		if _, ok := t.steps[name]; !ok {
+			t.steps[name] = run.Usage(name)
+		}
+	}
+	return t
+}
+
+// limit is a budget that applies to a step with the usage it is checked
+// against.
+type limit struct {
+	scope  string
+	budget *workflow.Budget
+	usage  workflow.Usage
+}
+
+// limits returns the budgets that apply to a step. Budgets that pause for
+// approval no longer apply once the step is approved. Callers hold t.mu.
+func (t *budgetTracker) limits(step *workflow.StepRun) []limit {
+	name := step.DefinitionName()
+	limits := []limit{
+		{"run", t.run, t.used},
+		{"workflow", t.def.Budget, t.spent.Add(t.used)},
+	}
+	if stepDef := t.def.GetStep(name); stepDef != nil {
+		limits = append(limits, limit{"step " + name, stepDef.Budget, t.steps[name]})
+	}
+
+	applied := limits[:0]
+	for _, l := range limits {
+		if l.budget == nil || l.budget.Approvable() && step.IsApproved() {
+			continue
+		}
+		applied = append(applied, l)
+	}
+	return applied
+}
+
+// check returns a *workflow.BudgetError when a budget that applies to the
+// step is used up. Budgets that fail the step are reported before those
+// that pause it for approval.
+func (t *budgetTracker) check(step *workflow.StepRun) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var exceeded *workflow.BudgetError
+	for _, l := range t.limits(step) {
+		if !l.budget.Exhausted(l.usage) {
+			continue
+		}
+		if exceeded == nil || exceeded.Budget.Approvable() && !l.budget.Approvable() {
+			exceeded = &workflow.BudgetError{Scope: l.scope, Budget: l.budget, Usage: l.usage}
+		}
+	}
+	if exceeded == nil {
+		return nil
+	}
+	return exceeded
+}
+
+// checkWorkflow returns a *workflow.BudgetError when the runs of the
+// workflow used up its budget and the budget fails runs rather than pausing
+// them for approval.
+func (t *budgetTracker) checkWorkflow() error {
+	if t == nil || t.def.Budget == nil || t.def.Budget.Approvable() {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.spent.Add(t.used)
+	if !t.def.Budget.Exhausted(usage) {
+		return nil
+	}
+	return &workflow.BudgetError{Scope: "workflow", Budget: t.def.Budget, Usage: usage}
+}
+
+// record adds the usage of an agent call made by the step.
+func (t *budgetTracker) record(step *workflow.StepRun, usage workflow.Usage) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	name := step.DefinitionName()
+	t.used = t.used.Add(usage)
+	t.steps[name] = t.steps[name].Add(usage)
+}
+
+// remaining returns the smallest budget the step has left, nil when no
+// budget applies. The run a sub-workflow step starts is limited to it;
+// child runs cannot wait for approval, so the budget fails the child run
+// and the step decides whether to pause.
+func (t *budgetTracker) remaining(step *workflow.StepRun) *workflow.Budget {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var remaining *workflow.Budget
+	for _, l := range t.limits(step) {
+		left := l.budget.Remaining(l.usage)
+		if remaining == nil {
+			remaining = left
+			continue
+		}
+		if left.MaxTokens > 0 && (remaining.MaxTokens == 0 || left.MaxTokens < remaining.MaxTokens) {
+			remaining.MaxTokens = left.MaxTokens
+		}
+		if left.MaxCostUSD > 0 && (remaining.MaxCostUSD == 0 || left.MaxCostUSD < remaining.MaxCostUSD) {
+			remaining.MaxCostUSD = left.MaxCostUSD
+		}
+	}
+	if remaining != nil {
+		remaining.OnExceeded = config.BudgetOnExceededFail
+	}
+	return remaining
+}
+
+// policyInput describes the usage of the run and the budget the step has
+// left for policies.
+func (t *budgetTracker) policyInput(step *workflow.StepRun) *governance.BudgetInput {
+	if t == nil {
+		return nil
+	}
+	remaining := t.remaining(step)
+
+	t.mu.Lock()
+	input := &governance.BudgetInput{
+		TokensUsed: t.used.Tokens,
+		CostUSD:    t.used.CostUSD,
+	}
+	t.mu.Unlock()
+
+	if remaining == nil {
+		return input
+	}
+	if remaining.MaxTokens > 0 {
+		tokens := remaining.MaxTokens
+		input.TokensRemaining = &tokens
+	}
+	if remaining.MaxCostUSD > 0 {
+		cost := remaining.MaxCostUSD
+		input.CostRemainingUSD = &cost
+	}
+	return input
+}
+
+// workflowSpent returns the usage of the runs of the workflow other than
+// run, which its budget counts. Workflows without a budget return no usage.
+func (o *Orchestrator) workflowSpent(ctx context.Context, run *workflow.WorkflowRun, def *workflow.WorkflowDefinition) (workflow.Usage, error) {
+	if def.Budget == nil {
+		return workflow.Usage{}, nil
+	}
+
+	total, err := o.workflowService.WorkflowUsage(ctx, def.Name)
+	if err != nil {
+		return workflow.Usage{}, fmt.Errorf("failed to load workflow usage: %w", err)
+	}
+	// The steps of the run stored so far are counted by the tracker
+	used := run.Usage("")
+	return workflow.Usage{
+		Tokens:  max(total.Tokens-used.Tokens, 0),
+		CostUSD: max(total.CostUSD-used.CostUSD, 0),
+	}, nil
+}
+
+// overBudget handles a step stopped by err because a budget was used up. A
+// budget that pauses for approval returns the step to await approval, and
+// overBudget returns true; otherwise the step fails. It returns false for
+// other errors.
+func (s *scheduler) overBudget(ctx context.Context, step *workflow.StepRun, err error) bool {
+	if !errors.Is(err, types.ErrBudgetExceeded) {
+		return false
+	}
+
+	// The run of a sub-workflow step reports the budget it inherited, the
+	// step is paused when the budget of the run it belongs to allows it
+	var exceeded *workflow.BudgetError
+	paused := errors.As(s.budget.check(step), &exceeded) && exceeded.Budget.Approvable() && step.Handler == ""
+
+	action := "fail"
+	if paused {
+		action = "await_approval"
+	}
+	s.o.auditService.LogBudgetExceeded(ctx, s.run.ID.String(), step.ID.String(), step.Name, err.Error(), action)
+
+	if !paused {
+		return false
+	}
+
+	step.Reset()
+	step.AwaitApproval()
+	s.o.workflowService.UpdateStep(ctx, step)
+
+	s.logger.Warn().
+		Str("step", step.Name).
+		Str("reason", err.Error()).
+		Msg("Step awaiting approval to exceed its budget")
+	return true
+}
@@ -0,0 +1,76 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// executeStep executes an agent step on a worker. A step with a cache
+// setting is served from the step cache when a result of the same agent,
+// prompt and tools has not expired; otherwise its result is marked to be
+// cached once the step completes. A cached result carries the tokens and
+// cost of the run that produced it; they are not counted against the
+// budgets of the run that reuses it.
+func (s *scheduler) executeStep(ctx context.Context, step *workflow.StepRun) (*StepResult, error) {
+	stepDef := s.def.GetStep(step.DefinitionName())
+	if stepDef == nil || stepDef.CacheTTL <= 0 || s.o.stepCache == nil {
+		return s.executor.ExecuteStep(ctx, s.run, s.def, step)
+	}
+
+	key, err := s.executor.CacheKey(s.run, s.def, step)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.o.noCache {
+		cached, err := s.o.stepCache.GetCachedResult(ctx, key)
+		switch {
+		case err == nil:
+			s.logger.Info().
+				Str("step", step.Name).
+				Str("cached_run_id", cached.RunID.String()).
+				Msg("Step result served from cache")
+			return &StepResult{
+				Output: cached.Output,
+				Tokens: workflow.TokenUsage{
+					Input:   cached.TokensIn,
+					Output:  cached.TokensOut,
+					CostUSD: cached.CostUSD,
+				},
+				CacheKey: key,
+				Cached:   true,
+			}, nil
+		case !errors.Is(err, types.ErrCacheMiss):
+			s.logger.Warn().
+				Str("step", step.Name).
+				Err(err).
+				Msg("Failed to read step cache")
+		}
+	}
+
+	result, err := s.executor.ExecuteStep(ctx, s.run, s.def, step)
+	if err != nil {
+		return nil, err
+	}
+	result.CacheKey = key
+	return result, nil
+}
+
+// cacheResult stores the result of a completed step for reuse by later
+// runs. Failing to store it does not fail the step.
+func (s *scheduler) cacheResult(ctx context.Context, step *workflow.StepRun, key string) {
+	stepDef := s.def.GetStep(step.DefinitionName())
+	if stepDef == nil || s.o.stepCache == nil {
+		return
+	}
+
+	if err := s.o.stepCache.SaveCachedResult(ctx, workflow.NewCachedResult(key, step, stepDef.CacheTTL)); err != nil {
+		s.logger.Warn().
+			Str("step", step.Name).
+			Err(err).
+			Msg("Failed to store step result in cache")
+	}
+}
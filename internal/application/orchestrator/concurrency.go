@@ -0,0 +1,137 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/felixgeelhaar/bolt"
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// enterGroup waits until a run may execute in its concurrency group, which
+// is once every earlier run in the group has finished. When the workflow
+// cancels runs in progress, the earlier runs are cancelled first and a run
+// superseded by a later run is cancelled itself. Runs in the group are
+// listed from the shared store, so groups hold across processes.
+//
+// While it waits, the run is leased and can be cancelled like an executing
+// run, and it times out at its deadline.
+func (o *Orchestrator) enterGroup(ctx context.Context, run *workflow.WorkflowRun, def *workflow.WorkflowDefinition, logger *bolt.Logger) error {
+	if run.ConcurrencyGroup == "" {
+		return nil
+	}
+
+	if err := o.workflowService.AcquireLease(ctx, run.ID, o.instanceID, o.leaseTTL); err != nil {
+		if errors.Is(err, types.ErrRunCancelled) {
+			defer o.workflowService.ReleaseLease(ctx, run.ID, o.instanceID)
+			o.cancelRun(ctx, run, cancelReason(err))
+			return err
+		}
+		return fmt.Errorf("failed to lease run %s: %w", run.ID, err)
+	}
+	defer o.workflowService.ReleaseLease(ctx, run.ID, o.instanceID)
+
+	waitCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	defer o.track(run.ID, cancel)()
+
+	ticker := time.NewTicker(o.leaseRenewal)
+	defer ticker.Stop()
+
+	superseded := make(map[types.RunID]bool)
+	queued := false
+	for {
+		runs, err := o.workflowService.ListActiveRunsInGroup(ctx, run.ConcurrencyGroup)
+		if err != nil {
+			return fmt.Errorf("failed to list runs in concurrency group %s: %w", run.ConcurrencyGroup, err)
+		}
+
+		earlier := make([]*workflow.WorkflowRun, 0, len(runs))
+		for _, other := range runs {
+			switch {
+			case other.ID == run.ID:
+			case other.Precedes(run):
+				earlier = append(earlier, other)
+			case def.CancelInProgress:
+				reason := "superseded by run " + other.ID.String()
+				o.cancelRun(ctx, run, reason)
+				return fmt.Errorf("%w: %s", types.ErrRunCancelled, reason)
+			}
+		}
+		if len(earlier) == 0 {
+			return nil
+		}
+
+		if def.CancelInProgress {
+			cancelled := false
+			for _, other := range earlier {
+				if superseded[other.ID] {
+					continue
+				}
+				superseded[other.ID] = true
+				cancelled = true
+
+				logger.Info().
+					Str("superseded_run_id", other.ID.String()).
+					Str("group", run.ConcurrencyGroup).
+					Msg("Cancelling earlier run in concurrency group")
+
+				err := o.CancelRun(ctx, other.ID, "superseded by run "+run.ID.String())
+				if err != nil && !errors.Is(err, types.ErrRunCompleted) {
+					logger.Warn().
+						Str("superseded_run_id", other.ID.String()).
+						Err(err).
+						Msg("Failed to cancel earlier run in concurrency group")
+				}
+			}
+			// Runs cancelled in this process have stopped, runs executing
+			// elsewhere stop on their next lease renewal
+			if cancelled {
+				continue
+			}
+		} else if !queued {
+			queued = true
+			logger.Info().
+				Str("group", run.ConcurrencyGroup).
+				Int("ahead", len(earlier)).
+				Msg("Run queued behind earlier runs in its concurrency group")
+		}
+
+		if err := o.waitInGroup(waitCtx, ticker, run); err != nil {
+			return err
+		}
+	}
+}
+
+// waitInGroup waits for the next check of a run's concurrency group. It
+// renews the run's lease and applies a cancellation or timeout of the run
+// that happened meanwhile.
+func (o *Orchestrator) waitInGroup(ctx context.Context, ticker *time.Ticker, run *workflow.WorkflowRun) error {
+	select {
+	case <-ctx.Done():
+		cause := context.Cause(ctx)
+		if errors.Is(cause, types.ErrRunCancelled) {
+			o.cancelRun(context.WithoutCancel(ctx), run, cancelReason(cause))
+			return cause
+		}
+		return fmt.Errorf("run queued in concurrency group %s: %w", run.ConcurrencyGroup, ctx.Err())
+	case <-ticker.C:
+	}
+
+	if err := o.workflowService.AcquireLease(ctx, run.ID, o.instanceID, o.leaseTTL); err != nil {
+		if errors.Is(err, types.ErrRunCancelled) {
+			o.cancelRun(ctx, run, cancelReason(err))
+			return err
+		}
+		return fmt.Errorf("failed to renew lease on run %s: %w", run.ID, err)
+	}
+
+	if reason, ok := run.Expired(time.Now()); ok {
+		o.timeOutRun(ctx, run, reason)
+		return fmt.Errorf("%w: %s", types.ErrRunTimedOut, reason)
+	}
+	return nil
+}
@@ -2,7 +2,13 @@ package orchestrator
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/felixgeelhaar/bolt"
@@ -10,6 +16,8 @@ import (
 	"github.com/felixgeelhaar/bridge/internal/domain/governance"
 	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/llm"
+	"github.com/felixgeelhaar/bridge/pkg/expression"
+	"github.com/felixgeelhaar/bridge/pkg/jsonschema"
 	"github.com/felixgeelhaar/bridge/pkg/types"
 )
 
@@ -18,39 +26,233 @@ type StepResult struct {
 	Output   map[string]any
 	Tokens   workflow.TokenUsage
 	Duration time.Duration
+	CacheKey string // Key the result is cached under, empty for steps without caching
+	Cached   bool   // Result was served from the step cache
 }
 
+// DefaultMaxToolIterations is the number of tool-use rounds an agent may
+// perform within a single step when no limit is configured.
+const DefaultMaxToolIterations = 10
+
 // Executor executes individual workflow steps.
 type Executor struct {
-	logger        *bolt.Logger
-	agentRunner   agents.Runner
-	agentRegistry *agents.AgentRegistry
-	auditService  *governance.AuditService
+	logger            *bolt.Logger
+	agentRunner       agents.Runner
+	agentRegistry     *agents.AgentRegistry
+	auditService      *governance.AuditService
+	policyEvaluator   governance.Evaluator
+	tools             *agents.ToolSet
+	actions           *workflow.ActionSet
+	maxToolIterations int
+	budget            *budgetTracker    // Budgets of the run, nil when not enforced
+	workflowService   *workflow.Service // Publishes policy violations, nil when not published
 }
 
-// NewExecutor creates a new step executor.
+// NewExecutor creates a new step executor. Tool calls requested by agents
+// are dispatched to tools, checked against policyEvaluator, for at most
+// maxToolIterations rounds per step. A nil tool set disables the tool loop.
+// Action steps run the built-in actions of the action set.
 func NewExecutor(
 	logger *bolt.Logger,
 	agentRunner agents.Runner,
 	agentRegistry *agents.AgentRegistry,
 	auditService *governance.AuditService,
+	policyEvaluator governance.Evaluator,
+	tools *agents.ToolSet,
+	actions *workflow.ActionSet,
+	maxToolIterations int,
 ) *Executor {
+	if maxToolIterations <= 0 {
+		maxToolIterations = DefaultMaxToolIterations
+	}
+
 	return &Executor{
-		logger:        logger,
-		agentRunner:   agentRunner,
-		agentRegistry: agentRegistry,
-		auditService:  auditService,
+		logger:            logger,
+		agentRunner:       agentRunner,
+		agentRegistry:     agentRegistry,
+		auditService:      auditService,
+		policyEvaluator:   policyEvaluator,
+		tools:             tools,
+		actions:           actions,
+		maxToolIterations: maxToolIterations,
+	}
+}
+
+// PrepareStep resolves the ${{ }} expressions in the step's input
+// definition against trigger data, inputs, metadata and earlier step outputs.
+// Fan-out items also resolve their item variables.
+func (e *Executor) PrepareStep(run *workflow.WorkflowRun, def *workflow.WorkflowDefinition, step *workflow.StepRun) (map[string]any, error) {
+	stepDef := def.GetStep(step.DefinitionName())
+	if stepDef == nil {
+		return nil, fmt.Errorf("%w: %s", types.ErrStepNotFound, step.Name)
+	}
+
+	scope := newScope(run, def)
+	for name, value := range step.Item {
+		scope[name] = value
+	}
+
+	input, err := expression.RenderMap(stepDef.Input, scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve input for step %s: %w", step.Name, err)
+	}
+
+	if input == nil {
+		input = make(map[string]any)
+	}
+
+	return input, nil
+}
+
+// ResolveOutputs evaluates the workflow outputs against the run's step
+// outputs, inputs and metadata.
+func (e *Executor) ResolveOutputs(run *workflow.WorkflowRun, def *workflow.WorkflowDefinition) (map[string]any, error) {
+	outputs, err := expression.RenderMap(def.Outputs, newScope(run, def))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workflow outputs: %w", err)
+	}
+	return outputs, nil
+}
+
+// ExpandItems evaluates the foreach list or matrix of a fan-out step into the
+// expression variables of each item: item and index for a foreach list,
+// matrix and index for a matrix, whose combinations are ordered by name.
+func (e *Executor) ExpandItems(run *workflow.WorkflowRun, def *workflow.WorkflowDefinition, step *workflow.StepRun) ([]map[string]any, error) {
+	stepDef := def.GetStep(step.Name)
+	if stepDef == nil {
+		return nil, fmt.Errorf("%w: %s", types.ErrStepNotFound, step.Name)
+	}
+
+	scope := newScope(run, def)
+
+	if stepDef.Foreach != nil {
+		list, err := renderList(stepDef.Foreach, scope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve foreach for step %s: %w", step.Name, err)
+		}
+		items := make([]map[string]any, len(list))
+		for i, value := range list {
+			items[i] = map[string]any{"item": value, "index": i}
+		}
+		return items, nil
+	}
+
+	names := make([]string, 0, len(stepDef.Matrix))
+	for name := range stepDef.Matrix {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	combinations := []map[string]any{{}}
+	for _, name := range names {
+		values, err := renderList(stepDef.Matrix[name], scope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve matrix %s for step %s: %w", name, step.Name, err)
+		}
+
+		next := make([]map[string]any, 0, len(combinations)*len(values))
+		for _, combination := range combinations {
+			for _, value := range values {
+				c := make(map[string]any, len(combination)+1)
+				for k, v := range combination {
+					c[k] = v
+				}
+				c[name] = value
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+
+	items := make([]map[string]any, len(combinations))
+	for i, combination := range combinations {
+		items[i] = map[string]any{"matrix": combination, "index": i}
+	}
+	return items, nil
+}
+
+// renderList resolves a list or an expression that evaluates to a list.
+func renderList(value any, scope expression.Scope) ([]any, error) {
+	rendered, err := expression.Render(value, scope)
+	if err != nil {
+		return nil, err
+	}
+	switch list := rendered.(type) {
+	case []any:
+		return list, nil
+	case []string:
+		out := make([]any, len(list))
+		for i, s := range list {
+			out[i] = s
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("expected a list, got %T", rendered)
+}
+
+// CheckStepPolicy evaluates the active policies against a step about to run,
+// with the capabilities of its agent or action and the resolved step input
+// as context. Action steps expose the action name as metadata.
+// Blocking violations are audited and returned as types.ErrPolicyViolation.
+func (e *Executor) CheckStepPolicy(ctx context.Context, run *workflow.WorkflowRun, def *workflow.WorkflowDefinition, step *workflow.StepRun, input map[string]any) (*governance.PolicyResult, error) {
+	if e.policyEvaluator == nil {
+		return &governance.PolicyResult{Allowed: true}, nil
+	}
+
+	policyInput := &governance.PolicyInput{
+		WorkflowID:   run.WorkflowID.String(),
+		WorkflowName: run.WorkflowName,
+		RunID:        run.ID.String(),
+		StepName:     step.Name,
+		AgentID:      step.AgentID,
+		Context:      input,
+		Budget:       e.budget.policyInput(step),
 	}
+
+	// An unknown agent or action is reported when the step executes
+	if name, ok := def.GetStep(step.DefinitionName()).Action(); ok {
+		if action, ok := e.actions.Get(name); ok {
+			policyInput.Capabilities = action.Capabilities
+		}
+		policyInput.Metadata = map[string]any{"action": name}
+	} else if agent, ok := e.agentRegistry.Get(step.AgentID); ok {
+		policyInput.AgentID = agent.ID.String()
+		policyInput.AgentName = agent.Name
+		policyInput.Capabilities = agent.Capabilities
+	}
+
+	result, err := e.policyEvaluator.EvaluateAll(ctx, policyInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate policy for step %s: %w", step.Name, err)
+	}
+
+	e.auditService.LogPolicyEvaluated(ctx, run.ID.String(), "step:"+step.Name, !result.IsBlocking())
+
+	if result.IsBlocking() {
+		for _, v := range result.Violations {
+			e.auditService.LogPolicyViolation(ctx, run.ID.String(), v.Rule, v.Message)
+		}
+		reportViolations(ctx, e.workflowService, run, step.Name, result.Violations)
+		return result, fmt.Errorf("%w: step %s: %s", types.ErrPolicyViolation, step.Name, formatViolations(result.Violations))
+	}
+
+	return result, nil
 }
 
-// ExecuteStep executes a single workflow step.
-func (e *Executor) ExecuteStep(ctx context.Context, run *workflow.WorkflowRun, step *workflow.StepRun) (*StepResult, error) {
+// ExecuteStep executes a single started workflow step with its resolved input.
+// Steps may execute concurrently, so only the run's identity is read.
+func (e *Executor) ExecuteStep(ctx context.Context, run *workflow.WorkflowRun, def *workflow.WorkflowDefinition, step *workflow.StepRun) (*StepResult, error) {
 	logger := e.logger.With().
 		Str("run_id", run.ID.String()).
 		Str("step_id", step.ID.String()).
 		Str("step_name", step.Name).
 		Logger()
 
+	stepDef := def.GetStep(step.DefinitionName())
+	if stepDef == nil {
+		return nil, fmt.Errorf("%w: %s", types.ErrStepNotFound, step.Name)
+	}
+
 	// Get agent
 	agent, ok := e.agentRegistry.Get(step.AgentID)
 	if !ok {
@@ -62,12 +264,6 @@ func (e *Executor) ExecuteStep(ctx context.Context, run *workflow.WorkflowRun, s
 		Str("model", agent.Model).
 		Msg("Executing step with agent")
 
-	// Build input from run context
-	input := e.buildStepInput(run, step)
-
-	// Start step
-	step.Start(input)
-
 	// Create timeout context
 	stepCtx := ctx
 	if step.Timeout > 0 {
@@ -76,69 +272,372 @@ func (e *Executor) ExecuteStep(ctx context.Context, run *workflow.WorkflowRun, s
 		defer cancel()
 	}
 
+	agent = e.configureAgent(agent, stepDef.OutputSchema)
+
 	// Build messages for agent
-	messages := e.buildMessages(run, step, input)
+	conv := &conversation{messages: e.buildMessages(run, step, step.Input, stepDef.OutputSchema)}
 
-	// Execute agent
-	response, err := e.agentRunner.Execute(stepCtx, agent, messages)
-	if err != nil {
-		return nil, err
+	var (
+		response *agents.AgentResponse
+		fields   map[string]any
+	)
+
+	for attempt := 1; ; attempt++ {
+		var err error
+		response, err = e.converse(stepCtx, logger, run, step, agent, conv)
+		if err != nil {
+			return nil, err
+		}
+
+		if stepDef.OutputSchema == nil {
+			break
+		}
+
+		// Validate structured output, asking the agent to correct mismatches
+		fields, err = parseOutput(response.Content, stepDef.OutputSchema)
+		if err == nil {
+			break
+		}
+		if attempt >= maxOutputAttempts {
+			return nil, fmt.Errorf("%w: %v", types.ErrStepOutputInvalid, err)
+		}
+
+		logger.Warn().
+			Int("attempt", attempt).
+			Err(err).
+			Msg("Step output does not match schema, retrying")
+
+		conv.messages = append(conv.messages, llm.Message{
+			Role: llm.RoleUser,
+			Content: fmt.Sprintf("Your response is invalid: %v\n\nRespond again with only a JSON object that matches the output schema.",
+				err),
+		})
 	}
 
-	// Log to audit
-	e.auditService.LogAgentCalled(
-		ctx,
-		run.ID.String(),
-		step.ID.String(),
-		agent.Name,
-		response.Model,
-		response.TokensIn,
-		response.TokensOut,
-	)
+	conv.tokens.Total = conv.tokens.Input + conv.tokens.Output
 
 	// Build output
-	output := e.buildOutput(response)
+	output := e.buildOutput(response, conv.tokens, conv.duration)
+	if e.tools != nil {
+		if len(conv.toolCalls) > 0 {
+			output["tool_calls"] = conv.toolCalls
+		}
+		output["transcript"] = buildTranscript(conv.messages)
+	}
+	for key, value := range fields {
+		output[key] = value
+	}
+
+	return &StepResult{
+		Output:   output,
+		Tokens:   conv.tokens,
+		Duration: conv.duration,
+	}, nil
+}
+
+// ExecuteAction executes a started action step by running its built-in action
+// with the resolved input. Output that does not match the step's output
+// schema fails the step.
+func (e *Executor) ExecuteAction(ctx context.Context, run *workflow.WorkflowRun, def *workflow.WorkflowDefinition, step *workflow.StepRun) (*StepResult, error) {
+	stepDef := def.GetStep(step.DefinitionName())
+	if stepDef == nil {
+		return nil, fmt.Errorf("%w: %s", types.ErrStepNotFound, step.Name)
+	}
+
+	name, _ := stepDef.Action()
+	action, ok := e.actions.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", types.ErrActionNotFound, name)
+	}
+
+	e.logger.Debug().
+		Str("run_id", run.ID.String()).
+		Str("step_name", step.Name).
+		Str("action", name).
+		Msg("Executing step with action")
+
+	// Create timeout context
+	stepCtx := ctx
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		stepCtx, cancel = context.WithTimeout(ctx, step.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	output, err := action.Handler(stepCtx, step.Input)
+	duration := time.Since(start)
+	if err != nil {
+		e.auditService.LogActionInvoked(ctx, run.ID.String(), step.ID.String(), name, err.Error())
+		return nil, fmt.Errorf("action %s: %w", name, err)
+	}
+	e.auditService.LogActionInvoked(ctx, run.ID.String(), step.ID.String(), name, "")
+
+	// Outputs are stored and exposed to expressions as JSON values
+	normalized, err := normalizeOutput(output)
+	if err != nil {
+		return nil, fmt.Errorf("action %s: %w", name, err)
+	}
+
+	if stepDef.OutputSchema != nil {
+		if err := jsonschema.Validate(stepDef.OutputSchema, normalized); err != nil {
+			return nil, fmt.Errorf("%w: %v", types.ErrStepOutputInvalid, err)
+		}
+	}
+	normalized["duration_ms"] = duration.Milliseconds()
 
 	return &StepResult{
-		Output: output,
-		Tokens: workflow.TokenUsage{
-			Input:  response.TokensIn,
-			Output: response.TokensOut,
-			Total:  response.TokensIn + response.TokensOut,
-		},
-		Duration: response.Duration,
+		Output:   normalized,
+		Duration: duration,
 	}, nil
 }
 
-func (e *Executor) buildStepInput(run *workflow.WorkflowRun, step *workflow.StepRun) map[string]any {
-	input := make(map[string]any)
+// normalizeOutput converts action output to its JSON form.
+func normalizeOutput(output map[string]any) (map[string]any, error) {
+	data, err := json.Marshal(output)
+	if err != nil {
+		return nil, fmt.Errorf("output is not JSON encodable: %w", err)
+	}
+	normalized := make(map[string]any)
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, fmt.Errorf("output is not a JSON object: %w", err)
+	}
+	return normalized, nil
+}
+
+// CacheKey returns the key a step's result is cached under: a hash of the
+// agent configuration and model, the rendered prompt and the tools offered,
+// which together determine the agent's answer.
+func (e *Executor) CacheKey(run *workflow.WorkflowRun, def *workflow.WorkflowDefinition, step *workflow.StepRun) (string, error) {
+	stepDef := def.GetStep(step.DefinitionName())
+	if stepDef == nil {
+		return "", fmt.Errorf("%w: %s", types.ErrStepNotFound, step.Name)
+	}
+	agent, ok := e.agentRegistry.Get(step.AgentID)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", types.ErrAgentNotFound, step.AgentID)
+	}
+	agent = e.configureAgent(agent, stepDef.OutputSchema)
+
+	data, err := json.Marshal(map[string]any{
+		"provider":        agent.Provider,
+		"model":           agent.Model,
+		"system_prompt":   agent.SystemPrompt,
+		"max_tokens":      agent.MaxTokens,
+		"temperature":     agent.Temperature,
+		"response_schema": agent.ResponseSchema,
+		"tools":           agent.Tools,
+		"tool_loop":       e.tools != nil,
+		"messages":        e.buildMessages(run, step, step.Input, stepDef.OutputSchema),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash step %s: %w", step.Name, err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// maxOutputAttempts is the number of times an agent may answer before a
+// response that does not match the step's output schema fails the step.
+const maxOutputAttempts = 3
+
+// conversation accumulates the messages and usage of a step's agent calls.
+type conversation struct {
+	messages  []llm.Message
+	tokens    workflow.TokenUsage
+	duration  time.Duration
+	toolCalls []map[string]any
+}
+
+// configureAgent returns the agent to use for a step. Agents that declare no
+// tools are offered the configured tool set, and steps with an output schema
+// request structured output from the provider.
+func (e *Executor) configureAgent(agent *agents.Agent, outputSchema map[string]any) *agents.Agent {
+	offerTools := len(agent.Tools) == 0 && e.tools != nil
+	if !offerTools && outputSchema == nil {
+		return agent
+	}
+
+	configured := *agent
+	if offerTools {
+		configured.Tools = e.tools.Tools()
+	}
+	if outputSchema != nil {
+		configured.ResponseSchema = outputSchema
+	}
+	return &configured
+}
+
+// converse calls the agent until it stops requesting tools, dispatching the
+// requested tool calls in between. It returns the agent's final response.
+// Each call is checked against the budgets of the run first.
+func (e *Executor) converse(ctx context.Context, logger *bolt.Logger, run *workflow.WorkflowRun, step *workflow.StepRun, agent *agents.Agent, conv *conversation) (*agents.AgentResponse, error) {
+	for iteration := 0; ; iteration++ {
+		if err := e.budget.check(step); err != nil {
+			return nil, err
+		}
+
+		// Execute agent
+		response, err := e.agentRunner.Execute(ctx, agent, conv.messages)
+		if err != nil {
+			return nil, err
+		}
+
+		// Log to audit
+		e.auditService.LogAgentCalled(
+			ctx,
+			run.ID.String(),
+			step.ID.String(),
+			agent.Name,
+			response.Model,
+			response.TokensIn,
+			response.TokensOut,
+		)
+
+		model := response.Model
+		if model == "" {
+			model = agent.Model
+		}
+		cost := llm.Cost(model, response.TokensIn, response.TokensOut)
+		e.budget.record(step, workflow.Usage{Tokens: response.TokensIn + response.TokensOut, CostUSD: cost})
+
+		conv.tokens.Input += response.TokensIn
+		conv.tokens.Output += response.TokensOut
+		conv.tokens.CostUSD += cost
+		conv.duration += response.Duration
+
+		conv.messages = append(conv.messages, llm.Message{
+			Role:      llm.RoleAssistant,
+			Content:   response.Content,
+			ToolCalls: response.ToolCalls,
+		})
+
+		if response.FinishReason != llm.FinishReasonToolUse || len(response.ToolCalls) == 0 || e.tools == nil {
+			return response, nil
+		}
+
+		if iteration >= e.maxToolIterations {
+			return nil, fmt.Errorf("%w: %d", types.ErrAgentToolLimit, e.maxToolIterations)
+		}
+
+		// Dispatch tool calls and hand the results back to the agent
+		for _, call := range response.ToolCalls {
+			result, record, err := e.callTool(ctx, run, step, agent, call)
+			if err != nil {
+				return nil, err
+			}
+			conv.toolCalls = append(conv.toolCalls, record)
+			conv.messages = append(conv.messages, llm.Message{
+				Role:       llm.RoleTool,
+				Content:    result,
+				Name:       call.Name,
+				ToolCallID: call.ID,
+			})
+		}
+
+		logger.Debug().
+			Int("iteration", iteration+1).
+			Int("tool_calls", len(response.ToolCalls)).
+			Msg("Tool calls dispatched")
+	}
+}
+
+// callTool checks a tool call against policy and executes it. It returns the
+// content reported back to the agent and a record of the call for the step
+// output. Denied or failing calls are reported to the agent rather than
+// failing the step; only policy evaluation errors are returned.
+func (e *Executor) callTool(ctx context.Context, run *workflow.WorkflowRun, step *workflow.StepRun, agent *agents.Agent, call llm.ToolCall) (string, map[string]any, error) {
+	record := map[string]any{
+		"id":        call.ID,
+		"name":      call.Name,
+		"arguments": call.Arguments,
+	}
 
-	// Copy step input
-	if step.Input != nil {
-		for k, v := range step.Input {
-			input[k] = v
+	if err := e.checkToolPolicy(ctx, run, step, agent, call); err != nil {
+		if !errors.Is(err, types.ErrMCPToolForbidden) {
+			return "", nil, err
 		}
+		e.auditService.LogToolInvoked(ctx, run.ID.String(), step.ID.String(), agent.Name, call.Name, false, err.Error())
+		record["denied"] = true
+		record["error"] = err.Error()
+		return "error: " + err.Error(), record, nil
 	}
 
-	// Add trigger data
-	if run.TriggerData != nil {
-		input["trigger"] = run.TriggerData
+	result, err := e.tools.Call(ctx, call)
+	if err != nil {
+		e.auditService.LogToolInvoked(ctx, run.ID.String(), step.ID.String(), agent.Name, call.Name, true, err.Error())
+		record["error"] = err.Error()
+		return "error: " + err.Error(), record, nil
 	}
 
-	// Add context from previous steps
-	if run.Context != nil {
-		input["context"] = run.Context
+	e.auditService.LogToolInvoked(ctx, run.ID.String(), step.ID.String(), agent.Name, call.Name, true, "")
+
+	content, ok := result.(string)
+	if !ok {
+		data, err := json.Marshal(result)
+		if err != nil {
+			content = fmt.Sprintf("%v", result)
+		} else {
+			content = string(data)
+		}
 	}
+	record["result"] = content
 
-	return input
+	return content, record, nil
 }
 
-func (e *Executor) buildMessages(run *workflow.WorkflowRun, step *workflow.StepRun, input map[string]any) []llm.Message {
+// checkToolPolicy evaluates the policies for a tool call. The tool arguments
+// are exposed as the policy context and the tool name as metadata. Calls that
+// violate a policy or would require approval are forbidden.
+func (e *Executor) checkToolPolicy(ctx context.Context, run *workflow.WorkflowRun, step *workflow.StepRun, agent *agents.Agent, call llm.ToolCall) error {
+	if e.policyEvaluator == nil {
+		return nil
+	}
+
+	input := &governance.PolicyInput{
+		WorkflowID:   run.WorkflowID.String(),
+		WorkflowName: run.WorkflowName,
+		RunID:        run.ID.String(),
+		StepName:     step.Name,
+		AgentID:      agent.ID.String(),
+		AgentName:    agent.Name,
+		Capabilities: agent.Capabilities,
+		Context:      call.Arguments,
+		Metadata:     map[string]any{"tool": call.Name},
+		Budget:       e.budget.policyInput(step),
+	}
+
+	result, err := e.policyEvaluator.EvaluateAll(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate policy for tool %s: %w", call.Name, err)
+	}
+
+	if result.IsBlocking() {
+		for _, v := range result.Violations {
+			e.auditService.LogPolicyViolation(ctx, run.ID.String(), v.Rule, v.Message)
+		}
+		reportViolations(ctx, e.workflowService, run, step.Name, result.Violations)
+		return fmt.Errorf("%w: %s: %s", types.ErrMCPToolForbidden, call.Name, formatViolations(result.Violations))
+	}
+
+	if result.RequiresApproval {
+		return fmt.Errorf("%w: %s requires approval", types.ErrMCPToolForbidden, call.Name)
+	}
+
+	return nil
+}
+
+func (e *Executor) buildMessages(run *workflow.WorkflowRun, step *workflow.StepRun, input map[string]any, outputSchema map[string]any) []llm.Message {
 	messages := make([]llm.Message, 0)
 
 	// Build user message with step context
 	userContent := fmt.Sprintf("Execute step: %s\n\nInput:\n%v", step.Name, formatInput(input))
 
+	if outputSchema != nil {
+		userContent += fmt.Sprintf("\n\nRespond with only a JSON object that matches this JSON Schema:\n%v", formatInput(outputSchema))
+	}
+
 	messages = append(messages, llm.Message{
 		Role:    llm.RoleUser,
 		Content: userContent,
@@ -147,12 +646,13 @@ func (e *Executor) buildMessages(run *workflow.WorkflowRun, step *workflow.StepR
 	return messages
 }
 
-func (e *Executor) buildOutput(response *agents.AgentResponse) map[string]any {
+func (e *Executor) buildOutput(response *agents.AgentResponse, tokens workflow.TokenUsage, duration time.Duration) map[string]any {
 	output := map[string]any{
 		"content":       response.Content,
-		"tokens_in":     response.TokensIn,
-		"tokens_out":    response.TokensOut,
-		"duration_ms":   response.Duration.Milliseconds(),
+		"tokens_in":     tokens.Input,
+		"tokens_out":    tokens.Output,
+		"cost_usd":      tokens.CostUSD,
+		"duration_ms":   duration.Milliseconds(),
 		"model":         response.Model,
 		"finish_reason": string(response.FinishReason),
 	}
@@ -172,16 +672,71 @@ func (e *Executor) buildOutput(response *agents.AgentResponse) map[string]any {
 	return output
 }
 
+// buildTranscript converts the conversation of a step into its output form.
+func buildTranscript(messages []llm.Message) []map[string]any {
+	transcript := make([]map[string]any, len(messages))
+	for i, msg := range messages {
+		entry := map[string]any{
+			"role":    string(msg.Role),
+			"content": msg.Content,
+		}
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]map[string]any, len(msg.ToolCalls))
+			for j, tc := range msg.ToolCalls {
+				calls[j] = map[string]any{
+					"id":        tc.ID,
+					"name":      tc.Name,
+					"arguments": tc.Arguments,
+				}
+			}
+			entry["tool_calls"] = calls
+		}
+		if msg.ToolCallID != "" {
+			entry["tool_call_id"] = msg.ToolCallID
+		}
+		transcript[i] = entry
+	}
+	return transcript
+}
+
+// parseOutput extracts the JSON object from an agent response and validates
+// it against the output schema.
+func parseOutput(content string, schema map[string]any) (map[string]any, error) {
+	content = strings.TrimSpace(content)
+
+	// Models frequently wrap JSON in a markdown code fence
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```")
+		if i := strings.IndexByte(content, '\n'); i >= 0 {
+			content = content[i+1:]
+		}
+		content = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(content), "```"))
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+
+	if err := jsonschema.Validate(schema, value); err != nil {
+		return nil, err
+	}
+
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("response is not a JSON object")
+	}
+	return fields, nil
+}
+
 func formatInput(input map[string]any) string {
 	if input == nil {
 		return "{}"
 	}
 
-	// Simple formatting for now
-	result := "{\n"
-	for k, v := range input {
-		result += fmt.Sprintf("  %s: %v\n", k, v)
+	data, err := json.MarshalIndent(input, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", input)
 	}
-	result += "}"
-	return result
+	return string(data)
 }
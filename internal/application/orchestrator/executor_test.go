@@ -0,0 +1,448 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/felixgeelhaar/bolt"
+	"github.com/felixgeelhaar/bridge/internal/domain/agents"
+	"github.com/felixgeelhaar/bridge/internal/domain/governance"
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/llm"
+	"github.com/felixgeelhaar/bridge/pkg/expression"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// mockRunner records the messages sent to agents and returns a fixed response.
+// Calls for which fail returns true return err.
+type mockRunner struct {
+	mu        sync.Mutex
+	messages  [][]llm.Message
+	content   string
+	model     string
+	err       error
+	fail      func(messages []llm.Message) bool
+	delay     time.Duration
+	active    int
+	maxActive int
+}
+
+func (m *mockRunner) Execute(ctx context.Context, agent *agents.Agent, messages []llm.Message) (*agents.AgentResponse, error) {
+	m.mu.Lock()
+	m.messages = append(m.messages, messages)
+	m.active++
+	if m.active > m.maxActive {
+		m.maxActive = m.active
+	}
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		m.active--
+		m.mu.Unlock()
+	}()
+
+	if m.delay > 0 {
+		select {
+		case <-time.After(m.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if m.err != nil && (m.fail == nil || m.fail(messages)) {
+		return nil, m.err
+	}
+	model := m.model
+	if model == "" {
+		model = "mock-model"
+	}
+	return &agents.AgentResponse{
+		Content:      m.content,
+		TokensIn:     10,
+		TokensOut:    5,
+		Model:        model,
+		FinishReason: llm.FinishReasonStop,
+	}, nil
+}
+
+// waitForCalls blocks until the runner has been called n times.
+func (m *mockRunner) waitForCalls(n int) {
+	for {
+		m.mu.Lock()
+		calls := len(m.messages)
+		m.mu.Unlock()
+		if calls >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func createTestExecutor(t *testing.T, runner agents.Runner) *Executor {
+	t.Helper()
+
+	handler := bolt.NewConsoleHandler(os.Stderr)
+	logger := bolt.New(handler).SetLevel(bolt.ERROR)
+
+	registry := agents.NewAgentRegistry()
+	registry.Register(&agents.Agent{
+		ID:       types.AgentID("reviewer"),
+		Name:     "reviewer",
+		Provider: "mock",
+		Model:    "mock-model",
+	})
+
+	auditService := governance.NewAuditService(governance.NewInMemoryAuditLogger())
+
+	return NewExecutor(logger, runner, registry, auditService, nil, nil, nil, 0)
+}
+
+func TestExecutor_ExecuteStep_ResolvesInput(t *testing.T) {
+	runner := &mockRunner{content: "looks good"}
+	executor := createTestExecutor(t, runner)
+
+	def := &workflow.WorkflowDefinition{ID: types.NewWorkflowID(), Name: "pr-review", Version: "1.0"}
+	def.Steps = []workflow.StepDefinition{
+		{Name: "fetch-changes", AgentID: "reviewer"},
+		{
+			Name:    "review",
+			AgentID: "reviewer",
+			Input: map[string]any{
+				"files": "${{ steps.fetch-changes.output.content }}",
+				"title": "PR #${{ trigger.pr.number }}",
+			},
+		},
+	}
+
+	run := workflow.NewWorkflowRun(def, "test", map[string]any{
+		"pr": map[string]any{"number": 42},
+	})
+	run.Steps[0].Start(nil)
+	run.Steps[0].Complete(map[string]any{"content": "main.go"}, 0, 0)
+
+	step := run.Steps[1]
+	input, err := executor.PrepareStep(run, def, step)
+	if err != nil {
+		t.Fatalf("PrepareStep() error = %v", err)
+	}
+	step.Start(input)
+
+	result, err := executor.ExecuteStep(context.Background(), run, def, step)
+	if err != nil {
+		t.Fatalf("ExecuteStep() error = %v", err)
+	}
+
+	if step.Input["files"] != "main.go" {
+		t.Errorf("Input[files] = %v, want main.go", step.Input["files"])
+	}
+	if step.Input["title"] != "PR #42" {
+		t.Errorf("Input[title] = %v, want PR #42", step.Input["title"])
+	}
+	if result.Output["content"] != "looks good" {
+		t.Errorf("Output[content] = %v, want looks good", result.Output["content"])
+	}
+
+	if len(runner.messages) != 1 {
+		t.Fatalf("expected 1 agent call, got %d", len(runner.messages))
+	}
+	if content := runner.messages[0][0].Content; !strings.Contains(content, "PR #42") {
+		t.Errorf("message should contain resolved input, got %q", content)
+	}
+}
+
+func TestExecutor_PrepareStep_UnresolvedReference(t *testing.T) {
+	executor := createTestExecutor(t, &mockRunner{})
+
+	def := &workflow.WorkflowDefinition{ID: types.NewWorkflowID(), Name: "pr-review", Version: "1.0"}
+	def.Steps = []workflow.StepDefinition{
+		{
+			Name:    "review",
+			AgentID: "reviewer",
+			Input:   map[string]any{"diff": "${{ steps.missing.output }}"},
+		},
+	}
+
+	run := workflow.NewWorkflowRun(def, "test", nil)
+
+	_, err := executor.PrepareStep(run, def, run.Steps[0])
+	if !errors.Is(err, expression.ErrUnresolvedReference) {
+		t.Fatalf("expected unresolved reference error, got %v", err)
+	}
+}
+
+// toolUseRunner requests the tool call until it has received a tool result
+// for it, then returns a final answer. With loop set it never stops calling
+// the tool.
+type toolUseRunner struct {
+	call  llm.ToolCall
+	loop  bool
+	calls int
+	tools []llm.Tool
+}
+
+func (r *toolUseRunner) Execute(ctx context.Context, agent *agents.Agent, messages []llm.Message) (*agents.AgentResponse, error) {
+	r.calls++
+	r.tools = agent.Tools
+
+	last := messages[len(messages)-1]
+	if r.loop || last.Role != llm.RoleTool {
+		return &agents.AgentResponse{
+			ToolCalls:    []llm.ToolCall{r.call},
+			TokensIn:     10,
+			TokensOut:    5,
+			Model:        "mock-model",
+			FinishReason: llm.FinishReasonToolUse,
+		}, nil
+	}
+
+	return &agents.AgentResponse{
+		Content:      "answer: " + last.Content,
+		TokensIn:     20,
+		TokensOut:    5,
+		Model:        "mock-model",
+		FinishReason: llm.FinishReasonStop,
+	}, nil
+}
+
+// denyToolEvaluator blocks calls of a single tool.
+type denyToolEvaluator struct {
+	tool string
+}
+
+func (e *denyToolEvaluator) Evaluate(ctx context.Context, bundle *governance.PolicyBundle, input *governance.PolicyInput) (*governance.PolicyResult, error) {
+	return e.EvaluateAll(ctx, input)
+}
+
+func (e *denyToolEvaluator) EvaluateAll(ctx context.Context, input *governance.PolicyInput) (*governance.PolicyResult, error) {
+	if input.Metadata["tool"] == e.tool {
+		return &governance.PolicyResult{
+			Allowed:    false,
+			Violations: []governance.Violation{{Rule: "tools", Message: "tool not allowed", Severity: governance.SeverityError}},
+		}, nil
+	}
+	return &governance.PolicyResult{Allowed: true}, nil
+}
+
+func createToolSet(invoked *int) *agents.ToolSet {
+	tools := agents.NewToolSet()
+	tools.Register(llm.Tool{Name: "lookup", Description: "Look up a value"}, func(ctx context.Context, args map[string]any) (any, error) {
+		*invoked++
+		return "value of " + args["key"].(string), nil
+	})
+	return tools
+}
+
+func startedStep(t *testing.T, outputSchema map[string]any) (*workflow.WorkflowDefinition, *workflow.WorkflowRun, *workflow.StepRun) {
+	t.Helper()
+
+	def := &workflow.WorkflowDefinition{ID: types.NewWorkflowID(), Name: "pr-review", Version: "1.0"}
+	def.Steps = []workflow.StepDefinition{{Name: "review", AgentID: "reviewer", OutputSchema: outputSchema}}
+
+	run := workflow.NewWorkflowRun(def, "test", nil)
+	step := run.Steps[0]
+	step.Start(map[string]any{})
+	return def, run, step
+}
+
+func TestExecutor_ExecuteStep_ToolLoop(t *testing.T) {
+	runner := &toolUseRunner{call: llm.ToolCall{ID: "call_1", Name: "lookup", Arguments: map[string]any{"key": "pr"}}}
+	executor := createTestExecutor(t, runner)
+
+	invoked := 0
+	executor.tools = createToolSet(&invoked)
+
+	def, run, step := startedStep(t, nil)
+	result, err := executor.ExecuteStep(context.Background(), run, def, step)
+	if err != nil {
+		t.Fatalf("ExecuteStep failed: %v", err)
+	}
+
+	if invoked != 1 {
+		t.Errorf("tool invoked %d times, want 1", invoked)
+	}
+	if runner.calls != 2 {
+		t.Errorf("agent called %d times, want 2", runner.calls)
+	}
+	if len(runner.tools) != 1 || runner.tools[0].Name != "lookup" {
+		t.Errorf("agent tools = %v, want [lookup]", runner.tools)
+	}
+	if got := result.Output["content"]; got != "answer: value of pr" {
+		t.Errorf("Output[content] = %v, want %v", got, "answer: value of pr")
+	}
+	if result.Tokens.Input != 30 || result.Tokens.Output != 10 || result.Tokens.Total != 40 {
+		t.Errorf("Tokens = %+v, want input 30, output 10, total 40", result.Tokens)
+	}
+
+	transcript, ok := result.Output["transcript"].([]map[string]any)
+	if !ok {
+		t.Fatalf("Output[transcript] = %T, want []map[string]any", result.Output["transcript"])
+	}
+	roles := make([]string, len(transcript))
+	for i, entry := range transcript {
+		roles[i] = entry["role"].(string)
+	}
+	if got, want := strings.Join(roles, ","), "user,assistant,tool,assistant"; got != want {
+		t.Errorf("transcript roles = %v, want %v", got, want)
+	}
+	if transcript[2]["tool_call_id"] != "call_1" || transcript[2]["content"] != "value of pr" {
+		t.Errorf("tool message = %v", transcript[2])
+	}
+
+	toolCalls, ok := result.Output["tool_calls"].([]map[string]any)
+	if !ok || len(toolCalls) != 1 {
+		t.Fatalf("Output[tool_calls] = %v, want one call", result.Output["tool_calls"])
+	}
+	if toolCalls[0]["result"] != "value of pr" {
+		t.Errorf("tool call result = %v, want %v", toolCalls[0]["result"], "value of pr")
+	}
+}
+
+func TestExecutor_ExecuteStep_ToolLimit(t *testing.T) {
+	runner := &toolUseRunner{call: llm.ToolCall{ID: "call_1", Name: "lookup", Arguments: map[string]any{"key": "pr"}}, loop: true}
+	executor := createTestExecutor(t, runner)
+
+	invoked := 0
+	executor.tools = createToolSet(&invoked)
+	executor.maxToolIterations = 2
+
+	def, run, step := startedStep(t, nil)
+	_, err := executor.ExecuteStep(context.Background(), run, def, step)
+	if !errors.Is(err, types.ErrAgentToolLimit) {
+		t.Fatalf("error = %v, want %v", err, types.ErrAgentToolLimit)
+	}
+	if invoked != 2 {
+		t.Errorf("tool invoked %d times, want 2", invoked)
+	}
+}
+
+func TestExecutor_ExecuteStep_ToolDeniedByPolicy(t *testing.T) {
+	runner := &toolUseRunner{call: llm.ToolCall{ID: "call_1", Name: "lookup", Arguments: map[string]any{"key": "pr"}}}
+	executor := createTestExecutor(t, runner)
+
+	invoked := 0
+	executor.tools = createToolSet(&invoked)
+	executor.policyEvaluator = &denyToolEvaluator{tool: "lookup"}
+
+	def, run, step := startedStep(t, nil)
+	result, err := executor.ExecuteStep(context.Background(), run, def, step)
+	if err != nil {
+		t.Fatalf("ExecuteStep failed: %v", err)
+	}
+
+	if invoked != 0 {
+		t.Errorf("tool invoked %d times, want 0", invoked)
+	}
+
+	toolCalls := result.Output["tool_calls"].([]map[string]any)
+	if toolCalls[0]["denied"] != true {
+		t.Errorf("tool call = %v, want denied", toolCalls[0])
+	}
+	if content := result.Output["content"].(string); !strings.Contains(content, "tool not allowed") {
+		t.Errorf("agent should receive the denial, got %q", content)
+	}
+}
+
+// scriptedRunner returns the scripted contents in order, repeating the last.
+type scriptedRunner struct {
+	contents []string
+	calls    int
+	agent    *agents.Agent
+	messages []llm.Message
+}
+
+func (r *scriptedRunner) Execute(ctx context.Context, agent *agents.Agent, messages []llm.Message) (*agents.AgentResponse, error) {
+	content := r.contents[min(r.calls, len(r.contents)-1)]
+	r.calls++
+	r.agent = agent
+	r.messages = messages
+
+	return &agents.AgentResponse{
+		Content:      content,
+		TokensIn:     10,
+		TokensOut:    5,
+		Model:        "mock-model",
+		FinishReason: llm.FinishReasonStop,
+	}, nil
+}
+
+func reviewOutputSchema() map[string]any {
+	return map[string]any{
+		"type":     "object",
+		"required": []any{"recommendation"},
+		"properties": map[string]any{
+			"recommendation": map[string]any{"type": "string", "enum": []any{"approve", "request_changes"}},
+		},
+	}
+}
+
+func TestExecutor_ExecuteStep_OutputSchema(t *testing.T) {
+	runner := &scriptedRunner{contents: []string{"```json\n{\"recommendation\": \"approve\"}\n```"}}
+	executor := createTestExecutor(t, runner)
+
+	def, run, step := startedStep(t, reviewOutputSchema())
+	result, err := executor.ExecuteStep(context.Background(), run, def, step)
+	if err != nil {
+		t.Fatalf("ExecuteStep() error = %v", err)
+	}
+
+	if got := result.Output["recommendation"]; got != "approve" {
+		t.Errorf("Output[recommendation] = %v, want approve", got)
+	}
+	if runner.agent.ResponseSchema == nil {
+		t.Error("expected the output schema to be requested from the provider")
+	}
+	if content := runner.messages[0].Content; !strings.Contains(content, `"recommendation"`) {
+		t.Errorf("prompt should contain the output schema, got %q", content)
+	}
+}
+
+func TestExecutor_ExecuteStep_OutputSchemaRetry(t *testing.T) {
+	runner := &scriptedRunner{contents: []string{
+		"The PR looks good.",
+		`{"recommendation": "merge"}`,
+		`{"recommendation": "request_changes"}`,
+	}}
+	executor := createTestExecutor(t, runner)
+
+	def, run, step := startedStep(t, reviewOutputSchema())
+	result, err := executor.ExecuteStep(context.Background(), run, def, step)
+	if err != nil {
+		t.Fatalf("ExecuteStep() error = %v", err)
+	}
+
+	if runner.calls != 3 {
+		t.Errorf("agent called %d times, want 3", runner.calls)
+	}
+	if got := result.Output["recommendation"]; got != "request_changes" {
+		t.Errorf("Output[recommendation] = %v, want request_changes", got)
+	}
+	if result.Tokens.Total != 45 {
+		t.Errorf("Tokens.Total = %d, want 45", result.Tokens.Total)
+	}
+
+	// The last retry reports the validation errors of the previous answer
+	feedback := runner.messages[len(runner.messages)-1]
+	if feedback.Role != llm.RoleUser || !strings.Contains(feedback.Content, "must be one of") {
+		t.Errorf("retry message = %+v, want validation errors", feedback)
+	}
+}
+
+func TestExecutor_ExecuteStep_OutputSchemaInvalid(t *testing.T) {
+	runner := &scriptedRunner{contents: []string{`{"verdict": "approve"}`}}
+	executor := createTestExecutor(t, runner)
+
+	def, run, step := startedStep(t, reviewOutputSchema())
+	_, err := executor.ExecuteStep(context.Background(), run, def, step)
+	if !errors.Is(err, types.ErrStepOutputInvalid) {
+		t.Fatalf("error = %v, want %v", err, types.ErrStepOutputInvalid)
+	}
+	if runner.calls != maxOutputAttempts {
+		t.Errorf("agent called %d times, want %d", runner.calls, maxOutputAttempts)
+	}
+}
@@ -0,0 +1,96 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/expression"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// ask renders the prompt of a human input step and leaves the step waiting
+// for an answer. Steps that do not depend on it keep running; the run
+// pauses once nothing else can run.
+func (s *scheduler) ask(ctx context.Context, step *workflow.StepRun, h *workflow.HumanInput) error {
+	prompt, err := expression.RenderString(h.Prompt, newScope(s.run, s.def))
+	if err != nil {
+		return fmt.Errorf("failed to render prompt for step %s: %w", step.Name, err)
+	}
+
+	step.AwaitInput(fmt.Sprint(prompt))
+	s.o.workflowService.UpdateStep(ctx, step)
+	return nil
+}
+
+// awaitInput pauses the run until the step is answered.
+func (s *scheduler) awaitInput(ctx context.Context, step *workflow.StepRun) error {
+	s.o.workflowService.RequestInput(ctx, s.run, step)
+	s.o.auditService.LogInputRequested(ctx, s.run.ID.String(), step.ID.String(), step.Name)
+
+	s.logger.Info().
+		Str("step", step.Name).
+		Msg("Workflow awaiting input")
+
+	return types.ErrInputRequired
+}
+
+// RespondToInput answers the human input step a run awaits with the fields
+// given by respondedBy, and resumes the run. An answer that does not match
+// the form of the step returns an error wrapping types.ErrAnswerInvalid and
+// leaves the run waiting.
+func (o *Orchestrator) RespondToInput(ctx context.Context, run *workflow.WorkflowRun, respondedBy string, fields map[string]any) error {
+	if run.Status != workflow.RunStatusAwaitingInput {
+		return fmt.Errorf("workflow is not awaiting input")
+	}
+
+	logger := o.logger.With().
+		Str("run_id", run.ID.String()).
+		Str("workflow", run.WorkflowName).
+		Logger()
+
+	// An answer given after the deadline does not resume the run
+	if reason, ok := run.Expired(time.Now()); ok {
+		o.timeOutRun(ctx, run, reason)
+		return fmt.Errorf("%w: %s", types.ErrRunTimedOut, reason)
+	}
+
+	def, err := o.workflowService.GetWorkflow(ctx, run.WorkflowID)
+	if err != nil {
+		return fmt.Errorf("failed to load workflow definition: %w", err)
+	}
+	step := run.GetStepByName(run.PendingStep)
+	stepDef := def.GetStep(run.PendingStep)
+	if step == nil || !step.IsAwaitingInput() || stepDef == nil || stepDef.HumanInput == nil {
+		return fmt.Errorf("step %q is not awaiting input", run.PendingStep)
+	}
+
+	answer, err := stepDef.HumanInput.Answer(fields)
+	if err != nil {
+		return fmt.Errorf("step %s: %w", step.Name, err)
+	}
+
+	if err := o.workflowService.ProvideInput(ctx, run, step, answer, respondedBy); err != nil {
+		return fmt.Errorf("failed to record answer: %w", err)
+	}
+	o.auditService.LogInputReceived(ctx, run.ID.String(), step.ID.String(), step.Name, respondedBy, answeredFields(answer))
+
+	logger.Info().
+		Str("step", step.Name).
+		Str("responded_by", respondedBy).
+		Msg("Resuming workflow after input")
+
+	return o.executeSteps(ctx, run, nil, logger)
+}
+
+// answeredFields returns the sorted names of the fields of an answer.
+func answeredFields(answer map[string]any) []string {
+	fields := make([]string, 0, len(answer))
+	for name := range answer {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
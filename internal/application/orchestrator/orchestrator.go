@@ -2,7 +2,11 @@ package orchestrator
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/felixgeelhaar/bolt"
 	"github.com/felixgeelhaar/bridge/internal/domain/agents"
@@ -10,18 +14,37 @@ import (
 	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/llm"
 	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/expression"
 	"github.com/felixgeelhaar/bridge/pkg/types"
 )
 
 // Orchestrator coordinates workflow execution.
 type Orchestrator struct {
-	logger          *bolt.Logger
-	workflowService *workflow.Service
-	policyEvaluator governance.Evaluator
-	auditService    *governance.AuditService
-	agentRunner     agents.Runner
-	agentRegistry   *agents.AgentRegistry
-	stateMachine    *workflow.RunStateMachine
+	logger            *bolt.Logger
+	workflowService   *workflow.Service
+	policyEvaluator   governance.Evaluator
+	auditService      *governance.AuditService
+	agentRunner       agents.Runner
+	agentRegistry     *agents.AgentRegistry
+	tools             *agents.ToolSet
+	actions           *workflow.ActionSet
+	maxToolIterations int
+	instanceID        string
+	leaseTTL          time.Duration
+	leaseRenewal      time.Duration // Interval of lease renewals, a third of the TTL
+	stateMachine      *workflow.RunStateMachine
+	stepCache         workflow.StepCache
+	noCache           bool
+	runBudget         *workflow.Budget
+
+	mu        sync.Mutex
+	executing map[types.RunID]*execution // Runs executing in this process
+}
+
+// execution tracks a run executing in this process.
+type execution struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{}
 }
 
 // Config contains orchestrator configuration.
@@ -33,6 +56,35 @@ type Config struct {
 	AuditLogger     governance.AuditLogger
 	LLMRegistry     *llm.Registry
 	AgentRegistry   *agents.AgentRegistry
+
+	// Tools executes tool calls requested by agents. When nil, tool calls
+	// are recorded in the step output but not executed.
+	Tools *agents.ToolSet
+	// MaxToolIterations caps the tool-use rounds per step
+	// (default DefaultMaxToolIterations).
+	MaxToolIterations int
+
+	// Actions holds the built-in actions steps can use. Steps using an
+	// action that is not registered fail.
+	Actions *workflow.ActionSet
+
+	// InstanceID identifies this process when leasing runs
+	// (default host name and process ID).
+	InstanceID string
+	// LeaseTTL is how long a run lease lasts without a heartbeat
+	// (default DefaultLeaseTTL).
+	LeaseTTL time.Duration
+
+	// StepCache stores the results of steps with a cache setting (default
+	// WorkflowRepo when it implements workflow.StepCache).
+	StepCache workflow.StepCache
+	// NoCache ignores cached step results. Results of cached steps are
+	// still stored for later runs.
+	NoCache bool
+
+	// RunBudget limits the tokens and cost of each run created, on top of
+	// the budgets of its workflow and steps. Nil sets no limit.
+	RunBudget *workflow.Budget
 }
 
 // New creates a new orchestrator.
@@ -42,25 +94,52 @@ func New(cfg Config) (*Orchestrator, error) {
 		return nil, fmt.Errorf("failed to create state machine: %w", err)
 	}
 
+	instanceID := cfg.InstanceID
+	if instanceID == "" {
+		instanceID = defaultInstanceID()
+	}
+	leaseTTL := cfg.LeaseTTL
+	if leaseTTL <= 0 {
+		leaseTTL = DefaultLeaseTTL
+	}
+	stepCache := cfg.StepCache
+	if repoCache, ok := cfg.WorkflowRepo.(workflow.StepCache); ok && stepCache == nil {
+		stepCache = repoCache
+	}
+
 	return &Orchestrator{
-		logger:          cfg.Logger,
-		workflowService: workflow.NewService(cfg.WorkflowRepo, cfg.EventPublisher),
-		policyEvaluator: cfg.PolicyEvaluator,
-		auditService:    governance.NewAuditService(cfg.AuditLogger),
-		agentRunner:     agents.NewRunner(cfg.Logger, cfg.LLMRegistry),
-		agentRegistry:   cfg.AgentRegistry,
-		stateMachine:    sm,
+		logger:            cfg.Logger,
+		workflowService:   workflow.NewService(cfg.WorkflowRepo, cfg.EventPublisher),
+		policyEvaluator:   cfg.PolicyEvaluator,
+		auditService:      governance.NewAuditService(cfg.AuditLogger),
+		agentRunner:       agents.NewRunner(cfg.Logger, cfg.LLMRegistry),
+		agentRegistry:     cfg.AgentRegistry,
+		tools:             cfg.Tools,
+		actions:           cfg.Actions,
+		maxToolIterations: cfg.MaxToolIterations,
+		instanceID:        instanceID,
+		leaseTTL:          leaseTTL,
+		leaseRenewal:      leaseTTL / 3,
+		stateMachine:      sm,
+		stepCache:         stepCache,
+		noCache:           cfg.NoCache,
+		runBudget:         cfg.RunBudget,
+		executing:         make(map[types.RunID]*execution),
 	}, nil
 }
 
-// CreateWorkflow creates a new workflow definition from config.
+// CreateWorkflow registers a workflow definition from config as a version
+// of its workflow. The version already stored is returned when the config
+// has not changed; a version changed since it was stored fails with
+// types.ErrWorkflowVersionConflict.
 func (o *Orchestrator) CreateWorkflow(ctx context.Context, cfg *config.WorkflowConfig) (*workflow.WorkflowDefinition, error) {
 	def, err := workflow.NewWorkflowDefinition(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := o.workflowService.CreateWorkflow(ctx, def); err != nil {
+	def, err = o.workflowService.CreateWorkflow(ctx, def)
+	if err != nil {
 		return nil, err
 	}
 
@@ -68,14 +147,26 @@ func (o *Orchestrator) CreateWorkflow(ctx context.Context, cfg *config.WorkflowC
 		Str("workflow_id", def.ID.String()).
 		Str("name", def.Name).
 		Str("version", def.Version).
-		Msg("Workflow created")
+		Str("checksum", def.Checksum).
+		Msg("Workflow registered")
 
 	return def, nil
 }
 
-// CreateRun creates a new workflow run.
+// ListWorkflowVersions returns the registered versions of a workflow, the
+// latest first.
+func (o *Orchestrator) ListWorkflowVersions(ctx context.Context, name string) ([]*workflow.WorkflowDefinition, error) {
+	return o.workflowService.ListWorkflowVersions(ctx, name)
+}
+
+// CreateRun creates a new workflow run. The trigger data is validated
+// against the inputs the workflow declares and stored as it is.
 func (o *Orchestrator) CreateRun(ctx context.Context, def *workflow.WorkflowDefinition, triggeredBy string, triggerData map[string]any) (*workflow.WorkflowRun, error) {
-	run, err := o.workflowService.StartRun(ctx, def, triggeredBy, triggerData)
+	if _, err := def.ResolveInputs(triggerData); err != nil {
+		return nil, err
+	}
+
+	run, err := o.workflowService.StartRun(ctx, def, triggeredBy, triggerData, o.runBudget)
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +182,36 @@ func (o *Orchestrator) CreateRun(ctx context.Context, def *workflow.WorkflowDefi
 	return run, nil
 }
 
+// CreateRerun creates a run that re-runs a finished run with the same
+// workflow definition, reusing the outputs of steps that are not run again.
+func (o *Orchestrator) CreateRerun(ctx context.Context, id types.RunID, triggeredBy string, opts workflow.RerunOptions) (*workflow.WorkflowRun, error) {
+	original, err := o.workflowService.GetRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	def, err := o.workflowService.GetWorkflow(ctx, original.WorkflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflow definition: %w", err)
+	}
+
+	run, err := o.workflowService.StartRerun(ctx, def, original, triggeredBy, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	o.auditService.LogWorkflowStarted(ctx, def.ID.String(), run.ID.String(), triggeredBy)
+
+	o.logger.Info().
+		Str("run_id", run.ID.String()).
+		Str("rerun_of", original.ID.String()).
+		Str("workflow", def.Name).
+		Str("triggered_by", triggeredBy).
+		Msg("Workflow rerun created")
+
+	return run, nil
+}
+
 // ExecuteWorkflow executes a workflow run.
 func (o *Orchestrator) ExecuteWorkflow(ctx context.Context, run *workflow.WorkflowRun) error {
 	logger := o.logger.With().
@@ -98,6 +219,27 @@ func (o *Orchestrator) ExecuteWorkflow(ctx context.Context, run *workflow.Workfl
 		Str("workflow", run.WorkflowName).
 		Logger()
 
+	// Runs in a concurrency group execute one at a time
+	def, err := o.workflowService.GetWorkflow(ctx, run.WorkflowID)
+	if err != nil {
+		return fmt.Errorf("failed to load workflow definition: %w", err)
+	}
+	if err := o.enterGroup(ctx, run, def, logger); err != nil {
+		return err
+	}
+
+	// Hold the lease while policies are checked, so that the run is
+	// recovered if this process dies before its steps execute
+	if err := o.workflowService.AcquireLease(ctx, run.ID, o.instanceID, o.leaseTTL); err != nil {
+		if errors.Is(err, types.ErrRunCancelled) {
+			defer o.workflowService.ReleaseLease(ctx, run.ID, o.instanceID)
+			o.cancelRun(ctx, run, cancelReason(err))
+			return err
+		}
+		return fmt.Errorf("failed to lease run %s: %w", run.ID, err)
+	}
+	defer o.workflowService.ReleaseLease(ctx, run.ID, o.instanceID)
+
 	logger.Info().Msg("Starting workflow execution")
 
 	// Initialize state machine
@@ -121,14 +263,12 @@ func (o *Orchestrator) ExecuteWorkflow(ctx context.Context, run *workflow.Workfl
 	// Policy check
 	policyResult, err := o.evaluatePolicy(ctx, run)
 	if err != nil {
-		run.Fail(fmt.Sprintf("policy evaluation failed: %v", err))
-		o.workflowService.UpdateRun(ctx, run)
+		o.workflowService.FailRun(ctx, run, fmt.Sprintf("policy evaluation failed: %v", err), "")
 		return err
 	}
 
 	if !policyResult.Allowed {
-		run.Fail("policy violation: " + formatViolations(policyResult.Violations))
-		o.workflowService.UpdateRun(ctx, run)
+		o.workflowService.FailRun(ctx, run, "policy violation: "+formatViolations(policyResult.Violations), "")
 		o.auditService.LogWorkflowFailed(ctx, run.WorkflowID.String(), run.ID.String(), run.Error)
 		return types.ErrPolicyViolation
 	}
@@ -143,8 +283,8 @@ func (o *Orchestrator) ExecuteWorkflow(ctx context.Context, run *workflow.Workfl
 		if err := o.stateMachine.Send(interp, workflow.EventApprovalRequired); err != nil {
 			return err
 		}
-		run.AwaitApproval()
-		o.workflowService.UpdateRun(ctx, run)
+		run.ExpireApprovalAfter(def.ApprovalTimeout)
+		o.workflowService.RequestApproval(ctx, run)
 		logger.Info().Msg("Workflow awaiting approval")
 		return types.ErrApprovalRequired
 	}
@@ -158,8 +298,8 @@ func (o *Orchestrator) ExecuteWorkflow(ctx context.Context, run *workflow.Workfl
 	return o.executeSteps(ctx, run, interp, logger)
 }
 
-// ResumeWorkflow resumes a workflow after approval.
-func (o *Orchestrator) ResumeWorkflow(ctx context.Context, run *workflow.WorkflowRun) error {
+// ResumeWorkflow resumes a workflow after it was approved by approvedBy.
+func (o *Orchestrator) ResumeWorkflow(ctx context.Context, run *workflow.WorkflowRun, approvedBy string) error {
 	if run.Status != workflow.RunStatusAwaitingApproval {
 		return fmt.Errorf("workflow is not awaiting approval")
 	}
@@ -169,6 +309,12 @@ func (o *Orchestrator) ResumeWorkflow(ctx context.Context, run *workflow.Workflo
 		Str("workflow", run.WorkflowName).
 		Logger()
 
+	// An approval given after a deadline does not resume the run
+	if reason, ok := run.Expired(time.Now()); ok {
+		o.timeOutRun(ctx, run, reason)
+		return fmt.Errorf("%w: %s", types.ErrRunTimedOut, reason)
+	}
+
 	logger.Info().Msg("Resuming workflow after approval")
 
 	// Initialize state machine at awaiting_approval state
@@ -183,70 +329,81 @@ func (o *Orchestrator) ResumeWorkflow(ctx context.Context, run *workflow.Workflo
 		return err
 	}
 
-	run.Approve()
-	o.workflowService.UpdateRun(ctx, run)
+	// Approve the step the run was paused for, if any
+	if run.PendingStep != "" {
+		logger.Info().Str("step", run.PendingStep).Msg("Step approved")
+	}
+	o.workflowService.ApproveRun(ctx, run, approvedBy)
 
 	return o.executeSteps(ctx, run, interp, logger)
 }
 
+// RejectWorkflow fails a workflow awaiting approval because rejectedBy
+// rejected it. Steps that had not run are cancelled.
+func (o *Orchestrator) RejectWorkflow(ctx context.Context, run *workflow.WorkflowRun, rejectedBy, comment string) error {
+	if run.Status != workflow.RunStatusAwaitingApproval {
+		return fmt.Errorf("workflow is not awaiting approval")
+	}
+
+	for _, step := range append(run.PendingSteps(), run.AwaitingApprovalSteps()...) {
+		o.workflowService.CancelStep(ctx, run, step, "not run: rejected by "+rejectedBy)
+	}
+	if err := o.workflowService.RejectRun(ctx, run, rejectedBy, comment); err != nil {
+		return err
+	}
+	o.auditService.LogWorkflowFailed(ctx, run.WorkflowID.String(), run.ID.String(), run.Error)
+
+	o.logger.Info().
+		Str("run_id", run.ID.String()).
+		Str("rejected_by", rejectedBy).
+		Msg("Workflow rejected")
+	return nil
+}
+
 func (o *Orchestrator) executeSteps(ctx context.Context, run *workflow.WorkflowRun, interp *workflow.Interpreter, logger *bolt.Logger) error {
-	executor := NewExecutor(o.logger, o.agentRunner, o.agentRegistry, o.auditService)
-
-	for run.HasMoreSteps() {
-		step := run.CurrentStep()
-
-		logger.Info().
-			Str("step", step.Name).
-			Int("index", step.StepIndex).
-			Msg("Executing step")
-
-		// Execute step
-		result, err := executor.ExecuteStep(ctx, run, step)
-		if err != nil {
-			step.Fail(err.Error())
-			o.workflowService.UpdateStep(ctx, step)
-
-			// Check if can retry
-			if step.CanRetry() {
-				step.IncrementRetry()
-				o.workflowService.UpdateStep(ctx, step)
-				logger.Warn().
-					Str("step", step.Name).
-					Int("retry", step.RetryCount).
-					Err(err).
-					Msg("Step failed, retrying")
-				continue
-			}
-
-			// No retry, fail workflow
-			run.Fail(fmt.Sprintf("step %s failed: %v", step.Name, err))
-			o.workflowService.UpdateRun(ctx, run)
-			o.auditService.LogWorkflowFailed(ctx, run.WorkflowID.String(), run.ID.String(), run.Error)
+	def, err := o.workflowService.GetWorkflow(ctx, run.WorkflowID)
+	if err != nil {
+		return fmt.Errorf("failed to load workflow definition: %w", err)
+	}
+
+	// Hold the lease on the run while its steps execute
+	if err := o.workflowService.AcquireLease(ctx, run.ID, o.instanceID, o.leaseTTL); err != nil {
+		if errors.Is(err, types.ErrRunCancelled) {
+			defer o.workflowService.ReleaseLease(ctx, run.ID, o.instanceID)
+			o.cancelRun(ctx, run, cancelReason(err))
 			return err
 		}
+		return fmt.Errorf("failed to lease run %s: %w", run.ID, err)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	defer o.track(run.ID, cancel)()
+	defer o.keepLease(ctx, run.ID, cancel, logger)()
+
+	// A run past its deadline is not executed any further
+	if reason, ok := run.Expired(time.Now()); ok {
+		o.timeOutRun(ctx, run, reason)
+		return fmt.Errorf("%w: %s", types.ErrRunTimedOut, reason)
+	}
+	ctx, cancelTimeout := withDeadline(ctx, run)
+	defer cancelTimeout()
 
-		// Step completed
-		step.Complete(result.Output, result.Tokens.Input, result.Tokens.Output)
-		o.workflowService.UpdateStep(ctx, step)
-
-		// Store output in context
-		run.SetContext(fmt.Sprintf("steps.%s.output", step.Name), result.Output)
-		o.workflowService.UpdateRun(ctx, run)
+	run.Execute()
+	o.workflowService.UpdateRun(ctx, run)
 
-		logger.Info().
-			Str("step", step.Name).
-			Dur("duration", result.Duration).
-			Int("tokens_in", result.Tokens.Input).
-			Int("tokens_out", result.Tokens.Output).
-			Msg("Step completed")
+	// The runs of a workflow share its budget
+	spent, err := o.workflowSpent(ctx, run, def)
+	if err != nil {
+		return err
+	}
 
-		// Advance to next step
-		run.AdvanceStep()
+	// Execute steps as a dependency graph
+	if err := newScheduler(o, run, def, spent, logger).execute(ctx); err != nil {
+		return err
 	}
 
 	// All steps completed
-	run.Complete()
-	o.workflowService.UpdateRun(ctx, run)
+	o.workflowService.CompleteRun(ctx, run)
 	o.auditService.LogWorkflowCompleted(ctx, run.WorkflowID.String(), run.ID.String(), run.Duration())
 
 	logger.Info().
@@ -256,6 +413,111 @@ func (o *Orchestrator) executeSteps(ctx context.Context, run *workflow.WorkflowR
 	return nil
 }
 
+// CancelRun cancels an active workflow run. A run executing in this process
+// is stopped and CancelRun returns once its in-flight steps have been
+// cancelled. A run executing in another process is asked to stop through
+// the shared store, and that process cancels it on its next lease renewal.
+// Any other active run is cancelled immediately.
+func (o *Orchestrator) CancelRun(ctx context.Context, id types.RunID, reason string) error {
+	o.mu.Lock()
+	exec, ok := o.executing[id]
+	o.mu.Unlock()
+	if ok {
+		exec.cancel(fmt.Errorf("%w: %s", types.ErrRunCancelled, reason))
+		select {
+		case <-exec.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	run, err := o.workflowService.GetRun(ctx, id)
+	if err != nil {
+		return err
+	}
+	if run.Status.IsTerminal() {
+		return fmt.Errorf("%w: run is %s", types.ErrRunCompleted, run.Status)
+	}
+
+	if err := o.workflowService.AcquireLease(ctx, id, o.instanceID, o.leaseTTL); err != nil {
+		if errors.Is(err, types.ErrRunLeased) {
+			o.logger.Info().
+				Str("run_id", id.String()).
+				Msg("Run is executing in another process, cancellation requested")
+			return o.workflowService.RequestCancel(ctx, id, reason)
+		}
+		if !errors.Is(err, types.ErrRunCancelled) {
+			return fmt.Errorf("failed to lease run %s: %w", id, err)
+		}
+	}
+	defer o.workflowService.ReleaseLease(ctx, id, o.instanceID)
+
+	o.cancelRun(ctx, run, reason)
+	return nil
+}
+
+// cancelRun records the cancellation of a run without steps in flight.
+// Steps that had not run are skipped.
+func (o *Orchestrator) cancelRun(ctx context.Context, run *workflow.WorkflowRun, reason string) {
+	// Steps left running by a process that died
+	for _, step := range run.RunningSteps() {
+		o.workflowService.CancelStep(ctx, run, step, "run cancelled: "+reason)
+	}
+	for _, step := range append(append(run.PendingSteps(), run.AwaitingApprovalSteps()...), run.AwaitingInputSteps()...) {
+		o.workflowService.SkipStep(ctx, run, step, "run cancelled: "+reason)
+	}
+
+	o.workflowService.CancelRun(ctx, run, reason)
+	o.auditService.LogWorkflowCancelled(ctx, run.WorkflowID.String(), run.ID.String(), reason)
+
+	o.logger.Info().
+		Str("run_id", run.ID.String()).
+		Str("reason", reason).
+		Msg("Workflow cancelled")
+}
+
+// track registers a run executing in this process until the returned
+// function is called.
+func (o *Orchestrator) track(id types.RunID, cancel context.CancelCauseFunc) func() {
+	exec := &execution{cancel: cancel, done: make(chan struct{})}
+
+	o.mu.Lock()
+	o.executing[id] = exec
+	o.mu.Unlock()
+
+	return func() {
+		o.mu.Lock()
+		delete(o.executing, id)
+		o.mu.Unlock()
+		close(exec.done)
+	}
+}
+
+// cancelReason returns the reason given for a run cancellation.
+func cancelReason(cause error) string {
+	return strings.TrimPrefix(cause.Error(), types.ErrRunCancelled.Error()+": ")
+}
+
+// evaluateCondition evaluates the step's condition against the run. It
+// returns false with the reason when the step should be skipped.
+func evaluateCondition(run *workflow.WorkflowRun, def *workflow.WorkflowDefinition, step *workflow.StepRun) (bool, string, error) {
+	stepDef := def.GetStep(step.Name)
+	if stepDef == nil || stepDef.Condition == "" {
+		return true, "", nil
+	}
+
+	ok, err := expression.EvaluateCondition(stepDef.Condition, newScope(run, def))
+	if err != nil {
+		return false, "", fmt.Errorf("failed to evaluate condition for step %s: %w", step.Name, err)
+	}
+	if !ok {
+		return false, fmt.Sprintf("condition %q evaluated to false", stepDef.Condition), nil
+	}
+
+	return true, "", nil
+}
+
 func (o *Orchestrator) evaluatePolicy(ctx context.Context, run *workflow.WorkflowRun) (*governance.PolicyResult, error) {
 	input := &governance.PolicyInput{
 		WorkflowID:   run.WorkflowID.String(),
@@ -274,10 +536,31 @@ func (o *Orchestrator) evaluatePolicy(ctx context.Context, run *workflow.Workflo
 	for _, v := range result.Violations {
 		o.auditService.LogPolicyViolation(ctx, run.ID.String(), v.Rule, v.Message)
 	}
+	reportViolations(ctx, o.workflowService, run, "", result.Violations)
 
 	return result, nil
 }
 
+// reportViolations publishes the violations of a run or step, one event
+// per violated policy rule.
+func reportViolations(ctx context.Context, svc *workflow.Service, run *workflow.WorkflowRun, stepName string, violations []governance.Violation) {
+	if svc == nil {
+		return
+	}
+
+	rules := make([]string, 0, len(violations))
+	messages := make(map[string][]string)
+	for _, v := range violations {
+		if _, ok := messages[v.Rule]; !ok {
+			rules = append(rules, v.Rule)
+		}
+		messages[v.Rule] = append(messages[v.Rule], v.Message)
+	}
+	for _, rule := range rules {
+		svc.ReportPolicyViolation(ctx, run, stepName, rule, messages[rule])
+	}
+}
+
 func formatViolations(violations []governance.Violation) string {
 	if len(violations) == 0 {
 		return "unknown violation"
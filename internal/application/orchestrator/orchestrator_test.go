@@ -2,8 +2,13 @@ package orchestrator
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/felixgeelhaar/bolt"
 	"github.com/felixgeelhaar/bridge/internal/domain/agents"
@@ -14,10 +19,16 @@ import (
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/persistence/memory"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/policy"
 	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
 )
 
 func createTestOrchestrator(t *testing.T) *Orchestrator {
 	t.Helper()
+	return createTestOrchestratorWithEvents(t, eventbus.New())
+}
+
+func createTestOrchestratorWithEvents(t *testing.T, eventPublisher workflow.EventPublisher) *Orchestrator {
+	t.Helper()
 
 	handler := bolt.NewConsoleHandler(os.Stderr)
 	logger := bolt.New(handler).SetLevel(bolt.ERROR)
@@ -25,7 +36,6 @@ func createTestOrchestrator(t *testing.T) *Orchestrator {
 	llmRegistry := llm.NewRegistry()
 	agentRegistry := agents.NewAgentRegistry()
 	workflowRepo := memory.NewWorkflowRepository()
-	eventPublisher := eventbus.New()
 	policyEngine := policy.NewEngine(logger)
 	auditLogger := governance.NewInMemoryAuditLogger()
 
@@ -113,6 +123,73 @@ func TestOrchestrator_CreateWorkflow(t *testing.T) {
 	}
 }
 
+func TestOrchestrator_CreateWorkflow_Versions(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	orch.agentRunner = &mockRunner{content: "ok"}
+	orch.agentRegistry.Register(&agents.Agent{ID: "test-agent", Name: "test-agent", Provider: "mock"})
+
+	newConfig := func(version string, steps ...string) *config.WorkflowConfig {
+		cfg := &config.WorkflowConfig{Name: "versioned", Version: version}
+		for _, name := range steps {
+			cfg.Steps = append(cfg.Steps, config.StepConfig{Name: name, Agent: "test-agent"})
+		}
+		return cfg
+	}
+
+	v1, err := orch.CreateWorkflow(ctx, newConfig("1.0", "review"))
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	// Registering the same content again returns the stored definition
+	again, err := orch.CreateWorkflow(ctx, newConfig("1.0", "review"))
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+	if again.ID != v1.ID {
+		t.Errorf("Workflow ID = %v, want %v", again.ID, v1.ID)
+	}
+
+	if _, err := orch.CreateWorkflow(ctx, newConfig("1.0", "review", "summarize")); !errors.Is(err, types.ErrWorkflowVersionConflict) {
+		t.Errorf("CreateWorkflow() error = %v, want %v", err, types.ErrWorkflowVersionConflict)
+	}
+
+	run, err := orch.CreateRun(ctx, v1, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	v2, err := orch.CreateWorkflow(ctx, newConfig("2.0", "review", "summarize"))
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+	if v2.Checksum == v1.Checksum {
+		t.Error("Checksums of different versions are equal")
+	}
+
+	// The run executes the version it was created from
+	if err := orch.ExecuteWorkflow(ctx, run); err != nil {
+		t.Fatalf("ExecuteWorkflow() error = %v", err)
+	}
+	if len(run.Steps) != 1 {
+		t.Errorf("Run steps = %d, want 1", len(run.Steps))
+	}
+
+	versions, err := orch.ListWorkflowVersions(ctx, "versioned")
+	if err != nil {
+		t.Fatalf("ListWorkflowVersions() error = %v", err)
+	}
+	got := make([]string, 0, len(versions))
+	for _, def := range versions {
+		got = append(got, def.Version)
+	}
+	if strings.Join(got, ",") != "2.0,1.0" {
+		t.Errorf("Versions = %v, want [2.0 1.0]", got)
+	}
+}
+
 func TestOrchestrator_CreateRun(t *testing.T) {
 	orch := createTestOrchestrator(t)
 	ctx := context.Background()
@@ -154,6 +231,57 @@ func TestOrchestrator_CreateRun(t *testing.T) {
 	}
 }
 
+func TestOrchestrator_CreateRun_Inputs(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	runner := &mockRunner{content: "ok"}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "typed-inputs",
+		Version: "1.0",
+		Inputs: map[string]config.InputConfig{
+			"pr":    {Type: config.InputNumber, Required: true},
+			"depth": {Type: config.InputString, Enum: []any{"quick", "full"}, Default: "quick"},
+		},
+		Steps: []config.StepConfig{
+			{Name: "review", Agent: "reviewer", Input: map[string]any{"pr": "${{ inputs.pr }}", "depth": "${{ inputs.depth }}"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	if _, err := orch.CreateRun(ctx, def, "cli", map[string]any{"pr": "abc"}); !errors.Is(err, types.ErrRunInputInvalid) {
+		t.Errorf("CreateRun() error = %v, want ErrRunInputInvalid", err)
+	}
+	if _, err := orch.CreateRun(ctx, def, "cli", nil); !errors.Is(err, types.ErrRunInputInvalid) {
+		t.Errorf("CreateRun() without required input error = %v, want ErrRunInputInvalid", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "cli", map[string]any{"pr": "42", "repo": "owner/repo"})
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+	if len(run.TriggerData) != 2 || run.TriggerData["pr"] != "42" {
+		t.Errorf("TriggerData = %v, want the values the run was created with", run.TriggerData)
+	}
+	inputs := newScope(run, def)["inputs"].(map[string]any)
+	if len(inputs) != 2 || inputs["pr"] != float64(42) || inputs["depth"] != "quick" {
+		t.Errorf("inputs = %v, want coerced pr and default depth only", inputs)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, run); err != nil {
+		t.Fatalf("ExecuteWorkflow() error = %v", err)
+	}
+	review := run.GetStepByName("review")
+	if review.Input["pr"] != float64(42) || review.Input["depth"] != "quick" {
+		t.Errorf("review Input = %v, want resolved inputs", review.Input)
+	}
+}
+
 func TestOrchestrator_GetRun(t *testing.T) {
 	orch := createTestOrchestrator(t)
 	ctx := context.Background()
@@ -239,3 +367,2201 @@ func TestConfig_Defaults(t *testing.T) {
 		t.Error("WorkflowRepo should not be nil")
 	}
 }
+
+func TestOrchestrator_ExecuteWorkflow_Condition(t *testing.T) {
+	tests := []struct {
+		name       string
+		condition  string
+		wantStatus workflow.StepStatus
+		wantCalls  int
+	}{
+		{"condition true", "steps.analyze.output.content == 'post'", workflow.StepStatusCompleted, 2},
+		{"condition false", "steps.analyze.output.content != 'post'", workflow.StepStatusSkipped, 1},
+		{"function call", "startsWith(trigger.ref, 'refs/tags/') && steps.analyze.status == 'completed'", workflow.StepStatusSkipped, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orch := createTestOrchestrator(t)
+			ctx := context.Background()
+
+			runner := &mockRunner{content: "post"}
+			orch.agentRunner = runner
+			orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+			def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+				Name:    "conditional",
+				Version: "1.0",
+				Steps: []config.StepConfig{
+					{Name: "analyze", Agent: "reviewer"},
+					{Name: "post-review", Agent: "reviewer", Condition: tt.condition},
+				},
+			})
+			if err != nil {
+				t.Fatalf("CreateWorkflow() error = %v", err)
+			}
+
+			run, err := orch.CreateRun(ctx, def, "test", map[string]any{"ref": "refs/heads/main"})
+			if err != nil {
+				t.Fatalf("CreateRun() error = %v", err)
+			}
+
+			if err := orch.ExecuteWorkflow(ctx, run); err != nil {
+				t.Fatalf("ExecuteWorkflow() error = %v", err)
+			}
+
+			if run.Status != workflow.RunStatusCompleted {
+				t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusCompleted)
+			}
+			step := run.GetStepByName("post-review")
+			if step.Status != tt.wantStatus {
+				t.Errorf("Step Status = %v, want %v", step.Status, tt.wantStatus)
+			}
+			if tt.wantStatus == workflow.StepStatusSkipped && step.Error == "" {
+				t.Error("skipped step should record the reason")
+			}
+			if len(runner.messages) != tt.wantCalls {
+				t.Errorf("agent calls = %d, want %d", len(runner.messages), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_InvalidCondition(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	orch.agentRunner = &mockRunner{content: "ok"}
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "conditional",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "post-review", Agent: "reviewer", Condition: "steps.missing.output.ok"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, run); err == nil {
+		t.Fatal("ExecuteWorkflow() expected error for unresolved condition")
+	}
+	if run.Status != workflow.RunStatusFailed {
+		t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusFailed)
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_Parallel(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxParallel int
+		wantMax     int
+	}{
+		{"unlimited", 0, 3},
+		{"limited", 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orch := createTestOrchestrator(t)
+			ctx := context.Background()
+
+			runner := &mockRunner{content: "ok", delay: 200 * time.Millisecond}
+			orch.agentRunner = runner
+			orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+			def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+				Name:        "parallel",
+				Version:     "1.0",
+				MaxParallel: tt.maxParallel,
+				Steps: []config.StepConfig{
+					{Name: "fetch", Agent: "reviewer"},
+					{Name: "analyze", Agent: "reviewer", Input: map[string]any{"diff": "${{ steps.fetch.output.content }}"}},
+					{Name: "security-scan", Agent: "reviewer", DependsOn: []string{"fetch"}},
+					{Name: "lint", Agent: "reviewer", DependsOn: []string{"fetch"}},
+					{Name: "review", Agent: "reviewer", DependsOn: []string{"analyze", "security-scan", "lint"}},
+				},
+			})
+			if err != nil {
+				t.Fatalf("CreateWorkflow() error = %v", err)
+			}
+
+			run, err := orch.CreateRun(ctx, def, "test", nil)
+			if err != nil {
+				t.Fatalf("CreateRun() error = %v", err)
+			}
+
+			if err := orch.ExecuteWorkflow(ctx, run); err != nil {
+				t.Fatalf("ExecuteWorkflow() error = %v", err)
+			}
+
+			if run.Status != workflow.RunStatusCompleted {
+				t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusCompleted)
+			}
+			if runner.maxActive != tt.wantMax {
+				t.Errorf("max concurrent steps = %d, want %d", runner.maxActive, tt.wantMax)
+			}
+
+			// The final step must start after all of its dependencies completed
+			review := run.GetStepByName("review")
+			for _, dep := range []string{"analyze", "security-scan", "lint"} {
+				step := run.GetStepByName(dep)
+				if step.CompletedAt.After(*review.StartedAt) {
+					t.Errorf("step %s completed after review started", dep)
+				}
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_FailureCancelsDownstream(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	runner := &mockRunner{
+		content: "ok",
+		delay:   20 * time.Millisecond,
+		err:     errors.New("scanner unavailable"),
+		fail: func(messages []llm.Message) bool {
+			return strings.Contains(messages[0].Content, "security-scan")
+		},
+	}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "failing",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "fetch", Agent: "reviewer"},
+			{Name: "security-scan", Agent: "reviewer", DependsOn: []string{"fetch"}},
+			{Name: "analyze", Agent: "reviewer", DependsOn: []string{"fetch"}},
+			{Name: "review", Agent: "reviewer", DependsOn: []string{"analyze", "security-scan"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, run); err == nil {
+		t.Fatal("ExecuteWorkflow() expected error")
+	}
+
+	if run.Status != workflow.RunStatusFailed {
+		t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusFailed)
+	}
+	if got := run.GetStepByName("security-scan").Status; got != workflow.StepStatusFailed {
+		t.Errorf("security-scan Status = %v, want %v", got, workflow.StepStatusFailed)
+	}
+	if got := run.GetStepByName("review").Status; got != workflow.StepStatusCancelled {
+		t.Errorf("review Status = %v, want %v", got, workflow.StepStatusCancelled)
+	}
+	if len(run.RunningSteps()) != 0 {
+		t.Errorf("no steps should be running after failure, got %d", len(run.RunningSteps()))
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_StepApproval(t *testing.T) {
+	tests := []struct {
+		name            string
+		timing          string
+		wantCallsPaused int
+		wantOutput      bool
+	}{
+		{"before", "", 1, false},
+		{"after", "after", 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orch := createTestOrchestrator(t)
+			ctx := context.Background()
+
+			runner := &mockRunner{content: "looks good"}
+			orch.agentRunner = runner
+			orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+			def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+				Name:    "approval",
+				Version: "1.0",
+				Steps: []config.StepConfig{
+					{Name: "analyze", Agent: "reviewer"},
+					{Name: "review", Agent: "reviewer", DependsOn: []string{"analyze"}, RequiresApproval: true, ApprovalTiming: tt.timing},
+					{Name: "post", Agent: "reviewer", Input: map[string]any{"review": "${{ steps.review.output.content }}"}},
+				},
+			})
+			if err != nil {
+				t.Fatalf("CreateWorkflow() error = %v", err)
+			}
+
+			run, err := orch.CreateRun(ctx, def, "test", nil)
+			if err != nil {
+				t.Fatalf("CreateRun() error = %v", err)
+			}
+
+			if err := orch.ExecuteWorkflow(ctx, run); !errors.Is(err, types.ErrApprovalRequired) {
+				t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, types.ErrApprovalRequired)
+			}
+
+			if run.Status != workflow.RunStatusAwaitingApproval {
+				t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusAwaitingApproval)
+			}
+			if run.PendingStep != "review" {
+				t.Errorf("Run PendingStep = %q, want %q", run.PendingStep, "review")
+			}
+			if len(runner.messages) != tt.wantCallsPaused {
+				t.Errorf("agent calls while paused = %d, want %d", len(runner.messages), tt.wantCallsPaused)
+			}
+
+			review := run.GetStepByName("review")
+			if review.Status != workflow.StepStatusAwaitingApproval {
+				t.Errorf("review Status = %v, want %v", review.Status, workflow.StepStatusAwaitingApproval)
+			}
+			if got := review.Output != nil; got != tt.wantOutput {
+				t.Errorf("review has output = %v, want %v", got, tt.wantOutput)
+			}
+			if post := run.GetStepByName("post"); post.Status != workflow.StepStatusPending {
+				t.Errorf("post Status = %v, want %v", post.Status, workflow.StepStatusPending)
+			}
+
+			// Resume from the persisted run
+			stored, err := orch.GetRun(ctx, run.ID)
+			if err != nil {
+				t.Fatalf("GetRun() error = %v", err)
+			}
+			if err := orch.ResumeWorkflow(ctx, stored, "tester"); err != nil {
+				t.Fatalf("ResumeWorkflow() error = %v", err)
+			}
+
+			if stored.Status != workflow.RunStatusCompleted {
+				t.Errorf("Run Status = %v, want %v", stored.Status, workflow.RunStatusCompleted)
+			}
+			if stored.PendingStep != "" {
+				t.Errorf("Run PendingStep = %q, want empty", stored.PendingStep)
+			}
+			if len(runner.messages) != 3 {
+				t.Errorf("agent calls = %d, want 3", len(runner.messages))
+			}
+			if !stored.GetStepByName("review").IsApproved() {
+				t.Error("expected review step to be approved")
+			}
+			if got := stored.GetStepByName("analyze").Output["content"]; got != "looks good" {
+				t.Errorf("analyze output = %v, want %v", got, "looks good")
+			}
+
+			// The post step sees the approved review
+			last := runner.messages[len(runner.messages)-1]
+			if !strings.Contains(last[len(last)-1].Content, "looks good") {
+				t.Errorf("post input = %q, want review output", last[len(last)-1].Content)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_HumanInput(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	runner := &mockRunner{content: "looks good"}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "human-input",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "analyze", Agent: "reviewer"},
+			{Name: "ask", Type: config.StepTypeHumanInput, Prompt: "Post the review: ${{ steps.analyze.output.content }}?", Form: map[string]config.InputConfig{
+				"post":    {Type: config.InputBool, Required: true},
+				"comment": {Default: ""},
+			}},
+			{Name: "post", Agent: "reviewer", Condition: "steps.ask.output.post", Input: map[string]any{"comment": "${{ steps.ask.output.comment }}"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, run); !errors.Is(err, types.ErrInputRequired) {
+		t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, types.ErrInputRequired)
+	}
+
+	if run.Status != workflow.RunStatusAwaitingInput {
+		t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusAwaitingInput)
+	}
+	if run.PendingStep != "ask" {
+		t.Errorf("Run PendingStep = %q, want %q", run.PendingStep, "ask")
+	}
+	ask := run.GetStepByName("ask")
+	if ask.Status != workflow.StepStatusAwaitingInput {
+		t.Errorf("ask Status = %v, want %v", ask.Status, workflow.StepStatusAwaitingInput)
+	}
+	if got := ask.Input["prompt"]; got != "Post the review: looks good?" {
+		t.Errorf("ask prompt = %v, want rendered prompt", got)
+	}
+	if len(runner.messages) != 1 {
+		t.Errorf("agent calls while paused = %d, want 1", len(runner.messages))
+	}
+
+	// Answer from the persisted run
+	stored, err := orch.GetRun(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+
+	// An invalid answer leaves the run waiting
+	err = orch.RespondToInput(ctx, stored, "tester", map[string]any{"comment": "ship it"})
+	if !errors.Is(err, types.ErrAnswerInvalid) {
+		t.Fatalf("RespondToInput() error = %v, want %v", err, types.ErrAnswerInvalid)
+	}
+	if stored.Status != workflow.RunStatusAwaitingInput {
+		t.Errorf("Run Status = %v, want %v", stored.Status, workflow.RunStatusAwaitingInput)
+	}
+
+	if err := orch.RespondToInput(ctx, stored, "tester", map[string]any{"post": "true", "comment": "ship it"}); err != nil {
+		t.Fatalf("RespondToInput() error = %v", err)
+	}
+
+	if stored.Status != workflow.RunStatusCompleted {
+		t.Errorf("Run Status = %v, want %v", stored.Status, workflow.RunStatusCompleted)
+	}
+	if stored.PendingStep != "" {
+		t.Errorf("Run PendingStep = %q, want empty", stored.PendingStep)
+	}
+	want := map[string]any{"post": true, "comment": "ship it"}
+	if got := stored.GetStepByName("ask").Output; !reflect.DeepEqual(got, want) {
+		t.Errorf("ask output = %v, want %v", got, want)
+	}
+	if len(runner.messages) != 2 {
+		t.Fatalf("agent calls = %d, want 2", len(runner.messages))
+	}
+
+	// The post step sees the answer
+	last := runner.messages[len(runner.messages)-1]
+	if !strings.Contains(last[len(last)-1].Content, "ship it") {
+		t.Errorf("post input = %q, want answer", last[len(last)-1].Content)
+	}
+
+	if err := orch.RespondToInput(ctx, stored, "tester", want); err == nil {
+		t.Error("RespondToInput() on a completed run succeeded, want error")
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_StepPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities []string
+		input        map[string]any
+		wantErr      error
+		wantStatus   workflow.StepStatus
+		wantWarnings int
+	}{
+		{"allowed", nil, map[string]any{"path": "src/main.go"}, nil, workflow.StepStatusCompleted, 0},
+		{"blocked by input", nil, map[string]any{"path": "config/.env"}, types.ErrPolicyViolation, workflow.StepStatusFailed, 0},
+		{"approval for capability", []string{"file-write"}, nil, types.ErrApprovalRequired, workflow.StepStatusAwaitingApproval, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orch := createTestOrchestrator(t)
+			ctx := context.Background()
+
+			warnings := governance.NewPolicyBundle("warnings", "1.0", "Step warnings")
+			warnings.AddRule(governance.PolicyRule{
+				Name:     "file-write-warning",
+				Enabled:  true,
+				Severity: governance.SeverityWarning,
+				Rego: `
+package bridge.policy
+
+warning contains msg if {
+    input.capabilities[_] == "file-write"
+    msg := sprintf("step %s writes files", [input.step_name])
+}
+`,
+			})
+			orch.policyEvaluator.(*policy.Engine).LoadBundle(warnings)
+
+			runner := &mockRunner{content: "ok"}
+			orch.agentRunner = runner
+			orch.agentRegistry.Register(&agents.Agent{ID: "fixer", Name: "fixer", Provider: "mock", Capabilities: tt.capabilities})
+
+			def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+				Name:    "policy",
+				Version: "1.0",
+				Steps: []config.StepConfig{
+					{Name: "apply-fix", Agent: "fixer", Input: tt.input},
+				},
+			})
+			if err != nil {
+				t.Fatalf("CreateWorkflow() error = %v", err)
+			}
+
+			run, err := orch.CreateRun(ctx, def, "test", nil)
+			if err != nil {
+				t.Fatalf("CreateRun() error = %v", err)
+			}
+
+			err = orch.ExecuteWorkflow(ctx, run)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("ExecuteWorkflow() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, tt.wantErr)
+			}
+
+			step := run.GetStepByName("apply-fix")
+			if step.Status != tt.wantStatus {
+				t.Errorf("step Status = %v, want %v", step.Status, tt.wantStatus)
+			}
+			if len(step.Warnings) != tt.wantWarnings {
+				t.Errorf("step Warnings = %v, want %d", step.Warnings, tt.wantWarnings)
+			}
+			if tt.wantStatus != workflow.StepStatusCompleted && len(runner.messages) != 0 {
+				t.Errorf("agent calls = %d, want 0", len(runner.messages))
+			}
+		})
+	}
+}
+
+func TestOrchestrator_RecoverRuns(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	runner := &mockRunner{content: "ok"}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "recovery",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "fetch", Agent: "reviewer"},
+			{Name: "analyze", Agent: "reviewer", DependsOn: []string{"fetch"}},
+			{Name: "post", Agent: "reviewer", DependsOn: []string{"analyze"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	// Simulate runs whose process stopped while analyze was in flight
+	interrupted := func(owner string, ttl time.Duration) *workflow.WorkflowRun {
+		run, err := orch.CreateRun(ctx, def, "test", nil)
+		if err != nil {
+			t.Fatalf("CreateRun() error = %v", err)
+		}
+		run.Start()
+		run.Execute()
+		fetch := run.GetStepByName("fetch")
+		fetch.Start(nil)
+		fetch.Complete(map[string]any{"content": "diff"}, 1, 1)
+		run.SetContext("steps.fetch.output", fetch.Output)
+		run.GetStepByName("analyze").Start(nil)
+		if err := orch.workflowService.AcquireLease(ctx, run.ID, owner, ttl); err != nil {
+			t.Fatalf("AcquireLease() error = %v", err)
+		}
+		return run
+	}
+	orphaned := interrupted("crashed-process", -time.Second)
+	leased := interrupted("live-process", time.Minute)
+
+	recovered, err := orch.RecoverRuns(ctx)
+	if err != nil {
+		t.Fatalf("RecoverRuns() error = %v", err)
+	}
+
+	if len(recovered) != 1 || recovered[0].ID != orphaned.ID {
+		t.Fatalf("RecoverRuns() = %d runs, want only the orphaned run", len(recovered))
+	}
+	if orphaned.Status != workflow.RunStatusCompleted {
+		t.Errorf("orphaned run Status = %v, want %v", orphaned.Status, workflow.RunStatusCompleted)
+	}
+	if orphaned.LeaseOwner != "" {
+		t.Errorf("orphaned run LeaseOwner = %q, want released", orphaned.LeaseOwner)
+	}
+	if got := orphaned.GetStepByName("fetch").Output["content"]; got != "diff" {
+		t.Errorf("fetch output = %v, want %v", got, "diff")
+	}
+
+	// Only the interrupted step and its dependents run again
+	if len(runner.messages) != 2 {
+		t.Errorf("agent calls = %d, want 2", len(runner.messages))
+	}
+
+	if leased.Status != workflow.RunStatusExecuting {
+		t.Errorf("leased run Status = %v, want %v", leased.Status, workflow.RunStatusExecuting)
+	}
+	if leased.GetStepByName("analyze").Status != workflow.StepStatusRunning {
+		t.Error("expected the step of the leased run to be left alone")
+	}
+}
+
+func TestOrchestrator_RecoverRuns_BeforeSteps(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	runner := &mockRunner{content: "ok"}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "recovery",
+		Version: "1.0",
+		Steps:   []config.StepConfig{{Name: "analyze", Agent: "reviewer"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	// Simulate runs whose process stopped before their steps executed
+	interrupted := func(status workflow.RunStatus) *workflow.WorkflowRun {
+		run, err := orch.CreateRun(ctx, def, "test", nil)
+		if err != nil {
+			t.Fatalf("CreateRun() error = %v", err)
+		}
+		run.Start()
+		run.Status = status
+		if err := orch.workflowService.AcquireLease(ctx, run.ID, "crashed-process", -time.Second); err != nil {
+			t.Fatalf("AcquireLease() error = %v", err)
+		}
+		return run
+	}
+	checking := interrupted(workflow.RunStatusPolicyCheck)
+	waiting := interrupted(workflow.RunStatusAwaitingApproval)
+
+	// A run created but never started holds no lease
+	unstarted, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	recovered, err := orch.RecoverRuns(ctx)
+	if err != nil {
+		t.Fatalf("RecoverRuns() error = %v", err)
+	}
+
+	if len(recovered) != 1 || recovered[0].ID != checking.ID {
+		t.Fatalf("RecoverRuns() = %d runs, want only the run checking policies", len(recovered))
+	}
+	if checking.Status != workflow.RunStatusCompleted {
+		t.Errorf("checking run Status = %v, want %v", checking.Status, workflow.RunStatusCompleted)
+	}
+	if waiting.Status != workflow.RunStatusAwaitingApproval {
+		t.Errorf("waiting run Status = %v, want %v", waiting.Status, workflow.RunStatusAwaitingApproval)
+	}
+	if unstarted.Status != workflow.RunStatusPending {
+		t.Errorf("unstarted run Status = %v, want %v", unstarted.Status, workflow.RunStatusPending)
+	}
+	if len(runner.messages) != 1 {
+		t.Errorf("agent calls = %d, want 1", len(runner.messages))
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_LeaseLost(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	orch.leaseRenewal = 5 * time.Millisecond
+	ctx := context.Background()
+
+	// The step only finishes when execution stops
+	runner := &mockRunner{content: "ok", delay: time.Hour}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "lease",
+		Version: "1.0",
+		Steps:   []config.StepConfig{{Name: "analyze", Agent: "reviewer"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	// Another process takes over the run while the step executes
+	go func() {
+		runner.waitForCalls(1)
+		orch.workflowService.ReleaseLease(ctx, run.ID, orch.instanceID)
+		orch.workflowService.AcquireLease(ctx, run.ID, "other-process", time.Minute)
+	}()
+
+	if err := orch.ExecuteWorkflow(ctx, run); !errors.Is(err, types.ErrRunLeased) {
+		t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, types.ErrRunLeased)
+	}
+	if run.Status != workflow.RunStatusExecuting {
+		t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusExecuting)
+	}
+}
+
+func TestOrchestrator_CancelRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		instance string
+	}{
+		{"in process", ""},
+		{"other process", "other-process"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The lease outlives the test, so the other process can only
+			// learn about the run through the store, and the cancellation is
+			// picked up at the next renewal
+			orch := createTestOrchestrator(t)
+			orch.leaseRenewal = 5 * time.Millisecond
+			ctx := context.Background()
+
+			bus := eventbus.New()
+			var cancelled []workflow.Event
+			bus.Subscribe("run.cancelled", func(ctx context.Context, event workflow.Event) error {
+				cancelled = append(cancelled, event)
+				return nil
+			})
+			orch.workflowService = workflow.NewService(memory.NewWorkflowRepository(), bus)
+
+			// The step only finishes when it is cancelled
+			runner := &mockRunner{content: "ok", delay: time.Hour}
+			orch.agentRunner = runner
+			orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+			def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+				Name:    "cancel",
+				Version: "1.0",
+				Steps: []config.StepConfig{
+					{Name: "analyze", Agent: "reviewer"},
+					{Name: "post", Agent: "reviewer", DependsOn: []string{"analyze"}},
+				},
+			})
+			if err != nil {
+				t.Fatalf("CreateWorkflow() error = %v", err)
+			}
+
+			run, err := orch.CreateRun(ctx, def, "test", nil)
+			if err != nil {
+				t.Fatalf("CreateRun() error = %v", err)
+			}
+
+			// A second process shares the store but not the execution
+			canceller := orch
+			if tt.instance != "" {
+				canceller = &Orchestrator{
+					logger:          orch.logger,
+					workflowService: orch.workflowService,
+					auditService:    orch.auditService,
+					instanceID:      tt.instance,
+					leaseTTL:        orch.leaseTTL,
+					leaseRenewal:    orch.leaseRenewal,
+					executing:       make(map[types.RunID]*execution),
+				}
+			}
+
+			cancelErr := make(chan error, 1)
+			go func() {
+				runner.waitForCalls(1)
+				cancelErr <- canceller.CancelRun(ctx, run.ID, "superseded")
+			}()
+
+			if err := orch.ExecuteWorkflow(ctx, run); !errors.Is(err, types.ErrRunCancelled) {
+				t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, types.ErrRunCancelled)
+			}
+			if err := <-cancelErr; err != nil {
+				t.Fatalf("CancelRun() error = %v", err)
+			}
+
+			if run.Status != workflow.RunStatusCancelled {
+				t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusCancelled)
+			}
+			if run.Error != "cancelled: superseded" {
+				t.Errorf("Run Error = %q, want %q", run.Error, "cancelled: superseded")
+			}
+			if got := run.GetStepByName("analyze").Status; got != workflow.StepStatusCancelled {
+				t.Errorf("analyze Status = %v, want %v", got, workflow.StepStatusCancelled)
+			}
+			if got := run.GetStepByName("post").Status; got != workflow.StepStatusSkipped {
+				t.Errorf("post Status = %v, want %v", got, workflow.StepStatusSkipped)
+			}
+			if len(cancelled) != 1 {
+				t.Errorf("run.cancelled events = %d, want 1", len(cancelled))
+			}
+			if err := orch.workflowService.AcquireLease(ctx, run.ID, "next-process", time.Minute); err != nil {
+				t.Errorf("AcquireLease() after cancellation error = %v, want the lease released", err)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_CancelRun_AwaitingApproval(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	orch.agentRunner = &mockRunner{content: "ok"}
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "cancel-paused",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "analyze", Agent: "reviewer"},
+			{Name: "review", Agent: "reviewer", DependsOn: []string{"analyze"}, RequiresApproval: true},
+			{Name: "post", Agent: "reviewer", DependsOn: []string{"review"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, run); !errors.Is(err, types.ErrApprovalRequired) {
+		t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, types.ErrApprovalRequired)
+	}
+
+	if err := orch.CancelRun(ctx, run.ID, "not needed"); err != nil {
+		t.Fatalf("CancelRun() error = %v", err)
+	}
+
+	if run.Status != workflow.RunStatusCancelled {
+		t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusCancelled)
+	}
+	want := map[string]workflow.StepStatus{
+		"analyze": workflow.StepStatusCompleted,
+		"review":  workflow.StepStatusSkipped,
+		"post":    workflow.StepStatusSkipped,
+	}
+	for name, status := range want {
+		if got := run.GetStepByName(name).Status; got != status {
+			t.Errorf("%s Status = %v, want %v", name, got, status)
+		}
+	}
+
+	// Cancelling a finished run is an error
+	if err := orch.CancelRun(ctx, run.ID, "again"); !errors.Is(err, types.ErrRunCompleted) {
+		t.Errorf("CancelRun() error = %v, want %v", err, types.ErrRunCompleted)
+	}
+}
+
+func TestOrchestrator_CreateRerun(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	runner := &mockRunner{
+		content: "ok",
+		err:     errors.New("github unavailable"),
+		fail: func(messages []llm.Message) bool {
+			return strings.Contains(messages[0].Content, "post-review")
+		},
+	}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "rerun",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "analyze", Agent: "reviewer"},
+			{Name: "review", Agent: "reviewer", Input: map[string]any{"analysis": "${{ steps.analyze.output.content }}"}},
+			{Name: "post-review", Agent: "reviewer", Input: map[string]any{"review": "${{ steps.review.output.content }}"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	original, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+	if err := orch.ExecuteWorkflow(ctx, original); err == nil {
+		t.Fatal("ExecuteWorkflow() expected error")
+	}
+
+	// The transient failure is gone on the second attempt
+	runner.err = nil
+	runner.messages = nil
+
+	run, err := orch.CreateRerun(ctx, original.ID, "test", workflow.RerunOptions{OnlyFailed: true})
+	if err != nil {
+		t.Fatalf("CreateRerun() error = %v", err)
+	}
+	if err := orch.ExecuteWorkflow(ctx, run); err != nil {
+		t.Fatalf("ExecuteWorkflow() error = %v", err)
+	}
+
+	if run.Status != workflow.RunStatusCompleted {
+		t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusCompleted)
+	}
+	if run.RerunOf != original.ID {
+		t.Errorf("Run RerunOf = %v, want %v", run.RerunOf, original.ID)
+	}
+	if len(runner.messages) != 1 {
+		t.Errorf("agent calls = %d, want 1", len(runner.messages))
+	}
+	if got := run.GetStepByName("post-review").Input["review"]; got != "ok" {
+		t.Errorf("post-review input = %v, want the reused review output", got)
+	}
+
+	// Only finished runs can be re-run
+	active, _ := orch.CreateRun(ctx, def, "test", nil)
+	if _, err := orch.CreateRerun(ctx, active.ID, "test", workflow.RerunOptions{}); err == nil {
+		t.Error("CreateRerun() of an active run expected error")
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_Foreach(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	runner := &mockRunner{content: "ok", delay: 100 * time.Millisecond}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "foreach",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{
+				Name:        "review",
+				Agent:       "reviewer",
+				Foreach:     "${{ trigger.files }}",
+				MaxParallel: 2,
+				Input:       map[string]any{"file": "${{ item }}", "position": "${{ index }}"},
+			},
+			{Name: "summarize", Agent: "reviewer", Input: map[string]any{"reviews": "${{ steps.review.output.items }}"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", map[string]any{"files": []any{"a.go", "b.go", "c.go"}})
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, run); err != nil {
+		t.Fatalf("ExecuteWorkflow() error = %v", err)
+	}
+
+	if run.Status != workflow.RunStatusCompleted {
+		t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusCompleted)
+	}
+	if runner.maxActive != 2 {
+		t.Errorf("max concurrent items = %d, want 2", runner.maxActive)
+	}
+
+	children := run.ChildSteps("review")
+	if len(children) != 3 {
+		t.Fatalf("ChildSteps count = %d, want 3", len(children))
+	}
+	for i, file := range []string{"a.go", "b.go", "c.go"} {
+		child := children[i]
+		if child.Name != fmt.Sprintf("review[%d]", i) {
+			t.Errorf("child %d Name = %v, want review[%d]", i, child.Name, i)
+		}
+		if child.Item["item"] != file {
+			t.Errorf("child %d item = %v, want %v", i, child.Item["item"], file)
+		}
+		if child.Status != workflow.StepStatusCompleted {
+			t.Errorf("child %d Status = %v, want %v", i, child.Status, workflow.StepStatusCompleted)
+		}
+	}
+
+	review := run.GetStepByName("review")
+	if review.Status != workflow.StepStatusCompleted {
+		t.Errorf("review Status = %v, want %v", review.Status, workflow.StepStatusCompleted)
+	}
+	items, ok := review.Output["items"].([]any)
+	if !ok || len(items) != 3 {
+		t.Fatalf("review output items = %v, want 3 items", review.Output["items"])
+	}
+	if review.TokensIn != 30 {
+		t.Errorf("review TokensIn = %d, want 30", review.TokensIn)
+	}
+
+	// Every item is sent to the agent, and the summary receives all outputs
+	var summary string
+	for _, messages := range runner.messages {
+		if strings.Contains(messages[0].Content, "Execute step: summarize") {
+			summary = messages[0].Content
+		}
+	}
+	for _, file := range []string{"a.go", "b.go", "c.go"} {
+		found := false
+		for _, messages := range runner.messages {
+			if strings.Contains(messages[0].Content, file) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("no agent call for item %s", file)
+		}
+	}
+	if strings.Count(summary, "content") != 3 {
+		t.Errorf("summarize input = %q, want 3 item outputs", summary)
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_Matrix(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	runner := &mockRunner{content: "ok"}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "matrix",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{
+				Name:   "test",
+				Agent:  "reviewer",
+				Matrix: map[string]any{"os": []any{"linux", "darwin"}, "go": []any{"1.22", "1.23"}},
+				Input:  map[string]any{"target": "${{ matrix.os }}/${{ matrix.go }}"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, run); err != nil {
+		t.Fatalf("ExecuteWorkflow() error = %v", err)
+	}
+
+	want := []string{"linux/1.22", "darwin/1.22", "linux/1.23", "darwin/1.23"}
+	children := run.ChildSteps("test")
+	if len(children) != len(want) {
+		t.Fatalf("ChildSteps count = %d, want %d", len(children), len(want))
+	}
+	for i, target := range want {
+		found := false
+		for _, messages := range runner.messages {
+			if strings.Contains(messages[0].Content, "Execute step: "+children[i].Name) &&
+				strings.Contains(messages[0].Content, target) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("child %s was not run for %s", children[i].Name, target)
+		}
+	}
+	if items, _ := run.GetStepByName("test").Output["items"].([]any); len(items) != len(want) {
+		t.Errorf("test output items = %d, want %d", len(items), len(want))
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_ForeachItemFails(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	runner := &mockRunner{
+		content: "ok",
+		err:     errors.New("file too large"),
+		fail: func(messages []llm.Message) bool {
+			return strings.Contains(messages[0].Content, "b.go")
+		},
+	}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "foreach-failing",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "review", Agent: "reviewer", Foreach: []any{"a.go", "b.go", "c.go"}, MaxParallel: 1, Input: map[string]any{"file": "${{ item }}"}},
+			{Name: "summarize", Agent: "reviewer", DependsOn: []string{"review"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, run); err == nil {
+		t.Fatal("ExecuteWorkflow() expected error")
+	}
+
+	if run.Status != workflow.RunStatusFailed {
+		t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusFailed)
+	}
+	wantStatus := map[string]workflow.StepStatus{
+		"review":    workflow.StepStatusFailed,
+		"review[0]": workflow.StepStatusCompleted,
+		"review[1]": workflow.StepStatusFailed,
+		"review[2]": workflow.StepStatusCancelled,
+		"summarize": workflow.StepStatusCancelled,
+	}
+	for name, want := range wantStatus {
+		if got := run.GetStepByName(name).Status; got != want {
+			t.Errorf("%s Status = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_SubWorkflow(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	runner := &mockRunner{content: "ok"}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	if _, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "security-scan",
+		Version: "1.2",
+		Steps: []config.StepConfig{
+			{Name: "scan", Agent: "reviewer", Input: map[string]any{"diff": "${{ inputs.diff }}"}},
+		},
+	}); err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "review",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "fetch", Agent: "reviewer"},
+			{Name: "security", Uses: "workflow://security-scan@1.2", Input: map[string]any{"diff": "${{ steps.fetch.output.content }}"}},
+			{Name: "report", Agent: "reviewer", Input: map[string]any{"findings": "${{ steps.security.output.steps.scan.content }}"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, run); err != nil {
+		t.Fatalf("ExecuteWorkflow() error = %v", err)
+	}
+
+	if run.Status != workflow.RunStatusCompleted {
+		t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusCompleted)
+	}
+
+	step := run.GetStepByName("security")
+	if step.ChildRunID == "" {
+		t.Fatal("security ChildRunID is empty")
+	}
+	child, err := orch.GetRun(ctx, step.ChildRunID)
+	if err != nil {
+		t.Fatalf("GetRun(child) error = %v", err)
+	}
+	if child.ParentRunID != run.ID || child.ParentStep != "security" {
+		t.Errorf("child parent = %v/%v, want %v/security", child.ParentRunID, child.ParentStep, run.ID)
+	}
+	if child.Status != workflow.RunStatusCompleted {
+		t.Errorf("child Status = %v, want %v", child.Status, workflow.RunStatusCompleted)
+	}
+	if got := child.GetStepByName("scan").Input["diff"]; got != "ok" {
+		t.Errorf("child scan input diff = %v, want ok", got)
+	}
+	if got := step.Output["run_id"]; got != child.ID.String() {
+		t.Errorf("security output run_id = %v, want %v", got, child.ID)
+	}
+	if got := run.GetStepByName("report").Input["findings"]; got != "ok" {
+		t.Errorf("report input findings = %v, want ok", got)
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_Outputs(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	orch.agentRunner = &mockRunner{content: "ok"}
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	if _, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "security-scan",
+		Version: "1.0",
+		Steps:   []config.StepConfig{{Name: "scan", Agent: "reviewer"}},
+		Outputs: map[string]any{"findings": "${{ steps.scan.output.content }}"},
+	}); err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "review",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "security", Uses: "workflow://security-scan"},
+			{Name: "report", Agent: "reviewer", DependsOn: []string{"security"}},
+		},
+		Outputs: map[string]any{
+			"findings": "${{ steps.security.output.outputs.findings }}",
+			"report":   map[string]any{"content": "${{ steps.report.output.content }}", "status": "${{ steps.report.status }}"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+	if err := orch.ExecuteWorkflow(ctx, run); err != nil {
+		t.Fatalf("ExecuteWorkflow() error = %v", err)
+	}
+
+	want := map[string]any{
+		"findings": "ok",
+		"report":   map[string]any{"content": "ok", "status": "completed"},
+	}
+	if !reflect.DeepEqual(run.Outputs, want) {
+		t.Errorf("Run Outputs = %v, want %v", run.Outputs, want)
+	}
+	if got := workflow.NewRunCompletedEvent(run).Outputs; !reflect.DeepEqual(got, want) {
+		t.Errorf("RunCompletedEvent Outputs = %v, want %v", got, want)
+	}
+
+	// An output that cannot be evaluated fails the run
+	def, err = orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "broken-outputs",
+		Version: "1.0",
+		Steps:   []config.StepConfig{{Name: "scan", Agent: "reviewer"}},
+		Outputs: map[string]any{"missing": "${{ steps.unknown.output.content }}"},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+	run, err = orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+	if err := orch.ExecuteWorkflow(ctx, run); err == nil {
+		t.Fatal("ExecuteWorkflow() expected error")
+	}
+	if run.Status != workflow.RunStatusFailed || !strings.Contains(run.Error, "workflow outputs") {
+		t.Errorf("Run = %v (%v), want failed on outputs", run.Status, run.Error)
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_SubWorkflowErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		uses    string
+		wantErr string
+	}{
+		{"unknown workflow", "workflow://missing", "workflow not found"},
+		{"unknown version", "workflow://security-scan@2.0", "found version 1.2"},
+		{"cycle", "workflow://review", "would run itself"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orch := createTestOrchestrator(t)
+			ctx := context.Background()
+
+			orch.agentRunner = &mockRunner{content: "ok"}
+			orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+			if _, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+				Name:    "security-scan",
+				Version: "1.2",
+				Steps:   []config.StepConfig{{Name: "scan", Agent: "reviewer"}},
+			}); err != nil {
+				t.Fatalf("CreateWorkflow() error = %v", err)
+			}
+
+			def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+				Name:    "review",
+				Version: "1.0",
+				Steps:   []config.StepConfig{{Name: "security", Uses: tt.uses}},
+			})
+			if err != nil {
+				t.Fatalf("CreateWorkflow() error = %v", err)
+			}
+
+			run, err := orch.CreateRun(ctx, def, "test", nil)
+			if err != nil {
+				t.Fatalf("CreateRun() error = %v", err)
+			}
+
+			err = orch.ExecuteWorkflow(ctx, run)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ExecuteWorkflow() error = %v, want %q", err, tt.wantErr)
+			}
+			if run.Status != workflow.RunStatusFailed {
+				t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusFailed)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_FailureHandlers(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	runner := &mockRunner{
+		content: "ok",
+		err:     errors.New("reviewer unavailable"),
+		fail: func(messages []llm.Message) bool {
+			return strings.Contains(messages[0].Content, "Execute step: review\n") ||
+				strings.Contains(messages[0].Content, "Execute step: notify\n")
+		},
+	}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "compensating",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{
+				Name:    "label",
+				Agent:   "reviewer",
+				Finally: []config.StepConfig{{Name: "log-label", Agent: "reviewer"}},
+			},
+			{
+				Name:      "review",
+				Agent:     "reviewer",
+				DependsOn: []string{"label"},
+				OnFailure: []config.StepConfig{{
+					Name:  "remove-labels",
+					Agent: "reviewer",
+					Input: map[string]any{"step": "${{ failure.step }}", "error": "${{ failure.error }}"},
+				}},
+			},
+		},
+		OnFailure: []config.StepConfig{
+			{Name: "notify", Agent: "reviewer", Input: map[string]any{"failed": "${{ failure.step }}"}},
+		},
+		Finally: []config.StepConfig{
+			{Name: "cleanup", Agent: "reviewer"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, run); err == nil {
+		t.Fatal("ExecuteWorkflow() expected error")
+	}
+
+	if run.Status != workflow.RunStatusFailed {
+		t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusFailed)
+	}
+	// A failing handler does not change why the run failed
+	if !strings.HasPrefix(run.Error, "step review failed") {
+		t.Errorf("Run Error = %v, want review failure", run.Error)
+	}
+
+	tests := []struct {
+		name      string
+		status    workflow.StepStatus
+		handler   workflow.HandlerKind
+		handlerOf string
+	}{
+		{"log-label", workflow.StepStatusCompleted, workflow.HandlerFinally, "label"},
+		{"remove-labels", workflow.StepStatusCompleted, workflow.HandlerOnFailure, "review"},
+		{"notify", workflow.StepStatusFailed, workflow.HandlerOnFailure, ""},
+		{"cleanup", workflow.StepStatusCompleted, workflow.HandlerFinally, ""},
+	}
+	for _, tt := range tests {
+		step := run.GetStepByName(tt.name)
+		if step == nil {
+			t.Errorf("handler %s did not run", tt.name)
+			continue
+		}
+		if step.Status != tt.status {
+			t.Errorf("%s Status = %v, want %v", tt.name, step.Status, tt.status)
+		}
+		if step.Handler != tt.handler || step.HandlerOf != tt.handlerOf {
+			t.Errorf("%s handler = %v of %q, want %v of %q", tt.name, step.Handler, step.HandlerOf, tt.handler, tt.handlerOf)
+		}
+	}
+
+	removeLabels := run.GetStepByName("remove-labels")
+	if got := removeLabels.Input["step"]; got != "review" {
+		t.Errorf("remove-labels input step = %v, want review", got)
+	}
+	if got, _ := removeLabels.Input["error"].(string); !strings.Contains(got, "reviewer unavailable") {
+		t.Errorf("remove-labels input error = %v, want reviewer unavailable", got)
+	}
+	if got := run.GetStepByName("notify").Input["failed"]; got != "review" {
+		t.Errorf("notify input failed = %v, want review", got)
+	}
+
+	// Step handlers run before the handlers of the run
+	order := []string{"remove-labels", "notify", "cleanup"}
+	for i := 1; i < len(order); i++ {
+		prev, next := run.GetStepByName(order[i-1]), run.GetStepByName(order[i])
+		if next.StartedAt.Before(*prev.StartedAt) {
+			t.Errorf("%s started before %s", order[i], order[i-1])
+		}
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_Finally(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	orch.agentRunner = &mockRunner{content: "ok"}
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:      "finally",
+		Version:   "1.0",
+		Steps:     []config.StepConfig{{Name: "review", Agent: "reviewer"}},
+		OnFailure: []config.StepConfig{{Name: "notify", Agent: "reviewer"}},
+		Finally: []config.StepConfig{
+			{Name: "cleanup", Agent: "reviewer", Input: map[string]any{"error": "${{ failure.error }}"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, run); err != nil {
+		t.Fatalf("ExecuteWorkflow() error = %v", err)
+	}
+
+	if run.Status != workflow.RunStatusCompleted {
+		t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusCompleted)
+	}
+	if run.GetStepByName("notify") != nil {
+		t.Error("on_failure handler ran for a successful run")
+	}
+	cleanup := run.GetStepByName("cleanup")
+	if cleanup == nil || cleanup.Status != workflow.StepStatusCompleted {
+		t.Fatalf("cleanup = %+v, want completed", cleanup)
+	}
+	if got := cleanup.Input["error"]; got != "" {
+		t.Errorf("cleanup input error = %v, want empty", got)
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_Timeout(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	auditLogger := governance.NewInMemoryAuditLogger()
+	orch.auditService = governance.NewAuditService(auditLogger)
+
+	orch.agentRunner = &mockRunner{content: "ok", delay: 200 * time.Millisecond}
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "timeout",
+		Version: "1.0",
+		Timeout: "50ms",
+		Steps: []config.StepConfig{
+			{Name: "analyze", Agent: "reviewer"},
+			{Name: "post", Agent: "reviewer", DependsOn: []string{"analyze"}},
+		},
+		Finally: []config.StepConfig{{Name: "cleanup", Agent: "reviewer"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	run, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, run); !errors.Is(err, types.ErrRunTimedOut) {
+		t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, types.ErrRunTimedOut)
+	}
+
+	if run.Status != workflow.RunStatusFailed {
+		t.Errorf("Run Status = %v, want %v", run.Status, workflow.RunStatusFailed)
+	}
+	if !run.TimedOut() {
+		t.Errorf("Run Error = %q, want timed out", run.Error)
+	}
+	want := map[string]workflow.StepStatus{
+		"analyze": workflow.StepStatusCancelled,
+		"post":    workflow.StepStatusSkipped,
+		"cleanup": workflow.StepStatusCompleted,
+	}
+	for name, status := range want {
+		if got := run.GetStepByName(name).Status; got != status {
+			t.Errorf("%s Status = %v, want %v", name, got, status)
+		}
+	}
+
+	events, _ := auditLogger.Query(ctx, governance.AuditFilter{Types: []governance.AuditEventType{governance.AuditEventWorkflowTimedOut}})
+	if len(events) != 1 {
+		t.Errorf("workflow.timed_out audit events = %d, want 1", len(events))
+	}
+}
+
+func TestOrchestrator_ApprovalTimeout(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	orch.agentRunner = &mockRunner{content: "ok"}
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:            "approval-timeout",
+		Version:         "1.0",
+		ApprovalTimeout: "10ms",
+		Steps: []config.StepConfig{
+			{Name: "analyze", Agent: "reviewer"},
+			{Name: "review", Agent: "reviewer", DependsOn: []string{"analyze"}, RequiresApproval: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	pause := func() *workflow.WorkflowRun {
+		t.Helper()
+		run, err := orch.CreateRun(ctx, def, "test", nil)
+		if err != nil {
+			t.Fatalf("CreateRun() error = %v", err)
+		}
+		if err := orch.ExecuteWorkflow(ctx, run); !errors.Is(err, types.ErrApprovalRequired) {
+			t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, types.ErrApprovalRequired)
+		}
+		if run.ApprovalDeadline == nil {
+			t.Fatal("ApprovalDeadline = nil, want deadline")
+		}
+		return run
+	}
+
+	late := pause()
+	expired := pause()
+	time.Sleep(20 * time.Millisecond)
+
+	// A late approval does not resume the run
+	if err := orch.ResumeWorkflow(ctx, late, "tester"); !errors.Is(err, types.ErrRunTimedOut) {
+		t.Fatalf("ResumeWorkflow() error = %v, want %v", err, types.ErrRunTimedOut)
+	}
+	if !late.TimedOut() {
+		t.Errorf("Run Error = %q, want timed out", late.Error)
+	}
+	if got := late.GetStepByName("review").Status; got != workflow.StepStatusSkipped {
+		t.Errorf("review Status = %v, want %v", got, workflow.StepStatusSkipped)
+	}
+
+	// An approval nobody gave is expired on startup
+	runs, err := orch.ExpireRuns(ctx)
+	if err != nil {
+		t.Fatalf("ExpireRuns() error = %v", err)
+	}
+	if len(runs) != 1 || runs[0].ID != expired.ID {
+		t.Fatalf("ExpireRuns() = %d runs, want %s", len(runs), expired.ID)
+	}
+	if runs[0].Status != workflow.RunStatusFailed || !runs[0].TimedOut() {
+		t.Errorf("Run Status = %v, Error = %q, want timed out", runs[0].Status, runs[0].Error)
+	}
+	if runs[0].LeaseOwner != "" {
+		t.Errorf("Run LeaseOwner = %q, want released", runs[0].LeaseOwner)
+	}
+}
+
+func TestRetryClass(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		retryOn []string
+		want    string
+		wantOK  bool
+	}{
+		{"overloaded provider", llm.NewProviderError("anthropic", 529, "overloaded", true), []string{config.RetryOnTransient}, config.RetryOnTransient, true},
+		{"bad request", llm.NewProviderError("openai", 400, "invalid model", false), []string{config.RetryOnTransient}, "", false},
+		{"server error class", fmt.Errorf("agent failed: %w", llm.NewProviderError("openai", 502, "bad gateway", true)), []string{config.RetryOnServerError, config.RetryOnTransient}, config.RetryOnServerError, true},
+		{"rate limited", llm.NewProviderError("gemini", 429, "slow down", true), []string{config.RetryOnRateLimited}, config.RetryOnRateLimited, true},
+		{"step timeout", context.DeadlineExceeded, []string{config.RetryOnTimeout}, config.RetryOnTimeout, true},
+		{"agent unavailable", types.ErrAgentUnavailable, []string{config.RetryOnTransient}, config.RetryOnTransient, true},
+		{"invalid output", fmt.Errorf("%w: missing field", types.ErrStepOutputInvalid), []string{config.RetryOnTransient}, "", false},
+		{"invalid output retried", fmt.Errorf("%w: missing field", types.ErrStepOutputInvalid), []string{config.RetryOnOutputInvalid}, config.RetryOnOutputInvalid, true},
+		{"action server error", &workflow.ActionError{Action: config.ActionHTTPRequest, StatusCode: 503, Err: errors.New("unavailable")}, []string{config.RetryOnTransient}, config.RetryOnTransient, true},
+		{"action rate limited", fmt.Errorf("action failed: %w", &workflow.ActionError{Action: config.ActionGitHubAddLabels, StatusCode: 429, Err: errors.New("slow down")}), []string{config.RetryOnRateLimited}, config.RetryOnRateLimited, true},
+		{"action not found", &workflow.ActionError{Action: config.ActionGitHubCreateReview, StatusCode: 404, Err: errors.New("not found")}, []string{config.RetryOnTransient}, "", false},
+		{"any error", errors.New("boom"), []string{config.RetryOnAny}, config.RetryOnAny, true},
+		{"policy violation", fmt.Errorf("%w: denied", types.ErrPolicyViolation), []string{config.RetryOnAny}, "", false},
+		{"cancelled", context.Canceled, []string{config.RetryOnAny}, "", false},
+		{"bad request with any", llm.NewProviderError("openai", 400, "invalid model", false), []string{config.RetryOnAny}, "", false},
+		{"action not found with any", &workflow.ActionError{Action: config.ActionGitHubCreateReview, StatusCode: 404, Err: errors.New("not found")}, []string{config.RetryOnAny}, "", false},
+		{"action rate limited with any", &workflow.ActionError{Action: config.ActionGitHubAddLabels, StatusCode: 429, Err: errors.New("slow down")}, []string{config.RetryOnAny}, config.RetryOnAny, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryClass(tt.err, tt.retryOn)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("retryClass() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_Retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		failures     int
+		wantStatus   workflow.RunStatus
+		wantCalls    int
+		wantAttempts int
+	}{
+		{"transient failures are retried", llm.NewProviderError("anthropic", 529, "overloaded", true), 2, workflow.RunStatusCompleted, 3, 2},
+		{"retries are bounded", llm.NewProviderError("anthropic", 529, "overloaded", true), 5, workflow.RunStatusFailed, 3, 2},
+		{"non-retryable failures are not retried", llm.NewProviderError("anthropic", 400, "invalid request", false), 1, workflow.RunStatusFailed, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := eventbus.New()
+			var failed, retrying int
+			bus.Subscribe("step.failed", func(context.Context, workflow.Event) error {
+				failed++
+				return nil
+			})
+			bus.Subscribe("step.retrying", func(context.Context, workflow.Event) error {
+				retrying++
+				return nil
+			})
+			orch := createTestOrchestratorWithEvents(t, bus)
+			ctx := context.Background()
+
+			calls := 0
+			runner := &mockRunner{content: "ok", err: tt.err, fail: func([]llm.Message) bool {
+				calls++
+				return calls <= tt.failures
+			}}
+			orch.agentRunner = runner
+			orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+			def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+				Name:    "retry",
+				Version: "1.0",
+				Steps: []config.StepConfig{{
+					Name:  "analyze",
+					Agent: "reviewer",
+					Retry: &config.RetryConfig{MaxAttempts: 3, Backoff: "1ms", Jitter: 0.5},
+				}},
+			})
+			if err != nil {
+				t.Fatalf("CreateWorkflow() error = %v", err)
+			}
+
+			run, err := orch.CreateRun(ctx, def, "test", nil)
+			if err != nil {
+				t.Fatalf("CreateRun() error = %v", err)
+			}
+			_ = orch.ExecuteWorkflow(ctx, run)
+
+			if run.Status != tt.wantStatus {
+				t.Errorf("Run Status = %v, want %v (error %q)", run.Status, tt.wantStatus, run.Error)
+			}
+			if len(runner.messages) != tt.wantCalls {
+				t.Errorf("agent calls = %d, want %d", len(runner.messages), tt.wantCalls)
+			}
+
+			step := run.GetStepByName("analyze")
+			if len(step.Attempts) != tt.wantAttempts {
+				t.Fatalf("Attempts = %d, want %d", len(step.Attempts), tt.wantAttempts)
+			}
+			for i, attempt := range step.Attempts {
+				if attempt.Attempt != i+1 || attempt.Class != config.RetryOnTransient || attempt.Error == "" || attempt.CompletedAt == nil {
+					t.Errorf("Attempt %d = %+v, want transient failure", i+1, attempt)
+				}
+			}
+
+			// Only the final failure is published as a failed step
+			wantFailed := 0
+			if tt.wantStatus == workflow.RunStatusFailed {
+				wantFailed = 1
+			}
+			if failed != wantFailed || retrying != tt.wantAttempts {
+				t.Errorf("step.failed = %d, step.retrying = %d, want %d and %d", failed, retrying, wantFailed, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_Cache(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	runner := &mockRunner{content: "looks good", model: "claude-sonnet-4"}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "cached",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "analyze", Agent: "reviewer", Cache: &config.CacheConfig{TTL: "1h"}},
+			{Name: "report", Agent: "reviewer"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	execute := func() *workflow.WorkflowRun {
+		t.Helper()
+		run, err := orch.CreateRun(ctx, def, "test", nil)
+		if err != nil {
+			t.Fatalf("CreateRun() error = %v", err)
+		}
+		if err := orch.ExecuteWorkflow(ctx, run); err != nil {
+			t.Fatalf("ExecuteWorkflow() error = %v", err)
+		}
+		return run
+	}
+
+	first := execute()
+	if first.GetStepByName("analyze").CacheHit {
+		t.Error("first run CacheHit = true, want false")
+	}
+	if len(runner.messages) != 2 {
+		t.Fatalf("agent calls = %d, want 2", len(runner.messages))
+	}
+
+	second := execute()
+	analyze := second.GetStepByName("analyze")
+	if !analyze.CacheHit {
+		t.Error("second run CacheHit = false, want true")
+	}
+	if analyze.Output["content"] != "looks good" {
+		t.Errorf("cached Output = %v, want content %q", analyze.Output, "looks good")
+	}
+	if second.GetStepByName("report").CacheHit {
+		t.Error("uncached step CacheHit = true, want false")
+	}
+
+	// The cached result keeps the usage of the run that produced it, which
+	// is not counted for the run reusing it
+	cachedFrom := first.GetStepByName("analyze")
+	if analyze.TokensIn != cachedFrom.TokensIn || analyze.TokensOut != cachedFrom.TokensOut || analyze.CostUSD != cachedFrom.CostUSD || analyze.CostUSD == 0 {
+		t.Errorf("cached usage = %d/%d/$%g, want %d/%d/$%g", analyze.TokensIn, analyze.TokensOut, analyze.CostUSD,
+			cachedFrom.TokensIn, cachedFrom.TokensOut, cachedFrom.CostUSD)
+	}
+	if got, want := second.Usage(""), second.GetStepByName("report").Usage(); got != want {
+		t.Errorf("run Usage() = %+v, want %+v of the uncached step", got, want)
+	}
+	if len(runner.messages) != 3 {
+		t.Errorf("agent calls = %d, want 3", len(runner.messages))
+	}
+
+	orch.noCache = true
+	third := execute()
+	if third.GetStepByName("analyze").CacheHit {
+		t.Error("run without cache CacheHit = true, want false")
+	}
+	if len(runner.messages) != 5 {
+		t.Errorf("agent calls = %d, want 5", len(runner.messages))
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_Action(t *testing.T) {
+	tests := []struct {
+		name         string
+		register     bool
+		capabilities []string
+		failures     int
+		wantErr      error
+		wantStatus   workflow.StepStatus
+		wantCalls    int
+	}{
+		{"completes", true, nil, 0, nil, workflow.StepStatusCompleted, 1},
+		{"server errors are retried", true, nil, 1, nil, workflow.StepStatusCompleted, 2},
+		{"approval for capability", true, []string{"file-write"}, 0, types.ErrApprovalRequired, workflow.StepStatusAwaitingApproval, 0},
+		{"unregistered action", false, nil, 0, types.ErrActionNotFound, workflow.StepStatusFailed, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orch := createTestOrchestrator(t)
+			ctx := context.Background()
+
+			auditLogger := governance.NewInMemoryAuditLogger()
+			orch.auditService = governance.NewAuditService(auditLogger)
+
+			runner := &mockRunner{content: "ok"}
+			orch.agentRunner = runner
+
+			calls := 0
+			orch.actions = workflow.NewActionSet()
+			if tt.register {
+				orch.actions.Register(workflow.Action{
+					Name:         config.ActionHTTPRequest,
+					Capabilities: tt.capabilities,
+					Handler: func(ctx context.Context, input map[string]any) (map[string]any, error) {
+						calls++
+						if calls <= tt.failures {
+							return nil, &workflow.ActionError{Action: config.ActionHTTPRequest, StatusCode: 503, Err: errors.New("unavailable")}
+						}
+						return map[string]any{"status": 200, "body": "fetched " + input["url"].(string)}, nil
+					},
+				})
+			}
+
+			def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+				Name:    "action",
+				Version: "1.0",
+				Steps: []config.StepConfig{{
+					Name:  "fetch",
+					Uses:  config.ActionHTTPRequest,
+					Input: map[string]any{"url": "https://example.com/${{ trigger.path }}"},
+					Retry: &config.RetryConfig{MaxAttempts: 2, Backoff: "1ms"},
+				}},
+			})
+			if err != nil {
+				t.Fatalf("CreateWorkflow() error = %v", err)
+			}
+
+			run, err := orch.CreateRun(ctx, def, "test", map[string]any{"path": "status"})
+			if err != nil {
+				t.Fatalf("CreateRun() error = %v", err)
+			}
+
+			err = orch.ExecuteWorkflow(ctx, run)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("ExecuteWorkflow() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, tt.wantErr)
+			}
+
+			step := run.GetStepByName("fetch")
+			if step.Status != tt.wantStatus {
+				t.Errorf("step Status = %v, want %v", step.Status, tt.wantStatus)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("action calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if len(runner.messages) != 0 {
+				t.Errorf("agent calls = %d, want 0", len(runner.messages))
+			}
+
+			events, _ := auditLogger.Query(ctx, governance.AuditFilter{Types: []governance.AuditEventType{governance.AuditEventActionInvoked}})
+			if len(events) != tt.wantCalls {
+				t.Errorf("action.invoked audit events = %d, want %d", len(events), tt.wantCalls)
+			}
+
+			if tt.wantStatus == workflow.StepStatusCompleted {
+				if got := step.Output["body"]; got != "fetched https://example.com/status" {
+					t.Errorf("Output[body] = %v, want %v", got, "fetched https://example.com/status")
+				}
+				if got := step.Output["status"]; got != float64(200) {
+					t.Errorf("Output[status] = %v, want %v", got, 200)
+				}
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_ConcurrencyCancelInProgress(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	orch.agentRunner = &mockRunner{content: "ok"}
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:        "review",
+		Version:     "1.0",
+		Concurrency: &config.ConcurrencyConfig{Group: "pr-${{ trigger.pr }}", CancelInProgress: true},
+		Steps: []config.StepConfig{
+			{Name: "post", Agent: "reviewer", RequiresApproval: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	createRun := func(pr int) *workflow.WorkflowRun {
+		run, err := orch.CreateRun(ctx, def, "test", map[string]any{"pr": pr})
+		if err != nil {
+			t.Fatalf("CreateRun() error = %v", err)
+		}
+		return run
+	}
+
+	executeRun := func(pr int) *workflow.WorkflowRun {
+		run := createRun(pr)
+		if err := orch.ExecuteWorkflow(ctx, run); !errors.Is(err, types.ErrApprovalRequired) {
+			t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, types.ErrApprovalRequired)
+		}
+		return run
+	}
+
+	// A newer push cancels the run awaiting approval
+	first := executeRun(1)
+	other := executeRun(2)
+	second := executeRun(1)
+	if first.ConcurrencyGroup != "pr-1" {
+		t.Errorf("ConcurrencyGroup = %q, want %q", first.ConcurrencyGroup, "pr-1")
+	}
+	if first.Status != workflow.RunStatusCancelled || !strings.Contains(first.Error, "superseded by run "+second.ID.String()) {
+		t.Errorf("first run = %v (%q), want cancelled as superseded", first.Status, first.Error)
+	}
+	if other.Status != workflow.RunStatusAwaitingApproval {
+		t.Errorf("run in other group Status = %v, want %v", other.Status, workflow.RunStatusAwaitingApproval)
+	}
+
+	// An earlier run executed after a later one is superseded right away
+	early := createRun(3)
+	late := executeRun(3)
+	if err := orch.ExecuteWorkflow(ctx, early); !errors.Is(err, types.ErrRunCancelled) {
+		t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, types.ErrRunCancelled)
+	}
+	if early.GetStepByName("post").Status != workflow.StepStatusSkipped {
+		t.Errorf("step Status = %v, want %v", early.GetStepByName("post").Status, workflow.StepStatusSkipped)
+	}
+	if late.Status != workflow.RunStatusAwaitingApproval {
+		t.Errorf("later run Status = %v, want %v", late.Status, workflow.RunStatusAwaitingApproval)
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_ConcurrencyQueue(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	orch.leaseTTL = 30 * time.Millisecond
+	orch.leaseRenewal = 10 * time.Millisecond
+	ctx := context.Background()
+
+	runner := &mockRunner{content: "ok"}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:        "deploy",
+		Version:     "1.0",
+		Concurrency: &config.ConcurrencyConfig{Group: "production"},
+		Steps: []config.StepConfig{
+			{Name: "deploy", Agent: "reviewer", RequiresApproval: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	first, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+	second, err := orch.CreateRun(ctx, def, "test", nil)
+	if err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+
+	if err := orch.ExecuteWorkflow(ctx, first); !errors.Is(err, types.ErrApprovalRequired) {
+		t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, types.ErrApprovalRequired)
+	}
+
+	// The second run waits while the first is active
+	waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := orch.ExecuteWorkflow(waitCtx, second); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if second.Status != workflow.RunStatusPending {
+		t.Errorf("queued run Status = %v, want %v", second.Status, workflow.RunStatusPending)
+	}
+	if first.Status != workflow.RunStatusAwaitingApproval {
+		t.Errorf("first run Status = %v, want %v", first.Status, workflow.RunStatusAwaitingApproval)
+	}
+
+	if err := orch.ResumeWorkflow(ctx, first, "tester"); err != nil {
+		t.Fatalf("ResumeWorkflow() error = %v", err)
+	}
+	if err := orch.ExecuteWorkflow(ctx, second); !errors.Is(err, types.ErrApprovalRequired) {
+		t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, types.ErrApprovalRequired)
+	}
+	if len(runner.messages) != 1 {
+		t.Errorf("agent calls = %d, want 1", len(runner.messages))
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_WorkflowBudget(t *testing.T) {
+	orch := createTestOrchestrator(t)
+	ctx := context.Background()
+
+	// Each agent call uses 15 tokens, a run uses 30 of the 40 all runs share
+	runner := &mockRunner{content: "ok"}
+	orch.agentRunner = runner
+	orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+	def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+		Name:    "budget",
+		Version: "1.0",
+		Budget:  &config.BudgetConfig{MaxTokens: 40},
+		Steps: []config.StepConfig{
+			{Name: "analyze", Agent: "reviewer"},
+			{Name: "review", Agent: "reviewer", DependsOn: []string{"analyze"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateWorkflow() error = %v", err)
+	}
+
+	execute := func() (*workflow.WorkflowRun, error) {
+		t.Helper()
+		run, err := orch.CreateRun(ctx, def, "test", nil)
+		if err != nil {
+			t.Fatalf("CreateRun() error = %v", err)
+		}
+		return run, orch.ExecuteWorkflow(ctx, run)
+	}
+
+	if _, err := execute(); err != nil {
+		t.Fatalf("first run: ExecuteWorkflow() error = %v", err)
+	}
+
+	// The second run uses up the rest of the budget in its first step
+	second, err := execute()
+	if !errors.Is(err, types.ErrBudgetExceeded) {
+		t.Fatalf("second run: ExecuteWorkflow() error = %v, want %v", err, types.ErrBudgetExceeded)
+	}
+	if got := second.GetStepByName("analyze").Status; got != workflow.StepStatusCompleted {
+		t.Errorf("second run: analyze Status = %v, want %v", got, workflow.StepStatusCompleted)
+	}
+
+	// Later runs fail before calling an agent
+	third, err := execute()
+	if !errors.Is(err, types.ErrBudgetExceeded) {
+		t.Fatalf("third run: ExecuteWorkflow() error = %v, want %v", err, types.ErrBudgetExceeded)
+	}
+	if third.Status != workflow.RunStatusFailed {
+		t.Errorf("third run: Status = %v, want %v", third.Status, workflow.RunStatusFailed)
+	}
+	if len(runner.messages) != 3 {
+		t.Errorf("agent calls = %d, want 3", len(runner.messages))
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_Budget(t *testing.T) {
+	tests := []struct {
+		name       string
+		workflow   *config.BudgetConfig
+		run        *workflow.Budget
+		wantStatus workflow.RunStatus
+		wantCalls  int
+		wantStep   string
+	}{
+		{"within budget", &config.BudgetConfig{MaxTokens: 100}, nil, workflow.RunStatusCompleted, 3, ""},
+		{"workflow budget fails the run", &config.BudgetConfig{MaxTokens: 30}, nil, workflow.RunStatusFailed, 2, "summarize"},
+		{"run budget fails the run", nil, &workflow.Budget{MaxTokens: 15, OnExceeded: config.BudgetOnExceededFail}, workflow.RunStatusFailed, 1, "review"},
+		{"workflow budget awaits approval", &config.BudgetConfig{MaxTokens: 30, OnExceeded: config.BudgetOnExceededApprove}, nil, workflow.RunStatusAwaitingApproval, 2, "summarize"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orch := createTestOrchestrator(t)
+			orch.runBudget = tt.run
+			ctx := context.Background()
+
+			// Each agent call uses 15 tokens
+			runner := &mockRunner{content: "ok"}
+			orch.agentRunner = runner
+			orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+			def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+				Name:    "budget",
+				Version: "1.0",
+				Budget:  tt.workflow,
+				Steps: []config.StepConfig{
+					{Name: "analyze", Agent: "reviewer"},
+					{Name: "review", Agent: "reviewer", DependsOn: []string{"analyze"}},
+					{Name: "summarize", Agent: "reviewer", DependsOn: []string{"review"}},
+				},
+			})
+			if err != nil {
+				t.Fatalf("CreateWorkflow() error = %v", err)
+			}
+
+			run, err := orch.CreateRun(ctx, def, "test", nil)
+			if err != nil {
+				t.Fatalf("CreateRun() error = %v", err)
+			}
+			err = orch.ExecuteWorkflow(ctx, run)
+
+			if run.Status != tt.wantStatus {
+				t.Errorf("Run Status = %v, want %v (error %q)", run.Status, tt.wantStatus, run.Error)
+			}
+			if len(runner.messages) != tt.wantCalls {
+				t.Errorf("agent calls = %d, want %d", len(runner.messages), tt.wantCalls)
+			}
+			if got := run.Usage(""); got.Tokens != tt.wantCalls*15 {
+				t.Errorf("Usage() tokens = %d, want %d", got.Tokens, tt.wantCalls*15)
+			}
+
+			switch tt.wantStatus {
+			case workflow.RunStatusFailed:
+				step := run.GetStepByName(tt.wantStep)
+				if step.Status != workflow.StepStatusFailed || !strings.Contains(step.Error, "budget exceeded") {
+					t.Errorf("%s = %v %q, want failed over budget", tt.wantStep, step.Status, step.Error)
+				}
+			case workflow.RunStatusAwaitingApproval:
+				if !errors.Is(err, types.ErrApprovalRequired) {
+					t.Fatalf("ExecuteWorkflow() error = %v, want %v", err, types.ErrApprovalRequired)
+				}
+				if run.PendingStep != tt.wantStep {
+					t.Errorf("Run PendingStep = %q, want %q", run.PendingStep, tt.wantStep)
+				}
+
+				// Approval lets the step exceed the budget
+				stored, err := orch.GetRun(ctx, run.ID)
+				if err != nil {
+					t.Fatalf("GetRun() error = %v", err)
+				}
+				if err := orch.ResumeWorkflow(ctx, stored, "tester"); err != nil {
+					t.Fatalf("ResumeWorkflow() error = %v", err)
+				}
+				if stored.Status != workflow.RunStatusCompleted {
+					t.Errorf("Run Status = %v, want %v", stored.Status, workflow.RunStatusCompleted)
+				}
+				if len(runner.messages) != 3 {
+					t.Errorf("agent calls = %d, want 3", len(runner.messages))
+				}
+			}
+		})
+	}
+}
+
+func TestOrchestrator_ExecuteWorkflow_Events(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantEvents []string
+	}{
+		{
+			"completed",
+			nil,
+			[]string{
+				"workflow.created", "run.started",
+				"step.started analyze", "step.completed analyze",
+				"approval.requested review", "approval.granted review",
+				"step.started review", "step.completed review",
+				"run.completed",
+			},
+		},
+		{
+			"failed",
+			errors.New("agent unavailable"),
+			[]string{
+				"workflow.created", "run.started",
+				"step.started analyze", "step.failed analyze",
+				"step.cancelled review", "run.failed analyze",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []workflow.Event
+			bus := eventbus.New()
+			bus.SubscribeAll(func(_ context.Context, event workflow.Event) error {
+				events = append(events, event)
+				return nil
+			})
+
+			orch := createTestOrchestratorWithEvents(t, bus)
+			ctx := context.Background()
+
+			orch.agentRunner = &mockRunner{content: "ok", err: tt.err}
+			orch.agentRegistry.Register(&agents.Agent{ID: "reviewer", Name: "reviewer", Provider: "mock"})
+
+			def, err := orch.CreateWorkflow(ctx, &config.WorkflowConfig{
+				Name:    "events",
+				Version: "1.0",
+				Steps: []config.StepConfig{
+					{Name: "analyze", Agent: "reviewer"},
+					{Name: "review", Agent: "reviewer", DependsOn: []string{"analyze"}, RequiresApproval: true},
+				},
+			})
+			if err != nil {
+				t.Fatalf("CreateWorkflow() error = %v", err)
+			}
+
+			run, err := orch.CreateRun(ctx, def, "test", nil)
+			if err != nil {
+				t.Fatalf("CreateRun() error = %v", err)
+			}
+			if err := orch.ExecuteWorkflow(ctx, run); errors.Is(err, types.ErrApprovalRequired) {
+				if err := orch.ResumeWorkflow(ctx, run, "alice"); err != nil {
+					t.Fatalf("ResumeWorkflow() error = %v", err)
+				}
+			}
+
+			got := make([]string, 0, len(events))
+			for _, event := range events {
+				desc := event.EventType()
+				switch e := event.(type) {
+				case *workflow.StepStartedEvent:
+					desc += " " + e.Name
+				case *workflow.StepCompletedEvent:
+					desc += " " + e.Name
+				case *workflow.StepFailedEvent:
+					desc += " " + e.Name
+				case *workflow.StepCancelledEvent:
+					desc += " " + e.Name
+				case *workflow.ApprovalRequestedEvent:
+					desc += " " + e.StepName
+				case *workflow.ApprovalGrantedEvent:
+					desc += " " + e.StepName
+					if e.ApprovedBy != "alice" {
+						t.Errorf("ApprovedBy = %q, want %q", e.ApprovedBy, "alice")
+					}
+				case *workflow.RunFailedEvent:
+					desc += " " + e.FailedStep
+				}
+				got = append(got, desc)
+
+				// Events of the run are all aggregated on the run
+				if event.EventType() != "workflow.created" && event.AggregateID() != run.ID.String() {
+					t.Errorf("%s AggregateID() = %v, want %v", event.EventType(), event.AggregateID(), run.ID)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantEvents) {
+				t.Errorf("events = %v, want %v", got, tt.wantEvents)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/felixgeelhaar/bolt"
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// DefaultLeaseTTL is how long a run lease lasts without a heartbeat. The
+// lease is renewed at a third of this interval while the run executes.
+const DefaultLeaseTTL = 30 * time.Second
+
+// RecoverRuns resumes the active runs whose lease expired because the
+// process executing them died. Runs interrupted while their steps executed
+// resume from the steps that had not completed; runs interrupted before,
+// while waiting for their concurrency group or checking policies, are
+// executed from the start. Runs leased by a live process are left alone. It
+// returns the runs that were resumed.
+func (o *Orchestrator) RecoverRuns(ctx context.Context) ([]*workflow.WorkflowRun, error) {
+	orphaned, err := o.workflowService.ListOrphanedRuns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orphaned runs: %w", err)
+	}
+
+	recovered := make([]*workflow.WorkflowRun, 0)
+	for _, candidate := range orphaned {
+		// Child runs are resumed by the sub-workflow step of their parent run
+		if candidate.ParentRunID != "" {
+			continue
+		}
+
+		// Another process may be recovering the same run
+		// A cancellation requested meanwhile is applied by executeSteps
+		err := o.workflowService.AcquireLease(ctx, candidate.ID, o.instanceID, o.leaseTTL)
+		if err != nil && !errors.Is(err, types.ErrRunCancelled) {
+			if errors.Is(err, types.ErrRunLeased) {
+				continue
+			}
+			return recovered, fmt.Errorf("failed to lease run %s: %w", candidate.ID, err)
+		}
+
+		run, err := o.workflowService.GetRun(ctx, candidate.ID)
+		if err != nil {
+			o.workflowService.ReleaseLease(ctx, candidate.ID, o.instanceID)
+			return recovered, fmt.Errorf("failed to load run %s: %w", candidate.ID, err)
+		}
+
+		// Runs waiting for approval or input resume once they are answered
+		switch run.Status {
+		case workflow.RunStatusAwaitingApproval, workflow.RunStatusAwaitingInput,
+			workflow.RunStatusCompleted, workflow.RunStatusFailed, workflow.RunStatusCancelled:
+			o.workflowService.ReleaseLease(ctx, run.ID, o.instanceID)
+			continue
+		}
+
+		logger := o.logger.With().
+			Str("run_id", run.ID.String()).
+			Str("workflow", run.WorkflowName).
+			Str("status", string(run.Status)).
+			Logger()
+
+		logger.Warn().Msg("Recovering interrupted workflow run")
+
+		if run.Status == workflow.RunStatusExecuting {
+			o.resetInterrupted(ctx, run)
+			err = o.executeSteps(ctx, run, nil, logger)
+		} else {
+			err = o.ExecuteWorkflow(ctx, run)
+		}
+		if err != nil &&
+			!errors.Is(err, types.ErrApprovalRequired) && !errors.Is(err, types.ErrInputRequired) &&
+			!errors.Is(err, types.ErrRunCancelled) {
+			logger.Error().Err(err).Msg("Recovered workflow run failed")
+		}
+		recovered = append(recovered, run)
+	}
+
+	return recovered, nil
+}
+
+// resetInterrupted returns the steps that were in flight when the process
+// executing a run died to pending, so that they run again. Fan-out steps
+// keep running; only their unfinished items run again.
+func (o *Orchestrator) resetInterrupted(ctx context.Context, run *workflow.WorkflowRun) {
+	for _, step := range run.RunningSteps() {
+		if len(run.ChildSteps(step.Name)) > 0 {
+			continue
+		}
+		step.Reset()
+		o.workflowService.UpdateStep(ctx, step)
+	}
+}
+
+// keepLease renews the lease on a run until the returned function is called,
+// which stops renewing and releases the lease. When the lease cannot be
+// renewed, execution is cancelled with types.ErrRunLeased so that the run is
+// never executed by two processes at once. A cancellation requested by
+// another process cancels execution with types.ErrRunCancelled.
+func (o *Orchestrator) keepLease(ctx context.Context, runID types.RunID, cancel context.CancelCauseFunc, logger *bolt.Logger) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(o.leaseRenewal)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := o.workflowService.AcquireLease(ctx, runID, o.instanceID, o.leaseTTL)
+				switch {
+				case err == nil:
+					continue
+				case errors.Is(err, types.ErrRunCancelled):
+					logger.Info().Msg("Run cancellation requested")
+					cancel(err)
+				default:
+					logger.Error().Err(err).Msg("Failed to renew run lease, stopping execution")
+					cancel(types.ErrRunLeased)
+				}
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		o.workflowService.ReleaseLease(context.WithoutCancel(ctx), runID, o.instanceID)
+	}
+}
+
+// defaultInstanceID identifies this process by host name and process ID.
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "bridge"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
@@ -0,0 +1,85 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/llm"
+	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// errorClasses returns the retry error classes a step failure belongs to,
+// from the most to the least specific.
+// Cancellations, policy violations, used up budgets and requests the remote
+// service rejected, such as a 400, belong to none, as running the step again
+// would not change their outcome.
+func errorClasses(err error) []string {
+	if errors.Is(err, context.Canceled) ||
+		errors.Is(err, types.ErrPolicyViolation) ||
+		errors.Is(err, types.ErrBudgetExceeded) ||
+		errors.Is(err, types.ErrMCPToolForbidden) {
+		return nil
+	}
+
+	var providerErr *llm.ProviderError
+	isProviderErr := errors.As(err, &providerErr)
+
+	var actionErr *workflow.ActionError
+	isActionErr := errors.As(err, &actionErr)
+
+	// Provider and action errors carry the status of the remote service
+	statusCode := 0
+	switch {
+	case isProviderErr:
+		statusCode = providerErr.StatusCode
+	case isActionErr:
+		statusCode = actionErr.StatusCode
+	}
+
+	rejected := isProviderErr && !providerErr.IsRetryable() ||
+		isActionErr && statusCode >= http.StatusBadRequest && statusCode < http.StatusInternalServerError &&
+			statusCode != http.StatusTooManyRequests
+	if rejected {
+		return nil
+	}
+
+	classes := make([]string, 0, 3)
+	timeout := errors.Is(err, types.ErrAgentTimeout) ||
+		errors.Is(err, types.ErrStepTimeout) ||
+		errors.Is(err, context.DeadlineExceeded)
+	if timeout {
+		classes = append(classes, config.RetryOnTimeout)
+	}
+	rateLimited := errors.Is(err, types.ErrLLMRateLimited) ||
+		statusCode == http.StatusTooManyRequests
+	if rateLimited {
+		classes = append(classes, config.RetryOnRateLimited)
+	}
+	serverError := statusCode >= http.StatusInternalServerError
+	if serverError {
+		classes = append(classes, config.RetryOnServerError)
+	}
+	if errors.Is(err, types.ErrStepOutputInvalid) {
+		classes = append(classes, config.RetryOnOutputInvalid)
+	}
+	if timeout || rateLimited || types.IsTransient(err) || isProviderErr && providerErr.IsRetryable() || isActionErr && serverError {
+		classes = append(classes, config.RetryOnTransient)
+	}
+	return append(classes, config.RetryOnAny)
+}
+
+// retryClass returns the most specific error class of err that retryOn
+// allows retrying, and false when the failure is not retried.
+func retryClass(err error, retryOn []string) (string, bool) {
+	classes := errorClasses(err)
+	for _, class := range classes {
+		if slices.Contains(retryOn, class) {
+			return class, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,769 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/bolt"
+	"github.com/felixgeelhaar/bridge/internal/domain/governance"
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// stepOutcome is the result reported by a worker executing a step.
+type stepOutcome struct {
+	step   *workflow.StepRun
+	result *StepResult
+	err    error
+}
+
+// scheduler executes the steps of a run as a dependency graph. Steps whose
+// dependencies have finished run concurrently, up to the workflow's
+// max_parallel limit. Run and step state is only mutated from the scheduling
+// goroutine; workers invoke the executor and report their outcome.
+type scheduler struct {
+	o        *Orchestrator
+	run      *workflow.WorkflowRun
+	def      *workflow.WorkflowDefinition
+	executor *Executor
+	budget   *budgetTracker
+	logger   *bolt.Logger
+	outcomes chan stepOutcome
+	inFlight map[types.StepID]context.CancelFunc
+	backoff  map[types.StepID]time.Duration // Delays of retried steps before their next attempt
+}
+
+// newScheduler creates a scheduler for run. spent is the usage of the other
+// runs of the workflow, counted against the budget of the workflow.
+func newScheduler(o *Orchestrator, run *workflow.WorkflowRun, def *workflow.WorkflowDefinition, spent workflow.Usage, logger *bolt.Logger) *scheduler {
+	executor := NewExecutor(o.logger, o.agentRunner, o.agentRegistry, o.auditService, o.policyEvaluator, o.tools, o.actions, o.maxToolIterations)
+	executor.budget = newBudgetTracker(run, def, spent)
+	executor.workflowService = o.workflowService
+
+	return &scheduler{
+		o:        o,
+		run:      run,
+		def:      def,
+		executor: executor,
+		budget:   executor.budget,
+		logger:   logger,
+		outcomes: make(chan stepOutcome),
+		inFlight: make(map[types.StepID]context.CancelFunc),
+		backoff:  make(map[types.StepID]time.Duration),
+	}
+}
+
+// execute runs all pending steps of the run. It returns when every step has
+// finished, after the first unrecoverable step failure once in-flight steps
+// have been cancelled, or with types.ErrApprovalRequired once no further
+// step can run until a step awaiting approval is approved, or with
+// types.ErrInputRequired until a human input step is answered. When the run
+// is cancelled, in-flight steps are stopped and an error wrapping
+// types.ErrRunCancelled is returned. A run of a workflow whose runs used up
+// its budget fails with a *workflow.BudgetError before any step runs.
+func (s *scheduler) execute(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := s.budget.checkWorkflow(); err != nil {
+		s.o.auditService.LogBudgetExceeded(ctx, s.run.ID.String(), "", "", err.Error(), "fail")
+		return s.abort(ctx, nil, err)
+	}
+
+	// Items of a recovered run may have finished before it was interrupted
+	s.finishFanOuts(ctx)
+
+	for {
+		if cause := context.Cause(ctx); errors.Is(cause, types.ErrRunCancelled) {
+			return s.cancel(ctx, cause)
+		}
+		if cause := context.Cause(ctx); errors.Is(cause, types.ErrRunTimedOut) {
+			return s.timeOut(ctx, cause)
+		}
+
+		if step, err := s.launchReady(ctx); err != nil {
+			s.o.workflowService.FailStep(ctx, s.run, step, err.Error())
+			return s.abort(ctx, s.failParent(ctx, step, err), err)
+		}
+
+		if len(s.inFlight) == 0 {
+			if awaiting := s.run.AwaitingApprovalSteps(); len(awaiting) > 0 {
+				return s.awaitApproval(ctx, awaiting[0])
+			}
+			if awaiting := s.run.AwaitingInputSteps(); len(awaiting) > 0 {
+				return s.awaitInput(ctx, awaiting[0])
+			}
+			if pending := s.run.PendingSteps(); len(pending) > 0 {
+				err := fmt.Errorf("steps cannot be scheduled, dependency cycle: %s", stepNames(pending))
+				return s.abort(ctx, nil, err)
+			}
+
+			outputs, err := s.executor.ResolveOutputs(s.run, s.def)
+			if err != nil {
+				return s.abort(ctx, nil, err)
+			}
+			s.run.SetOutputs(outputs)
+
+			s.runHandlers(ctx, s.addHandlers(ctx, workflow.HandlerFinally, "", s.def.Finally, "", ""))
+			return nil
+		}
+
+		outcome := <-s.outcomes
+		s.release(outcome.step)
+
+		// Another process took over the run, leave its state alone
+		if errors.Is(context.Cause(ctx), types.ErrRunLeased) {
+			return s.abandon()
+		}
+		if cause := context.Cause(ctx); errors.Is(cause, types.ErrRunCancelled) {
+			return s.cancel(ctx, cause, outcome)
+		}
+		if cause := context.Cause(ctx); errors.Is(cause, types.ErrRunTimedOut) {
+			return s.timeOut(ctx, cause, outcome)
+		}
+
+		if outcome.err != nil {
+			step := outcome.step
+			if s.overBudget(ctx, step, outcome.err) {
+				continue
+			}
+
+			if s.retry(ctx, step, outcome.err) {
+				continue
+			}
+			s.o.workflowService.FailStep(ctx, s.run, step, outcome.err.Error())
+
+			// Handler failures do not fail the run
+			if step.Handler != "" {
+				s.logHandlerFailure(step, outcome.err)
+				continue
+			}
+
+			return s.abort(ctx, s.failParent(ctx, step, outcome.err), outcome.err)
+		}
+
+		s.complete(ctx, outcome.step, outcome.result)
+		s.finishFanOuts(ctx)
+	}
+}
+
+// launchReady starts every step whose dependencies are satisfied, as long as
+// the parallelism limit allows. Steps whose condition is false are skipped,
+// which may in turn make further steps ready. Fan-out steps are expanded
+// into their items, which start before other ready steps.
+func (s *scheduler) launchReady(ctx context.Context) (*workflow.StepRun, error) {
+	for {
+		if step, err := s.launchItems(ctx); err != nil {
+			return step, err
+		}
+
+		progressed := false
+
+		for _, step := range s.run.ReadySteps(s.def) {
+			if s.def.MaxParallel > 0 && len(s.inFlight) >= s.def.MaxParallel {
+				return nil, nil
+			}
+
+			// Evaluate the step condition
+			shouldRun, reason, err := evaluateCondition(s.run, s.def, step)
+			if err != nil {
+				return step, err
+			}
+
+			if !shouldRun {
+				s.o.workflowService.SkipStep(ctx, s.run, step, reason)
+				s.o.auditService.LogStepSkipped(ctx, s.run.ID.String(), step.ID.String(), step.Name, reason)
+
+				s.logger.Info().
+					Str("step", step.Name).
+					Str("reason", reason).
+					Msg("Step skipped")
+
+				progressed = true
+				continue
+			}
+
+			stepDef := s.def.GetStep(step.Name)
+			if stepDef.HumanInput != nil {
+				if err := s.ask(ctx, step, stepDef.HumanInput); err != nil {
+					return step, err
+				}
+				continue
+			}
+			if stepDef.IsFanOut() {
+				if stepDef.ApprovesBefore() && !step.IsApproved() {
+					step.AwaitApproval()
+					s.o.workflowService.UpdateStep(ctx, step)
+					continue
+				}
+				if err := s.expand(ctx, step); err != nil {
+					return step, err
+				}
+				progressed = true
+				continue
+			}
+
+			input, ok, err := s.prepare(ctx, step, stepDef.ApprovesBefore())
+			if err != nil {
+				return step, err
+			}
+			if ok {
+				s.start(ctx, step, input)
+			}
+		}
+
+		if !progressed {
+			return nil, nil
+		}
+	}
+}
+
+// launchItems starts pending fan-out items and failure handlers, as long as
+// the parallelism limits of the workflow and of their fan-out step allow.
+func (s *scheduler) launchItems(ctx context.Context) (*workflow.StepRun, error) {
+	for _, step := range s.run.PendingSteps() {
+		if step.Parent == "" && step.Handler == "" {
+			continue
+		}
+		if s.def.MaxParallel > 0 && len(s.inFlight) >= s.def.MaxParallel {
+			return nil, nil
+		}
+		if step.Parent != "" {
+			if limit := s.def.GetStep(step.Parent).MaxParallel; limit > 0 && s.runningItems(step.Parent) >= limit {
+				continue
+			}
+		}
+
+		input, ok, err := s.prepare(ctx, step, false)
+		if err != nil {
+			return step, err
+		}
+		if ok {
+			s.start(ctx, step, input)
+		}
+	}
+	return nil, nil
+}
+
+// runningItems returns the number of items of a fan-out step in flight.
+func (s *scheduler) runningItems(parent string) int {
+	running := 0
+	for _, step := range s.run.ChildSteps(parent) {
+		if step.IsRunning() {
+			running++
+		}
+	}
+	return running
+}
+
+// prepare resolves the input of a step and checks it against its budgets
+// and policy. It returns false when the step must be approved before it
+// runs, either because approve is set, because a policy requires it or
+// because it used up a budget that pauses for approval.
+func (s *scheduler) prepare(ctx context.Context, step *workflow.StepRun, approve bool) (map[string]any, bool, error) {
+	input, err := s.executor.PrepareStep(s.run, s.def, step)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Actions do not call agents, other steps only start within budget
+	if _, action := s.def.GetStep(step.DefinitionName()).Action(); !action {
+		if err := s.budget.check(step); err != nil {
+			if s.overBudget(ctx, step, err) {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+	}
+
+	policyResult, err := s.executor.CheckStepPolicy(ctx, s.run, s.def, step, input)
+	if err != nil {
+		return nil, false, err
+	}
+	step.Warnings = policyWarnings(policyResult)
+	for _, warning := range step.Warnings {
+		s.logger.Warn().
+			Str("step", step.Name).
+			Str("warning", warning).
+			Msg("Policy warning")
+	}
+
+	// Pause steps that must be approved before they run
+	if (approve || policyResult.RequiresApproval) && !step.IsApproved() {
+		step.AwaitApproval()
+		s.o.workflowService.UpdateStep(ctx, step)
+		return nil, false, nil
+	}
+
+	return input, true, nil
+}
+
+// expand starts a fan-out step by adding a step for each of its items. The
+// fan-out step completes once all of its items have completed.
+func (s *scheduler) expand(ctx context.Context, step *workflow.StepRun) error {
+	items, err := s.executor.ExpandItems(s.run, s.def, step)
+	if err != nil {
+		return err
+	}
+
+	s.o.workflowService.StartStep(ctx, s.run, step, nil)
+
+	children := s.run.ExpandStep(step, items)
+	if err := s.o.workflowService.CreateSteps(ctx, s.run, children); err != nil {
+		return fmt.Errorf("failed to create items of step %s: %w", step.Name, err)
+	}
+
+	s.logger.Info().
+		Str("step", step.Name).
+		Int("items", len(children)).
+		Msg("Step fanned out")
+
+	// A step without items is complete right away
+	s.finishFanOuts(ctx)
+	return nil
+}
+
+// finishFanOuts completes the fan-out steps whose items have all completed.
+// The item outputs are collected in order under the "items" output.
+func (s *scheduler) finishFanOuts(ctx context.Context) {
+	for _, step := range s.run.RunningSteps() {
+		stepDef := s.def.GetStep(step.Name)
+		if step.Parent != "" || stepDef == nil || !stepDef.IsFanOut() {
+			continue
+		}
+
+		children := s.run.ChildSteps(step.Name)
+		outputs := make([]any, 0, len(children))
+		var tokens workflow.TokenUsage
+		for _, child := range children {
+			if child.Status != workflow.StepStatusCompleted {
+				break
+			}
+			outputs = append(outputs, child.Output)
+			tokens.Input += child.TokensIn
+			tokens.Output += child.TokensOut
+			tokens.CostUSD += child.CostUSD
+		}
+		if len(outputs) < len(children) {
+			continue
+		}
+		tokens.Total = tokens.Input + tokens.Output
+
+		s.complete(ctx, step, &StepResult{
+			Output: map[string]any{
+				"items":      outputs,
+				"tokens_in":  tokens.Input,
+				"tokens_out": tokens.Output,
+				"cost_usd":   tokens.CostUSD,
+			},
+			Tokens:   tokens,
+			Duration: step.Duration(),
+		})
+	}
+}
+
+// failParent fails the fan-out step of a failed item and returns it, so that
+// the run fails on the step as defined. Other steps are returned unchanged.
+func (s *scheduler) failParent(ctx context.Context, step *workflow.StepRun, cause error) *workflow.StepRun {
+	parent := s.run.GetStepByName(step.Parent)
+	if step.Parent == "" || parent == nil {
+		return step
+	}
+	s.o.workflowService.FailStep(ctx, s.run, parent, fmt.Sprintf("item %s failed: %v", step.Name, cause))
+	return parent
+}
+
+// start marks the step as running and executes it on a worker goroutine.
+func (s *scheduler) start(ctx context.Context, step *workflow.StepRun, input map[string]any) {
+	s.o.workflowService.StartStep(ctx, s.run, step, input)
+
+	s.logger.Info().
+		Str("step", step.Name).
+		Int("index", step.StepIndex).
+		Int("in_flight", len(s.inFlight)+1).
+		Msg("Executing step")
+
+	stepCtx, cancel := context.WithCancel(ctx)
+	s.inFlight[step.ID] = cancel
+
+	stepDef := s.def.GetStep(step.DefinitionName())
+	ref, subWorkflow := stepDef.WorkflowRef()
+	_, action := stepDef.Action()
+
+	backoff := s.backoff[step.ID]
+	delete(s.backoff, step.ID)
+
+	// The child run is recorded on the step before a worker executes it
+	var child *workflow.WorkflowRun
+	var childErr error
+	if subWorkflow {
+		child, childErr = s.o.childRun(ctx, s.run, step, ref, s.budget.remaining(step))
+	}
+
+	go func() {
+		// A retried step waits out its backoff before the next attempt
+		if backoff > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-stepCtx.Done():
+				if child != nil && !child.Status.IsTerminal() {
+					s.o.cancelRun(context.WithoutCancel(stepCtx), child, "parent step cancelled")
+				}
+				s.outcomes <- stepOutcome{step: step, err: stepCtx.Err()}
+				return
+			}
+		}
+
+		var result *StepResult
+		var err error
+		switch {
+		case subWorkflow && childErr != nil:
+			err = childErr
+		case subWorkflow:
+			result, err = s.o.executeSubWorkflow(stepCtx, s.run, child, step.Name, ref)
+			s.budget.record(step, childUsage(child, result))
+		case action:
+			result, err = s.executor.ExecuteAction(stepCtx, s.run, s.def, step)
+		default:
+			result, err = s.executeStep(stepCtx, step)
+		}
+		s.outcomes <- stepOutcome{step: step, result: result, err: err}
+	}()
+}
+
+// retry returns a step whose attempt failed to pending when its retry
+// policy allows another attempt for err. The next attempt starts after the
+// backoff of the policy. It returns false when the failure is final.
+func (s *scheduler) retry(ctx context.Context, step *workflow.StepRun, err error) bool {
+	stepDef := s.def.GetStep(step.DefinitionName())
+	if !step.CanRetry() || stepDef == nil {
+		return false
+	}
+
+	class, ok := retryClass(err, stepDef.Retry.RetryOn)
+	if !ok {
+		s.logger.Warn().
+			Str("step", step.Name).
+			Err(err).
+			Msg("Step failed with an error that is not retried")
+		return false
+	}
+
+	backoff := stepDef.Retry.Delay(step.RetryCount+1, rand.Float64())
+	s.o.workflowService.RetryStep(ctx, s.run, step, err.Error(), class, backoff)
+	s.backoff[step.ID] = backoff
+
+	s.logger.Warn().
+		Str("step", step.Name).
+		Int("attempt", step.RetryCount+1).
+		Int("max_attempts", stepDef.Retry.MaxAttempts).
+		Str("class", class).
+		Dur("backoff", backoff).
+		Err(err).
+		Msg("Step failed, retrying")
+	return true
+}
+
+// release removes a finished step from the in-flight set.
+func (s *scheduler) release(step *workflow.StepRun) {
+	if cancel, ok := s.inFlight[step.ID]; ok {
+		cancel()
+		delete(s.inFlight, step.ID)
+	}
+}
+
+// complete records a successful step result.
+func (s *scheduler) complete(ctx context.Context, step *workflow.StepRun, result *StepResult) {
+	step.CostUSD = result.Tokens.CostUSD
+	step.CacheHit = result.Cached
+	s.o.workflowService.CompleteStep(ctx, s.run, step, result.Output, result.Tokens.Input, result.Tokens.Output)
+	if result.CacheKey != "" && !result.Cached {
+		s.cacheResult(ctx, step, result.CacheKey)
+	}
+
+	// Hold back dependents until the output is approved
+	if stepDef := s.def.GetStep(step.Name); stepDef != nil && stepDef.ApprovesAfter() && !step.IsApproved() {
+		step.AwaitApproval()
+		s.o.workflowService.UpdateStep(ctx, step)
+	}
+
+	// Store output in context, items are collected by their fan-out step
+	if step.Parent == "" {
+		s.run.SetContext(fmt.Sprintf("steps.%s.output", step.Name), result.Output)
+		s.o.workflowService.UpdateRun(ctx, s.run)
+	}
+
+	s.logger.Info().
+		Str("step", step.Name).
+		Dur("duration", result.Duration).
+		Int("tokens_in", result.Tokens.Input).
+		Int("tokens_out", result.Tokens.Output).
+		Msg("Step completed")
+
+	// The finally handlers of the step run alongside the steps that follow
+	if stepDef := s.def.GetStep(step.Name); stepDef != nil && step.Parent == "" && step.Handler == "" && step.Status == workflow.StepStatusCompleted {
+		s.addHandlers(ctx, workflow.HandlerFinally, step.Name, stepDef.Finally, "", "")
+	}
+}
+
+// addHandlers adds the failure handler steps of a block, run for the named
+// step or, when of is empty, for the run, and returns those that have not
+// finished. The handlers can read the failing step and its error as
+// ${{ failure.step }} and ${{ failure.error }}. Handlers are added only
+// once, so that a recovered run does not repeat them.
+func (s *scheduler) addHandlers(ctx context.Context, kind workflow.HandlerKind, of string, defs []workflow.StepDefinition, failedStep, cause string) []*workflow.StepRun {
+	handlers := s.run.HandlerSteps(kind, of)
+	if len(handlers) == 0 && len(defs) > 0 {
+		handlers = s.run.AddHandlers(kind, of, defs, map[string]any{"step": failedStep, "error": cause})
+		if err := s.o.workflowService.CreateSteps(ctx, s.run, handlers); err != nil {
+			s.logger.Error().
+				Err(err).
+				Str("handler", string(kind)).
+				Msg("Failed to store failure handler steps")
+		}
+	}
+
+	unfinished := make([]*workflow.StepRun, 0, len(handlers))
+	for _, step := range handlers {
+		if !step.Status.IsTerminal() {
+			unfinished = append(unfinished, step)
+		}
+	}
+	return unfinished
+}
+
+// runHandlers runs failure handler steps one after another while no other
+// step is in flight. Handler failures are recorded on the handler step but
+// do not change the outcome of the run.
+func (s *scheduler) runHandlers(ctx context.Context, handlers []*workflow.StepRun) {
+	for _, step := range handlers {
+		for step.IsPending() {
+			input, ok, err := s.prepare(ctx, step, false)
+			if err != nil {
+				s.handlerFailed(ctx, step, err)
+				break
+			}
+			if !ok {
+				s.o.workflowService.SkipStep(ctx, s.run, step, "failure handlers cannot wait for approval")
+				break
+			}
+
+			s.start(ctx, step, input)
+			outcome := <-s.outcomes
+			s.release(outcome.step)
+
+			if outcome.err == nil {
+				s.complete(ctx, step, outcome.result)
+				break
+			}
+			if !s.retry(ctx, step, outcome.err) {
+				s.handlerFailed(ctx, step, outcome.err)
+			}
+		}
+	}
+}
+
+// handlerFailed records the failure of a failure handler step.
+func (s *scheduler) handlerFailed(ctx context.Context, step *workflow.StepRun, err error) {
+	s.o.workflowService.FailStep(ctx, s.run, step, err.Error())
+	s.logHandlerFailure(step, err)
+}
+
+func (s *scheduler) logHandlerFailure(step *workflow.StepRun, err error) {
+	s.logger.Warn().
+		Str("step", step.Name).
+		Str("handler", string(step.Handler)).
+		Str("handler_of", step.HandlerOf).
+		Err(err).
+		Msg("Failure handler failed")
+}
+
+// pendingHandlers returns the failure handlers that were added but have not
+// started yet.
+func (s *scheduler) pendingHandlers() []*workflow.StepRun {
+	handlers := make([]*workflow.StepRun, 0)
+	for _, step := range s.run.PendingSteps() {
+		if step.Handler != "" {
+			handlers = append(handlers, step)
+		}
+	}
+	return handlers
+}
+
+// awaitApproval pauses the run until the step is approved.
+func (s *scheduler) awaitApproval(ctx context.Context, step *workflow.StepRun) error {
+	s.run.ExpireApprovalAfter(s.def.ApprovalTimeout)
+	s.o.workflowService.RequestStepApproval(ctx, s.run, step)
+	s.o.auditService.LogApprovalRequested(ctx, step.ID.String(), s.run.ID.String(), "step:"+step.Name)
+
+	s.logger.Info().
+		Str("step", step.Name).
+		Msg("Workflow awaiting step approval")
+
+	return types.ErrApprovalRequired
+}
+
+// abandon stops in-flight steps without recording their outcome.
+func (s *scheduler) abandon() error {
+	for _, cancel := range s.inFlight {
+		cancel()
+	}
+	for len(s.inFlight) > 0 {
+		outcome := <-s.outcomes
+		s.release(outcome.step)
+	}
+	return types.ErrRunLeased
+}
+
+// cancel stops in-flight steps, skips the steps that had not run and cancels
+// the run. received holds outcomes already taken from workers.
+func (s *scheduler) cancel(ctx context.Context, cause error, received ...stepOutcome) error {
+	// The execution context is cancelled, state must still be recorded
+	ctx = context.WithoutCancel(ctx)
+	reason := cancelReason(cause)
+
+	s.stop(ctx, "run cancelled: "+reason, received)
+
+	handlers := s.pendingHandlers()
+	handlers = append(handlers, s.addHandlers(ctx, workflow.HandlerFinally, "", s.def.Finally, "", "cancelled: "+reason)...)
+	s.runHandlers(ctx, handlers)
+
+	s.o.cancelRun(ctx, s.run, reason)
+	return cause
+}
+
+// timeOut stops in-flight steps once the run has passed its deadline, runs
+// the failure handlers of the run and fails it as timed out. received holds
+// outcomes already taken from workers.
+func (s *scheduler) timeOut(ctx context.Context, cause error, received ...stepOutcome) error {
+	// The execution context has expired, state must still be recorded
+	ctx = context.WithoutCancel(ctx)
+	reason := timeoutReason(cause)
+
+	s.stop(ctx, "run timed out: "+reason, received)
+
+	failure := workflow.TimedOutReason + ": " + reason
+	handlers := s.pendingHandlers()
+	handlers = append(handlers, s.addHandlers(ctx, workflow.HandlerOnFailure, "", s.def.OnFailure, "", failure)...)
+	handlers = append(handlers, s.addHandlers(ctx, workflow.HandlerFinally, "", s.def.Finally, "", failure)...)
+	s.runHandlers(ctx, handlers)
+
+	s.o.timeOutRun(ctx, s.run, reason)
+	return cause
+}
+
+// stop cancels in-flight steps and waits for their workers to report back.
+// Steps that completed meanwhile keep their output, the others are
+// cancelled with reason.
+func (s *scheduler) stop(ctx context.Context, reason string, received []stepOutcome) {
+	settle := func(outcome stepOutcome) {
+		if outcome.err == nil {
+			s.complete(ctx, outcome.step, outcome.result)
+			return
+		}
+		s.o.workflowService.CancelStep(ctx, s.run, outcome.step, reason)
+	}
+
+	for _, cancel := range s.inFlight {
+		cancel()
+	}
+	for _, outcome := range received {
+		settle(outcome)
+	}
+	for len(s.inFlight) > 0 {
+		outcome := <-s.outcomes
+		s.release(outcome.step)
+		settle(outcome)
+	}
+}
+
+// abort cancels in-flight steps, marks the remaining steps as cancelled and
+// fails the run. failed is nil when the failure is not tied to a single step.
+func (s *scheduler) abort(ctx context.Context, failed *workflow.StepRun, cause error) error {
+	reason := cause.Error()
+	if failed != nil {
+		reason = fmt.Sprintf("step %s failed", failed.Name)
+	}
+
+	// Cancel in-flight steps and wait for their workers to report back.
+	// Failure handlers already running are left to finish.
+	for _, step := range s.run.RunningSteps() {
+		if cancel, ok := s.inFlight[step.ID]; ok && step.Handler == "" {
+			cancel()
+		}
+	}
+	for len(s.inFlight) > 0 {
+		outcome := <-s.outcomes
+		s.release(outcome.step)
+
+		switch {
+		case outcome.err == nil:
+			s.complete(ctx, outcome.step, outcome.result)
+		case outcome.step.Handler != "":
+			s.handlerFailed(ctx, outcome.step, outcome.err)
+		default:
+			s.o.workflowService.CancelStep(ctx, s.run, outcome.step, "cancelled: "+reason)
+		}
+	}
+
+	// Handlers added before the failure still run
+	handlers := s.pendingHandlers()
+
+	for _, step := range append(append(s.run.PendingSteps(), s.run.AwaitingApprovalSteps()...), s.run.AwaitingInputSteps()...) {
+		if step.Handler != "" && step.IsPending() {
+			continue
+		}
+		s.o.workflowService.CancelStep(ctx, s.run, step, "not run: "+reason)
+	}
+	// Fan-out steps with unfinished items
+	for _, step := range s.run.RunningSteps() {
+		s.o.workflowService.CancelStep(ctx, s.run, step, "cancelled: "+reason)
+	}
+
+	// Compensate for the failure: handlers of the failed step, then of the run
+	failedStep := ""
+	if failed != nil {
+		failedStep = failed.Name
+		if stepDef := s.def.GetStep(failed.Name); stepDef != nil {
+			handlers = append(handlers, s.addHandlers(ctx, workflow.HandlerOnFailure, failed.Name, stepDef.OnFailure, failedStep, cause.Error())...)
+			handlers = append(handlers, s.addHandlers(ctx, workflow.HandlerFinally, failed.Name, stepDef.Finally, failedStep, cause.Error())...)
+		}
+	}
+	handlers = append(handlers, s.addHandlers(ctx, workflow.HandlerOnFailure, "", s.def.OnFailure, failedStep, cause.Error())...)
+	handlers = append(handlers, s.addHandlers(ctx, workflow.HandlerFinally, "", s.def.Finally, failedStep, cause.Error())...)
+	s.runHandlers(ctx, handlers)
+
+	if failed != nil {
+		s.o.workflowService.FailRun(ctx, s.run, fmt.Sprintf("step %s failed: %v", failed.Name, cause), failed.Name)
+	} else {
+		s.o.workflowService.FailRun(ctx, s.run, cause.Error(), "")
+	}
+	s.o.auditService.LogWorkflowFailed(ctx, s.run.WorkflowID.String(), s.run.ID.String(), s.run.Error)
+
+	return cause
+}
+
+// policyWarnings returns the warning messages of a policy result.
+func policyWarnings(result *governance.PolicyResult) []string {
+	if len(result.Warnings) == 0 {
+		return nil
+	}
+	warnings := make([]string, len(result.Warnings))
+	for i, w := range result.Warnings {
+		warnings[i] = w.Message
+	}
+	return warnings
+}
+
+// stepNames returns a comma separated list of step names.
+func stepNames(steps []*workflow.StepRun) string {
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.Name
+	}
+	return strings.Join(names, ", ")
+}
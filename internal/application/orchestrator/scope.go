@@ -0,0 +1,50 @@
+package orchestrator
+
+import (
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/expression"
+)
+
+// newScope builds the expression scope for a workflow run.
+// It exposes steps.<name>.output/status, trigger.*, inputs.* and metadata.*.
+func newScope(run *workflow.WorkflowRun, def *workflow.WorkflowDefinition) expression.Scope {
+	steps := make(map[string]any, len(run.Steps))
+	for _, s := range run.Steps {
+		entry := map[string]any{
+			"status": string(s.Status),
+		}
+		if s.Output != nil {
+			entry["output"] = s.Output
+		}
+		if s.Error != "" {
+			entry["error"] = s.Error
+		}
+		steps[s.Name] = entry
+	}
+
+	trigger := run.TriggerData
+	if trigger == nil {
+		trigger = make(map[string]any)
+	}
+
+	metadata := make(map[string]any)
+	inputs := make(map[string]any)
+	if def != nil {
+		if def.Metadata != nil {
+			metadata = def.Metadata
+		}
+		// Inputs (e.g. bridge run --input) are carried in the trigger data,
+		// which was validated against the declared inputs when the run was
+		// created.
+		if resolved, err := def.ResolveInputs(trigger); err == nil {
+			inputs = resolved
+		}
+	}
+
+	return expression.Scope{
+		"steps":    steps,
+		"trigger":  trigger,
+		"inputs":   inputs,
+		"metadata": metadata,
+	}
+}
@@ -0,0 +1,183 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// executeSubWorkflow executes child, the child run of the sub-workflow step
+// named step. The child run is executed with its own policies and audit
+// trail, and its workflow outputs and the outputs of its steps become the
+// output of the step. A child run interrupted along with the parent run is
+// resumed rather than started again.
+func (o *Orchestrator) executeSubWorkflow(ctx context.Context, run, child *workflow.WorkflowRun, step string, ref config.WorkflowRef) (*StepResult, error) {
+	start := time.Now()
+
+	logger := o.logger.With().
+		Str("run_id", child.ID.String()).
+		Str("workflow", child.WorkflowName).
+		Str("parent_run_id", run.ID.String()).
+		Str("parent_step", step).
+		Logger()
+
+	var err error
+	switch {
+	case child.Status == workflow.RunStatusExecuting:
+		logger.Info().Msg("Resuming sub-workflow run")
+		o.resetInterrupted(ctx, child)
+		err = o.executeSteps(ctx, child, nil, logger)
+	case !child.Status.IsTerminal():
+		err = o.ExecuteWorkflow(ctx, child)
+	}
+
+	// The parent step cannot be paused along with the child run
+	if errors.Is(err, types.ErrApprovalRequired) {
+		reason := "sub-workflow steps cannot wait for approval"
+		o.cancelRun(ctx, child, reason)
+		return nil, fmt.Errorf("sub-workflow %s run %s: %s", ref, child.ID, reason)
+	}
+	if errors.Is(err, types.ErrInputRequired) {
+		reason := "sub-workflow steps cannot wait for input"
+		o.cancelRun(ctx, child, reason)
+		return nil, fmt.Errorf("sub-workflow %s run %s: %s", ref, child.ID, reason)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sub-workflow %s run %s: %w", ref, child.ID, err)
+	}
+
+	outputs := make(map[string]any)
+	var tokens workflow.TokenUsage
+	for _, s := range child.Steps {
+		if s.Parent != "" {
+			continue
+		}
+		if s.Output != nil {
+			outputs[s.Name] = s.Output
+		}
+		tokens.Input += s.TokensIn
+		tokens.Output += s.TokensOut
+		tokens.CostUSD += s.CostUSD
+	}
+	tokens.Total = tokens.Input + tokens.Output
+
+	workflowOutputs := child.Outputs
+	if workflowOutputs == nil {
+		workflowOutputs = make(map[string]any)
+	}
+
+	return &StepResult{
+		Output: map[string]any{
+			"run_id":     child.ID.String(),
+			"workflow":   child.WorkflowName,
+			"version":    child.WorkflowVersion,
+			"status":     string(child.Status),
+			"outputs":    workflowOutputs,
+			"steps":      outputs,
+			"tokens_in":  tokens.Input,
+			"tokens_out": tokens.Output,
+			"cost_usd":   tokens.CostUSD,
+		},
+		Tokens:   tokens,
+		Duration: time.Since(start),
+	}, nil
+}
+
+// childRun returns the child run of a sub-workflow step, with the step input
+// as the child run's inputs. A child run the step started before that
+// completed or is still active is reused, otherwise a new child run limited
+// to budget, the budget the step has left, is created and recorded on the
+// step. It is called from the scheduling goroutine.
+func (o *Orchestrator) childRun(ctx context.Context, run *workflow.WorkflowRun, step *workflow.StepRun, ref config.WorkflowRef, budget *workflow.Budget) (*workflow.WorkflowRun, error) {
+	if step.ChildRunID != "" {
+		child, err := o.workflowService.GetRun(ctx, step.ChildRunID)
+		if err == nil && (child.Status == workflow.RunStatusCompleted || !child.Status.IsTerminal()) {
+			return child, nil
+		}
+	}
+
+	def, err := o.resolveWorkflow(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// A workflow must not run itself through its sub-workflows
+	for ancestor := run; ; {
+		if ancestor.WorkflowName == def.Name {
+			return nil, fmt.Errorf("sub-workflow %s: workflow %s would run itself", ref, def.Name)
+		}
+		if ancestor.ParentRunID == "" {
+			break
+		}
+		if ancestor, err = o.workflowService.GetRun(ctx, ancestor.ParentRunID); err != nil {
+			return nil, fmt.Errorf("failed to load parent run: %w", err)
+		}
+	}
+
+	if _, err := def.ResolveInputs(step.Input); err != nil {
+		return nil, fmt.Errorf("sub-workflow %s: %w", ref, err)
+	}
+
+	child, err := o.workflowService.StartChildRun(ctx, def, run, step, step.Input, budget)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sub-workflow %s: %w", ref, err)
+	}
+
+	step.ChildRunID = child.ID
+	o.workflowService.UpdateStep(ctx, step)
+
+	o.auditService.LogWorkflowStarted(ctx, def.ID.String(), child.ID.String(), child.TriggeredBy)
+
+	o.logger.Info().
+		Str("run_id", child.ID.String()).
+		Str("workflow", def.Name).
+		Str("parent_run_id", run.ID.String()).
+		Str("parent_step", step.Name).
+		Msg("Sub-workflow run created")
+
+	return child, nil
+}
+
+// childUsage returns the usage of the child run of a sub-workflow step. The
+// usage of a child run that failed is read from the run.
+func childUsage(child *workflow.WorkflowRun, result *StepResult) workflow.Usage {
+	if result != nil {
+		return workflow.Usage{Tokens: result.Tokens.Total, CostUSD: result.Tokens.CostUSD}
+	}
+	return child.Usage("")
+}
+
+// resolveWorkflow loads the workflow definition a reference points to: the
+// version it names, or the latest version.
+func (o *Orchestrator) resolveWorkflow(ctx context.Context, ref config.WorkflowRef) (*workflow.WorkflowDefinition, error) {
+	if ref.Version == "" {
+		def, err := o.workflowService.GetWorkflowByName(ctx, ref.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load sub-workflow %s: %w", ref, err)
+		}
+		return def, nil
+	}
+
+	def, err := o.workflowService.GetWorkflowVersion(ctx, ref.Name, ref.Version)
+	if errors.Is(err, types.ErrWorkflowNotFound) {
+		// Name the versions that are registered instead
+		if versions, _ := o.workflowService.ListWorkflowVersions(ctx, ref.Name); len(versions) > 0 {
+			found := make([]string, len(versions))
+			for i, version := range versions {
+				found[i] = version.Version
+			}
+			return nil, fmt.Errorf("failed to load sub-workflow %s: %w: found version %s",
+				ref, types.ErrWorkflowNotFound, strings.Join(found, ", "))
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sub-workflow %s: %w", ref, err)
+	}
+	return def, nil
+}
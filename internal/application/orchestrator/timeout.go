@@ -0,0 +1,100 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// ExpireRuns times out active runs that have passed their deadline or whose
+// pending approval has passed its deadline. Runs executing in this or
+// another live process time out on their own and are left alone. It
+// returns the runs that were timed out.
+func (o *Orchestrator) ExpireRuns(ctx context.Context) ([]*workflow.WorkflowRun, error) {
+	active, err := o.workflowService.ListActiveRuns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active runs: %w", err)
+	}
+
+	expired := make([]*workflow.WorkflowRun, 0)
+	for _, candidate := range active {
+		now := time.Now()
+		if _, ok := candidate.Expired(now); !ok || candidate.IsLeased(o.instanceID, now) {
+			continue
+		}
+
+		o.mu.Lock()
+		_, executing := o.executing[candidate.ID]
+		o.mu.Unlock()
+		if executing {
+			continue
+		}
+
+		// Another process may be executing or expiring the same run
+		err := o.workflowService.AcquireLease(ctx, candidate.ID, o.instanceID, o.leaseTTL)
+		if err != nil && !errors.Is(err, types.ErrRunCancelled) {
+			if errors.Is(err, types.ErrRunLeased) {
+				continue
+			}
+			return expired, fmt.Errorf("failed to lease run %s: %w", candidate.ID, err)
+		}
+
+		run, err := o.workflowService.GetRun(ctx, candidate.ID)
+		if err == nil {
+			if reason, ok := run.Expired(time.Now()); ok {
+				o.timeOutRun(ctx, run, reason)
+				expired = append(expired, run)
+			}
+		}
+		o.workflowService.ReleaseLease(ctx, candidate.ID, o.instanceID)
+		if err != nil {
+			return expired, fmt.Errorf("failed to load run %s: %w", candidate.ID, err)
+		}
+	}
+
+	return expired, nil
+}
+
+// timeOutRun records the timeout of a run without steps in flight. Steps
+// that had not run are skipped.
+func (o *Orchestrator) timeOutRun(ctx context.Context, run *workflow.WorkflowRun, reason string) {
+	if interp, err := o.stateMachine.Start(workflow.RunContext{Run: run}); err == nil {
+		o.stateMachine.Send(interp, workflow.EventTimeout)
+	}
+
+	// Steps left running by a process that died
+	for _, step := range run.RunningSteps() {
+		o.workflowService.CancelStep(ctx, run, step, "run timed out: "+reason)
+	}
+	for _, step := range append(append(run.PendingSteps(), run.AwaitingApprovalSteps()...), run.AwaitingInputSteps()...) {
+		o.workflowService.SkipStep(ctx, run, step, "run timed out: "+reason)
+	}
+
+	o.workflowService.TimeOutRun(ctx, run, reason)
+	o.auditService.LogWorkflowTimedOut(ctx, run.WorkflowID.String(), run.ID.String(), reason)
+
+	o.logger.Warn().
+		Str("run_id", run.ID.String()).
+		Str("reason", reason).
+		Msg("Workflow timed out")
+}
+
+// withDeadline bounds execution of a run by its deadline. When the
+// deadline passes, the context is cancelled with types.ErrRunTimedOut.
+func withDeadline(ctx context.Context, run *workflow.WorkflowRun) (context.Context, context.CancelFunc) {
+	if run.Deadline == nil {
+		return ctx, func() {}
+	}
+	cause := fmt.Errorf("%w: %s", types.ErrRunTimedOut, workflow.DeadlineReason(*run.Deadline))
+	return context.WithDeadlineCause(ctx, *run.Deadline, cause)
+}
+
+// timeoutReason returns the reason given for a run timeout.
+func timeoutReason(cause error) string {
+	return strings.TrimPrefix(cause.Error(), types.ErrRunTimedOut.Error()+": ")
+}
@@ -23,6 +23,9 @@ type Agent struct {
 	Temperature  float64
 	Capabilities []string
 	Metadata     map[string]any
+
+	// ResponseSchema asks the provider for JSON content matching the schema.
+	ResponseSchema map[string]any
 }
 
 // AgentResponse represents the result of an agent invocation.
@@ -83,6 +86,8 @@ func (r *runner) Execute(ctx context.Context, agent *Agent, messages []llm.Messa
 		Tools:        agent.Tools,
 		MaxTokens:    agent.MaxTokens,
 		Temperature:  agent.Temperature,
+
+		ResponseSchema: agent.ResponseSchema,
 	}
 
 	// Execute completion
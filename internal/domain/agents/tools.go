@@ -0,0 +1,78 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/llm"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// ToolHandler executes a tool call with its arguments and returns the result.
+type ToolHandler func(ctx context.Context, args map[string]any) (any, error)
+
+// registeredTool pairs a tool definition with its handler.
+type registeredTool struct {
+	tool    llm.Tool
+	handler ToolHandler
+}
+
+// ToolSet dispatches tool calls requested by agents to tool backends.
+type ToolSet struct {
+	mu    sync.RWMutex
+	tools map[string]registeredTool
+}
+
+// NewToolSet creates an empty tool set.
+func NewToolSet() *ToolSet {
+	return &ToolSet{
+		tools: make(map[string]registeredTool),
+	}
+}
+
+// Register adds a tool backend, replacing any tool with the same name.
+func (s *ToolSet) Register(tool llm.Tool, handler ToolHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tools[tool.Name] = registeredTool{tool: tool, handler: handler}
+}
+
+// Has reports whether a tool is registered.
+func (s *ToolSet) Has(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.tools[name]
+	return ok
+}
+
+// Tools returns the definitions of all registered tools, sorted by name.
+func (s *ToolSet) Tools() []llm.Tool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tools := make([]llm.Tool, 0, len(s.tools))
+	for _, t := range s.tools {
+		tools = append(tools, t.tool)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// Call executes a tool call.
+func (s *ToolSet) Call(ctx context.Context, call llm.ToolCall) (any, error) {
+	s.mu.RLock()
+	t, ok := s.tools[call.Name]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", types.ErrMCPToolNotFound, call.Name)
+	}
+
+	args := call.Arguments
+	if args == nil {
+		args = make(map[string]any)
+	}
+	return t.handler(ctx, args)
+}
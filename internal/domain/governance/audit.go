@@ -2,6 +2,7 @@ package governance
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,7 +15,10 @@ const (
 	AuditEventWorkflowStarted   AuditEventType = "workflow.started"
 	AuditEventWorkflowCompleted AuditEventType = "workflow.completed"
 	AuditEventWorkflowFailed    AuditEventType = "workflow.failed"
+	AuditEventWorkflowCancelled AuditEventType = "workflow.cancelled"
+	AuditEventWorkflowTimedOut  AuditEventType = "workflow.timed_out"
 	AuditEventStepExecuted      AuditEventType = "step.executed"
+	AuditEventStepSkipped       AuditEventType = "step.skipped"
 	AuditEventPolicyEvaluated   AuditEventType = "policy.evaluated"
 	AuditEventPolicyViolation   AuditEventType = "policy.violation"
 	AuditEventApprovalRequested AuditEventType = "approval.requested"
@@ -22,6 +26,10 @@ const (
 	AuditEventApprovalRejected  AuditEventType = "approval.rejected"
 	AuditEventToolInvoked       AuditEventType = "tool.invoked"
 	AuditEventAgentCalled       AuditEventType = "agent.called"
+	AuditEventActionInvoked     AuditEventType = "action.invoked"
+	AuditEventBudgetExceeded    AuditEventType = "budget.exceeded"
+	AuditEventInputRequested    AuditEventType = "input.requested"
+	AuditEventInputReceived     AuditEventType = "input.received"
 )
 
 // AuditEvent represents an auditable event in the system.
@@ -85,6 +93,7 @@ type AuditFilter struct {
 
 // InMemoryAuditLogger is an in-memory implementation of AuditLogger.
 type InMemoryAuditLogger struct {
+	mu     sync.RWMutex
 	events []*AuditEvent
 }
 
@@ -97,12 +106,18 @@ func NewInMemoryAuditLogger() *InMemoryAuditLogger {
 
 // Log logs an audit event.
 func (l *InMemoryAuditLogger) Log(ctx context.Context, event *AuditEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	l.events = append(l.events, event)
 	return nil
 }
 
 // Query queries audit events.
 func (l *InMemoryAuditLogger) Query(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
 	result := make([]*AuditEvent, 0)
 
 	for _, event := range l.events {
@@ -193,6 +208,22 @@ func (s *AuditService) LogWorkflowFailed(ctx context.Context, workflowID, runID,
 	return s.logger.Log(ctx, event)
 }
 
+// LogWorkflowCancelled logs a workflow cancellation event.
+func (s *AuditService) LogWorkflowCancelled(ctx context.Context, workflowID, runID, reason string) error {
+	event := NewAuditEvent(AuditEventWorkflowCancelled, "system", "workflow_run", runID, "cancel").
+		WithDetails("workflow_id", workflowID).
+		WithDetails("reason", reason)
+	return s.logger.Log(ctx, event)
+}
+
+// LogWorkflowTimedOut logs a workflow timeout event.
+func (s *AuditService) LogWorkflowTimedOut(ctx context.Context, workflowID, runID, reason string) error {
+	event := NewAuditEvent(AuditEventWorkflowTimedOut, "system", "workflow_run", runID, "timeout").
+		WithDetails("workflow_id", workflowID).
+		WithDetails("reason", reason)
+	return s.logger.Log(ctx, event)
+}
+
 // LogStepExecuted logs a step execution event.
 func (s *AuditService) LogStepExecuted(ctx context.Context, runID, stepID, stepName string, tokensUsed int) error {
 	event := NewAuditEvent(AuditEventStepExecuted, "system", "step", stepID, "execute").
@@ -202,6 +233,15 @@ func (s *AuditService) LogStepExecuted(ctx context.Context, runID, stepID, stepN
 	return s.logger.Log(ctx, event)
 }
 
+// LogStepSkipped logs a step skipped event.
+func (s *AuditService) LogStepSkipped(ctx context.Context, runID, stepID, stepName, reason string) error {
+	event := NewAuditEvent(AuditEventStepSkipped, "system", "step", stepID, "skip").
+		WithDetails("run_id", runID).
+		WithDetails("step_name", stepName).
+		WithDetails("reason", reason)
+	return s.logger.Log(ctx, event)
+}
+
 // LogPolicyEvaluated logs a policy evaluation event.
 func (s *AuditService) LogPolicyEvaluated(ctx context.Context, runID, policyName string, allowed bool) error {
 	event := NewAuditEvent(AuditEventPolicyEvaluated, "system", "workflow_run", runID, "evaluate").
@@ -242,3 +282,54 @@ func (s *AuditService) LogAgentCalled(ctx context.Context, runID, stepID, agentN
 		WithDetails("tokens_out", tokensOut)
 	return s.logger.Log(ctx, event)
 }
+
+// LogToolInvoked logs a tool invocation requested by an agent.
+func (s *AuditService) LogToolInvoked(ctx context.Context, runID, stepID, agentName, toolName string, allowed bool, errorMsg string) error {
+	event := NewAuditEvent(AuditEventToolInvoked, agentName, "step", stepID, "invoke_tool").
+		WithDetails("run_id", runID).
+		WithDetails("tool_name", toolName).
+		WithDetails("allowed", allowed)
+	if errorMsg != "" {
+		event.WithDetails("error", errorMsg)
+	}
+	return s.logger.Log(ctx, event)
+}
+
+// LogBudgetExceeded logs a step stopped because a budget was used up, and
+// whether it awaits approval or failed.
+func (s *AuditService) LogBudgetExceeded(ctx context.Context, runID, stepID, stepName, reason, action string) error {
+	event := NewAuditEvent(AuditEventBudgetExceeded, "system", "step", stepID, action).
+		WithDetails("run_id", runID).
+		WithDetails("step_name", stepName).
+		WithDetails("reason", reason)
+	return s.logger.Log(ctx, event)
+}
+
+// LogInputRequested logs a human input step waiting for an answer.
+func (s *AuditService) LogInputRequested(ctx context.Context, runID, stepID, stepName string) error {
+	event := NewAuditEvent(AuditEventInputRequested, "system", "step", stepID, "request_input").
+		WithDetails("run_id", runID).
+		WithDetails("step_name", stepName)
+	return s.logger.Log(ctx, event)
+}
+
+// LogInputReceived logs the answer to a human input step. Only the answered
+// fields are recorded, the answer itself is kept in the step output.
+func (s *AuditService) LogInputReceived(ctx context.Context, runID, stepID, stepName, respondedBy string, fields []string) error {
+	event := NewAuditEvent(AuditEventInputReceived, respondedBy, "step", stepID, "respond").
+		WithDetails("run_id", runID).
+		WithDetails("step_name", stepName).
+		WithDetails("fields", fields)
+	return s.logger.Log(ctx, event)
+}
+
+// LogActionInvoked logs the invocation of a built-in action by a step.
+func (s *AuditService) LogActionInvoked(ctx context.Context, runID, stepID, actionName, errorMsg string) error {
+	event := NewAuditEvent(AuditEventActionInvoked, "system", "step", stepID, "invoke_action").
+		WithDetails("run_id", runID).
+		WithDetails("action_name", actionName)
+	if errorMsg != "" {
+		event.WithDetails("error", errorMsg)
+	}
+	return s.logger.Log(ctx, event)
+}
@@ -294,6 +294,7 @@ func TestAuditEventTypes(t *testing.T) {
 		AuditEventWorkflowStarted:   "workflow.started",
 		AuditEventWorkflowCompleted: "workflow.completed",
 		AuditEventWorkflowFailed:    "workflow.failed",
+		AuditEventWorkflowTimedOut:  "workflow.timed_out",
 		AuditEventStepExecuted:      "step.executed",
 		AuditEventPolicyEvaluated:   "policy.evaluated",
 		AuditEventPolicyViolation:   "policy.violation",
@@ -302,6 +303,10 @@ func TestAuditEventTypes(t *testing.T) {
 		AuditEventApprovalRejected:  "approval.rejected",
 		AuditEventToolInvoked:       "tool.invoked",
 		AuditEventAgentCalled:       "agent.called",
+		AuditEventActionInvoked:     "action.invoked",
+		AuditEventBudgetExceeded:    "budget.exceeded",
+		AuditEventInputRequested:    "input.requested",
+		AuditEventInputReceived:     "input.received",
 	}
 
 	for eventType, expected := range types {
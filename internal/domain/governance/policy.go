@@ -50,6 +50,17 @@ type PolicyInput struct {
 	Capabilities []string       `json:"capabilities,omitempty"`
 	Context      map[string]any `json:"context,omitempty"`
 	Metadata     map[string]any `json:"metadata,omitempty"`
+	Budget       *BudgetInput   `json:"budget,omitempty"`
+}
+
+// BudgetInput describes the usage of a run and the budget a step has left.
+// The remaining limits are the smallest left by the budgets of the run, its
+// workflow and the step, and are nil when no budget sets them.
+type BudgetInput struct {
+	TokensUsed       int      `json:"tokens_used"`
+	CostUSD          float64  `json:"cost_usd"`
+	TokensRemaining  *int     `json:"tokens_remaining,omitempty"`
+	CostRemainingUSD *float64 `json:"cost_remaining_usd,omitempty"`
 }
 
 // PolicyResult contains the result of policy evaluation.
@@ -0,0 +1,80 @@
+package workflow
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// ActionHandler executes a built-in action with the resolved input of a
+// step and returns the step output.
+type ActionHandler func(ctx context.Context, input map[string]any) (map[string]any, error)
+
+// Action is a deterministic operation a step can use in place of an agent,
+// such as fetching the files of a pull request.
+type Action struct {
+	Name         string // Name steps use the action by, such as github.get_pr_files
+	Description  string
+	Capabilities []string // Capabilities policies evaluate the step with
+	Handler      ActionHandler
+}
+
+// ActionSet holds the built-in actions available to steps.
+type ActionSet struct {
+	mu      sync.RWMutex
+	actions map[string]Action
+}
+
+// NewActionSet creates an empty action set.
+func NewActionSet() *ActionSet {
+	return &ActionSet{
+		actions: make(map[string]Action),
+	}
+}
+
+// Register adds an action, replacing any action with the same name.
+func (s *ActionSet) Register(action Action) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions[action.Name] = action
+}
+
+// Get returns an action by name.
+func (s *ActionSet) Get(name string) (Action, bool) {
+	if s == nil {
+		return Action{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	action, ok := s.actions[name]
+	return action, ok
+}
+
+// Names returns the names of all registered actions, sorted.
+func (s *ActionSet) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.actions))
+	for name := range s.actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ActionError is a failure of an action reported by a remote service, such
+// as an HTTP error status. The status classifies the failure for retries.
+type ActionError struct {
+	Action     string
+	StatusCode int
+	Err        error
+}
+
+func (e *ActionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ActionError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,127 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// Budget limits the tokens and cost of the agent calls of a run or step.
+// A zero limit does not limit usage.
+type Budget struct {
+	MaxTokens  int     `json:"max_tokens,omitempty"`
+	MaxCostUSD float64 `json:"max_cost_usd,omitempty"`
+	OnExceeded string  `json:"on_exceeded,omitempty"` // See the config.BudgetOnExceeded constants
+}
+
+// Usage is the tokens and cost used by agent calls.
+type Usage struct {
+	Tokens  int
+	CostUSD float64
+}
+
+// Add returns the sum of two usages.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{Tokens: u.Tokens + other.Tokens, CostUSD: u.CostUSD + other.CostUSD}
+}
+
+// newBudget creates a budget from its config, nil without one.
+func newBudget(cfg *config.BudgetConfig) *Budget {
+	if cfg == nil {
+		return nil
+	}
+	onExceeded := cfg.OnExceeded
+	if onExceeded == "" {
+		onExceeded = config.BudgetOnExceededFail
+	}
+	return &Budget{
+		MaxTokens:  cfg.MaxTokens,
+		MaxCostUSD: cfg.MaxCostUSD,
+		OnExceeded: onExceeded,
+	}
+}
+
+// Exhausted returns true if usage has reached a limit of the budget, so
+// that no further agent call may be made.
+func (b *Budget) Exhausted(usage Usage) bool {
+	return b.MaxTokens > 0 && usage.Tokens >= b.MaxTokens ||
+		b.MaxCostUSD > 0 && usage.CostUSD >= b.MaxCostUSD
+}
+
+// Approvable returns true if a step that used up the budget is paused for
+// approval rather than failed.
+func (b *Budget) Approvable() bool {
+	return b.OnExceeded == config.BudgetOnExceededApprove
+}
+
+// Remaining returns the budget left after usage, with the same action when
+// it is used up. Limits already reached are left at their smallest positive
+// value, as a zero limit does not limit usage.
+func (b *Budget) Remaining(usage Usage) *Budget {
+	remaining := &Budget{OnExceeded: b.OnExceeded}
+	if b.MaxTokens > 0 {
+		remaining.MaxTokens = max(b.MaxTokens-usage.Tokens, 1)
+	}
+	if b.MaxCostUSD > 0 {
+		remaining.MaxCostUSD = max(b.MaxCostUSD-usage.CostUSD, 1e-9)
+	}
+	return remaining
+}
+
+// String describes the limits of the budget.
+func (b *Budget) String() string {
+	limits := make([]string, 0, 2)
+	if b.MaxTokens > 0 {
+		limits = append(limits, fmt.Sprintf("%d tokens", b.MaxTokens))
+	}
+	if b.MaxCostUSD > 0 {
+		limits = append(limits, fmt.Sprintf("$%.2f", b.MaxCostUSD))
+	}
+	return strings.Join(limits, ", ")
+}
+
+// BudgetError reports a budget used up before an agent call.
+type BudgetError struct {
+	Scope  string // run, workflow or step name
+	Budget *Budget
+	Usage  Usage
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%s: %s budget of %s used up (%d tokens, $%.4f)",
+		types.ErrBudgetExceeded, e.Scope, e.Budget, e.Usage.Tokens, e.Usage.CostUSD)
+}
+
+func (e *BudgetError) Unwrap() error {
+	return types.ErrBudgetExceeded
+}
+
+// Usage returns the tokens and cost used by the steps of the run. Steps
+// named name only are counted when name is not empty, including the items
+// of a fan-out step.
+func (r *WorkflowRun) Usage(name string) Usage {
+	var usage Usage
+	for _, step := range r.Steps {
+		if name != "" && step.DefinitionName() != name {
+			continue
+		}
+		// Fan-out steps sum up the usage of their items
+		if len(r.ChildSteps(step.Name)) > 0 {
+			continue
+		}
+		usage = usage.Add(step.Usage())
+	}
+	return usage
+}
+
+// Usage returns the tokens and cost used by the step. Steps served from the
+// step cache made no agent calls and use nothing, their tokens and cost are
+// those of the run that cached the result.
+func (s *StepRun) Usage() Usage {
+	if s.CacheHit {
+		return Usage{}
+	}
+	return Usage{Tokens: s.TokensIn + s.TokensOut, CostUSD: s.CostUSD}
+}
@@ -0,0 +1,44 @@
+package workflow
+
+import (
+	"time"
+
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// CachedResult is a step result stored for reuse under the hash of the
+// agent configuration, prompt and tools that produced it. The tokens and
+// cost are those of the agent calls that produced it.
+type CachedResult struct {
+	Key       string         `json:"key"`
+	Output    map[string]any `json:"output"`
+	TokensIn  int            `json:"tokens_in"`
+	TokensOut int            `json:"tokens_out"`
+	CostUSD   float64        `json:"cost_usd"`
+	RunID     types.RunID    `json:"run_id"` // Run the result was produced by
+	Step      string         `json:"step"`
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+// NewCachedResult creates a cached result of a completed step that expires
+// after ttl.
+func NewCachedResult(key string, step *StepRun, ttl time.Duration) *CachedResult {
+	now := time.Now()
+	return &CachedResult{
+		Key:       key,
+		Output:    step.Output,
+		TokensIn:  step.TokensIn,
+		TokensOut: step.TokensOut,
+		CostUSD:   step.CostUSD,
+		RunID:     step.RunID,
+		Step:      step.Name,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+// Expired returns true if the result may no longer be reused at now.
+func (c *CachedResult) Expired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
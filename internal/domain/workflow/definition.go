@@ -3,26 +3,40 @@ package workflow
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/expression"
 	"github.com/felixgeelhaar/bridge/pkg/types"
 )
 
 // WorkflowDefinition is the aggregate root for workflow definitions.
 // It represents a reusable template for workflow execution.
 type WorkflowDefinition struct {
-	ID          types.WorkflowID
-	Name        string
-	Version     string
-	Description string
-	Steps       []StepDefinition
-	Triggers    []Trigger
-	Policies    []PolicyRef
-	Checksum    string
-	Metadata    map[string]any
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID               types.WorkflowID
+	Name             string
+	Version          string
+	Description      string
+	Inputs           map[string]config.InputConfig // Inputs the workflow accepts
+	Steps            []StepDefinition
+	OnFailure        []StepDefinition // Handlers run when the run fails
+	Finally          []StepDefinition // Handlers run when the run finishes
+	Outputs          map[string]any   // Run results, evaluated on completion
+	MaxParallel      int
+	Timeout          time.Duration // Maximum run duration, 0 for none
+	ApprovalTimeout  time.Duration // Maximum wait for each approval, 0 for none
+	ConcurrencyGroup string        // Expression naming the group runs execute one at a time in
+	CancelInProgress bool          // Runs cancel earlier runs in their group instead of queueing
+	Budget           *Budget       // Usage limit of all runs together, nil for none
+	Triggers         []Trigger
+	Policies         []PolicyRef
+	Checksum         string
+	Metadata         map[string]any
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 // StepDefinition defines a step template within a workflow.
@@ -33,9 +47,63 @@ type StepDefinition struct {
 	Output           string
 	RequiresApproval bool
 	Timeout          time.Duration
-	Retries          int
+	Retry            RetryPolicy
 	Condition        string
 	DependsOn        []string
+	OutputSchema     map[string]any // JSON Schema the step output must match
+	ApprovalTiming   ApprovalTiming
+	Foreach          any              // List or expression the step runs once per item of
+	Matrix           map[string]any   // Named lists the step runs once per combination of
+	MaxParallel      int              // Concurrent item runs, 0 for unlimited
+	Uses             string           // Built-in action the step runs, or workflow it runs as a child run
+	CacheTTL         time.Duration    // How long results are reused by later runs, 0 without caching
+	Budget           *Budget          // Usage limit of the step in a run, nil for none
+	HumanInput       *HumanInput      // Question the step asks a user, nil for other steps
+	OnFailure        []StepDefinition // Handlers run when the step fails
+	Finally          []StepDefinition // Handlers run when the step finishes
+}
+
+// IsFanOut returns true if the step runs once per foreach item or matrix
+// combination.
+func (s *StepDefinition) IsFanOut() bool {
+	return s.Foreach != nil || s.Matrix != nil
+}
+
+// WorkflowRef returns the workflow a sub-workflow step runs. It returns
+// false for steps executed by an agent.
+func (s *StepDefinition) WorkflowRef() (config.WorkflowRef, bool) {
+	if s.Uses == "" {
+		return config.WorkflowRef{}, false
+	}
+	ref, err := config.ParseWorkflowRef(s.Uses)
+	return ref, err == nil
+}
+
+// Action returns the built-in action an action step runs. It returns false
+// for steps executed by an agent or a sub-workflow.
+func (s *StepDefinition) Action() (string, bool) {
+	return s.Uses, config.IsAction(s.Uses)
+}
+
+// ApprovalTiming controls when a step that requires approval is paused.
+type ApprovalTiming string
+
+const (
+	// ApprovalBefore pauses the run before the step executes.
+	ApprovalBefore ApprovalTiming = "before"
+	// ApprovalAfter pauses the run after the step executes, before its
+	// dependents run, so reviewers can inspect the step output.
+	ApprovalAfter ApprovalTiming = "after"
+)
+
+// ApprovesAfter returns true if the step is approved after it executes.
+func (s *StepDefinition) ApprovesAfter() bool {
+	return s.RequiresApproval && s.ApprovalTiming == ApprovalAfter
+}
+
+// ApprovesBefore returns true if the step is approved before it executes.
+func (s *StepDefinition) ApprovesBefore() bool {
+	return s.RequiresApproval && s.ApprovalTiming != ApprovalAfter
 }
 
 // Trigger defines when a workflow should be executed.
@@ -76,7 +144,9 @@ func NewWorkflowDefinition(cfg *config.WorkflowConfig) (*WorkflowDefinition, err
 		Name:        cfg.Name,
 		Version:     cfg.Version,
 		Description: cfg.Description,
+		Inputs:      cfg.Inputs,
 		Steps:       make([]StepDefinition, 0, len(cfg.Steps)),
+		MaxParallel: cfg.MaxParallel,
 		Triggers:    make([]Trigger, 0, len(cfg.Triggers)),
 		Policies:    make([]PolicyRef, 0, len(cfg.Policies)),
 		Metadata:    cfg.Metadata,
@@ -86,26 +156,20 @@ func NewWorkflowDefinition(cfg *config.WorkflowConfig) (*WorkflowDefinition, err
 
 	// Convert steps
 	for _, s := range cfg.Steps {
-		timeout := 5 * time.Minute // default
-		if s.Timeout != "" {
-			d, err := time.ParseDuration(s.Timeout)
-			if err == nil {
-				timeout = d
-			}
-		}
+		def.Steps = append(def.Steps, newStepDefinition(s))
+	}
+	def.OnFailure = newStepDefinitions(cfg.OnFailure)
+	def.Finally = newStepDefinitions(cfg.Finally)
+	def.Outputs = cfg.Outputs
 
-		def.Steps = append(def.Steps, StepDefinition{
-			Name:             s.Name,
-			AgentID:          s.Agent,
-			Input:            s.Input,
-			Output:           s.Output,
-			RequiresApproval: s.RequiresApproval,
-			Timeout:          timeout,
-			Retries:          s.Retries,
-			Condition:        s.Condition,
-			DependsOn:        s.DependsOn,
-		})
+	// Durations are checked by Validate
+	def.Timeout, _ = parseOptionalDuration(cfg.Timeout)
+	def.ApprovalTimeout, _ = parseOptionalDuration(cfg.ApprovalTimeout)
+	if cfg.Concurrency != nil {
+		def.ConcurrencyGroup = cfg.Concurrency.Group
+		def.CancelInProgress = cfg.Concurrency.CancelInProgress
 	}
+	def.Budget = newBudget(cfg.Budget)
 
 	// Convert triggers
 	for _, t := range cfg.Triggers {
@@ -132,17 +196,133 @@ func NewWorkflowDefinition(cfg *config.WorkflowConfig) (*WorkflowDefinition, err
 	return def, nil
 }
 
-// calculateChecksum generates a SHA256 checksum of the workflow definition.
+// newStepDefinition converts a step config into a step definition.
+func newStepDefinition(s config.StepConfig) StepDefinition {
+	timeout := 5 * time.Minute // default
+	if s.Timeout != "" {
+		d, err := time.ParseDuration(s.Timeout)
+		if err == nil {
+			timeout = d
+		}
+	}
+
+	var cacheTTL time.Duration
+	if s.Cache != nil {
+		cacheTTL, _ = parseOptionalDuration(s.Cache.TTL)
+	}
+
+	return StepDefinition{
+		Name:             s.Name,
+		AgentID:          s.Agent,
+		Input:            s.Input,
+		Output:           s.Output,
+		RequiresApproval: s.RequiresApproval,
+		Timeout:          timeout,
+		Retry:            newRetryPolicy(s.Retries, s.Retry),
+		Condition:        s.Condition,
+		DependsOn:        s.DependsOn,
+		OutputSchema:     s.OutputSchema,
+		ApprovalTiming:   ApprovalTiming(s.ApprovalTiming),
+		Foreach:          s.Foreach,
+		Matrix:           s.Matrix,
+		MaxParallel:      s.MaxParallel,
+		Uses:             s.Uses,
+		CacheTTL:         cacheTTL,
+		Budget:           newBudget(s.Budget),
+		HumanInput:       newHumanInput(s),
+		OnFailure:        newStepDefinitions(s.OnFailure),
+		Finally:          newStepDefinitions(s.Finally),
+	}
+}
+
+// parseOptionalDuration parses a duration, returning 0 for an empty value.
+func parseOptionalDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// newStepDefinitions converts a list of handler step configs.
+func newStepDefinitions(configs []config.StepConfig) []StepDefinition {
+	if len(configs) == 0 {
+		return nil
+	}
+	defs := make([]StepDefinition, len(configs))
+	for i, s := range configs {
+		defs[i] = newStepDefinition(s)
+	}
+	return defs
+}
+
+// calculateChecksum generates a SHA256 checksum of the content of the
+// workflow definition. The definition is hashed in its JSON encoding, which
+// orders map keys, so that the checksum does not depend on how the workflow
+// file was laid out. The ID and timestamps of the stored definition are not
+// part of its content.
 func (d *WorkflowDefinition) calculateChecksum() string {
-	data, _ := (&config.WorkflowConfig{
-		Name:        d.Name,
-		Version:     d.Version,
-		Description: d.Description,
-	}).ToYAML()
+	content := *d
+	content.ID = ""
+	content.Checksum = ""
+	content.CreatedAt = time.Time{}
+	content.UpdatedAt = time.Time{}
+
+	data, _ := json.Marshal(content)
 	hash := sha256.Sum256(data)
 	return hex.EncodeToString(hash[:])
 }
 
+// ResolveInputs validates run inputs against the declared inputs and returns
+// them with types coerced and defaults filled in. Values that are not
+// declared are left out. A workflow that declares no inputs takes all values
+// as its inputs.
+func (d *WorkflowDefinition) ResolveInputs(values map[string]any) (map[string]any, error) {
+	if len(d.Inputs) == 0 {
+		inputs := make(map[string]any, len(values))
+		for k, v := range values {
+			inputs[k] = v
+		}
+		return inputs, nil
+	}
+
+	resolved, err := config.ResolveInputs(d.Inputs, values)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", types.ErrRunInputInvalid, err)
+	}
+	return resolved, nil
+}
+
+// ResolveConcurrencyGroup resolves the concurrency group of a run from its
+// trigger data and inputs. It returns an empty group for workflows without
+// concurrency settings.
+func (d *WorkflowDefinition) ResolveConcurrencyGroup(triggerData map[string]any) (string, error) {
+	if d.ConcurrencyGroup == "" {
+		return "", nil
+	}
+
+	if triggerData == nil {
+		triggerData = make(map[string]any)
+	}
+	inputs, err := d.ResolveInputs(triggerData)
+	if err != nil {
+		return "", err
+	}
+	metadata := d.Metadata
+	if metadata == nil {
+		metadata = make(map[string]any)
+	}
+
+	group, err := expression.RenderString(d.ConcurrencyGroup, expression.Scope{
+		"trigger":  triggerData,
+		"inputs":   inputs,
+		"metadata": metadata,
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: concurrency group: %w", types.ErrRunInputInvalid, err)
+	}
+	return fmt.Sprint(group), nil
+}
+
 // RequiresApproval returns true if any step requires approval.
 func (d *WorkflowDefinition) RequiresApproval() bool {
 	for _, s := range d.Steps {
@@ -153,12 +333,81 @@ func (d *WorkflowDefinition) RequiresApproval() bool {
 	return false
 }
 
-// GetStep returns a step by name.
+// GetStep returns a step or a failure handler step by name.
 func (d *WorkflowDefinition) GetStep(name string) *StepDefinition {
+	if step := findStep(d.Steps, name); step != nil {
+		return step
+	}
+	if step := findStep(d.OnFailure, name); step != nil {
+		return step
+	}
+	if step := findStep(d.Finally, name); step != nil {
+		return step
+	}
 	for i := range d.Steps {
-		if d.Steps[i].Name == name {
-			return &d.Steps[i]
+		if step := findStep(d.Steps[i].OnFailure, name); step != nil {
+			return step
+		}
+		if step := findStep(d.Steps[i].Finally, name); step != nil {
+			return step
 		}
 	}
 	return nil
 }
+
+func findStep(steps []StepDefinition, name string) *StepDefinition {
+	for i := range steps {
+		if steps[i].Name == name {
+			return &steps[i]
+		}
+	}
+	return nil
+}
+
+// Dependencies returns the names of the steps that must finish before the
+// named step can run: its explicit depends_on entries plus every step
+// referenced through ${{ steps.<name> }} in its input, condition, foreach
+// list, matrix or prompt.
+func (d *WorkflowDefinition) Dependencies(name string) []string {
+	step := d.GetStep(name)
+	if step == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	deps := make([]string, 0, len(step.DependsOn))
+	add := func(dep string) {
+		if dep == name || seen[dep] || findStep(d.Steps, dep) == nil {
+			return
+		}
+		seen[dep] = true
+		deps = append(deps, dep)
+	}
+
+	for _, dep := range step.DependsOn {
+		add(dep)
+	}
+
+	// Invalid expressions are reported when the step executes
+	refs, _ := expression.ReferencesIn(step.Input)
+	for _, value := range []any{step.Foreach, step.Matrix} {
+		fanOutRefs, _ := expression.ReferencesIn(value)
+		refs = append(refs, fanOutRefs...)
+	}
+	if step.Condition != "" {
+		condRefs, _ := expression.ReferencesExpr(step.Condition)
+		refs = append(refs, condRefs...)
+	}
+	if step.HumanInput != nil {
+		promptRefs, _ := expression.References(step.HumanInput.Prompt)
+		refs = append(refs, promptRefs...)
+	}
+	for _, ref := range refs {
+		parts := strings.SplitN(ref, ".", 3)
+		if len(parts) >= 2 && parts[0] == "steps" {
+			add(parts[1])
+		}
+	}
+
+	return deps
+}
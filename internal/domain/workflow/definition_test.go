@@ -1,9 +1,12 @@
 package workflow
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
 )
 
 func TestNewWorkflowDefinition(t *testing.T) {
@@ -140,3 +143,237 @@ func TestWorkflowDefinition_GetStep(t *testing.T) {
 		t.Error("GetStep() should return nil for non-existent step")
 	}
 }
+
+func TestWorkflowDefinition_Checksum(t *testing.T) {
+	newDef := func(input map[string]any, agent string) *WorkflowDefinition {
+		t.Helper()
+		def, err := NewWorkflowDefinition(&config.WorkflowConfig{
+			Name:    "test",
+			Version: "1.0",
+			Steps: []config.StepConfig{
+				{Name: "step1", Agent: agent, Input: input},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create definition: %v", err)
+		}
+		return def
+	}
+
+	base := newDef(map[string]any{"a": 1, "b": "two", "c": []any{3}}, "agent1")
+
+	tests := []struct {
+		name  string
+		def   *WorkflowDefinition
+		equal bool
+	}{
+		{"same content", newDef(map[string]any{"c": []any{3}, "b": "two", "a": 1}, "agent1"), true},
+		{"changed input", newDef(map[string]any{"a": 1, "b": "three", "c": []any{3}}, "agent1"), false},
+		{"changed agent", newDef(map[string]any{"a": 1, "b": "two", "c": []any{3}}, "agent2"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.def.Checksum == base.Checksum; got != tt.equal {
+				t.Errorf("Checksum equal = %v, want %v", got, tt.equal)
+			}
+		})
+	}
+}
+
+func TestWorkflowDefinition_Dependencies(t *testing.T) {
+	cfg := &config.WorkflowConfig{
+		Name:    "test",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "fetch", Agent: "agent1"},
+			{Name: "scan", Agent: "agent1", DependsOn: []string{"fetch"}},
+			{
+				Name:      "review",
+				Agent:     "agent2",
+				Input:     map[string]any{"files": "${{ steps.fetch.output.files }}", "nested": []any{"${{ steps.scan.output }}"}},
+				Condition: "steps.scan.status == 'completed' && trigger.pr.draft == false",
+				DependsOn: []string{"fetch"},
+			},
+			{Name: "confirm", Type: config.StepTypeHumanInput, Prompt: "Post ${{ steps.review.output.summary }}?"},
+		},
+	}
+
+	def, err := NewWorkflowDefinition(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create definition: %v", err)
+	}
+
+	tests := []struct {
+		step string
+		want []string
+	}{
+		{"fetch", []string{}},
+		{"scan", []string{"fetch"}},
+		{"review", []string{"fetch", "scan"}},
+		{"confirm", []string{"review"}},
+	}
+
+	for _, tt := range tests {
+		got := def.Dependencies(tt.step)
+		if len(got) != len(tt.want) {
+			t.Errorf("Dependencies(%s) = %v, want %v", tt.step, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Dependencies(%s) = %v, want %v", tt.step, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestHumanInput_Answer(t *testing.T) {
+	form := &HumanInput{Prompt: "Ship?", Form: map[string]config.InputConfig{
+		"ship":  {Type: config.InputBool, Required: true},
+		"notes": {Default: "none"},
+	}}
+	freeForm := &HumanInput{Prompt: "Anything to add?"}
+
+	tests := []struct {
+		name    string
+		input   *HumanInput
+		fields  map[string]any
+		want    map[string]any
+		wantErr bool
+	}{
+		{"form", form, map[string]any{"ship": "true"}, map[string]any{"ship": true, "notes": "none"}, false},
+		{"missing required field", form, map[string]any{"notes": "later"}, nil, true},
+		{"invalid field type", form, map[string]any{"ship": "maybe"}, nil, true},
+		{"unknown field", form, map[string]any{"ship": "true", "reason": "ready"}, nil, true},
+		{"free-form", freeForm, map[string]any{"reason": "ready"}, map[string]any{"reason": "ready"}, false},
+		{"empty free-form", freeForm, map[string]any{}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.input.Answer(tt.fields)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Answer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, types.ErrAnswerInvalid) {
+					t.Errorf("Answer() error = %v, want ErrAnswerInvalid", err)
+				}
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Answer() = %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("Answer()[%s] = %v, want %v", key, got[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	cfg := &config.WorkflowConfig{
+		Name:    "retry",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "none", Agent: "agent1"},
+			{Name: "shorthand", Agent: "agent1", Retries: 2},
+			{Name: "configured", Agent: "agent1", Retry: &config.RetryConfig{
+				MaxAttempts: 4,
+				Backoff:     "100ms",
+				MaxBackoff:  "300ms",
+				Multiplier:  3,
+				RetryOn:     []string{config.RetryOnServerError},
+			}},
+		},
+	}
+
+	def, err := NewWorkflowDefinition(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create definition: %v", err)
+	}
+
+	tests := []struct {
+		step        string
+		wantRetries int
+		wantDelays  []time.Duration
+		wantRetryOn string
+	}{
+		{"none", 0, nil, config.RetryOnTransient},
+		{"shorthand", 2, []time.Duration{time.Second, 2 * time.Second}, config.RetryOnTransient},
+		{"configured", 3, []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}, config.RetryOnServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.step, func(t *testing.T) {
+			policy := def.GetStep(tt.step).Retry
+			if got := policy.Retries(); got != tt.wantRetries {
+				t.Errorf("Retries() = %v, want %v", got, tt.wantRetries)
+			}
+			for i, want := range tt.wantDelays {
+				if got := policy.Delay(i+1, 0.5); got != want {
+					t.Errorf("Delay(%d) = %v, want %v", i+1, got, want)
+				}
+			}
+			if len(policy.RetryOn) != 1 || policy.RetryOn[0] != tt.wantRetryOn {
+				t.Errorf("RetryOn = %v, want [%s]", policy.RetryOn, tt.wantRetryOn)
+			}
+		})
+	}
+
+	// Jitter spreads the delay around its nominal value
+	policy := RetryPolicy{Backoff: time.Second, Multiplier: 2, Jitter: 0.5}
+	if got := policy.Delay(1, 0); got != 500*time.Millisecond {
+		t.Errorf("Delay() with lowest jitter = %v, want 500ms", got)
+	}
+	if got := policy.Delay(1, 0.99); got < 1400*time.Millisecond || got > 1500*time.Millisecond {
+		t.Errorf("Delay() with highest jitter = %v, want about 1.5s", got)
+	}
+}
+
+func TestWorkflowDefinition_ResolveConcurrencyGroup(t *testing.T) {
+	prInputs := map[string]config.InputConfig{
+		"pr":  {Type: config.InputNumber, Required: true},
+		"env": {Default: "prod"},
+	}
+
+	tests := []struct {
+		name        string
+		group       string
+		inputs      map[string]config.InputConfig
+		triggerData map[string]any
+		want        string
+		wantErr     bool
+	}{
+		{"no group", "", nil, nil, "", false},
+		{"static group", "deploys", nil, nil, "deploys", false},
+		{"trigger data", "${{ trigger.repo }}-${{ trigger.pr }}", nil, map[string]any{"repo": "owner/repo", "pr": 42}, "owner/repo-42", false},
+		{"missing trigger data", "${{ trigger.pr.number }}", nil, nil, "", true},
+		{"declared inputs", "${{ inputs.env }}-${{ inputs.pr }}", prInputs, map[string]any{"pr": "42"}, "prod-42", false},
+		{"undeclared input", "${{ inputs.repo }}", prInputs, map[string]any{"pr": "42", "repo": "owner/repo"}, "", true},
+		{"invalid input", "${{ inputs.pr }}", prInputs, map[string]any{"pr": "abc"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := &WorkflowDefinition{ConcurrencyGroup: tt.group, Inputs: tt.inputs}
+			got, err := def.ResolveConcurrencyGroup(tt.triggerData)
+			if tt.wantErr {
+				if !errors.Is(err, types.ErrRunInputInvalid) {
+					t.Errorf("ResolveConcurrencyGroup() error = %v, want %v", err, types.ErrRunInputInvalid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveConcurrencyGroup() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveConcurrencyGroup() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
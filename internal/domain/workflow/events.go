@@ -54,62 +54,95 @@ func NewWorkflowCreatedEvent(def *WorkflowDefinition) *WorkflowCreatedEvent {
 	}
 }
 
+// RunRef identifies the run an event belongs to. Events of a run and of its
+// steps carry it and use the run as their aggregate, so that subscribers can
+// follow a run through its events.
+type RunRef struct {
+	RunID        types.RunID      `json:"run_id"`
+	WorkflowID   types.WorkflowID `json:"workflow_id"`
+	WorkflowName string           `json:"workflow_name"`
+}
+
+func newRunRef(run *WorkflowRun) RunRef {
+	return RunRef{
+		RunID:        run.ID,
+		WorkflowID:   run.WorkflowID,
+		WorkflowName: run.WorkflowName,
+	}
+}
+
+// StepRef identifies the step an event belongs to.
+type StepRef struct {
+	StepID  types.StepID `json:"step_id"`
+	Name    string       `json:"name"`
+	Parent  string       `json:"parent,omitempty"`
+	Handler HandlerKind  `json:"handler,omitempty"`
+}
+
+func newStepRef(step *StepRun) StepRef {
+	return StepRef{
+		StepID:  step.ID,
+		Name:    step.Name,
+		Parent:  step.Parent,
+		Handler: step.Handler,
+	}
+}
+
 // RunStartedEvent is emitted when a workflow run is started.
 type RunStartedEvent struct {
 	BaseEvent
-	RunID       types.RunID      `json:"run_id"`
-	WorkflowID  types.WorkflowID `json:"workflow_id"`
-	TriggeredBy string           `json:"triggered_by"`
+	RunRef
+	TriggeredBy string      `json:"triggered_by"`
+	ParentRunID types.RunID `json:"parent_run_id,omitempty"`
+	RerunOf     types.RunID `json:"rerun_of,omitempty"`
 }
 
 func NewRunStartedEvent(run *WorkflowRun) *RunStartedEvent {
 	return &RunStartedEvent{
 		BaseEvent:   newBaseEvent("run.started", run.ID.String()),
-		RunID:       run.ID,
-		WorkflowID:  run.WorkflowID,
+		RunRef:      newRunRef(run),
 		TriggeredBy: run.TriggeredBy,
+		ParentRunID: run.ParentRunID,
+		RerunOf:     run.RerunOf,
 	}
 }
 
 // RunCompletedEvent is emitted when a workflow run completes successfully.
 type RunCompletedEvent struct {
 	BaseEvent
-	RunID       types.RunID      `json:"run_id"`
-	WorkflowID  types.WorkflowID `json:"workflow_id"`
-	Duration    time.Duration    `json:"duration"`
-	StepsCount  int              `json:"steps_count"`
-	TotalTokens int              `json:"total_tokens"`
+	RunRef
+	Duration    time.Duration  `json:"duration"`
+	StepsCount  int            `json:"steps_count"`
+	TotalTokens int            `json:"total_tokens"`
+	CostUSD     float64        `json:"cost_usd"`
+	Outputs     map[string]any `json:"outputs,omitempty"`
 }
 
 func NewRunCompletedEvent(run *WorkflowRun) *RunCompletedEvent {
-	totalTokens := 0
-	for _, step := range run.Steps {
-		totalTokens += step.TokensIn + step.TokensOut
-	}
+	usage := run.Usage("")
 	return &RunCompletedEvent{
 		BaseEvent:   newBaseEvent("run.completed", run.ID.String()),
-		RunID:       run.ID,
-		WorkflowID:  run.WorkflowID,
+		RunRef:      newRunRef(run),
 		Duration:    run.Duration(),
 		StepsCount:  len(run.Steps),
-		TotalTokens: totalTokens,
+		TotalTokens: usage.Tokens,
+		CostUSD:     usage.CostUSD,
+		Outputs:     run.Outputs,
 	}
 }
 
 // RunFailedEvent is emitted when a workflow run fails.
 type RunFailedEvent struct {
 	BaseEvent
-	RunID      types.RunID      `json:"run_id"`
-	WorkflowID types.WorkflowID `json:"workflow_id"`
-	Error      string           `json:"error"`
-	FailedStep string           `json:"failed_step,omitempty"`
+	RunRef
+	Error      string `json:"error"`
+	FailedStep string `json:"failed_step,omitempty"`
 }
 
 func NewRunFailedEvent(run *WorkflowRun, failedStep string) *RunFailedEvent {
 	return &RunFailedEvent{
 		BaseEvent:  newBaseEvent("run.failed", run.ID.String()),
-		RunID:      run.ID,
-		WorkflowID: run.WorkflowID,
+		RunRef:     newRunRef(run),
 		Error:      run.Error,
 		FailedStep: failedStep,
 	}
@@ -118,131 +151,226 @@ func NewRunFailedEvent(run *WorkflowRun, failedStep string) *RunFailedEvent {
 // RunCancelledEvent is emitted when a workflow run is cancelled.
 type RunCancelledEvent struct {
 	BaseEvent
-	RunID      types.RunID      `json:"run_id"`
-	WorkflowID types.WorkflowID `json:"workflow_id"`
-	Reason     string           `json:"reason"`
+	RunRef
+	Reason string `json:"reason"`
 }
 
 func NewRunCancelledEvent(run *WorkflowRun) *RunCancelledEvent {
 	return &RunCancelledEvent{
-		BaseEvent:  newBaseEvent("run.cancelled", run.ID.String()),
-		RunID:      run.ID,
-		WorkflowID: run.WorkflowID,
-		Reason:     run.Error,
+		BaseEvent: newBaseEvent("run.cancelled", run.ID.String()),
+		RunRef:    newRunRef(run),
+		Reason:    run.Error,
 	}
 }
 
 // StepStartedEvent is emitted when a step begins execution.
 type StepStartedEvent struct {
 	BaseEvent
-	RunID   types.RunID  `json:"run_id"`
-	StepID  types.StepID `json:"step_id"`
-	Name    string       `json:"name"`
-	AgentID string       `json:"agent_id"`
+	RunRef
+	StepRef
+	AgentID string `json:"agent_id,omitempty"`
+	Attempt int    `json:"attempt"`
 }
 
-func NewStepStartedEvent(runID types.RunID, step *StepRun) *StepStartedEvent {
+func NewStepStartedEvent(run *WorkflowRun, step *StepRun) *StepStartedEvent {
 	return &StepStartedEvent{
-		BaseEvent: newBaseEvent("step.started", step.ID.String()),
-		RunID:     runID,
-		StepID:    step.ID,
-		Name:      step.Name,
+		BaseEvent: newBaseEvent("step.started", run.ID.String()),
+		RunRef:    newRunRef(run),
+		StepRef:   newStepRef(step),
 		AgentID:   step.AgentID,
+		Attempt:   step.RetryCount + 1,
 	}
 }
 
 // StepCompletedEvent is emitted when a step completes successfully.
 type StepCompletedEvent struct {
 	BaseEvent
-	RunID     types.RunID   `json:"run_id"`
-	StepID    types.StepID  `json:"step_id"`
-	Name      string        `json:"name"`
+	RunRef
+	StepRef
 	Duration  time.Duration `json:"duration"`
 	TokensIn  int           `json:"tokens_in"`
 	TokensOut int           `json:"tokens_out"`
+	CostUSD   float64       `json:"cost_usd"`
+	CacheHit  bool          `json:"cache_hit,omitempty"`
 }
 
-func NewStepCompletedEvent(runID types.RunID, step *StepRun) *StepCompletedEvent {
+func NewStepCompletedEvent(run *WorkflowRun, step *StepRun) *StepCompletedEvent {
 	return &StepCompletedEvent{
-		BaseEvent: newBaseEvent("step.completed", step.ID.String()),
-		RunID:     runID,
-		StepID:    step.ID,
-		Name:      step.Name,
+		BaseEvent: newBaseEvent("step.completed", run.ID.String()),
+		RunRef:    newRunRef(run),
+		StepRef:   newStepRef(step),
 		Duration:  step.Duration(),
 		TokensIn:  step.TokensIn,
 		TokensOut: step.TokensOut,
+		CostUSD:   step.CostUSD,
+		CacheHit:  step.CacheHit,
 	}
 }
 
 // StepFailedEvent is emitted when a step fails.
 type StepFailedEvent struct {
 	BaseEvent
-	RunID      types.RunID  `json:"run_id"`
-	StepID     types.StepID `json:"step_id"`
-	Name       string       `json:"name"`
-	Error      string       `json:"error"`
-	RetryCount int          `json:"retry_count"`
-	CanRetry   bool         `json:"can_retry"`
+	RunRef
+	StepRef
+	Error      string `json:"error"`
+	RetryCount int    `json:"retry_count"`
+	CanRetry   bool   `json:"can_retry"`
 }
 
-func NewStepFailedEvent(runID types.RunID, step *StepRun) *StepFailedEvent {
+func NewStepFailedEvent(run *WorkflowRun, step *StepRun) *StepFailedEvent {
 	return &StepFailedEvent{
-		BaseEvent:  newBaseEvent("step.failed", step.ID.String()),
-		RunID:      runID,
-		StepID:     step.ID,
-		Name:       step.Name,
+		BaseEvent:  newBaseEvent("step.failed", run.ID.String()),
+		RunRef:     newRunRef(run),
+		StepRef:    newStepRef(step),
 		Error:      step.Error,
 		RetryCount: step.RetryCount,
 		CanRetry:   step.CanRetry(),
 	}
 }
 
+// StepRetryingEvent is emitted when a failed step is scheduled for another
+// attempt.
+type StepRetryingEvent struct {
+	BaseEvent
+	RunRef
+	StepRef
+	Attempt int           `json:"attempt"`
+	Error   string        `json:"error"`
+	Class   string        `json:"class"`
+	Backoff time.Duration `json:"backoff"`
+}
+
+func NewStepRetryingEvent(run *WorkflowRun, step *StepRun, err, class string, backoff time.Duration) *StepRetryingEvent {
+	return &StepRetryingEvent{
+		BaseEvent: newBaseEvent("step.retrying", run.ID.String()),
+		RunRef:    newRunRef(run),
+		StepRef:   newStepRef(step),
+		Attempt:   step.RetryCount + 1,
+		Error:     err,
+		Class:     class,
+		Backoff:   backoff,
+	}
+}
+
+// StepSkippedEvent is emitted when a step is skipped because its condition is false.
+type StepSkippedEvent struct {
+	BaseEvent
+	RunRef
+	StepRef
+	Reason string `json:"reason"`
+}
+
+func NewStepSkippedEvent(run *WorkflowRun, step *StepRun) *StepSkippedEvent {
+	return &StepSkippedEvent{
+		BaseEvent: newBaseEvent("step.skipped", run.ID.String()),
+		RunRef:    newRunRef(run),
+		StepRef:   newStepRef(step),
+		Reason:    step.Error,
+	}
+}
+
+// StepCancelledEvent is emitted when a step is stopped or will not run
+// because the run was cancelled, timed out or failed.
+type StepCancelledEvent struct {
+	BaseEvent
+	RunRef
+	StepRef
+	Reason string `json:"reason"`
+}
+
+func NewStepCancelledEvent(run *WorkflowRun, step *StepRun) *StepCancelledEvent {
+	return &StepCancelledEvent{
+		BaseEvent: newBaseEvent("step.cancelled", run.ID.String()),
+		RunRef:    newRunRef(run),
+		StepRef:   newStepRef(step),
+		Reason:    step.Error,
+	}
+}
+
 // ApprovalRequestedEvent is emitted when a workflow requires approval.
 type ApprovalRequestedEvent struct {
 	BaseEvent
-	RunID        types.RunID      `json:"run_id"`
-	WorkflowID   types.WorkflowID `json:"workflow_id"`
-	WorkflowName string           `json:"workflow_name"`
-	StepName     string           `json:"step_name,omitempty"`
+	RunRef
+	StepName string     `json:"step_name,omitempty"`
+	Deadline *time.Time `json:"deadline,omitempty"`
 }
 
 func NewApprovalRequestedEvent(run *WorkflowRun, stepName string) *ApprovalRequestedEvent {
 	return &ApprovalRequestedEvent{
-		BaseEvent:    newBaseEvent("approval.requested", run.ID.String()),
-		RunID:        run.ID,
-		WorkflowID:   run.WorkflowID,
-		WorkflowName: run.WorkflowName,
-		StepName:     stepName,
+		BaseEvent: newBaseEvent("approval.requested", run.ID.String()),
+		RunRef:    newRunRef(run),
+		StepName:  stepName,
+		Deadline:  run.ApprovalDeadline,
 	}
 }
 
 // ApprovalGrantedEvent is emitted when a workflow approval is granted.
 type ApprovalGrantedEvent struct {
 	BaseEvent
-	RunID      types.RunID `json:"run_id"`
-	ApprovedBy string      `json:"approved_by"`
+	RunRef
+	StepName   string `json:"step_name,omitempty"`
+	ApprovedBy string `json:"approved_by"`
 }
 
-func NewApprovalGrantedEvent(runID types.RunID, approvedBy string) *ApprovalGrantedEvent {
+func NewApprovalGrantedEvent(run *WorkflowRun, stepName, approvedBy string) *ApprovalGrantedEvent {
 	return &ApprovalGrantedEvent{
-		BaseEvent:  newBaseEvent("approval.granted", runID.String()),
-		RunID:      runID,
+		BaseEvent:  newBaseEvent("approval.granted", run.ID.String()),
+		RunRef:     newRunRef(run),
+		StepName:   stepName,
 		ApprovedBy: approvedBy,
 	}
 }
 
+// InputRequestedEvent is emitted when a human input step waits for an
+// answer.
+type InputRequestedEvent struct {
+	BaseEvent
+	RunRef
+	StepRef
+	Prompt string `json:"prompt"`
+}
+
+func NewInputRequestedEvent(run *WorkflowRun, step *StepRun) *InputRequestedEvent {
+	prompt, _ := step.Input["prompt"].(string)
+	return &InputRequestedEvent{
+		BaseEvent: newBaseEvent("input.requested", run.ID.String()),
+		RunRef:    newRunRef(run),
+		StepRef:   newStepRef(step),
+		Prompt:    prompt,
+	}
+}
+
+// InputReceivedEvent is emitted when a human input step is answered.
+type InputReceivedEvent struct {
+	BaseEvent
+	RunRef
+	StepRef
+	RespondedBy string `json:"responded_by"`
+}
+
+func NewInputReceivedEvent(run *WorkflowRun, step *StepRun, respondedBy string) *InputReceivedEvent {
+	return &InputReceivedEvent{
+		BaseEvent:   newBaseEvent("input.received", run.ID.String()),
+		RunRef:      newRunRef(run),
+		StepRef:     newStepRef(step),
+		RespondedBy: respondedBy,
+	}
+}
+
 // PolicyViolationEvent is emitted when a policy is violated.
 type PolicyViolationEvent struct {
 	BaseEvent
-	RunID      types.RunID `json:"run_id"`
-	PolicyName string      `json:"policy_name"`
-	Violations []string    `json:"violations"`
+	RunRef
+	StepName   string   `json:"step_name,omitempty"`
+	PolicyName string   `json:"policy_name"`
+	Violations []string `json:"violations"`
 }
 
-func NewPolicyViolationEvent(runID types.RunID, policyName string, violations []string) *PolicyViolationEvent {
+func NewPolicyViolationEvent(run *WorkflowRun, stepName, policyName string, violations []string) *PolicyViolationEvent {
 	return &PolicyViolationEvent{
-		BaseEvent:  newBaseEvent("policy.violation", runID.String()),
-		RunID:      runID,
+		BaseEvent:  newBaseEvent("policy.violation", run.ID.String()),
+		RunRef:     newRunRef(run),
+		StepName:   stepName,
 		PolicyName: policyName,
 		Violations: violations,
 	}
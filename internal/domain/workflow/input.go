@@ -0,0 +1,59 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// HumanInput is the question a human input step asks. The run waits until
+// a user answers it, and the answer becomes the output of the step.
+type HumanInput struct {
+	Prompt string                        // May reference inputs and the outputs of earlier steps
+	Form   map[string]config.InputConfig // Fields of the answer, nil for a free-form answer
+}
+
+// newHumanInput creates the question of a human input step, nil for other
+// steps.
+func newHumanInput(cfg config.StepConfig) *HumanInput {
+	if !cfg.IsHumanInput() {
+		return nil
+	}
+	return &HumanInput{Prompt: cfg.Prompt, Form: cfg.Form}
+}
+
+// Answer validates the fields a user answered with against the form and
+// returns the answer with the fields coerced to their types and defaults
+// filled in. A free-form answer takes any fields, at least one.
+func (h *HumanInput) Answer(fields map[string]any) (map[string]any, error) {
+	if h.Form == nil {
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%w: at least one field is required", types.ErrAnswerInvalid)
+		}
+		answer := make(map[string]any, len(fields))
+		for name, value := range fields {
+			answer[name] = value
+		}
+		return answer, nil
+	}
+
+	unknown := make([]string, 0)
+	for name := range fields {
+		if _, ok := h.Form[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%w: unknown field %s", types.ErrAnswerInvalid, strings.Join(unknown, ", "))
+	}
+
+	answer, err := config.ResolveInputs(h.Form, fields)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", types.ErrAnswerInvalid, err)
+	}
+	return answer, nil
+}
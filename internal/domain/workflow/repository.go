@@ -2,18 +2,26 @@ package workflow
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/felixgeelhaar/bridge/pkg/types"
 )
 
 // Repository defines the interface for workflow persistence.
 type Repository interface {
-	// Definition operations
+	// Definition operations. Definitions are immutable; each version of a
+	// workflow is stored as its own definition, and CreateDefinition returns
+	// types.ErrWorkflowAlreadyExists for a name and version already stored.
+	// GetDefinitionByName returns the version stored last, and
+	// ListDefinitionVersions returns all versions, the latest first.
 	CreateDefinition(ctx context.Context, def *WorkflowDefinition) error
 	GetDefinition(ctx context.Context, id types.WorkflowID) (*WorkflowDefinition, error)
 	GetDefinitionByName(ctx context.Context, name string) (*WorkflowDefinition, error)
+	GetDefinitionVersion(ctx context.Context, name, version string) (*WorkflowDefinition, error)
 	ListDefinitions(ctx context.Context, limit, offset int) ([]*WorkflowDefinition, error)
-	UpdateDefinition(ctx context.Context, def *WorkflowDefinition) error
+	ListDefinitionVersions(ctx context.Context, name string) ([]*WorkflowDefinition, error)
 	DeleteDefinition(ctx context.Context, id types.WorkflowID) error
 
 	// Run operations
@@ -21,13 +29,38 @@ type Repository interface {
 	GetRun(ctx context.Context, id types.RunID) (*WorkflowRun, error)
 	ListRuns(ctx context.Context, workflowID types.WorkflowID, limit, offset int) ([]*WorkflowRun, error)
 	ListActiveRuns(ctx context.Context) ([]*WorkflowRun, error)
+	ListActiveRunsInGroup(ctx context.Context, group string) ([]*WorkflowRun, error)
+	ListOrphanedRuns(ctx context.Context) ([]*WorkflowRun, error) // Active runs whose lease expired
 	UpdateRun(ctx context.Context, run *WorkflowRun) error
 
-	// Step operations
+	// WorkflowUsage returns the tokens and cost used by the steps of the
+	// runs of all versions of the named workflow, as WorkflowRun.Usage
+	// counts them.
+	WorkflowUsage(ctx context.Context, name string) (Usage, error)
+
+	// Lease operations. AcquireLease takes or renews the lease on a run for
+	// ttl and returns types.ErrRunLeased while another owner holds it. Once
+	// cancellation of the run is requested, AcquireLease renews the lease but
+	// returns an error wrapping types.ErrRunCancelled with the reason.
+	AcquireLease(ctx context.Context, id types.RunID, owner string, ttl time.Duration) error
+	ReleaseLease(ctx context.Context, id types.RunID, owner string) error
+	RequestCancel(ctx context.Context, id types.RunID, reason string) error
+
+	// Step operations. CreateSteps stores steps added to a run while it
+	// executes, such as fan-out items.
+	CreateSteps(ctx context.Context, run *WorkflowRun, steps []*StepRun) error
 	GetStep(ctx context.Context, id types.StepID) (*StepRun, error)
 	UpdateStep(ctx context.Context, step *StepRun) error
 }
 
+// StepCache stores step results for reuse by later runs.
+// GetCachedResult returns types.ErrCacheMiss when no result that has not
+// expired is stored under the key.
+type StepCache interface {
+	GetCachedResult(ctx context.Context, key string) (*CachedResult, error)
+	SaveCachedResult(ctx context.Context, result *CachedResult) error
+}
+
 // EventPublisher publishes domain events.
 type EventPublisher interface {
 	Publish(ctx context.Context, event Event) error
@@ -47,16 +80,33 @@ func NewService(repo Repository, publisher EventPublisher) *Service {
 	}
 }
 
-// CreateWorkflow creates a new workflow definition.
-func (s *Service) CreateWorkflow(ctx context.Context, def *WorkflowDefinition) error {
+// CreateWorkflow stores a workflow definition as a new version of its
+// workflow and returns the stored definition. A version stored before with
+// the same checksum is returned as is, so that registering a workflow again
+// is harmless; one stored with other content is never replaced and
+// types.ErrWorkflowVersionConflict is returned.
+func (s *Service) CreateWorkflow(ctx context.Context, def *WorkflowDefinition) (*WorkflowDefinition, error) {
+	existing, err := s.repo.GetDefinitionVersion(ctx, def.Name, def.Version)
+	switch {
+	case err == nil && existing.Checksum == def.Checksum:
+		return existing, nil
+	case err == nil:
+		return nil, fmt.Errorf("%w: %s@%s has checksum %s, not %s",
+			types.ErrWorkflowVersionConflict, def.Name, def.Version, existing.Checksum, def.Checksum)
+	case !errors.Is(err, types.ErrWorkflowNotFound):
+		return nil, err
+	}
+
 	if err := s.repo.CreateDefinition(ctx, def); err != nil {
-		return err
+		return nil, err
 	}
 
 	if s.publisher != nil {
-		return s.publisher.Publish(ctx, NewWorkflowCreatedEvent(def))
+		if err := s.publisher.Publish(ctx, NewWorkflowCreatedEvent(def)); err != nil {
+			return def, err
+		}
 	}
-	return nil
+	return def, nil
 }
 
 // GetWorkflow retrieves a workflow definition by ID.
@@ -64,14 +114,70 @@ func (s *Service) GetWorkflow(ctx context.Context, id types.WorkflowID) (*Workfl
 	return s.repo.GetDefinition(ctx, id)
 }
 
-// GetWorkflowByName retrieves a workflow definition by name.
+// GetWorkflowByName retrieves the latest version of a workflow definition
+// by name.
 func (s *Service) GetWorkflowByName(ctx context.Context, name string) (*WorkflowDefinition, error) {
 	return s.repo.GetDefinitionByName(ctx, name)
 }
 
-// StartRun creates and starts a new workflow run.
-func (s *Service) StartRun(ctx context.Context, def *WorkflowDefinition, triggeredBy string, triggerData map[string]any) (*WorkflowRun, error) {
+// GetWorkflowVersion retrieves a version of a workflow definition.
+func (s *Service) GetWorkflowVersion(ctx context.Context, name, version string) (*WorkflowDefinition, error) {
+	return s.repo.GetDefinitionVersion(ctx, name, version)
+}
+
+// ListWorkflowVersions returns the versions of a workflow, the latest first.
+func (s *Service) ListWorkflowVersions(ctx context.Context, name string) ([]*WorkflowDefinition, error) {
+	return s.repo.ListDefinitionVersions(ctx, name)
+}
+
+// StartRun creates and starts a new workflow run. The budget limits the
+// run on top of the budgets of its workflow, nil for none.
+func (s *Service) StartRun(ctx context.Context, def *WorkflowDefinition, triggeredBy string, triggerData map[string]any, budget *Budget) (*WorkflowRun, error) {
+	group, err := def.ResolveConcurrencyGroup(triggerData)
+	if err != nil {
+		return nil, err
+	}
+
 	run := NewWorkflowRun(def, triggeredBy, triggerData)
+	run.ConcurrencyGroup = group
+	run.Budget = budget
+
+	if err := s.repo.CreateRun(ctx, run); err != nil {
+		return nil, err
+	}
+
+	if s.publisher != nil {
+		if err := s.publisher.Publish(ctx, NewRunStartedEvent(run)); err != nil {
+			return nil, err
+		}
+	}
+
+	return run, nil
+}
+
+// StartRerun creates and starts a run that re-runs a finished run.
+func (s *Service) StartRerun(ctx context.Context, def *WorkflowDefinition, original *WorkflowRun, triggeredBy string, opts RerunOptions) (*WorkflowRun, error) {
+	run, err := NewRerun(def, original, triggeredBy, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateRun(ctx, run); err != nil {
+		return nil, err
+	}
+
+	if s.publisher != nil {
+		if err := s.publisher.Publish(ctx, NewRunStartedEvent(run)); err != nil {
+			return nil, err
+		}
+	}
+
+	return run, nil
+}
+
+// StartChildRun creates and starts a run of def for a sub-workflow step.
+func (s *Service) StartChildRun(ctx context.Context, def *WorkflowDefinition, parent *WorkflowRun, step *StepRun, input map[string]any, budget *Budget) (*WorkflowRun, error) {
+	run := NewChildRun(def, parent, step, input, budget)
 
 	if err := s.repo.CreateRun(ctx, run); err != nil {
 		return nil, err
@@ -124,11 +230,264 @@ func (s *Service) FailRun(ctx context.Context, run *WorkflowRun, err string, fai
 	return nil
 }
 
+// RejectRun marks a workflow run awaiting approval as failed because the
+// approval was rejected.
+func (s *Service) RejectRun(ctx context.Context, run *WorkflowRun, rejectedBy, comment string) error {
+	return s.FailRun(ctx, run, "Rejected by "+rejectedBy+": "+comment, run.PendingStep)
+}
+
+// CancelRun marks a workflow run as cancelled.
+func (s *Service) CancelRun(ctx context.Context, run *WorkflowRun, reason string) error {
+	run.Cancel(reason)
+
+	if err := s.repo.UpdateRun(ctx, run); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		return s.publisher.Publish(ctx, NewRunCancelledEvent(run))
+	}
+	return nil
+}
+
+// TimeOutRun marks a workflow run as failed because it timed out.
+func (s *Service) TimeOutRun(ctx context.Context, run *WorkflowRun, reason string) error {
+	run.TimeOut(reason)
+
+	if err := s.repo.UpdateRun(ctx, run); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		return s.publisher.Publish(ctx, NewRunFailedEvent(run, ""))
+	}
+	return nil
+}
+
+// RequestCancel asks the process executing a run to cancel it.
+func (s *Service) RequestCancel(ctx context.Context, id types.RunID, reason string) error {
+	return s.repo.RequestCancel(ctx, id, reason)
+}
+
+// WorkflowUsage returns the tokens and cost used by the runs of a workflow.
+func (s *Service) WorkflowUsage(ctx context.Context, name string) (Usage, error) {
+	return s.repo.WorkflowUsage(ctx, name)
+}
+
 // ListActiveRuns returns all active workflow runs.
 func (s *Service) ListActiveRuns(ctx context.Context) ([]*WorkflowRun, error) {
 	return s.repo.ListActiveRuns(ctx)
 }
 
+// ListActiveRunsInGroup returns the active runs in a concurrency group.
+func (s *Service) ListActiveRunsInGroup(ctx context.Context, group string) ([]*WorkflowRun, error) {
+	return s.repo.ListActiveRunsInGroup(ctx, group)
+}
+
+// ListOrphanedRuns returns the active runs whose lease expired, as the
+// process executing them died.
+func (s *Service) ListOrphanedRuns(ctx context.Context) ([]*WorkflowRun, error) {
+	return s.repo.ListOrphanedRuns(ctx)
+}
+
+// StartStep marks a step run as running with its resolved input.
+func (s *Service) StartStep(ctx context.Context, run *WorkflowRun, step *StepRun, input map[string]any) error {
+	step.Start(input)
+
+	if err := s.repo.UpdateStep(ctx, step); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		return s.publisher.Publish(ctx, NewStepStartedEvent(run, step))
+	}
+	return nil
+}
+
+// CompleteStep marks a step run as completed with its output.
+func (s *Service) CompleteStep(ctx context.Context, run *WorkflowRun, step *StepRun, output map[string]any, tokensIn, tokensOut int) error {
+	step.Complete(output, tokensIn, tokensOut)
+
+	if err := s.repo.UpdateStep(ctx, step); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		return s.publisher.Publish(ctx, NewStepCompletedEvent(run, step))
+	}
+	return nil
+}
+
+// FailStep marks a step run as failed.
+func (s *Service) FailStep(ctx context.Context, run *WorkflowRun, step *StepRun, err string) error {
+	step.Fail(err)
+
+	if updateErr := s.repo.UpdateStep(ctx, step); updateErr != nil {
+		return updateErr
+	}
+
+	if s.publisher != nil {
+		return s.publisher.Publish(ctx, NewStepFailedEvent(run, step))
+	}
+	return nil
+}
+
+// RetryStep returns a step run whose attempt failed with err to pending for
+// another attempt.
+func (s *Service) RetryStep(ctx context.Context, run *WorkflowRun, step *StepRun, err, class string, backoff time.Duration) error {
+	step.Retry(err, class, backoff)
+
+	if err := s.repo.UpdateStep(ctx, step); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		return s.publisher.Publish(ctx, NewStepRetryingEvent(run, step, err, class, backoff))
+	}
+	return nil
+}
+
+// CancelStep marks a step run as cancelled.
+func (s *Service) CancelStep(ctx context.Context, run *WorkflowRun, step *StepRun, reason string) error {
+	step.Cancel(reason)
+
+	if err := s.repo.UpdateStep(ctx, step); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		return s.publisher.Publish(ctx, NewStepCancelledEvent(run, step))
+	}
+	return nil
+}
+
+// SkipStep marks a step run as skipped.
+func (s *Service) SkipStep(ctx context.Context, run *WorkflowRun, step *StepRun, reason string) error {
+	step.Skip(reason)
+
+	if err := s.repo.UpdateStep(ctx, step); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		return s.publisher.Publish(ctx, NewStepSkippedEvent(run, step))
+	}
+	return nil
+}
+
+// RequestApproval pauses the run until it is approved.
+func (s *Service) RequestApproval(ctx context.Context, run *WorkflowRun) error {
+	run.AwaitApproval()
+
+	if err := s.repo.UpdateRun(ctx, run); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		return s.publisher.Publish(ctx, NewApprovalRequestedEvent(run, ""))
+	}
+	return nil
+}
+
+// RequestStepApproval pauses the run until the step is approved.
+func (s *Service) RequestStepApproval(ctx context.Context, run *WorkflowRun, step *StepRun) error {
+	run.AwaitStepApproval(step.Name)
+
+	if err := s.repo.UpdateRun(ctx, run); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		return s.publisher.Publish(ctx, NewApprovalRequestedEvent(run, step.Name))
+	}
+	return nil
+}
+
+// ApproveRun approves a run awaiting approval, and the step it was paused
+// for if any, so that it continues executing.
+func (s *Service) ApproveRun(ctx context.Context, run *WorkflowRun, approvedBy string) error {
+	step := run.GetStepByName(run.PendingStep)
+	run.Approve()
+
+	if step != nil {
+		if err := s.repo.UpdateStep(ctx, step); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.UpdateRun(ctx, run); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		stepName := ""
+		if step != nil {
+			stepName = step.Name
+		}
+		return s.publisher.Publish(ctx, NewApprovalGrantedEvent(run, stepName, approvedBy))
+	}
+	return nil
+}
+
+// RequestInput pauses the run until the human input step is answered.
+func (s *Service) RequestInput(ctx context.Context, run *WorkflowRun, step *StepRun) error {
+	run.AwaitStepInput(step.Name)
+
+	if err := s.repo.UpdateRun(ctx, run); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		return s.publisher.Publish(ctx, NewInputRequestedEvent(run, step))
+	}
+	return nil
+}
+
+// ProvideInput completes the human input step the run awaits with the
+// answer given by respondedBy, so that the run continues executing.
+func (s *Service) ProvideInput(ctx context.Context, run *WorkflowRun, step *StepRun, answer map[string]any, respondedBy string) error {
+	step.Complete(answer, 0, 0)
+	run.ReceiveInput()
+
+	if err := s.repo.UpdateStep(ctx, step); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateRun(ctx, run); err != nil {
+		return err
+	}
+
+	if s.publisher != nil {
+		if err := s.publisher.Publish(ctx, NewInputReceivedEvent(run, step, respondedBy)); err != nil {
+			return err
+		}
+		return s.publisher.Publish(ctx, NewStepCompletedEvent(run, step))
+	}
+	return nil
+}
+
+// ReportPolicyViolation publishes the violations of a policy by a run, or
+// by one of its steps when stepName is not empty.
+func (s *Service) ReportPolicyViolation(ctx context.Context, run *WorkflowRun, stepName, policyName string, violations []string) error {
+	if s.publisher != nil {
+		return s.publisher.Publish(ctx, NewPolicyViolationEvent(run, stepName, policyName, violations))
+	}
+	return nil
+}
+
+// AcquireLease takes or renews the lease on a run.
+func (s *Service) AcquireLease(ctx context.Context, id types.RunID, owner string, ttl time.Duration) error {
+	return s.repo.AcquireLease(ctx, id, owner, ttl)
+}
+
+// ReleaseLease releases the lease on a run held by owner.
+func (s *Service) ReleaseLease(ctx context.Context, id types.RunID, owner string) error {
+	return s.repo.ReleaseLease(ctx, id, owner)
+}
+
+// CreateSteps stores steps added to a run while it executes.
+func (s *Service) CreateSteps(ctx context.Context, run *WorkflowRun, steps []*StepRun) error {
+	return s.repo.CreateSteps(ctx, run, steps)
+}
+
 // UpdateStep updates a step run.
 func (s *Service) UpdateStep(ctx context.Context, step *StepRun) error {
 	return s.repo.UpdateStep(ctx, step)
@@ -0,0 +1,74 @@
+package workflow
+
+import (
+	"math"
+	"time"
+
+	"github.com/felixgeelhaar/bridge/pkg/config"
+)
+
+// Default retry settings of steps that are retried.
+const (
+	DefaultRetryBackoff    = time.Second
+	DefaultRetryMaxBackoff = time.Minute
+	DefaultRetryMultiplier = 2.0
+)
+
+// RetryPolicy defines how a failed step is retried. Retries back off
+// exponentially and only happen for failures of the listed error classes.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts including the first
+	Backoff     time.Duration // Delay before the first retry
+	MaxBackoff  time.Duration // Upper bound of the delay
+	Multiplier  float64       // Growth of the delay per retry
+	Jitter      float64       // Fraction of the delay randomized
+	RetryOn     []string      // Error classes retried, see the config.RetryOn constants
+}
+
+// newRetryPolicy creates the retry policy of a step from its retries
+// shorthand or its retry settings.
+func newRetryPolicy(retries int, cfg *config.RetryConfig) RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts: retries + 1,
+		Backoff:     DefaultRetryBackoff,
+		MaxBackoff:  DefaultRetryMaxBackoff,
+		Multiplier:  DefaultRetryMultiplier,
+		RetryOn:     []string{config.RetryOnTransient},
+	}
+	if cfg == nil {
+		return policy
+	}
+
+	policy.MaxAttempts = cfg.MaxAttempts
+	policy.Jitter = cfg.Jitter
+	if d, err := time.ParseDuration(cfg.Backoff); err == nil {
+		policy.Backoff = d
+	}
+	if d, err := time.ParseDuration(cfg.MaxBackoff); err == nil {
+		policy.MaxBackoff = d
+	}
+	if cfg.Multiplier > 0 {
+		policy.Multiplier = cfg.Multiplier
+	}
+	if len(cfg.RetryOn) > 0 {
+		policy.RetryOn = cfg.RetryOn
+	}
+	return policy
+}
+
+// Retries returns the number of times a step may be retried.
+func (p RetryPolicy) Retries() int {
+	return max(p.MaxAttempts-1, 0)
+}
+
+// Delay returns the backoff before the given retry, counting from 1. The
+// jitter moves the delay by up to its fraction in either direction, with
+// rnd in [0, 1) choosing where.
+func (p RetryPolicy) Delay(retry int, rnd float64) time.Duration {
+	delay := float64(p.Backoff) * math.Pow(p.Multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	delay += delay * p.Jitter * (2*rnd - 1)
+	return time.Duration(delay)
+}
@@ -1,6 +1,8 @@
 package workflow
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/felixgeelhaar/bridge/pkg/types"
@@ -13,6 +15,7 @@ const (
 	RunStatusPending          RunStatus = "pending"
 	RunStatusPolicyCheck      RunStatus = "policy_check"
 	RunStatusAwaitingApproval RunStatus = "awaiting_approval"
+	RunStatusAwaitingInput    RunStatus = "awaiting_input"
 	RunStatusExecuting        RunStatus = "executing"
 	RunStatusCompleted        RunStatus = "completed"
 	RunStatusFailed           RunStatus = "failed"
@@ -27,21 +30,31 @@ func (s RunStatus) IsTerminal() bool {
 // WorkflowRun is the aggregate root for workflow execution.
 // It represents a single execution instance of a workflow definition.
 type WorkflowRun struct {
-	ID              types.RunID
-	WorkflowID      types.WorkflowID
-	WorkflowName    string
-	WorkflowVersion string
-	Status          RunStatus
-	CurrentStepIdx  int
-	Steps           []*StepRun
-	Context         map[string]any
-	TriggeredBy     string
-	TriggerData     map[string]any
-	Error           string
-	StartedAt       *time.Time
-	CompletedAt     *time.Time
-	CreatedAt       time.Time
-	UpdatedAt       time.Time
+	ID               types.RunID
+	WorkflowID       types.WorkflowID
+	WorkflowName     string
+	WorkflowVersion  string
+	Status           RunStatus
+	Steps            []*StepRun
+	Context          map[string]any
+	TriggeredBy      string
+	TriggerData      map[string]any
+	Error            string
+	PendingStep      string // Step awaiting approval or input, empty for run-level approval
+	LeaseOwner       string // Process currently executing the run
+	LeaseExpiresAt   *time.Time
+	RerunOf          types.RunID    // Run this run re-runs, empty otherwise
+	ParentRunID      types.RunID    // Run whose sub-workflow step started this run
+	ParentStep       string         // Sub-workflow step of the parent run
+	Outputs          map[string]any // Workflow outputs, set on completion
+	ConcurrencyGroup string         // Group the run executes one at a time in, empty for none
+	Budget           *Budget        // Usage limit of the run on top of its workflow's, nil for none
+	Deadline         *time.Time     // When the run times out, nil without a timeout
+	ApprovalDeadline *time.Time     // When the pending approval times out
+	StartedAt        *time.Time
+	CompletedAt      *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 // NewWorkflowRun creates a new workflow run from a definition.
@@ -53,7 +66,6 @@ func NewWorkflowRun(def *WorkflowDefinition, triggeredBy string, triggerData map
 		WorkflowName:    def.Name,
 		WorkflowVersion: def.Version,
 		Status:          RunStatusPending,
-		CurrentStepIdx:  0,
 		Steps:           make([]*StepRun, 0, len(def.Steps)),
 		Context:         make(map[string]any),
 		TriggeredBy:     triggeredBy,
@@ -61,6 +73,10 @@ func NewWorkflowRun(def *WorkflowDefinition, triggeredBy string, triggerData map
 		CreatedAt:       now,
 		UpdatedAt:       now,
 	}
+	if def.Timeout > 0 {
+		deadline := now.Add(def.Timeout)
+		run.Deadline = &deadline
+	}
 
 	// Create step runs
 	for i, stepDef := range def.Steps {
@@ -73,7 +89,7 @@ func NewWorkflowRun(def *WorkflowDefinition, triggeredBy string, triggerData map
 			Status:           StepStatusPending,
 			RequiresApproval: stepDef.RequiresApproval,
 			Timeout:          stepDef.Timeout,
-			MaxRetries:       stepDef.Retries,
+			MaxRetries:       stepDef.Retry.Retries(),
 			CreatedAt:        now,
 		})
 	}
@@ -81,6 +97,84 @@ func NewWorkflowRun(def *WorkflowDefinition, triggeredBy string, triggerData map
 	return run
 }
 
+// RerunOptions selects the steps of a finished run that execute again.
+// Without options every step runs again.
+type RerunOptions struct {
+	FromStep   string // Re-run this step and every step defined after it
+	OnlyFailed bool   // Re-run only the steps that did not succeed
+}
+
+// NewRerun creates a run that re-runs a finished run of def. Steps that
+// succeeded in the original run and come before the selected steps keep
+// their status and output and are not executed again.
+func NewRerun(def *WorkflowDefinition, original *WorkflowRun, triggeredBy string, opts RerunOptions) (*WorkflowRun, error) {
+	if !original.Status.IsTerminal() {
+		return nil, fmt.Errorf("run %s is %s, only finished runs can be re-run", original.ID, original.Status)
+	}
+
+	// Steps before this index are reused when they succeeded
+	rerunFrom := 0
+	if opts.OnlyFailed {
+		rerunFrom = len(def.Steps)
+	}
+	if opts.FromStep != "" {
+		rerunFrom = -1
+		for i, stepDef := range def.Steps {
+			if stepDef.Name == opts.FromStep {
+				rerunFrom = i
+			}
+		}
+		if rerunFrom < 0 {
+			return nil, fmt.Errorf("%w: %s", types.ErrStepNotFound, opts.FromStep)
+		}
+	}
+
+	run := NewWorkflowRun(def, triggeredBy, original.TriggerData)
+	run.RerunOf = original.ID
+	run.ConcurrencyGroup = original.ConcurrencyGroup
+	run.Budget = original.Budget
+
+	for _, step := range run.Steps {
+		prev := original.GetStepByName(step.Name)
+		if prev == nil || !prev.Succeeded() || step.StepIndex >= rerunFrom {
+			continue
+		}
+		step.Reuse(prev)
+		if step.Status == StepStatusCompleted {
+			run.SetContext(fmt.Sprintf("steps.%s.output", step.Name), step.Output)
+		}
+	}
+
+	return run, nil
+}
+
+// NewChildRun creates a run of def for a sub-workflow step of parent. The
+// step input is passed to the child run as its inputs, and budget limits
+// the child run to what the parent run has left.
+func NewChildRun(def *WorkflowDefinition, parent *WorkflowRun, step *StepRun, input map[string]any, budget *Budget) *WorkflowRun {
+	run := NewWorkflowRun(def, parent.TriggeredBy, input)
+	run.ParentRunID = parent.ID
+	run.ParentStep = step.Name
+	run.Budget = budget
+	return run
+}
+
+// IsLeased returns true if another owner holds an unexpired lease on the run.
+func (r *WorkflowRun) IsLeased(owner string, now time.Time) bool {
+	return r.LeaseOwner != "" && r.LeaseOwner != owner &&
+		r.LeaseExpiresAt != nil && r.LeaseExpiresAt.After(now)
+}
+
+// Precedes returns true if the run was created before other. Runs created
+// at the same time are ordered by ID, so that runs in a concurrency group
+// agree on their order.
+func (r *WorkflowRun) Precedes(other *WorkflowRun) bool {
+	if !r.CreatedAt.Equal(other.CreatedAt) {
+		return r.CreatedAt.Before(other.CreatedAt)
+	}
+	return r.ID.String() < other.ID.String()
+}
+
 // Start begins the workflow execution.
 func (r *WorkflowRun) Start() {
 	now := time.Now()
@@ -95,12 +189,71 @@ func (r *WorkflowRun) AwaitApproval() {
 	r.UpdatedAt = time.Now()
 }
 
-// Approve approves the workflow and continues execution.
+// AwaitStepApproval sets the run to await approval of a step.
+func (r *WorkflowRun) AwaitStepApproval(stepName string) {
+	r.Status = RunStatusAwaitingApproval
+	r.PendingStep = stepName
+	r.UpdatedAt = time.Now()
+}
+
+// Approve approves the workflow and continues execution. When a step is
+// awaiting approval, that step is approved.
 func (r *WorkflowRun) Approve() {
+	if step := r.GetStepByName(r.PendingStep); step != nil && step.IsAwaitingApproval() {
+		step.Approve()
+	}
 	r.Status = RunStatusExecuting
+	r.PendingStep = ""
+	r.ApprovalDeadline = nil
+	r.UpdatedAt = time.Now()
+}
+
+// AwaitStepInput sets the run to await the answer to a human input step.
+func (r *WorkflowRun) AwaitStepInput(stepName string) {
+	r.Status = RunStatusAwaitingInput
+	r.PendingStep = stepName
 	r.UpdatedAt = time.Now()
 }
 
+// ReceiveInput continues the run once the step it awaits input for is
+// answered.
+func (r *WorkflowRun) ReceiveInput() {
+	r.Status = RunStatusExecuting
+	r.PendingStep = ""
+	r.UpdatedAt = time.Now()
+}
+
+// ExpireApprovalAfter sets the deadline of the pending approval. A zero
+// timeout leaves the approval without a deadline.
+func (r *WorkflowRun) ExpireApprovalAfter(timeout time.Duration) {
+	if timeout <= 0 {
+		r.ApprovalDeadline = nil
+		return
+	}
+	deadline := time.Now().Add(timeout)
+	r.ApprovalDeadline = &deadline
+}
+
+// Expired returns why an active run has timed out at now: it has passed its
+// deadline, or the approval it awaits has passed its deadline.
+func (r *WorkflowRun) Expired(now time.Time) (string, bool) {
+	if r.Status.IsTerminal() {
+		return "", false
+	}
+	if r.Deadline != nil && !now.Before(*r.Deadline) {
+		return DeadlineReason(*r.Deadline), true
+	}
+	if r.Status == RunStatusAwaitingApproval && r.ApprovalDeadline != nil && !now.Before(*r.ApprovalDeadline) {
+		return fmt.Sprintf("approval was not given by %s", r.ApprovalDeadline.Format(time.RFC3339)), true
+	}
+	return "", false
+}
+
+// DeadlineReason describes a run that did not finish by its deadline.
+func DeadlineReason(deadline time.Time) string {
+	return fmt.Sprintf("run did not finish by %s", deadline.Format(time.RFC3339))
+}
+
 // Reject rejects the workflow approval.
 func (r *WorkflowRun) Reject(reason string) {
 	now := time.Now()
@@ -124,6 +277,12 @@ func (r *WorkflowRun) Complete() {
 	r.UpdatedAt = now
 }
 
+// SetOutputs records the evaluated workflow outputs.
+func (r *WorkflowRun) SetOutputs(outputs map[string]any) {
+	r.Outputs = outputs
+	r.UpdatedAt = time.Now()
+}
+
 // Fail marks the workflow run as failed.
 func (r *WorkflowRun) Fail(err string) {
 	now := time.Now()
@@ -133,6 +292,20 @@ func (r *WorkflowRun) Fail(err string) {
 	r.UpdatedAt = now
 }
 
+// TimedOutReason prefixes the error of runs that timed out.
+const TimedOutReason = "timed_out"
+
+// TimeOut fails the workflow run because it exceeded its timeout or an
+// approval deadline.
+func (r *WorkflowRun) TimeOut(reason string) {
+	r.Fail(TimedOutReason + ": " + reason)
+}
+
+// TimedOut returns true if the run failed because it timed out.
+func (r *WorkflowRun) TimedOut() bool {
+	return r.Status == RunStatusFailed && strings.HasPrefix(r.Error, TimedOutReason+": ")
+}
+
 // Cancel cancels the workflow run.
 func (r *WorkflowRun) Cancel(reason string) {
 	now := time.Now()
@@ -142,24 +315,180 @@ func (r *WorkflowRun) Cancel(reason string) {
 	r.UpdatedAt = now
 }
 
-// CurrentStep returns the current step being executed.
-func (r *WorkflowRun) CurrentStep() *StepRun {
-	if r.CurrentStepIdx >= 0 && r.CurrentStepIdx < len(r.Steps) {
-		return r.Steps[r.CurrentStepIdx]
+// ReadySteps returns the pending steps whose dependencies have all
+// completed or been skipped, in definition order. Fan-out items and
+// failure handlers are not included; they are ready once added.
+func (r *WorkflowRun) ReadySteps(def *WorkflowDefinition) []*StepRun {
+	ready := make([]*StepRun, 0)
+	for _, step := range r.Steps {
+		if !step.IsPending() || step.Parent != "" || step.Handler != "" {
+			continue
+		}
+
+		satisfied := true
+		for _, dep := range def.Dependencies(step.Name) {
+			depStep := r.GetStepByName(dep)
+			if depStep == nil || !depStep.Succeeded() {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			ready = append(ready, step)
+		}
 	}
-	return nil
+	return ready
 }
 
-// AdvanceStep moves to the next step.
-func (r *WorkflowRun) AdvanceStep() bool {
-	r.CurrentStepIdx++
-	r.UpdatedAt = time.Now()
-	return r.CurrentStepIdx < len(r.Steps)
+// ExpandStep adds a step for each fan-out item of parent, placed after the
+// parent. Each item holds the expression variables of one step.
+func (r *WorkflowRun) ExpandStep(parent *StepRun, items []map[string]any) []*StepRun {
+	now := time.Now()
+	children := make([]*StepRun, len(items))
+	for i, item := range items {
+		children[i] = &StepRun{
+			ID:         types.NewStepID(),
+			RunID:      r.ID,
+			StepIndex:  parent.StepIndex,
+			Name:       fmt.Sprintf("%s[%d]", parent.Name, i),
+			Parent:     parent.Name,
+			Item:       item,
+			AgentID:    parent.AgentID,
+			Status:     StepStatusPending,
+			Timeout:    parent.Timeout,
+			MaxRetries: parent.MaxRetries,
+			CreatedAt:  now,
+		}
+	}
+
+	steps := make([]*StepRun, 0, len(r.Steps)+len(children))
+	for _, step := range r.Steps {
+		steps = append(steps, step)
+		if step == parent {
+			steps = append(steps, children...)
+		}
+	}
+	r.Steps = steps
+	r.UpdatedAt = now
+
+	return children
+}
+
+// AddHandlers adds a step for each failure handler in defs, run for the
+// named step or, when of is empty, for the run. failure holds the failing
+// step and error exposed to the handlers. Step handlers are placed after the
+// step, run handlers after all other steps.
+func (r *WorkflowRun) AddHandlers(kind HandlerKind, of string, defs []StepDefinition, failure map[string]any) []*StepRun {
+	// Place the handlers after the step and its fan-out items
+	index, at := len(r.Steps), len(r.Steps)
+	if step := r.GetStepByName(of); step != nil {
+		index = step.StepIndex
+		for i, s := range r.Steps {
+			if s == step || s.Parent == of {
+				at = i + 1
+			}
+		}
+	}
+
+	now := time.Now()
+	handlers := make([]*StepRun, len(defs))
+	for i, def := range defs {
+		handlers[i] = &StepRun{
+			ID:         types.NewStepID(),
+			RunID:      r.ID,
+			StepIndex:  index,
+			Name:       def.Name,
+			Item:       map[string]any{"failure": failure},
+			Handler:    kind,
+			HandlerOf:  of,
+			AgentID:    def.AgentID,
+			Status:     StepStatusPending,
+			Timeout:    def.Timeout,
+			MaxRetries: def.Retry.Retries(),
+			CreatedAt:  now,
+		}
+	}
+
+	steps := make([]*StepRun, 0, len(r.Steps)+len(handlers))
+	steps = append(steps, r.Steps[:at]...)
+	steps = append(steps, handlers...)
+	steps = append(steps, r.Steps[at:]...)
+	r.Steps = steps
+	r.UpdatedAt = now
+
+	return handlers
+}
+
+// HandlerSteps returns the failure handler steps of a block run for the
+// named step or, when of is empty, for the run.
+func (r *WorkflowRun) HandlerSteps(kind HandlerKind, of string) []*StepRun {
+	handlers := make([]*StepRun, 0)
+	for _, step := range r.Steps {
+		if step.Handler == kind && step.HandlerOf == of {
+			handlers = append(handlers, step)
+		}
+	}
+	return handlers
+}
+
+// ChildSteps returns the fan-out items of the named step.
+func (r *WorkflowRun) ChildSteps(parent string) []*StepRun {
+	children := make([]*StepRun, 0)
+	for _, step := range r.Steps {
+		if step.Parent == parent {
+			children = append(children, step)
+		}
+	}
+	return children
+}
+
+// RunningSteps returns the steps currently in flight.
+func (r *WorkflowRun) RunningSteps() []*StepRun {
+	running := make([]*StepRun, 0)
+	for _, step := range r.Steps {
+		if step.IsRunning() {
+			running = append(running, step)
+		}
+	}
+	return running
+}
+
+// PendingSteps returns the steps that have not started yet.
+func (r *WorkflowRun) PendingSteps() []*StepRun {
+	pending := make([]*StepRun, 0)
+	for _, step := range r.Steps {
+		if step.IsPending() {
+			pending = append(pending, step)
+		}
+	}
+	return pending
+}
+
+// AwaitingApprovalSteps returns the steps waiting for approval.
+func (r *WorkflowRun) AwaitingApprovalSteps() []*StepRun {
+	awaiting := make([]*StepRun, 0)
+	for _, step := range r.Steps {
+		if step.IsAwaitingApproval() {
+			awaiting = append(awaiting, step)
+		}
+	}
+	return awaiting
+}
+
+// AwaitingInputSteps returns the steps waiting for an answer.
+func (r *WorkflowRun) AwaitingInputSteps() []*StepRun {
+	awaiting := make([]*StepRun, 0)
+	for _, step := range r.Steps {
+		if step.IsAwaitingInput() {
+			awaiting = append(awaiting, step)
+		}
+	}
+	return awaiting
 }
 
-// HasMoreSteps returns true if there are more steps to execute.
-func (r *WorkflowRun) HasMoreSteps() bool {
-	return r.CurrentStepIdx < len(r.Steps)
+// HasPendingSteps returns true if any step has not started yet.
+func (r *WorkflowRun) HasPendingSteps() bool {
+	return len(r.PendingSteps()) > 0
 }
 
 // GetStepByName returns a step run by name.
@@ -1,9 +1,13 @@
 package workflow
 
 import (
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
 )
 
 func createTestDefinition(t *testing.T) *WorkflowDefinition {
@@ -149,42 +153,357 @@ func TestWorkflowRun_Approve(t *testing.T) {
 	}
 }
 
-func TestWorkflowRun_StepNavigation(t *testing.T) {
+func TestWorkflowRun_Expired(t *testing.T) {
 	def := createTestDefinition(t)
+	def.Timeout = time.Hour
 	run := NewWorkflowRun(def, "test", nil)
+	now := time.Now()
 
-	// Initially should have more steps
-	if !run.HasMoreSteps() {
-		t.Error("HasMoreSteps should return true initially")
+	if run.Deadline == nil {
+		t.Fatal("Deadline = nil, want creation time plus timeout")
+	}
+	if _, ok := run.Expired(now); ok {
+		t.Error("Expired() = true before the deadline, want false")
+	}
+	if reason, ok := run.Expired(now.Add(2 * time.Hour)); !ok || !strings.Contains(reason, "run did not finish by") {
+		t.Errorf("Expired() = %q, %v after the deadline, want deadline reason", reason, ok)
+	}
+
+	run.Start()
+	run.AwaitApproval()
+	run.ExpireApprovalAfter(time.Minute)
+	if reason, ok := run.Expired(now.Add(2 * time.Minute)); !ok || !strings.Contains(reason, "approval was not given by") {
+		t.Errorf("Expired() = %q, %v after the approval deadline, want approval reason", reason, ok)
+	}
+
+	run.Approve()
+	if run.ApprovalDeadline != nil {
+		t.Errorf("ApprovalDeadline after Approve = %v, want nil", run.ApprovalDeadline)
+	}
+
+	run.TimeOut(DeadlineReason(*run.Deadline))
+	if run.Status != RunStatusFailed || !run.TimedOut() {
+		t.Errorf("Status = %v, TimedOut() = %v, want failed and timed out", run.Status, run.TimedOut())
+	}
+	if _, ok := run.Expired(now.Add(2 * time.Hour)); ok {
+		t.Error("Expired() = true for a finished run, want false")
+	}
+}
+
+func TestStepRun_Retry(t *testing.T) {
+	def := createTestDefinition(t)
+	run := NewWorkflowRun(def, "test", nil)
+	step := run.Steps[0]
+	step.MaxRetries = 1
+
+	step.Start(nil)
+	if !step.CanRetry() {
+		t.Fatal("CanRetry() = false, want true")
+	}
+	step.Retry("overloaded", "transient", time.Second)
+
+	if step.Status != StepStatusPending || step.RetryCount != 1 {
+		t.Errorf("Status = %v, RetryCount = %v, want pending and 1", step.Status, step.RetryCount)
+	}
+	if len(step.Attempts) != 1 {
+		t.Fatalf("Attempts = %d, want 1", len(step.Attempts))
+	}
+	attempt := step.Attempts[0]
+	if attempt.Attempt != 1 || attempt.Error != "overloaded" || attempt.Class != "transient" || attempt.Backoff != time.Second {
+		t.Errorf("Attempt = %+v, want attempt 1 failed with overloaded", attempt)
+	}
+	if attempt.StartedAt == nil || attempt.CompletedAt == nil {
+		t.Error("Attempt times not recorded")
+	}
+
+	step.Start(nil)
+	step.Fail("overloaded")
+	if step.CanRetry() {
+		t.Error("CanRetry() after the last attempt = true, want false")
+	}
+}
+
+func TestWorkflowRun_ApproveStep(t *testing.T) {
+	def := createTestDefinition(t)
+	run := NewWorkflowRun(def, "test", nil)
+	run.Start()
+
+	step := run.Steps[0]
+	step.Start(nil)
+	step.Complete(map[string]any{"content": "review"}, 1, 1)
+	step.AwaitApproval()
+	run.AwaitStepApproval(step.Name)
+
+	if run.PendingStep != step.Name {
+		t.Errorf("PendingStep = %q, want %q", run.PendingStep, step.Name)
+	}
+	if step.Succeeded() {
+		t.Error("step awaiting approval should not satisfy its dependents")
+	}
+
+	run.Approve()
+
+	if run.Status != RunStatusExecuting {
+		t.Errorf("Status after Approve = %v, want %v", run.Status, RunStatusExecuting)
+	}
+	if run.PendingStep != "" {
+		t.Errorf("PendingStep after Approve = %q, want empty", run.PendingStep)
+	}
+	if step.Status != StepStatusCompleted || !step.IsApproved() {
+		t.Errorf("step Status = %v, approved = %v, want completed and approved", step.Status, step.IsApproved())
+	}
+	if step.Output["content"] != "review" {
+		t.Errorf("step Output = %v, want output preserved", step.Output)
+	}
+}
+
+func TestWorkflowRun_ReadySteps(t *testing.T) {
+	cfg := &config.WorkflowConfig{
+		Name:    "dag-workflow",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "fetch", Agent: "agent1"},
+			{Name: "analyze", Agent: "agent2", Input: map[string]any{"diff": "${{ steps.fetch.output.diff }}"}},
+			{Name: "scan", Agent: "agent3", DependsOn: []string{"fetch"}},
+			{Name: "review", Agent: "agent2", DependsOn: []string{"analyze", "scan"}},
+		},
+	}
+	def, err := NewWorkflowDefinition(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create definition: %v", err)
+	}
+	run := NewWorkflowRun(def, "test", nil)
+
+	readyNames := func() []string {
+		names := make([]string, 0)
+		for _, s := range run.ReadySteps(def) {
+			names = append(names, s.Name)
+		}
+		return names
+	}
+
+	// Only the root step is ready initially
+	if got := readyNames(); len(got) != 1 || got[0] != "fetch" {
+		t.Fatalf("ReadySteps = %v, want [fetch]", got)
+	}
+
+	run.GetStepByName("fetch").Start(nil)
+	if got := readyNames(); len(got) != 0 {
+		t.Errorf("ReadySteps while fetch is running = %v, want []", got)
+	}
+	if len(run.RunningSteps()) != 1 {
+		t.Errorf("RunningSteps count = %v, want 1", len(run.RunningSteps()))
+	}
+
+	// Both dependents become ready once fetch completes
+	run.GetStepByName("fetch").Complete(nil, 0, 0)
+	if got := readyNames(); len(got) != 2 || got[0] != "analyze" || got[1] != "scan" {
+		t.Fatalf("ReadySteps = %v, want [analyze scan]", got)
+	}
+
+	run.GetStepByName("analyze").Start(nil)
+	run.GetStepByName("scan").Start(nil)
+	if len(run.RunningSteps()) != 2 {
+		t.Errorf("RunningSteps count = %v, want 2", len(run.RunningSteps()))
+	}
+
+	// A skipped dependency still satisfies its dependents
+	run.GetStepByName("analyze").Complete(nil, 0, 0)
+	run.GetStepByName("scan").Skip("condition false")
+	if got := readyNames(); len(got) != 1 || got[0] != "review" {
+		t.Fatalf("ReadySteps = %v, want [review]", got)
+	}
+
+	run.GetStepByName("review").Start(nil)
+	run.GetStepByName("review").Complete(nil, 0, 0)
+	if run.HasPendingSteps() {
+		t.Error("HasPendingSteps should return false after all steps")
+	}
+}
+
+func TestWorkflowRun_ExpandStep(t *testing.T) {
+	cfg := &config.WorkflowConfig{
+		Name:    "fan-out",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "review", Agent: "agent1", Foreach: "${{ trigger.files }}"},
+			{Name: "summarize", Agent: "agent2", DependsOn: []string{"review"}},
+		},
+	}
+	def, err := NewWorkflowDefinition(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create definition: %v", err)
+	}
+	run := NewWorkflowRun(def, "test", nil)
+
+	review := run.GetStepByName("review")
+	review.Start(nil)
+	children := run.ExpandStep(review, []map[string]any{
+		{"item": "a.go", "index": 0},
+		{"item": "b.go", "index": 1},
+	})
+
+	wantNames := []string{"review", "review[0]", "review[1]", "summarize"}
+	if len(run.Steps) != len(wantNames) {
+		t.Fatalf("Steps count = %v, want %v", len(run.Steps), len(wantNames))
+	}
+	for i, name := range wantNames {
+		if run.Steps[i].Name != name {
+			t.Errorf("Steps[%d].Name = %v, want %v", i, run.Steps[i].Name, name)
+		}
+	}
+	if got := run.ChildSteps("review"); len(got) != 2 || got[0] != children[0] || got[1] != children[1] {
+		t.Errorf("ChildSteps = %v, want %v", got, children)
+	}
+	if children[1].Parent != "review" || children[1].Item["item"] != "b.go" {
+		t.Errorf("child = %+v, want parent review and item b.go", children[1])
+	}
+	if children[1].DefinitionName() != "review" {
+		t.Errorf("DefinitionName() = %v, want review", children[1].DefinitionName())
+	}
+
+	// Items are started by the scheduler, never reported as ready
+	if got := run.ReadySteps(def); len(got) != 0 {
+		t.Errorf("ReadySteps = %v, want []", got)
+	}
+}
+
+func TestWorkflowRun_AddHandlers(t *testing.T) {
+	cfg := &config.WorkflowConfig{
+		Name:    "handlers",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "label", Agent: "agent1", OnFailure: []config.StepConfig{{Name: "unlabel", Agent: "agent1"}}},
+			{Name: "review", Agent: "agent2"},
+		},
+		Finally: []config.StepConfig{{Name: "cleanup", Agent: "agent1"}},
+	}
+	def, err := NewWorkflowDefinition(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create definition: %v", err)
+	}
+	run := NewWorkflowRun(def, "test", nil)
+
+	failure := map[string]any{"step": "label", "error": "boom"}
+	unlabel := run.AddHandlers(HandlerOnFailure, "label", def.GetStep("label").OnFailure, failure)
+	cleanup := run.AddHandlers(HandlerFinally, "", def.Finally, failure)
+
+	wantNames := []string{"label", "unlabel", "review", "cleanup"}
+	if len(run.Steps) != len(wantNames) {
+		t.Fatalf("Steps count = %v, want %v", len(run.Steps), len(wantNames))
+	}
+	for i, name := range wantNames {
+		if run.Steps[i].Name != name {
+			t.Errorf("Steps[%d].Name = %v, want %v", i, run.Steps[i].Name, name)
+		}
+	}
+	if got := run.HandlerSteps(HandlerOnFailure, "label"); len(got) != 1 || got[0] != unlabel[0] {
+		t.Errorf("HandlerSteps = %v, want %v", got, unlabel)
+	}
+	if unlabel[0].StepIndex != 0 || cleanup[0].StepIndex != 3 {
+		t.Errorf("StepIndex = %v, %v, want 0, 3", unlabel[0].StepIndex, cleanup[0].StepIndex)
+	}
+	if got := cleanup[0].Item["failure"]; got == nil {
+		t.Error("handler item is missing the failure")
 	}
 
-	// Get first step
-	step := run.CurrentStep()
-	if step == nil {
-		t.Fatal("CurrentStep returned nil")
+	// Handlers are started by the scheduler, never reported as ready
+	for _, step := range run.ReadySteps(def) {
+		if step.Handler != "" {
+			t.Errorf("ReadySteps includes handler %v", step.Name)
+		}
 	}
-	if step.Name != "step1" {
-		t.Errorf("First step name = %v, want step1", step.Name)
+}
+
+func TestWorkflowRun_ReadySteps_FailedDependency(t *testing.T) {
+	def := createTestDefinition(t)
+	def.Steps[1].DependsOn = []string{"step1"}
+	run := NewWorkflowRun(def, "test", nil)
+
+	run.Steps[0].Start(nil)
+	run.Steps[0].Fail("boom")
+
+	for _, s := range run.ReadySteps(def) {
+		if s.Name == "step2" {
+			t.Error("step2 should not be ready when its dependency failed")
+		}
 	}
+}
 
-	// Advance to next step
-	run.AdvanceStep()
-	step = run.CurrentStep()
-	if step.Name != "step2" {
-		t.Errorf("Second step name = %v, want step2", step.Name)
+func TestNewRerun(t *testing.T) {
+	def := createTestDefinition(t)
+
+	original := NewWorkflowRun(def, "test", map[string]any{"pr": 42})
+	for _, step := range original.Steps[:2] {
+		step.Start(nil)
+		step.Complete(map[string]any{"content": step.Name}, 10, 5)
+	}
+	original.Steps[2].Start(nil)
+	original.Steps[2].Fail("github unavailable")
+	original.Fail("step step3 failed")
+
+	tests := []struct {
+		name       string
+		opts       RerunOptions
+		wantReused []string
+		wantErr    error
+	}{
+		{"all steps", RerunOptions{}, nil, nil},
+		{"only failed", RerunOptions{OnlyFailed: true}, []string{"step1", "step2"}, nil},
+		{"from step", RerunOptions{FromStep: "step2"}, []string{"step1"}, nil},
+		{"unknown step", RerunOptions{FromStep: "missing"}, nil, types.ErrStepNotFound},
 	}
 
-	// Advance again
-	run.AdvanceStep()
-	step = run.CurrentStep()
-	if step.Name != "step3" {
-		t.Errorf("Third step name = %v, want step3", step.Name)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run, err := NewRerun(def, original, "user", tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("NewRerun() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewRerun() error = %v", err)
+			}
+
+			if run.ID == original.ID || run.RerunOf != original.ID {
+				t.Errorf("RerunOf = %v, want %v", run.RerunOf, original.ID)
+			}
+			if run.TriggerData["pr"] != 42 {
+				t.Errorf("TriggerData = %v, want the original trigger data", run.TriggerData)
+			}
+
+			reused := make([]string, 0)
+			for _, step := range run.Steps {
+				if !step.Reused {
+					if !step.IsPending() {
+						t.Errorf("step %s Status = %v, want pending", step.Name, step.Status)
+					}
+					continue
+				}
+				reused = append(reused, step.Name)
+				if step.Status != StepStatusCompleted || step.RunID != run.ID {
+					t.Errorf("reused step %s = %v in run %v", step.Name, step.Status, step.RunID)
+				}
+				if got := run.GetContext("steps." + step.Name + ".output"); got == nil {
+					t.Errorf("output of reused step %s missing from context", step.Name)
+				}
+			}
+			if len(reused) != len(tt.wantReused) {
+				t.Fatalf("reused steps = %v, want %v", reused, tt.wantReused)
+			}
+			for i, name := range tt.wantReused {
+				if reused[i] != name {
+					t.Errorf("reused steps = %v, want %v", reused, tt.wantReused)
+				}
+			}
+		})
 	}
 
-	// Advance past last step
-	run.AdvanceStep()
-	if run.HasMoreSteps() {
-		t.Error("HasMoreSteps should return false after all steps")
+	// Active runs cannot be re-run
+	if _, err := NewRerun(def, NewWorkflowRun(def, "test", nil), "user", RerunOptions{}); err == nil {
+		t.Error("NewRerun() of an active run expected error")
 	}
 }
 
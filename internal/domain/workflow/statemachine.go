@@ -147,7 +147,7 @@ func MapStatusToState(status RunStatus) string {
 		return "policy_check"
 	case RunStatusAwaitingApproval:
 		return "awaiting_approval"
-	case RunStatusExecuting:
+	case RunStatusExecuting, RunStatusAwaitingInput:
 		return "executing"
 	case RunStatusCompleted:
 		return "completed"
@@ -15,19 +15,37 @@ const (
 	StepStatusCompleted StepStatus = "completed"
 	StepStatusFailed    StepStatus = "failed"
 	StepStatusSkipped   StepStatus = "skipped"
+	StepStatusCancelled StepStatus = "cancelled"
+
+	StepStatusAwaitingApproval StepStatus = "awaiting_approval"
+	StepStatusAwaitingInput    StepStatus = "awaiting_input"
 )
 
 // IsTerminal returns true if the status is a terminal state.
 func (s StepStatus) IsTerminal() bool {
-	return s == StepStatusCompleted || s == StepStatusFailed || s == StepStatusSkipped
+	return s == StepStatusCompleted || s == StepStatusFailed || s == StepStatusSkipped || s == StepStatusCancelled
 }
 
+// HandlerKind identifies the block a failure handler step belongs to.
+type HandlerKind string
+
+const (
+	// HandlerOnFailure handlers run when a step or the run fails.
+	HandlerOnFailure HandlerKind = "on_failure"
+	// HandlerFinally handlers run when a step or the run finishes.
+	HandlerFinally HandlerKind = "finally"
+)
+
 // StepRun represents a single step execution within a workflow run.
 type StepRun struct {
 	ID               types.StepID
 	RunID            types.RunID
 	StepIndex        int
 	Name             string
+	Parent           string         // Fan-out step this step runs an item of
+	Item             map[string]any // Expression variables of the fan-out item or handler
+	Handler          HandlerKind    // Block of a failure handler step, empty for other steps
+	HandlerOf        string         // Step a handler runs for, empty for the run
 	AgentID          string
 	Status           StepStatus
 	Input            map[string]any
@@ -36,14 +54,40 @@ type StepRun struct {
 	Timeout          time.Duration
 	MaxRetries       int
 	RetryCount       int
+	Attempts         []StepAttempt // Failed attempts that were retried
 	Error            string
 	TokensIn         int
 	TokensOut        int
+	CostUSD          float64  // Cost of the tokens in US dollars
+	Warnings         []string // Policy warnings raised before the step ran
+	ApprovedAt       *time.Time
+	Reused           bool        // Result carried over from the run being re-run
+	CacheHit         bool        // Result served from the step cache
+	ChildRunID       types.RunID // Run of the workflow a sub-workflow step uses
 	StartedAt        *time.Time
 	CompletedAt      *time.Time
 	CreatedAt        time.Time
 }
 
+// StepAttempt records a failed attempt of a step that was retried.
+type StepAttempt struct {
+	Attempt     int           `json:"attempt"`
+	Error       string        `json:"error"`
+	Class       string        `json:"class"`   // Error class the attempt was retried for
+	Backoff     time.Duration `json:"backoff"` // Delay before the next attempt
+	StartedAt   *time.Time    `json:"started_at,omitempty"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty"`
+}
+
+// DefinitionName returns the name of the step definition the step runs,
+// which for a fan-out item is the name of its parent step.
+func (s *StepRun) DefinitionName() string {
+	if s.Parent != "" {
+		return s.Parent
+	}
+	return s.Name
+}
+
 // Start begins the step execution.
 func (s *StepRun) Start(input map[string]any) {
 	now := time.Now()
@@ -78,13 +122,100 @@ func (s *StepRun) Skip(reason string) {
 	s.CompletedAt = &now
 }
 
-// CanRetry returns true if the step can be retried.
+// Cancel marks the step as cancelled, e.g. because an upstream step failed.
+func (s *StepRun) Cancel(reason string) {
+	now := time.Now()
+	s.Status = StepStatusCancelled
+	s.Error = reason
+	s.CompletedAt = &now
+}
+
+// Reset returns a step interrupted mid-execution to pending so that it runs
+// again.
+func (s *StepRun) Reset() {
+	s.Status = StepStatusPending
+	s.Input = nil
+	s.Error = ""
+	s.StartedAt = nil
+	s.CompletedAt = nil
+}
+
+// Reuse carries over the result of the same step in a previous run, so that
+// the step does not execute again.
+func (s *StepRun) Reuse(prev *StepRun) {
+	s.Status = prev.Status
+	s.Input = prev.Input
+	s.Output = prev.Output
+	s.Error = prev.Error
+	s.Warnings = prev.Warnings
+	s.ApprovedAt = prev.ApprovedAt
+	s.StartedAt = prev.StartedAt
+	s.CompletedAt = prev.CompletedAt
+	s.ChildRunID = prev.ChildRunID
+	s.Reused = true
+}
+
+// AwaitApproval pauses the step until it is approved. A step that has not
+// started waits for approval to run; a completed step waits for approval
+// before its dependents run.
+func (s *StepRun) AwaitApproval() {
+	s.Status = StepStatusAwaitingApproval
+}
+
+// Approve approves a step awaiting approval. A step that has not started
+// becomes pending again, a step that already ran becomes completed.
+func (s *StepRun) Approve() {
+	now := time.Now()
+	s.ApprovedAt = &now
+	if s.StartedAt != nil {
+		s.Status = StepStatusCompleted
+	} else {
+		s.Status = StepStatusPending
+	}
+}
+
+// IsApproved returns true if the step has been approved.
+func (s *StepRun) IsApproved() bool {
+	return s.ApprovedAt != nil
+}
+
+// IsAwaitingApproval returns true if the step is waiting for approval.
+func (s *StepRun) IsAwaitingApproval() bool {
+	return s.Status == StepStatusAwaitingApproval
+}
+
+// AwaitInput pauses a human input step until a user answers prompt. The
+// prompt is kept as the input of the step.
+func (s *StepRun) AwaitInput(prompt string) {
+	now := time.Now()
+	s.Status = StepStatusAwaitingInput
+	s.Input = map[string]any{"prompt": prompt}
+	s.StartedAt = &now
+}
+
+// IsAwaitingInput returns true if the step is waiting for an answer.
+func (s *StepRun) IsAwaitingInput() bool {
+	return s.Status == StepStatusAwaitingInput
+}
+
+// CanRetry returns true if the running or failed step has attempts left.
 func (s *StepRun) CanRetry() bool {
-	return s.Status == StepStatusFailed && s.RetryCount < s.MaxRetries
+	return (s.Status == StepStatusRunning || s.Status == StepStatusFailed) && s.RetryCount < s.MaxRetries
 }
 
-// IncrementRetry increments the retry counter and resets status.
-func (s *StepRun) IncrementRetry() {
+// Retry records the attempt that failed with err, retried for an error of
+// the given class after backoff, and returns the step to pending. The step
+// does not pass through failed, which is reserved for its final outcome.
+func (s *StepRun) Retry(err, class string, backoff time.Duration) {
+	now := time.Now()
+	s.Attempts = append(s.Attempts, StepAttempt{
+		Attempt:     s.RetryCount + 1,
+		Error:       err,
+		Class:       class,
+		Backoff:     backoff,
+		StartedAt:   s.StartedAt,
+		CompletedAt: &now,
+	})
 	s.RetryCount++
 	s.Status = StepStatusPending
 	s.Error = ""
@@ -111,6 +242,12 @@ func (s *StepRun) IsRunning() bool {
 	return s.Status == StepStatusRunning
 }
 
+// Succeeded returns true if the step completed or was skipped, so that
+// dependent steps may run.
+func (s *StepRun) Succeeded() bool {
+	return s.Status == StepStatusCompleted || s.Status == StepStatusSkipped
+}
+
 // IsPending returns true if the step is pending.
 func (s *StepRun) IsPending() bool {
 	return s.Status == StepStatusPending
@@ -118,9 +255,10 @@ func (s *StepRun) IsPending() bool {
 
 // TokenUsage represents the token usage for an LLM call.
 type TokenUsage struct {
-	Input  int
-	Output int
-	Total  int
+	Input   int
+	Output  int
+	Total   int
+	CostUSD float64 // Cost of the tokens in US dollars, 0 for models without a known price
 }
 
 // StepResult represents the result of a step execution.
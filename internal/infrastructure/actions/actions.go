@@ -0,0 +1,161 @@
+// Package actions provides the built-in actions that do not depend on an
+// external service client: HTTP requests and jq transforms.
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/jq"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// maxResponseSize caps the response body an HTTP request action reads.
+const maxResponseSize = 10 << 20
+
+// DefaultTimeout bounds an HTTP request action, including reading the
+// response body.
+const DefaultTimeout = 30 * time.Second
+
+// NewClient returns the client HTTP request actions are sent with by
+// default.
+func NewClient() *http.Client {
+	return &http.Client{Timeout: DefaultTimeout}
+}
+
+// Register registers the http.request and transform.jq actions. HTTP
+// requests are sent with client.
+func Register(set *workflow.ActionSet, client *http.Client) {
+	set.Register(workflow.Action{
+		Name:         config.ActionHTTPRequest,
+		Description:  "Send an HTTP request",
+		Capabilities: []string{"network"},
+		Handler: func(ctx context.Context, input map[string]any) (map[string]any, error) {
+			return httpRequest(ctx, client, input)
+		},
+	})
+
+	set.Register(workflow.Action{
+		Name:        config.ActionTransformJQ,
+		Description: "Transform a value with a jq filter",
+		Handler:     transformJQ,
+	})
+}
+
+// httpRequest sends the request described by input. A body that is not a
+// string is sent as JSON. Responses with an error status fail the action.
+func httpRequest(ctx context.Context, client *http.Client, input map[string]any) (map[string]any, error) {
+	url, _ := input["url"].(string)
+	if url == "" {
+		return nil, fmt.Errorf("%w: url is required", types.ErrActionInputInvalid)
+	}
+	method, _ := input["method"].(string)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	contentType := ""
+	switch b := input["body"].(type) {
+	case nil:
+	case string:
+		body = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("%w: body: %v", types.ErrActionInputInvalid, err)
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), url, body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", types.ErrActionInputInvalid, err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if headers, ok := input["headers"].(map[string]any); ok {
+		for name, value := range headers {
+			req.Header.Set(name, fmt.Sprint(value))
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, &workflow.ActionError{
+			Action:     config.ActionHTTPRequest,
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("%s %s returned status %d: %s", req.Method, url, resp.StatusCode, truncate(string(data), 200)),
+		}
+	}
+
+	headers := make(map[string]any, len(resp.Header))
+	for name := range resp.Header {
+		headers[name] = resp.Header.Get(name)
+	}
+	output := map[string]any{
+		"status":  resp.StatusCode,
+		"headers": headers,
+		"body":    string(data),
+	}
+
+	// JSON responses are also exposed decoded
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil &&
+		(mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		var value any
+		if err := json.Unmarshal(data, &value); err == nil {
+			output["json"] = value
+		}
+	}
+
+	return output, nil
+}
+
+// transformJQ applies the filter to the input value. A filter producing a
+// single value returns it as the result; other filters return the list of
+// values they produce.
+func transformJQ(ctx context.Context, input map[string]any) (map[string]any, error) {
+	filter, _ := input["filter"].(string)
+	if filter == "" {
+		return nil, fmt.Errorf("%w: filter is required", types.ErrActionInputInvalid)
+	}
+
+	results, err := jq.Run(ctx, filter, input["input"])
+	if err != nil {
+		return nil, err
+	}
+
+	var result any = results
+	if len(results) == 1 {
+		result = results[0]
+	}
+	return map[string]any{"result": result}, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
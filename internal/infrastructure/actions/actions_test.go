@@ -0,0 +1,109 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+func TestHTTPRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("slow down"))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"method":       r.Method,
+			"content_type": r.Header.Get("Content-Type"),
+			"token":        r.Header.Get("X-Token"),
+			"body":         string(body),
+		})
+	}))
+	defer server.Close()
+
+	set := workflow.NewActionSet()
+	Register(set, server.Client())
+	action, ok := set.Get(config.ActionHTTPRequest)
+	if !ok {
+		t.Fatalf("action %s is not registered", config.ActionHTTPRequest)
+	}
+
+	output, err := action.Handler(context.Background(), map[string]any{
+		"url":     server.URL + "/hooks",
+		"method":  "post",
+		"headers": map[string]any{"X-Token": "secret"},
+		"body":    map[string]any{"ok": true},
+	})
+	if err != nil {
+		t.Fatalf("Handler() error = %v", err)
+	}
+	if output["status"] != http.StatusOK {
+		t.Errorf("status = %v, want %v", output["status"], http.StatusOK)
+	}
+	want := map[string]any{
+		"method":       "POST",
+		"content_type": "application/json",
+		"token":        "secret",
+		"body":         `{"ok":true}`,
+	}
+	if !reflect.DeepEqual(output["json"], want) {
+		t.Errorf("json = %v, want %v", output["json"], want)
+	}
+
+	_, err = action.Handler(context.Background(), map[string]any{"url": server.URL + "/fail"})
+	var actionErr *workflow.ActionError
+	if !errors.As(err, &actionErr) || actionErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Handler() error = %v, want action error with status 429", err)
+	}
+
+	if _, err := action.Handler(context.Background(), map[string]any{}); !errors.Is(err, types.ErrActionInputInvalid) {
+		t.Errorf("Handler() error = %v, want %v", err, types.ErrActionInputInvalid)
+	}
+}
+
+func TestTransformJQ(t *testing.T) {
+	input := map[string]any{
+		"files": []any{
+			map[string]any{"filename": "main.go"},
+			map[string]any{"filename": "README.md"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		want   any
+	}{
+		{"single value", `[.files[] | select(.filename | endswith(".go")) | .filename]`, []any{"main.go"}},
+		{"several values", ".files[].filename", []any{"main.go", "README.md"}},
+		{"no values", "empty", []any{}},
+	}
+
+	set := workflow.NewActionSet()
+	Register(set, http.DefaultClient)
+	action, _ := set.Get(config.ActionTransformJQ)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := action.Handler(context.Background(), map[string]any{"filter": tt.filter, "input": input})
+			if err != nil {
+				t.Fatalf("Handler() error = %v", err)
+			}
+			if !reflect.DeepEqual(output["result"], tt.want) {
+				t.Errorf("result = %#v, want %#v", output["result"], tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// RegisterActions registers the built-in GitHub actions backed by client.
+// Actions address a pull request by its repo, as owner/name, and pr_number.
+func RegisterActions(set *workflow.ActionSet, client *Client) {
+	set.Register(workflow.Action{
+		Name:         config.ActionGitHubGetPRFiles,
+		Description:  "List the files changed by a pull request",
+		Capabilities: []string{"github-read"},
+		Handler: func(ctx context.Context, input map[string]any) (map[string]any, error) {
+			owner, repo, number, err := pullRequestInput(input)
+			if err != nil {
+				return nil, err
+			}
+			files, err := client.GetPullRequestFiles(ctx, owner, repo, number)
+			if err != nil {
+				return nil, actionError(config.ActionGitHubGetPRFiles, err)
+			}
+
+			list := make([]map[string]any, len(files))
+			var diff strings.Builder
+			for i, f := range files {
+				list[i] = map[string]any{
+					"filename":  f.Filename,
+					"status":    f.Status,
+					"additions": f.Additions,
+					"deletions": f.Deletions,
+					"changes":   f.Changes,
+					"patch":     f.Patch,
+				}
+				if f.Patch != "" {
+					fmt.Fprintf(&diff, "--- a/%s\n+++ b/%s\n%s\n", f.Filename, f.Filename, f.Patch)
+				}
+			}
+
+			return map[string]any{
+				"files": list,
+				"count": len(files),
+				"diff":  diff.String(),
+			}, nil
+		},
+	})
+
+	set.Register(workflow.Action{
+		Name:         config.ActionGitHubCreateReview,
+		Description:  "Create a review on a pull request",
+		Capabilities: []string{"github-write"},
+		Handler: func(ctx context.Context, input map[string]any) (map[string]any, error) {
+			owner, repo, number, err := pullRequestInput(input)
+			if err != nil {
+				return nil, err
+			}
+
+			review := CreateReviewInput{Event: "COMMENT"}
+			review.Body, _ = input["body"].(string)
+			if event, ok := input["event"].(string); ok && event != "" {
+				review.Event = strings.ToUpper(event)
+			}
+			if comments, ok := input["comments"]; ok && comments != nil {
+				data, err := json.Marshal(comments)
+				if err == nil {
+					err = json.Unmarshal(data, &review.Comments)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("%w: comments: %v", types.ErrActionInputInvalid, err)
+				}
+			}
+
+			result, err := client.CreateReview(ctx, owner, repo, number, review)
+			if err != nil {
+				return nil, actionError(config.ActionGitHubCreateReview, err)
+			}
+			return map[string]any{
+				"id":       result.ID,
+				"state":    result.State,
+				"html_url": result.HTMLURL,
+			}, nil
+		},
+	})
+
+	set.Register(workflow.Action{
+		Name:         config.ActionGitHubAddLabels,
+		Description:  "Add labels to a pull request",
+		Capabilities: []string{"github-write"},
+		Handler: func(ctx context.Context, input map[string]any) (map[string]any, error) {
+			owner, repo, number, err := pullRequestInput(input)
+			if err != nil {
+				return nil, err
+			}
+			labels, err := stringList(input["labels"])
+			if err != nil {
+				return nil, fmt.Errorf("%w: labels: %v", types.ErrActionInputInvalid, err)
+			}
+
+			result, err := client.AddLabels(ctx, owner, repo, number, labels)
+			if err != nil {
+				return nil, actionError(config.ActionGitHubAddLabels, err)
+			}
+			names := make([]string, len(result))
+			for i, label := range result {
+				names[i] = label.Name
+			}
+			return map[string]any{"labels": names}, nil
+		},
+	})
+}
+
+// pullRequestInput reads the pull request an action addresses.
+func pullRequestInput(input map[string]any) (owner, repo string, number int, err error) {
+	full, _ := input["repo"].(string)
+	owner, repo, ok := strings.Cut(full, "/")
+	if !ok || owner == "" || repo == "" {
+		return "", "", 0, fmt.Errorf("%w: repo %q must be owner/name", types.ErrActionInputInvalid, full)
+	}
+
+	switch n := input["pr_number"].(type) {
+	case int:
+		number = n
+	case int64:
+		number = int(n)
+	case float64:
+		number = int(n)
+	case string:
+		number, err = strconv.Atoi(n)
+	}
+	if err != nil || number <= 0 {
+		return "", "", 0, fmt.Errorf("%w: pr_number %v is not a pull request number", types.ErrActionInputInvalid, input["pr_number"])
+	}
+	return owner, repo, number, nil
+}
+
+// stringList reads a list of strings or a comma-separated string.
+func stringList(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		var list []string
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		return list, nil
+	case []string:
+		return v, nil
+	case []any:
+		list := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings, got %T", item)
+			}
+			list[i] = s
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("expected a list of strings, got %T", value)
+}
+
+// actionError reports API errors with their status so that failed actions
+// are retried like provider errors.
+func actionError(action string, err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return &workflow.ActionError{Action: action, StatusCode: apiErr.StatusCode, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+func TestRegisterActions(t *testing.T) {
+	var review CreateReviewInput
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/pulls/42/files":
+			_ = json.NewEncoder(w).Encode([]PRFile{
+				{Filename: "main.go", Status: "modified", Additions: 2, Deletions: 1, Changes: 3, Patch: "@@ -1 +1,2 @@"},
+			})
+		case "/repos/owner/repo/pulls/42/reviews":
+			_ = json.NewDecoder(r.Body).Decode(&review)
+			_ = json.NewEncoder(w).Encode(PRReview{ID: 7, State: "COMMENTED", HTMLURL: "https://github.com/owner/repo/pull/42"})
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(APIError{Message: "unavailable"})
+		}
+	}))
+	defer server.Close()
+
+	set := workflow.NewActionSet()
+	RegisterActions(set, NewClient(testLogger(t), Config{BaseURL: server.URL}))
+	ctx := context.Background()
+
+	getFiles, _ := set.Get(config.ActionGitHubGetPRFiles)
+	output, err := getFiles.Handler(ctx, map[string]any{"repo": "owner/repo", "pr_number": float64(42)})
+	if err != nil {
+		t.Fatalf("get_pr_files error = %v", err)
+	}
+	if output["count"] != 1 {
+		t.Errorf("count = %v, want %v", output["count"], 1)
+	}
+	if want := "--- a/main.go\n+++ b/main.go\n@@ -1 +1,2 @@\n"; output["diff"] != want {
+		t.Errorf("diff = %q, want %q", output["diff"], want)
+	}
+
+	createReview, _ := set.Get(config.ActionGitHubCreateReview)
+	output, err = createReview.Handler(ctx, map[string]any{
+		"repo":      "owner/repo",
+		"pr_number": "42",
+		"body":      "Looks good",
+		"event":     "approve",
+		"comments":  []any{map[string]any{"path": "main.go", "body": "nit", "line": 2}},
+	})
+	if err != nil {
+		t.Fatalf("create_review error = %v", err)
+	}
+	if output["id"] != int64(7) {
+		t.Errorf("id = %v, want %v", output["id"], 7)
+	}
+	if review.Event != "APPROVE" || review.Body != "Looks good" || len(review.Comments) != 1 || *review.Comments[0].Line != 2 {
+		t.Errorf("review = %+v, want approval with one comment", review)
+	}
+
+	addLabels, _ := set.Get(config.ActionGitHubAddLabels)
+	_, err = addLabels.Handler(ctx, map[string]any{"repo": "owner/repo", "pr_number": 42, "labels": "bug, ui"})
+	var actionErr *workflow.ActionError
+	if !errors.As(err, &actionErr) || actionErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("add_labels error = %v, want action error with status 503", err)
+	}
+}
+
+func TestPullRequestInput(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      map[string]any
+		wantNumber int
+		wantErr    bool
+	}{
+		{"number", map[string]any{"repo": "owner/repo", "pr_number": 42}, 42, false},
+		{"float", map[string]any{"repo": "owner/repo", "pr_number": float64(42)}, 42, false},
+		{"string", map[string]any{"repo": "owner/repo", "pr_number": "42"}, 42, false},
+		{"invalid number", map[string]any{"repo": "owner/repo", "pr_number": "forty-two"}, 0, true},
+		{"missing number", map[string]any{"repo": "owner/repo"}, 0, true},
+		{"invalid repo", map[string]any{"repo": "repo", "pr_number": 42}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, repo, number, err := pullRequestInput(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, types.ErrActionInputInvalid) {
+					t.Errorf("pullRequestInput() error = %v, want %v", err, types.ErrActionInputInvalid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pullRequestInput() error = %v", err)
+			}
+			if owner != "owner" || repo != "repo" || number != tt.wantNumber {
+				t.Errorf("pullRequestInput() = %s, %s, %d, want owner, repo, %d", owner, repo, number, tt.wantNumber)
+			}
+		})
+	}
+}
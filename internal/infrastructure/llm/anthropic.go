@@ -13,6 +13,9 @@ import (
 const (
 	anthropicAPIURL     = "https://api.anthropic.com/v1/messages"
 	anthropicAPIVersion = "2023-06-01"
+
+	// anthropicResponseTool is the tool forced to return structured output.
+	anthropicResponseTool = "respond"
 )
 
 // AnthropicProvider implements the Provider interface for Anthropic Claude.
@@ -75,6 +78,7 @@ type anthropicRequest struct {
 	System      string             `json:"system,omitempty"`
 	Messages    []anthropicMessage `json:"messages"`
 	Tools       []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice  *anthropicChoice   `json:"tool_choice,omitempty"`
 	Temperature float64            `json:"temperature,omitempty"`
 	TopP        float64            `json:"top_p,omitempty"`
 	Stop        []string           `json:"stop_sequences,omitempty"`
@@ -82,7 +86,7 @@ type anthropicRequest struct {
 
 type anthropicMessage struct {
 	Role    string `json:"role"`
-	Content string `json:"content"`
+	Content any    `json:"content"` // string or []anthropicBlock
 }
 
 type anthropicTool struct {
@@ -91,6 +95,11 @@ type anthropicTool struct {
 	InputSchema map[string]any `json:"input_schema"`
 }
 
+type anthropicChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
 // anthropicResponse is the response structure from Anthropic API.
 type anthropicResponse struct {
 	ID           string             `json:"id"`
@@ -111,6 +120,18 @@ type anthropicContent struct {
 	Input map[string]any `json:"input,omitempty"`
 }
 
+// anthropicBlock is a request content block. Input is kept as an interface
+// so that tool calls without arguments still send an empty object.
+type anthropicBlock struct {
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Input     any    `json:"input,omitempty"`
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
 type anthropicUsage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
@@ -166,7 +187,7 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 		}
 		apiReq.Messages = append(apiReq.Messages, anthropicMessage{
 			Role:    role,
-			Content: msg.Content,
+			Content: anthropicMessageContent(msg),
 		})
 	}
 
@@ -179,6 +200,19 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 		})
 	}
 
+	// Anthropic has no JSON mode; structured output is requested by forcing
+	// a tool whose input is the schema. This cannot be combined with the
+	// tools of the agent, and the input of a tool must be an object.
+	forced := req.ResponseSchema != nil && len(req.Tools) == 0 && req.ResponseSchema["type"] == "object"
+	if forced {
+		apiReq.Tools = []anthropicTool{{
+			Name:        anthropicResponseTool,
+			Description: "Respond with the final answer.",
+			InputSchema: req.ResponseSchema,
+		}}
+		apiReq.ToolChoice = &anthropicChoice{Type: "tool", Name: anthropicResponseTool}
+	}
+
 	// Marshal request
 	body, err := json.Marshal(apiReq)
 	if err != nil {
@@ -241,6 +275,14 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 		case "text":
 			result.Content = content.Text
 		case "tool_use":
+			if forced && content.Name == anthropicResponseTool {
+				input, err := json.Marshal(content.Input)
+				if err != nil {
+					return nil, fmt.Errorf("failed to encode response: %w", err)
+				}
+				result.Content = string(input)
+				continue
+			}
 			result.ToolCalls = append(result.ToolCalls, ToolCall{
 				ID:        content.ID,
 				Name:      content.Name,
@@ -257,6 +299,9 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 		result.FinishReason = FinishReasonMaxTokens
 	case "tool_use":
 		result.FinishReason = FinishReasonToolUse
+		if forced {
+			result.FinishReason = FinishReasonStop
+		}
 	default:
 		result.FinishReason = FinishReasonStop
 	}
@@ -264,5 +309,39 @@ func (p *AnthropicProvider) Complete(ctx context.Context, req *CompletionRequest
 	return result, nil
 }
 
+// anthropicMessageContent converts a message into Anthropic content. Tool
+// calls and tool results are sent as content blocks.
+func anthropicMessageContent(msg Message) any {
+	if msg.Role == RoleTool && msg.ToolCallID != "" {
+		return []anthropicBlock{{
+			Type:      "tool_result",
+			ToolUseID: msg.ToolCallID,
+			Content:   msg.Content,
+		}}
+	}
+
+	if len(msg.ToolCalls) == 0 {
+		return msg.Content
+	}
+
+	blocks := make([]anthropicBlock, 0, len(msg.ToolCalls)+1)
+	if msg.Content != "" {
+		blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+	}
+	for _, tc := range msg.ToolCalls {
+		input := tc.Arguments
+		if input == nil {
+			input = map[string]any{}
+		}
+		blocks = append(blocks, anthropicBlock{
+			Type:  "tool_use",
+			ID:    tc.ID,
+			Name:  tc.Name,
+			Input: input,
+		})
+	}
+	return blocks
+}
+
 // Ensure AnthropicProvider implements Provider.
 var _ Provider = (*AnthropicProvider)(nil)
@@ -77,8 +77,9 @@ type geminiContent struct {
 }
 
 type geminiPart struct {
-	Text         string              `json:"text,omitempty"`
-	FunctionCall *geminiFunctionCall `json:"functionCall,omitempty"`
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
 }
 
 type geminiFunctionCall struct {
@@ -86,6 +87,11 @@ type geminiFunctionCall struct {
 	Args map[string]any `json:"args"`
 }
 
+type geminiFunctionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
 type geminiTool struct {
 	FunctionDeclarations []geminiFunctionDecl `json:"functionDeclarations,omitempty"`
 }
@@ -101,6 +107,8 @@ type geminiGenerationConfig struct {
 	Temperature     float64  `json:"temperature,omitempty"`
 	TopP            float64  `json:"topP,omitempty"`
 	StopSequences   []string `json:"stopSequences,omitempty"`
+
+	ResponseMimeType string `json:"responseMimeType,omitempty"`
 }
 
 // geminiResponse is the response structure from Gemini API.
@@ -157,6 +165,11 @@ func (p *GeminiProvider) Complete(ctx context.Context, req *CompletionRequest) (
 		apiReq.GenerationConfig.MaxOutputTokens = p.config.MaxTokens
 	}
 
+	// JSON mode cannot be combined with function calling
+	if req.ResponseSchema != nil && len(req.Tools) == 0 {
+		apiReq.GenerationConfig.ResponseMimeType = "application/json"
+	}
+
 	// Add system instruction
 	if req.SystemPrompt != "" {
 		apiReq.SystemInstruction = &geminiContent{
@@ -173,10 +186,13 @@ func (p *GeminiProvider) Complete(ctx context.Context, req *CompletionRequest) (
 		if role == "system" {
 			continue // Handled separately
 		}
+		if role == "tool" {
+			role = "user" // Tool responses are sent as user messages
+		}
 
 		apiReq.Contents = append(apiReq.Contents, geminiContent{
 			Role:  role,
-			Parts: []geminiPart{{Text: msg.Content}},
+			Parts: geminiParts(msg),
 		})
 	}
 
@@ -252,14 +268,15 @@ func (p *GeminiProvider) Complete(ctx context.Context, req *CompletionRequest) (
 		},
 	}
 
-	// Extract content and tool calls
+	// Extract content and tool calls. Gemini does not provide call IDs, so
+	// calls are numbered; results are matched to calls by name.
 	for _, part := range candidate.Content.Parts {
 		if part.Text != "" {
 			result.Content = part.Text
 		}
 		if part.FunctionCall != nil {
 			result.ToolCalls = append(result.ToolCalls, ToolCall{
-				ID:        "", // Gemini doesn't provide IDs
+				ID:        fmt.Sprintf("call_%d", len(result.ToolCalls)),
 				Name:      part.FunctionCall.Name,
 				Arguments: part.FunctionCall.Args,
 			})
@@ -278,8 +295,45 @@ func (p *GeminiProvider) Complete(ctx context.Context, req *CompletionRequest) (
 		result.FinishReason = FinishReasonStop
 	}
 
+	// Gemini finishes with STOP when it calls functions
+	if len(result.ToolCalls) > 0 {
+		result.FinishReason = FinishReasonToolUse
+	}
+
 	return result, nil
 }
 
+// geminiParts converts a message into Gemini parts. Tool calls are sent as
+// functionCall parts and tool results as functionResponse parts.
+func geminiParts(msg Message) []geminiPart {
+	if msg.Role == RoleTool && msg.ToolCallID != "" {
+		return []geminiPart{{
+			FunctionResponse: &geminiFunctionResponse{
+				Name:     msg.Name,
+				Response: map[string]any{"content": msg.Content},
+			},
+		}}
+	}
+
+	if len(msg.ToolCalls) == 0 {
+		return []geminiPart{{Text: msg.Content}}
+	}
+
+	parts := make([]geminiPart, 0, len(msg.ToolCalls)+1)
+	if msg.Content != "" {
+		parts = append(parts, geminiPart{Text: msg.Content})
+	}
+	for _, tc := range msg.ToolCalls {
+		args := tc.Arguments
+		if args == nil {
+			args = map[string]any{}
+		}
+		parts = append(parts, geminiPart{
+			FunctionCall: &geminiFunctionCall{Name: tc.Name, Args: args},
+		})
+	}
+	return parts
+}
+
 // Ensure GeminiProvider implements Provider.
 var _ Provider = (*GeminiProvider)(nil)
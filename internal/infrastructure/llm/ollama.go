@@ -72,6 +72,7 @@ type ollamaRequest struct {
 	Tools    []ollamaTool    `json:"tools,omitempty"`
 	Stream   bool            `json:"stream"`
 	Options  *ollamaOptions  `json:"options,omitempty"`
+	Format   map[string]any  `json:"format,omitempty"`
 }
 
 type ollamaMessage struct {
@@ -148,6 +149,7 @@ func (p *OllamaProvider) Complete(ctx context.Context, req *CompletionRequest) (
 			NumPredict:  req.MaxTokens,
 			Stop:        req.StopSequences,
 		},
+		Format: req.ResponseSchema,
 	}
 
 	// Add system prompt
@@ -160,10 +162,16 @@ func (p *OllamaProvider) Complete(ctx context.Context, req *CompletionRequest) (
 
 	// Convert messages
 	for _, msg := range req.Messages {
-		apiReq.Messages = append(apiReq.Messages, ollamaMessage{
+		apiMsg := ollamaMessage{
 			Role:    string(msg.Role),
 			Content: msg.Content,
-		})
+		}
+		for _, tc := range msg.ToolCalls {
+			apiMsg.ToolCalls = append(apiMsg.ToolCalls, ollamaToolCall{
+				Function: ollamaToolFunc{Name: tc.Name, Arguments: tc.Arguments},
+			})
+		}
+		apiReq.Messages = append(apiReq.Messages, apiMsg)
 	}
 
 	// Convert tools
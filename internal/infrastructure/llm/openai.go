@@ -75,6 +75,18 @@ type openaiRequest struct {
 	Temperature float64         `json:"temperature,omitempty"`
 	TopP        float64         `json:"top_p,omitempty"`
 	Stop        []string        `json:"stop,omitempty"`
+
+	ResponseFormat *openaiResponseFormat `json:"response_format,omitempty"`
+}
+
+type openaiResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openaiJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openaiJSONSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
 }
 
 type openaiMessage struct {
@@ -159,6 +171,13 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 		apiReq.Model = "gpt-4o"
 	}
 
+	if req.ResponseSchema != nil {
+		apiReq.ResponseFormat = &openaiResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &openaiJSONSchema{Name: "response", Schema: req.ResponseSchema},
+		}
+	}
+
 	// Add system prompt
 	if req.SystemPrompt != "" {
 		apiReq.Messages = append(apiReq.Messages, openaiMessage{
@@ -169,10 +188,23 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *CompletionRequest) (
 
 	// Convert messages
 	for _, msg := range req.Messages {
-		apiReq.Messages = append(apiReq.Messages, openaiMessage{
-			Role:    string(msg.Role),
-			Content: msg.Content,
-		})
+		apiMsg := openaiMessage{
+			Role:       string(msg.Role),
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, tc := range msg.ToolCalls {
+			args, err := json.Marshal(tc.Arguments)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal tool call arguments: %w", err)
+			}
+			apiMsg.ToolCalls = append(apiMsg.ToolCalls, openaiToolCall{
+				ID:       tc.ID,
+				Type:     "function",
+				Function: openaiToolFunc{Name: tc.Name, Arguments: string(args)},
+			})
+		}
+		apiReq.Messages = append(apiReq.Messages, apiMsg)
 	}
 
 	// Convert tools
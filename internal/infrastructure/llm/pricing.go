@@ -0,0 +1,63 @@
+package llm
+
+import "strings"
+
+// Price is the list price of a model in US dollars per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Cost returns the cost of a call that used the given tokens.
+func (p Price) Cost(tokensIn, tokensOut int) float64 {
+	return (float64(tokensIn)*p.Input + float64(tokensOut)*p.Output) / 1e6
+}
+
+// prices holds the list prices of the hosted models, keyed by model name
+// without the release date suffix.
+var prices = map[string]Price{
+	"claude-opus-4":     {Input: 15, Output: 75},
+	"claude-sonnet-4":   {Input: 3, Output: 15},
+	"claude-3-5-sonnet": {Input: 3, Output: 15},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4},
+	"claude-3-opus":     {Input: 15, Output: 75},
+	"claude-3-sonnet":   {Input: 3, Output: 15},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+
+	"gpt-4o":        {Input: 2.5, Output: 10},
+	"gpt-4o-mini":   {Input: 0.15, Output: 0.6},
+	"gpt-4-turbo":   {Input: 10, Output: 30},
+	"gpt-4":         {Input: 30, Output: 60},
+	"gpt-3.5-turbo": {Input: 0.5, Output: 1.5},
+	"o1":            {Input: 15, Output: 60},
+	"o1-mini":       {Input: 3, Output: 12},
+
+	"gemini-2.0-flash":    {Input: 0.1, Output: 0.4},
+	"gemini-1.5-pro":      {Input: 1.25, Output: 5},
+	"gemini-1.5-flash":    {Input: 0.075, Output: 0.3},
+	"gemini-1.5-flash-8b": {Input: 0.0375, Output: 0.15},
+	"gemini-1.0-pro":      {Input: 0.5, Output: 1.5},
+}
+
+// ModelPrice returns the list price of a model. Dated and suffixed model
+// names match the longest known model name they start with. Models without
+// a known price, such as local Ollama models, return false.
+func ModelPrice(model string) (Price, bool) {
+	best := ""
+	for name := range prices {
+		if len(name) > len(best) && (model == name || strings.HasPrefix(model, name+"-")) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return prices[best], true
+}
+
+// Cost returns the cost in US dollars of a call to model, 0 for models
+// without a known price.
+func Cost(model string, tokensIn, tokensOut int) float64 {
+	price, _ := ModelPrice(model)
+	return price.Cost(tokensIn, tokensOut)
+}
@@ -0,0 +1,37 @@
+package llm
+
+import "testing"
+
+func TestModelPrice(t *testing.T) {
+	tests := []struct {
+		model string
+		want  Price
+		found bool
+	}{
+		{"gpt-4o", Price{Input: 2.5, Output: 10}, true},
+		{"gpt-4o-mini", Price{Input: 0.15, Output: 0.6}, true},
+		{"gpt-4o-2024-08-06", Price{Input: 2.5, Output: 10}, true},
+		{"claude-sonnet-4-20250514", Price{Input: 3, Output: 15}, true},
+		{"gemini-1.5-flash-8b", Price{Input: 0.0375, Output: 0.15}, true},
+		{"gpt-4oo", Price{}, false},
+		{"llama3", Price{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, found := ModelPrice(tt.model)
+			if got != tt.want || found != tt.found {
+				t.Errorf("ModelPrice() = %v, %v, want %v, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestCost(t *testing.T) {
+	if got := Cost("claude-sonnet-4-20250514", 1000, 2000); got != 0.033 {
+		t.Errorf("Cost() = %v, want %v", got, 0.033)
+	}
+	if got := Cost("llama3", 1000, 2000); got != 0 {
+		t.Errorf("Cost() = %v, want %v", got, 0)
+	}
+}
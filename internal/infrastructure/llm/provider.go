@@ -28,13 +28,19 @@ type CompletionRequest struct {
 	TopP          float64
 	StopSequences []string
 	Metadata      map[string]any
+
+	// ResponseSchema requests JSON content matching the schema. Providers
+	// with a structured output mode enforce it; others rely on the prompt.
+	ResponseSchema map[string]any
 }
 
 // Message represents a chat message.
 type Message struct {
-	Role    Role
-	Content string
-	Name    string // Optional: function/tool name for tool responses
+	Role       Role
+	Content    string
+	Name       string     // Optional: function/tool name for tool responses
+	ToolCalls  []ToolCall // Optional: tool calls requested by an assistant message
+	ToolCallID string     // Optional: ID of the tool call a tool message responds to
 }
 
 // Role represents the role of a message sender.
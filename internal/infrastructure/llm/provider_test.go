@@ -2,6 +2,10 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -214,3 +218,128 @@ func (m *MockProvider) Complete(ctx context.Context, req *CompletionRequest) (*C
 
 // Ensure MockProvider implements Provider
 var _ Provider = (*MockProvider)(nil)
+
+func TestAnthropicMessageContent(t *testing.T) {
+	text := anthropicMessageContent(Message{Role: RoleUser, Content: "hello"})
+	if text != "hello" {
+		t.Errorf("content = %v, want %v", text, "hello")
+	}
+
+	result, ok := anthropicMessageContent(Message{Role: RoleTool, Content: "ok", ToolCallID: "call_1"}).([]anthropicBlock)
+	if !ok || len(result) != 1 {
+		t.Fatalf("tool result content = %v, want one block", result)
+	}
+	if result[0].Type != "tool_result" || result[0].ToolUseID != "call_1" || result[0].Content != "ok" {
+		t.Errorf("tool result block = %+v", result[0])
+	}
+
+	calls, ok := anthropicMessageContent(Message{
+		Role:      RoleAssistant,
+		Content:   "checking",
+		ToolCalls: []ToolCall{{ID: "call_1", Name: "git_status"}},
+	}).([]anthropicBlock)
+	if !ok || len(calls) != 2 {
+		t.Fatalf("assistant content = %v, want two blocks", calls)
+	}
+	if calls[1].Type != "tool_use" || calls[1].Name != "git_status" {
+		t.Errorf("tool use block = %+v", calls[1])
+	}
+	if input, ok := calls[1].Input.(map[string]any); !ok || input == nil {
+		t.Errorf("tool use input = %v, want empty object", calls[1].Input)
+	}
+}
+
+func TestAnthropicProvider_ResponseSchema(t *testing.T) {
+	var got anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		_, _ = w.Write([]byte(`{
+			"model": "claude-sonnet-4-20250514",
+			"stop_reason": "tool_use",
+			"content": [{"type": "tool_use", "id": "toolu_1", "name": "respond", "input": {"verdict": "APPROVE"}}],
+			"usage": {"input_tokens": 10, "output_tokens": 5}
+		}`))
+	}))
+	defer server.Close()
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"verdict": map[string]any{"type": "string"}},
+	}
+	provider := NewAnthropicProvider(AnthropicConfig{ProviderConfig: ProviderConfig{BaseURL: server.URL}})
+	resp, err := provider.Complete(context.Background(), &CompletionRequest{
+		Messages:       []Message{{Role: RoleUser, Content: "review"}},
+		ResponseSchema: schema,
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if len(got.Tools) != 1 || got.Tools[0].Name != anthropicResponseTool || got.Tools[0].InputSchema["type"] != "object" {
+		t.Errorf("tools = %+v, want the response tool", got.Tools)
+	}
+	if got.ToolChoice == nil || got.ToolChoice.Type != "tool" || got.ToolChoice.Name != anthropicResponseTool {
+		t.Errorf("tool_choice = %+v, want the response tool", got.ToolChoice)
+	}
+	if resp.Content != `{"verdict":"APPROVE"}` {
+		t.Errorf("content = %q, want the tool input", resp.Content)
+	}
+	if len(resp.ToolCalls) != 0 || resp.FinishReason != FinishReasonStop {
+		t.Errorf("tool calls = %v, finish = %s, want none and stop", resp.ToolCalls, resp.FinishReason)
+	}
+}
+
+func TestGeminiProvider_ToolMessages(t *testing.T) {
+	var got geminiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		_, _ = w.Write([]byte(`{
+			"candidates": [{
+				"content": {"role": "model", "parts": [{"functionCall": {"name": "git_diff", "args": {"path": "main.go"}}}]},
+				"finishReason": "STOP"
+			}],
+			"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 5, "totalTokenCount": 15}
+		}`))
+	}))
+	defer server.Close()
+
+	provider := NewGeminiProvider(GeminiConfig{ProviderConfig: ProviderConfig{BaseURL: server.URL}})
+	resp, err := provider.Complete(context.Background(), &CompletionRequest{
+		Messages: []Message{
+			{Role: RoleUser, Content: "review"},
+			{Role: RoleAssistant, Content: "checking", ToolCalls: []ToolCall{{ID: "call_0", Name: "git_status"}}},
+			{Role: RoleTool, Content: "clean", Name: "git_status", ToolCallID: "call_0"},
+		},
+		Tools: []Tool{{Name: "git_status"}, {Name: "git_diff"}},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	if len(got.Contents) != 3 {
+		t.Fatalf("contents = %+v, want three", got.Contents)
+	}
+	call := got.Contents[1]
+	if call.Role != "model" || len(call.Parts) != 2 || call.Parts[0].Text != "checking" ||
+		call.Parts[1].FunctionCall == nil || call.Parts[1].FunctionCall.Name != "git_status" || call.Parts[1].FunctionCall.Args == nil {
+		t.Errorf("assistant content = %+v, want text and functionCall parts", call)
+	}
+	result := got.Contents[2]
+	if result.Role != "user" || len(result.Parts) != 1 || result.Parts[0].FunctionResponse == nil ||
+		result.Parts[0].FunctionResponse.Name != "git_status" || result.Parts[0].FunctionResponse.Response["content"] != "clean" {
+		t.Errorf("tool content = %+v, want a functionResponse part", result)
+	}
+
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID == "" || resp.ToolCalls[0].Name != "git_diff" {
+		t.Errorf("tool calls = %+v, want git_diff with an ID", resp.ToolCalls)
+	}
+	if resp.FinishReason != FinishReasonToolUse {
+		t.Errorf("finish reason = %s, want %s", resp.FinishReason, FinishReasonToolUse)
+	}
+}
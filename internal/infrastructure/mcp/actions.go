@@ -0,0 +1,35 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// RegisterActions registers the built-in actions backed by the registry's
+// file tools. Paths are restricted to the registry's allowed directories.
+func RegisterActions(set *workflow.ActionSet, registry *ToolRegistry) {
+	set.Register(workflow.Action{
+		Name:         config.ActionFileRead,
+		Description:  "Read the contents of a file",
+		Capabilities: []string{"file-read"},
+		Handler: func(ctx context.Context, input map[string]any) (map[string]any, error) {
+			path, err := requiredString(input, "path")
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", types.ErrActionInputInvalid, err)
+			}
+			result, err := registry.FileRead(ctx, path)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{
+				"path":    path,
+				"content": result.Content,
+				"size":    result.Size,
+			}, nil
+		},
+	})
+}
@@ -0,0 +1,141 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/agents"
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/llm"
+)
+
+// RegisterAgentTools exposes the registry's file and git tools to agents
+// under the same names as the MCP server tools. Shell execution is only
+// registered when allowShell is set.
+func RegisterAgentTools(set *agents.ToolSet, registry *ToolRegistry, allowShell bool) {
+	set.Register(llm.Tool{
+		Name:        "file_read",
+		Description: "Read the contents of a file",
+		Parameters: objectSchema(map[string]any{
+			"path": stringProperty("Path to the file to read"),
+		}, "path"),
+	}, func(ctx context.Context, args map[string]any) (any, error) {
+		path, err := requiredString(args, "path")
+		if err != nil {
+			return nil, err
+		}
+		result, err := registry.FileRead(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		return result.Content, nil
+	})
+
+	set.Register(llm.Tool{
+		Name:        "file_write",
+		Description: "Write content to a file",
+		Parameters: objectSchema(map[string]any{
+			"path":    stringProperty("Path to the file to write"),
+			"content": stringProperty("Content to write to the file"),
+		}, "path", "content"),
+	}, func(ctx context.Context, args map[string]any) (any, error) {
+		path, err := requiredString(args, "path")
+		if err != nil {
+			return nil, err
+		}
+		content, _ := args["content"].(string)
+		if err := registry.FileWrite(ctx, path, content); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Successfully wrote %d bytes to %s", len(content), path), nil
+	})
+
+	set.Register(llm.Tool{
+		Name:        "file_list",
+		Description: "List directory contents",
+		Parameters: objectSchema(map[string]any{
+			"path": stringProperty("Directory path to list (defaults to current directory)"),
+		}),
+	}, func(ctx context.Context, args map[string]any) (any, error) {
+		path, _ := args["path"].(string)
+		if path == "" {
+			path = "."
+		}
+		return registry.FileList(ctx, path)
+	})
+
+	set.Register(llm.Tool{
+		Name:        "git_status",
+		Description: "Get the current git repository status",
+		Parameters:  objectSchema(map[string]any{}),
+	}, func(ctx context.Context, args map[string]any) (any, error) {
+		return registry.GitStatus(ctx)
+	})
+
+	set.Register(llm.Tool{
+		Name:        "git_diff",
+		Description: "Get git diff for staged or unstaged changes",
+		Parameters: objectSchema(map[string]any{
+			"staged": map[string]any{"type": "boolean", "description": "Show staged changes only"},
+		}),
+	}, func(ctx context.Context, args map[string]any) (any, error) {
+		staged, _ := args["staged"].(bool)
+		return registry.GitDiff(ctx, staged)
+	})
+
+	set.Register(llm.Tool{
+		Name:        "git_log",
+		Description: "Get recent commit history",
+		Parameters: objectSchema(map[string]any{
+			"count": map[string]any{"type": "integer", "description": "Number of commits to show (default 10)"},
+		}),
+	}, func(ctx context.Context, args map[string]any) (any, error) {
+		count := 10
+		if n, ok := args["count"].(float64); ok && n > 0 {
+			count = int(n)
+		}
+		return registry.GitLog(ctx, count)
+	})
+
+	if allowShell {
+		set.Register(llm.Tool{
+			Name:        "shell_exec",
+			Description: "Execute a shell command (sandboxed)",
+			Parameters: objectSchema(map[string]any{
+				"command": stringProperty("Shell command to execute"),
+				"workdir": stringProperty("Working directory for command execution"),
+			}, "command"),
+		}, func(ctx context.Context, args map[string]any) (any, error) {
+			command, err := requiredString(args, "command")
+			if err != nil {
+				return nil, err
+			}
+			workdir, _ := args["workdir"].(string)
+			return registry.ShellExec(ctx, command, workdir)
+		})
+	}
+}
+
+// objectSchema builds a JSON schema for tool parameters.
+func objectSchema(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringProperty(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+// requiredString returns a non-empty string argument.
+func requiredString(args map[string]any, name string) (string, error) {
+	value, _ := args[name].(string)
+	if value == "" {
+		return "", fmt.Errorf("argument %q is required", name)
+	}
+	return value, nil
+}
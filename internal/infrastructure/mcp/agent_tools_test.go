@@ -0,0 +1,59 @@
+package mcp_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/agents"
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/llm"
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/mcp"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+func TestRegisterAgentTools(t *testing.T) {
+	set := agents.NewToolSet()
+	mcp.RegisterAgentTools(set, mcp.NewToolRegistry(newTestLogger(), []string{"."}), false)
+
+	for _, name := range []string{"file_read", "file_write", "file_list", "git_status", "git_diff", "git_log"} {
+		if !set.Has(name) {
+			t.Errorf("expected tool %s to be registered", name)
+		}
+	}
+	if set.Has("shell_exec") {
+		t.Error("expected shell_exec not to be registered when shell is disabled")
+	}
+}
+
+func TestRegisterAgentTools_FileRead(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "README.md")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	set := agents.NewToolSet()
+	mcp.RegisterAgentTools(set, mcp.NewToolRegistry(newTestLogger(), []string{tmpDir}), false)
+
+	result, err := set.Call(context.Background(), llm.ToolCall{
+		ID:        "call_1",
+		Name:      "file_read",
+		Arguments: map[string]any{"path": path},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "hello" {
+		t.Errorf("result = %v, want %v", result, "hello")
+	}
+
+	if _, err := set.Call(context.Background(), llm.ToolCall{Name: "file_read"}); err == nil {
+		t.Error("expected error for missing path argument")
+	}
+
+	if _, err := set.Call(context.Background(), llm.ToolCall{Name: "unknown"}); !errors.Is(err, types.ErrMCPToolNotFound) {
+		t.Errorf("error = %v, want %v", err, types.ErrMCPToolNotFound)
+	}
+}
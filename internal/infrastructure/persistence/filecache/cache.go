@@ -0,0 +1,105 @@
+// Package filecache stores cached step results as JSON files in a local
+// directory, so that results are reused across CLI invocations.
+package filecache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+// StepCache is a workflow.StepCache that keeps one file per cached result.
+type StepCache struct {
+	dir string
+}
+
+// NewStepCache creates a step cache in dir. The directory is created when
+// the first result is saved.
+func NewStepCache(dir string) *StepCache {
+	return &StepCache{dir: dir}
+}
+
+// GetCachedResult retrieves the step result cached under a key. Expired
+// results are removed.
+func (c *StepCache) GetCachedResult(ctx context.Context, key string) (*workflow.CachedResult, error) {
+	path, err := c.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, types.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached result: %w", err)
+	}
+
+	var result workflow.CachedResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cached result: %w", err)
+	}
+	if result.Expired(time.Now()) {
+		_ = os.Remove(path)
+		return nil, types.ErrCacheMiss
+	}
+	return &result, nil
+}
+
+// SaveCachedResult stores a step result, replacing any result cached under
+// the same key.
+func (c *StepCache) SaveCachedResult(ctx context.Context, result *workflow.CachedResult) error {
+	path, err := c.path(result.Key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached result: %w", err)
+	}
+
+	if err := os.MkdirAll(c.dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Write to a temporary file first so that readers never see a partial result
+	tmp, err := os.CreateTemp(c.dir, ".result-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to store cache file: %w", err)
+	}
+	return nil
+}
+
+// path returns the file of a key. Keys are hashes, anything that could
+// leave the cache directory is rejected.
+func (c *StepCache) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\.`) {
+		return "", fmt.Errorf("invalid cache key %q", key)
+	}
+	return filepath.Join(c.dir, key+".json"), nil
+}
+
+// Ensure StepCache implements workflow.StepCache.
+var _ workflow.StepCache = (*StepCache)(nil)
@@ -0,0 +1,45 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+)
+
+func TestStepCache(t *testing.T) {
+	ctx := context.Background()
+	cache := NewStepCache(t.TempDir() + "/steps")
+
+	if _, err := cache.GetCachedResult(ctx, "abc123"); !errors.Is(err, types.ErrCacheMiss) {
+		t.Fatalf("GetCachedResult() error = %v, want %v", err, types.ErrCacheMiss)
+	}
+
+	step := &workflow.StepRun{RunID: "run-1", Name: "review", Output: map[string]any{"content": "looks good"}, TokensIn: 10, CostUSD: 0.25}
+	if err := cache.SaveCachedResult(ctx, workflow.NewCachedResult("abc123", step, time.Hour)); err != nil {
+		t.Fatalf("SaveCachedResult() error = %v", err)
+	}
+
+	got, err := cache.GetCachedResult(ctx, "abc123")
+	if err != nil {
+		t.Fatalf("GetCachedResult() error = %v", err)
+	}
+	if got.Output["content"] != "looks good" || got.TokensIn != 10 || got.CostUSD != 0.25 || got.RunID != "run-1" {
+		t.Errorf("GetCachedResult() = %+v, want the saved result", got)
+	}
+
+	// Expired results are not reused
+	if err := cache.SaveCachedResult(ctx, workflow.NewCachedResult("def456", step, -time.Second)); err != nil {
+		t.Fatalf("SaveCachedResult() error = %v", err)
+	}
+	if _, err := cache.GetCachedResult(ctx, "def456"); !errors.Is(err, types.ErrCacheMiss) {
+		t.Errorf("GetCachedResult() of expired result error = %v, want %v", err, types.ErrCacheMiss)
+	}
+
+	if _, err := cache.GetCachedResult(ctx, "../secrets"); err == nil {
+		t.Error("GetCachedResult() with path in key succeeded, want error")
+	}
+}
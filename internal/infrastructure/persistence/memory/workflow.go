@@ -2,7 +2,9 @@ package memory
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
 	"github.com/felixgeelhaar/bridge/pkg/types"
@@ -12,18 +14,32 @@ import (
 type WorkflowRepository struct {
 	mu          sync.RWMutex
 	definitions map[types.WorkflowID]*workflow.WorkflowDefinition
-	nameIndex   map[string]types.WorkflowID
+	nameIndex   map[string][]types.WorkflowID // Versions by workflow name, in the order stored
 	runs        map[types.RunID]*workflow.WorkflowRun
 	steps       map[types.StepID]*workflow.StepRun
+	cancels     map[types.RunID]string // Requested cancellations by run
+	leases      map[types.RunID]lease
+	cache       map[string]*workflow.CachedResult
+}
+
+// lease is the lease on a run. Leases are kept apart from the stored runs,
+// which are the live runs of the executing process and must not be changed
+// by the lease heartbeat.
+type lease struct {
+	owner     string
+	expiresAt time.Time
 }
 
 // NewWorkflowRepository creates a new in-memory workflow repository.
 func NewWorkflowRepository() *WorkflowRepository {
 	return &WorkflowRepository{
 		definitions: make(map[types.WorkflowID]*workflow.WorkflowDefinition),
-		nameIndex:   make(map[string]types.WorkflowID),
+		nameIndex:   make(map[string][]types.WorkflowID),
 		runs:        make(map[types.RunID]*workflow.WorkflowRun),
 		steps:       make(map[types.StepID]*workflow.StepRun),
+		cancels:     make(map[types.RunID]string),
+		leases:      make(map[types.RunID]lease),
+		cache:       make(map[string]*workflow.CachedResult),
 	}
 }
 
@@ -32,12 +48,14 @@ func (r *WorkflowRepository) CreateDefinition(ctx context.Context, def *workflow
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.nameIndex[def.Name]; exists {
-		return types.ErrWorkflowAlreadyExists
+	for _, id := range r.nameIndex[def.Name] {
+		if r.definitions[id].Version == def.Version {
+			return types.ErrWorkflowAlreadyExists
+		}
 	}
 
 	r.definitions[def.ID] = def
-	r.nameIndex[def.Name] = def.ID
+	r.nameIndex[def.Name] = append(r.nameIndex[def.Name], def.ID)
 	return nil
 }
 
@@ -53,16 +71,43 @@ func (r *WorkflowRepository) GetDefinition(ctx context.Context, id types.Workflo
 	return def, nil
 }
 
-// GetDefinitionByName retrieves a workflow definition by name.
+// GetDefinitionByName retrieves the latest version of a workflow
+// definition by name.
 func (r *WorkflowRepository) GetDefinitionByName(ctx context.Context, name string) (*workflow.WorkflowDefinition, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	id, ok := r.nameIndex[name]
-	if !ok {
+	ids := r.nameIndex[name]
+	if len(ids) == 0 {
 		return nil, types.ErrWorkflowNotFound
 	}
-	return r.definitions[id], nil
+	return r.definitions[ids[len(ids)-1]], nil
+}
+
+// GetDefinitionVersion retrieves a version of a workflow definition.
+func (r *WorkflowRepository) GetDefinitionVersion(ctx context.Context, name, version string) (*workflow.WorkflowDefinition, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, id := range r.nameIndex[name] {
+		if def := r.definitions[id]; def.Version == version {
+			return def, nil
+		}
+	}
+	return nil, types.ErrWorkflowNotFound
+}
+
+// ListDefinitionVersions lists the versions of a workflow, the latest first.
+func (r *WorkflowRepository) ListDefinitionVersions(ctx context.Context, name string) ([]*workflow.WorkflowDefinition, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := r.nameIndex[name]
+	defs := make([]*workflow.WorkflowDefinition, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		defs = append(defs, r.definitions[ids[i]])
+	}
+	return defs, nil
 }
 
 // ListDefinitions lists workflow definitions with pagination.
@@ -88,19 +133,6 @@ func (r *WorkflowRepository) ListDefinitions(ctx context.Context, limit, offset
 	return defs[offset:end], nil
 }
 
-// UpdateDefinition updates a workflow definition.
-func (r *WorkflowRepository) UpdateDefinition(ctx context.Context, def *workflow.WorkflowDefinition) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if _, ok := r.definitions[def.ID]; !ok {
-		return types.ErrWorkflowNotFound
-	}
-
-	r.definitions[def.ID] = def
-	return nil
-}
-
 // DeleteDefinition deletes a workflow definition.
 func (r *WorkflowRepository) DeleteDefinition(ctx context.Context, id types.WorkflowID) error {
 	r.mu.Lock()
@@ -112,7 +144,17 @@ func (r *WorkflowRepository) DeleteDefinition(ctx context.Context, id types.Work
 	}
 
 	delete(r.definitions, id)
-	delete(r.nameIndex, def.Name)
+	versions := r.nameIndex[def.Name][:0]
+	for _, version := range r.nameIndex[def.Name] {
+		if version != id {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		delete(r.nameIndex, def.Name)
+	} else {
+		r.nameIndex[def.Name] = versions
+	}
 	return nil
 }
 
@@ -183,6 +225,55 @@ func (r *WorkflowRepository) ListActiveRuns(ctx context.Context) ([]*workflow.Wo
 	return runs, nil
 }
 
+// ListActiveRunsInGroup lists the active workflow runs in a concurrency group.
+func (r *WorkflowRepository) ListActiveRunsInGroup(ctx context.Context, group string) ([]*workflow.WorkflowRun, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	runs := make([]*workflow.WorkflowRun, 0)
+	for _, run := range r.runs {
+		if run.ConcurrencyGroup == group && !run.Status.IsTerminal() {
+			runs = append(runs, run)
+		}
+	}
+
+	return runs, nil
+}
+
+// ListOrphanedRuns lists the active workflow runs whose lease has expired.
+func (r *WorkflowRepository) ListOrphanedRuns(ctx context.Context) ([]*workflow.WorkflowRun, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	runs := make([]*workflow.WorkflowRun, 0)
+	for id, held := range r.leases {
+		if held.expiresAt.After(now) {
+			continue
+		}
+		if run, ok := r.runs[id]; ok && !run.Status.IsTerminal() {
+			runs = append(runs, run)
+		}
+	}
+
+	return runs, nil
+}
+
+// WorkflowUsage returns the tokens and cost used by the runs of all versions
+// of the named workflow.
+func (r *WorkflowRepository) WorkflowUsage(ctx context.Context, name string) (workflow.Usage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var usage workflow.Usage
+	for _, run := range r.runs {
+		if run.WorkflowName == name {
+			usage = usage.Add(run.Usage(""))
+		}
+	}
+	return usage, nil
+}
+
 // UpdateRun updates a workflow run.
 func (r *WorkflowRepository) UpdateRun(ctx context.Context, run *workflow.WorkflowRun) error {
 	r.mu.Lock()
@@ -193,6 +284,84 @@ func (r *WorkflowRepository) UpdateRun(ctx context.Context, run *workflow.Workfl
 	}
 
 	r.runs[run.ID] = run
+
+	// A finished run has nothing left to cancel
+	if run.Status.IsTerminal() {
+		delete(r.cancels, run.ID)
+	}
+	return nil
+}
+
+// AcquireLease takes or renews the lease on a workflow run.
+func (r *WorkflowRepository) AcquireLease(ctx context.Context, id types.RunID, owner string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.runs[id]; !ok {
+		return types.ErrRunNotFound
+	}
+
+	now := time.Now()
+	if held, ok := r.leases[id]; ok && held.owner != owner && held.expiresAt.After(now) {
+		return types.ErrRunLeased
+	}
+	r.leases[id] = lease{owner: owner, expiresAt: now.Add(ttl)}
+
+	if reason, ok := r.cancels[id]; ok {
+		return fmt.Errorf("%w: %s", types.ErrRunCancelled, reason)
+	}
+	return nil
+}
+
+// ReleaseLease releases the lease on a workflow run held by owner.
+func (r *WorkflowRepository) ReleaseLease(ctx context.Context, id types.RunID, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.runs[id]; !ok {
+		return types.ErrRunNotFound
+	}
+
+	if r.leases[id].owner == owner {
+		delete(r.leases, id)
+	}
+	return nil
+}
+
+// RequestCancel records a cancellation request for a workflow run.
+func (r *WorkflowRepository) RequestCancel(ctx context.Context, id types.RunID, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.runs[id]; !ok {
+		return types.ErrRunNotFound
+	}
+
+	r.cancels[id] = reason
+	return nil
+}
+
+// CreateSteps stores steps added to a run while it executes.
+func (r *WorkflowRepository) CreateSteps(ctx context.Context, run *workflow.WorkflowRun, steps []*workflow.StepRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.runs[run.ID]; !ok {
+		return types.ErrRunNotFound
+	}
+
+	// Step names are unique within a run, as in the postgres schema
+	for _, step := range steps {
+		for id, existing := range r.steps {
+			if id != step.ID && existing.RunID == step.RunID && existing.Name == step.Name {
+				return fmt.Errorf("step %s already exists in run %s", step.Name, run.ID)
+			}
+		}
+	}
+
+	for _, step := range steps {
+		r.steps[step.ID] = step
+	}
 	return nil
 }
 
@@ -221,5 +390,30 @@ func (r *WorkflowRepository) UpdateStep(ctx context.Context, step *workflow.Step
 	return nil
 }
 
-// Ensure WorkflowRepository implements workflow.Repository.
-var _ workflow.Repository = (*WorkflowRepository)(nil)
+// GetCachedResult retrieves the step result cached under a key.
+func (r *WorkflowRepository) GetCachedResult(ctx context.Context, key string) (*workflow.CachedResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result, ok := r.cache[key]
+	if !ok || result.Expired(time.Now()) {
+		return nil, types.ErrCacheMiss
+	}
+	return result, nil
+}
+
+// SaveCachedResult stores a step result, replacing any result cached under
+// the same key.
+func (r *WorkflowRepository) SaveCachedResult(ctx context.Context, result *workflow.CachedResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cache[result.Key] = result
+	return nil
+}
+
+// Ensure WorkflowRepository implements workflow.Repository and workflow.StepCache.
+var (
+	_ workflow.Repository = (*WorkflowRepository)(nil)
+	_ workflow.StepCache  = (*WorkflowRepository)(nil)
+)
@@ -2,7 +2,10 @@ package memory
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
 	"github.com/felixgeelhaar/bridge/pkg/config"
@@ -162,34 +165,43 @@ func TestWorkflowRepository_ListDefinitions_Pagination(t *testing.T) {
 	}
 }
 
-func TestWorkflowRepository_UpdateDefinition(t *testing.T) {
+func TestWorkflowRepository_DefinitionVersions(t *testing.T) {
 	repo := NewWorkflowRepository()
 	ctx := context.Background()
 
-	def := createTestWorkflowDefinition(t, "test-workflow")
-	repo.CreateDefinition(ctx, def)
-
-	def.Description = "Updated description"
-	err := repo.UpdateDefinition(ctx, def)
-	if err != nil {
-		t.Fatalf("UpdateDefinition() error = %v", err)
+	v1 := createTestWorkflowDefinition(t, "test-workflow")
+	v2 := createTestWorkflowDefinition(t, "test-workflow")
+	v2.Version = "2.0"
+	if err := repo.CreateDefinition(ctx, v1); err != nil {
+		t.Fatalf("CreateDefinition() error = %v", err)
+	}
+	if err := repo.CreateDefinition(ctx, v2); err != nil {
+		t.Fatalf("CreateDefinition() error = %v", err)
 	}
 
-	retrieved, _ := repo.GetDefinition(ctx, def.ID)
-	if retrieved.Description != "Updated description" {
-		t.Error("Definition should be updated")
+	latest, err := repo.GetDefinitionByName(ctx, "test-workflow")
+	if err != nil || latest.ID != v2.ID {
+		t.Errorf("GetDefinitionByName() = %v, %v, want version 2.0", latest, err)
 	}
-}
 
-func TestWorkflowRepository_UpdateDefinition_NotFound(t *testing.T) {
-	repo := NewWorkflowRepository()
-	ctx := context.Background()
+	got, err := repo.GetDefinitionVersion(ctx, "test-workflow", "1.0")
+	if err != nil || got.ID != v1.ID {
+		t.Errorf("GetDefinitionVersion() = %v, %v, want version 1.0", got, err)
+	}
+	if _, err := repo.GetDefinitionVersion(ctx, "test-workflow", "3.0"); err != types.ErrWorkflowNotFound {
+		t.Errorf("GetDefinitionVersion() error = %v, want %v", err, types.ErrWorkflowNotFound)
+	}
 
-	def := createTestWorkflowDefinition(t, "test-workflow")
-	err := repo.UpdateDefinition(ctx, def)
+	versions, _ := repo.ListDefinitionVersions(ctx, "test-workflow")
+	if len(versions) != 2 || versions[0].ID != v2.ID || versions[1].ID != v1.ID {
+		t.Errorf("ListDefinitionVersions() = %v, want versions 2.0 and 1.0", versions)
+	}
 
-	if err != types.ErrWorkflowNotFound {
-		t.Errorf("Expected ErrWorkflowNotFound, got %v", err)
+	// Deleting a version keeps the others
+	repo.DeleteDefinition(ctx, v2.ID)
+	latest, err = repo.GetDefinitionByName(ctx, "test-workflow")
+	if err != nil || latest.ID != v1.ID {
+		t.Errorf("GetDefinitionByName() = %v, %v, want version 1.0", latest, err)
 	}
 }
 
@@ -375,6 +387,166 @@ func TestWorkflowRepository_UpdateRun_NotFound(t *testing.T) {
 	}
 }
 
+func TestWorkflowRepository_WorkflowUsage(t *testing.T) {
+	repo := NewWorkflowRepository()
+	ctx := context.Background()
+
+	def := createTestWorkflowDefinition(t, "test-workflow")
+	other := createTestWorkflowDefinition(t, "other-workflow")
+	for _, d := range []*workflow.WorkflowDefinition{def, def, other} {
+		run := workflow.NewWorkflowRun(d, "trigger", nil)
+		run.Steps[0].Complete(nil, 10, 5)
+		run.Steps[0].CostUSD = 0.5
+		repo.CreateRun(ctx, run)
+	}
+
+	usage, err := repo.WorkflowUsage(ctx, "test-workflow")
+	if err != nil {
+		t.Fatalf("WorkflowUsage() error = %v", err)
+	}
+	if want := (workflow.Usage{Tokens: 30, CostUSD: 1}); usage != want {
+		t.Errorf("WorkflowUsage() = %+v, want %+v", usage, want)
+	}
+}
+
+func TestWorkflowRepository_AcquireLease(t *testing.T) {
+	repo := NewWorkflowRepository()
+	ctx := context.Background()
+
+	def := createTestWorkflowDefinition(t, "test-workflow")
+	run := workflow.NewWorkflowRun(def, "trigger", nil)
+	repo.CreateRun(ctx, run)
+
+	if err := repo.AcquireLease(ctx, run.ID, "worker-1", time.Minute); err != nil {
+		t.Fatalf("AcquireLease() error = %v", err)
+	}
+
+	// The owner can renew, other owners are refused
+	if err := repo.AcquireLease(ctx, run.ID, "worker-1", time.Minute); err != nil {
+		t.Errorf("AcquireLease() renew error = %v", err)
+	}
+	if err := repo.AcquireLease(ctx, run.ID, "worker-2", time.Minute); err != types.ErrRunLeased {
+		t.Errorf("Expected ErrRunLeased, got %v", err)
+	}
+
+	// The stored run is shared with the executing process and left alone
+	if run.LeaseOwner != "" || run.LeaseExpiresAt != nil {
+		t.Errorf("LeaseOwner = %q, want the run unchanged", run.LeaseOwner)
+	}
+
+	// Only the owner can release
+	repo.ReleaseLease(ctx, run.ID, "worker-2")
+	if err := repo.AcquireLease(ctx, run.ID, "worker-2", time.Minute); err != types.ErrRunLeased {
+		t.Errorf("AcquireLease() after release by another owner error = %v, want ErrRunLeased", err)
+	}
+	if err := repo.ReleaseLease(ctx, run.ID, "worker-1"); err != nil {
+		t.Fatalf("ReleaseLease() error = %v", err)
+	}
+	if err := repo.AcquireLease(ctx, run.ID, "worker-2", time.Minute); err != nil {
+		t.Errorf("AcquireLease() after release error = %v", err)
+	}
+}
+
+func TestWorkflowRepository_AcquireLease_Expired(t *testing.T) {
+	repo := NewWorkflowRepository()
+	ctx := context.Background()
+
+	def := createTestWorkflowDefinition(t, "test-workflow")
+	run := workflow.NewWorkflowRun(def, "trigger", nil)
+	repo.CreateRun(ctx, run)
+
+	repo.AcquireLease(ctx, run.ID, "worker-1", -time.Second)
+	if err := repo.AcquireLease(ctx, run.ID, "worker-2", time.Minute); err != nil {
+		t.Errorf("AcquireLease() on expired lease error = %v", err)
+	}
+	if err := repo.AcquireLease(ctx, run.ID, "worker-1", time.Minute); err != types.ErrRunLeased {
+		t.Errorf("AcquireLease() by the previous owner error = %v, want ErrRunLeased", err)
+	}
+}
+
+func TestWorkflowRepository_ListOrphanedRuns(t *testing.T) {
+	repo := NewWorkflowRepository()
+	ctx := context.Background()
+
+	def := createTestWorkflowDefinition(t, "test-workflow")
+	orphaned := workflow.NewWorkflowRun(def, "trigger", nil)
+	leased := workflow.NewWorkflowRun(def, "trigger", nil)
+	released := workflow.NewWorkflowRun(def, "trigger", nil)
+	finished := workflow.NewWorkflowRun(def, "trigger", nil)
+	finished.Complete()
+	for _, run := range []*workflow.WorkflowRun{orphaned, leased, released, finished} {
+		repo.CreateRun(ctx, run)
+	}
+
+	repo.AcquireLease(ctx, orphaned.ID, "worker-1", -time.Second)
+	repo.AcquireLease(ctx, leased.ID, "worker-1", time.Minute)
+	repo.AcquireLease(ctx, released.ID, "worker-1", -time.Second)
+	repo.ReleaseLease(ctx, released.ID, "worker-1")
+	repo.AcquireLease(ctx, finished.ID, "worker-1", -time.Second)
+
+	runs, err := repo.ListOrphanedRuns(ctx)
+	if err != nil {
+		t.Fatalf("ListOrphanedRuns() error = %v", err)
+	}
+	if len(runs) != 1 || runs[0].ID != orphaned.ID {
+		t.Errorf("ListOrphanedRuns() = %d runs, want only the run with an expired lease", len(runs))
+	}
+}
+
+func TestWorkflowRepository_RequestCancel(t *testing.T) {
+	repo := NewWorkflowRepository()
+	ctx := context.Background()
+
+	def := createTestWorkflowDefinition(t, "test-workflow")
+	run := workflow.NewWorkflowRun(def, "trigger", nil)
+	repo.CreateRun(ctx, run)
+
+	repo.AcquireLease(ctx, run.ID, "worker-1", time.Minute)
+	if err := repo.RequestCancel(ctx, run.ID, "superseded"); err != nil {
+		t.Fatalf("RequestCancel() error = %v", err)
+	}
+
+	// The lease holder learns about the request when renewing
+	err := repo.AcquireLease(ctx, run.ID, "worker-1", time.Minute)
+	if !errors.Is(err, types.ErrRunCancelled) || !strings.Contains(err.Error(), "superseded") {
+		t.Errorf("AcquireLease() error = %v, want ErrRunCancelled with reason", err)
+	}
+	if err := repo.AcquireLease(ctx, run.ID, "worker-2", time.Minute); err != types.ErrRunLeased {
+		t.Errorf("AcquireLease() by another owner error = %v, want ErrRunLeased", err)
+	}
+
+	// The request is cleared once the run is cancelled
+	run.Cancel("superseded")
+	repo.UpdateRun(ctx, run)
+	if err := repo.AcquireLease(ctx, run.ID, "worker-1", time.Minute); err != nil {
+		t.Errorf("AcquireLease() after cancellation error = %v", err)
+	}
+
+	if err := repo.RequestCancel(ctx, types.NewRunID(), "missing"); err != types.ErrRunNotFound {
+		t.Errorf("Expected ErrRunNotFound, got %v", err)
+	}
+}
+
+func TestWorkflowRepository_CreateSteps(t *testing.T) {
+	repo := NewWorkflowRepository()
+	ctx := context.Background()
+
+	def := createTestWorkflowDefinition(t, "test-workflow")
+	run := workflow.NewWorkflowRun(def, "trigger", nil)
+	repo.CreateRun(ctx, run)
+
+	// Fan-out items share the index of their step
+	items := run.ExpandStep(run.Steps[0], []map[string]any{{"item": 1}, {"item": 2}})
+	if err := repo.CreateSteps(ctx, run, items); err != nil {
+		t.Fatalf("CreateSteps() error = %v", err)
+	}
+
+	duplicate := &workflow.StepRun{ID: types.NewStepID(), RunID: run.ID, Name: "step1[0]"}
+	if err := repo.CreateSteps(ctx, run, []*workflow.StepRun{duplicate}); err == nil {
+		t.Error("CreateSteps() with a duplicate step name succeeded, want error")
+	}
+}
+
 func TestWorkflowRepository_GetStep(t *testing.T) {
 	repo := NewWorkflowRepository()
 	ctx := context.Background()
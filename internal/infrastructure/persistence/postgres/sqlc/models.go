@@ -61,6 +61,18 @@ type PolicyBundle struct {
 	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
 }
 
+type StepCache struct {
+	Key       string             `json:"key"`
+	Output    []byte             `json:"output"`
+	TokensIn  int32              `json:"tokens_in"`
+	TokensOut int32              `json:"tokens_out"`
+	CostUsd   float64            `json:"cost_usd"`
+	RunID     *string            `json:"run_id"`
+	StepName  *string            `json:"step_name"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
 type StepRun struct {
 	ID               string             `json:"id"`
 	RunID            string             `json:"run_id"`
@@ -74,10 +86,21 @@ type StepRun struct {
 	TimeoutSeconds   *int32             `json:"timeout_seconds"`
 	MaxRetries       *int32             `json:"max_retries"`
 	RetryCount       *int32             `json:"retry_count"`
+	Attempts         []byte             `json:"attempts"`
 	Error            *string            `json:"error"`
 	TokensIn         *int32             `json:"tokens_in"`
 	TokensOut        *int32             `json:"tokens_out"`
+	CostUsd          *float64           `json:"cost_usd"`
+	Warnings         []string           `json:"warnings"`
 	StepOrder        int32              `json:"step_order"`
+	ApprovedAt       pgtype.Timestamptz `json:"approved_at"`
+	Reused           bool               `json:"reused"`
+	CacheHit         bool               `json:"cache_hit"`
+	ParentStep       *string            `json:"parent_step"`
+	Item             []byte             `json:"item"`
+	ChildRunID       *string            `json:"child_run_id"`
+	Handler          *string            `json:"handler"`
+	HandlerOf        *string            `json:"handler_of"`
 	StartedAt        pgtype.Timestamptz `json:"started_at"`
 	CompletedAt      pgtype.Timestamptz `json:"completed_at"`
 	CreatedAt        pgtype.Timestamptz `json:"created_at"`
@@ -106,6 +129,18 @@ type WorkflowRun struct {
 	TriggeredBy      *string            `json:"triggered_by"`
 	TriggerData      []byte             `json:"trigger_data"`
 	Error            *string            `json:"error"`
+	PendingStep      *string            `json:"pending_step"`
+	LeaseOwner       *string            `json:"lease_owner"`
+	LeaseExpiresAt   pgtype.Timestamptz `json:"lease_expires_at"`
+	CancelReason     *string            `json:"cancel_reason"`
+	RerunOf          *string            `json:"rerun_of"`
+	ParentRunID      *string            `json:"parent_run_id"`
+	ParentStep       *string            `json:"parent_step"`
+	ConcurrencyGroup *string            `json:"concurrency_group"`
+	Budget           []byte             `json:"budget"`
+	Outputs          []byte             `json:"outputs"`
+	Deadline         pgtype.Timestamptz `json:"deadline"`
+	ApprovalDeadline pgtype.Timestamptz `json:"approval_deadline"`
 	StartedAt        pgtype.Timestamptz `json:"started_at"`
 	CompletedAt      pgtype.Timestamptz `json:"completed_at"`
 	CreatedAt        pgtype.Timestamptz `json:"created_at"`
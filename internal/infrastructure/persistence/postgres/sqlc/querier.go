@@ -11,6 +11,7 @@ import (
 )
 
 type Querier interface {
+	AcquireWorkflowRunLease(ctx context.Context, arg AcquireWorkflowRunLeaseParams) (*string, error)
 	CountActiveWorkflowRuns(ctx context.Context) (int64, error)
 	CountAgents(ctx context.Context) (int64, error)
 	CountAuditEvents(ctx context.Context) (int64, error)
@@ -26,6 +27,7 @@ type Querier interface {
 	CreateWorkflowRun(ctx context.Context, arg CreateWorkflowRunParams) (WorkflowRun, error)
 	DeleteAgent(ctx context.Context, id string) error
 	DeleteApprovalRequest(ctx context.Context, id string) error
+	DeleteExpiredStepCache(ctx context.Context) error
 	DeleteOldAuditEvents(ctx context.Context, timestamp pgtype.Timestamptz) error
 	DeletePolicyBundle(ctx context.Context, id string) error
 	DeleteStepRunsByRunID(ctx context.Context, runID string) error
@@ -36,32 +38,40 @@ type Querier interface {
 	GetApprovalRequest(ctx context.Context, id string) (ApprovalRequest, error)
 	GetApprovalRequestByRunID(ctx context.Context, runID string) (ApprovalRequest, error)
 	GetAuditEvent(ctx context.Context, id string) (AuditEvent, error)
+	GetCachedStepResult(ctx context.Context, key string) (StepCache, error)
 	GetPolicyBundle(ctx context.Context, id string) (PolicyBundle, error)
 	GetPolicyBundleByName(ctx context.Context, name string) (PolicyBundle, error)
 	GetStepRun(ctx context.Context, id string) (StepRun, error)
 	GetWorkflowDefinition(ctx context.Context, id string) (WorkflowDefinition, error)
 	GetWorkflowDefinitionByName(ctx context.Context, name string) (WorkflowDefinition, error)
+	GetWorkflowDefinitionVersion(ctx context.Context, arg GetWorkflowDefinitionVersionParams) (WorkflowDefinition, error)
 	GetWorkflowRun(ctx context.Context, id string) (WorkflowRun, error)
+	GetWorkflowUsage(ctx context.Context, workflowName string) (GetWorkflowUsageRow, error)
 	ListActiveAgents(ctx context.Context) ([]Agent, error)
 	ListActivePolicyBundles(ctx context.Context) ([]PolicyBundle, error)
 	ListActiveWorkflowRuns(ctx context.Context) ([]WorkflowRun, error)
+	ListActiveWorkflowRunsInGroup(ctx context.Context, concurrencyGroup *string) ([]WorkflowRun, error)
 	ListAgents(ctx context.Context, arg ListAgentsParams) ([]Agent, error)
 	ListApprovalRequestsByRunID(ctx context.Context, runID string) ([]ApprovalRequest, error)
 	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error)
 	ListAuditEventsByActor(ctx context.Context, arg ListAuditEventsByActorParams) ([]AuditEvent, error)
 	ListAuditEventsByResource(ctx context.Context, arg ListAuditEventsByResourceParams) ([]AuditEvent, error)
 	ListAuditEventsByType(ctx context.Context, arg ListAuditEventsByTypeParams) ([]AuditEvent, error)
+	ListOrphanedWorkflowRuns(ctx context.Context) ([]WorkflowRun, error)
 	ListPendingApprovalRequests(ctx context.Context) ([]ApprovalRequest, error)
 	ListPolicyBundles(ctx context.Context, arg ListPolicyBundlesParams) ([]PolicyBundle, error)
 	ListStepRunsByRunID(ctx context.Context, runID string) ([]StepRun, error)
+	ListWorkflowDefinitionVersions(ctx context.Context, name string) ([]WorkflowDefinition, error)
 	ListWorkflowDefinitions(ctx context.Context, arg ListWorkflowDefinitionsParams) ([]WorkflowDefinition, error)
 	ListWorkflowRuns(ctx context.Context, arg ListWorkflowRunsParams) ([]WorkflowRun, error)
+	ReleaseWorkflowRunLease(ctx context.Context, arg ReleaseWorkflowRunLeaseParams) error
+	RequestWorkflowRunCancel(ctx context.Context, arg RequestWorkflowRunCancelParams) (int64, error)
 	UpdateAgent(ctx context.Context, arg UpdateAgentParams) (Agent, error)
 	UpdateApprovalRequest(ctx context.Context, arg UpdateApprovalRequestParams) (ApprovalRequest, error)
 	UpdatePolicyBundle(ctx context.Context, arg UpdatePolicyBundleParams) (PolicyBundle, error)
 	UpdateStepRun(ctx context.Context, arg UpdateStepRunParams) (StepRun, error)
-	UpdateWorkflowDefinition(ctx context.Context, arg UpdateWorkflowDefinitionParams) (WorkflowDefinition, error)
 	UpdateWorkflowRun(ctx context.Context, arg UpdateWorkflowRunParams) (WorkflowRun, error)
+	UpsertCachedStepResult(ctx context.Context, arg UpsertCachedStepResultParams) (StepCache, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,24 @@
+-- name: GetCachedStepResult :one
+SELECT * FROM step_cache
+WHERE key = $1 AND expires_at > NOW();
+
+-- name: UpsertCachedStepResult :one
+INSERT INTO step_cache (
+    key, output, tokens_in, tokens_out, cost_usd, run_id, step_name, created_at, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+ON CONFLICT (key) DO UPDATE SET
+    output = EXCLUDED.output,
+    tokens_in = EXCLUDED.tokens_in,
+    tokens_out = EXCLUDED.tokens_out,
+    cost_usd = EXCLUDED.cost_usd,
+    run_id = EXCLUDED.run_id,
+    step_name = EXCLUDED.step_name,
+    created_at = EXCLUDED.created_at,
+    expires_at = EXCLUDED.expires_at
+RETURNING *;
+
+-- name: DeleteExpiredStepCache :exec
+DELETE FROM step_cache
+WHERE expires_at <= NOW();
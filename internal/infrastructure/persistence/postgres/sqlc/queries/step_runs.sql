@@ -3,10 +3,12 @@ INSERT INTO step_runs (
     id, run_id, step_index, name, agent_id, status,
     input, output, requires_approval, timeout_seconds,
     max_retries, retry_count, error, tokens_in, tokens_out,
-    step_order, started_at, completed_at, created_at
+    step_order, started_at, completed_at, created_at, reused,
+    parent_step, item, child_run_id, handler, handler_of
 ) VALUES (
     $1, $2, $3, $4, $5, $6, $7, $8, $9, $10,
-    $11, $12, $13, $14, $15, $16, $17, $18, $19
+    $11, $12, $13, $14, $15, $16, $17, $18, $19, $20,
+    $21, $22, $23, $24, $25
 )
 RETURNING *;
 
@@ -17,7 +19,7 @@ WHERE id = $1;
 -- name: ListStepRunsByRunID :many
 SELECT * FROM step_runs
 WHERE run_id = $1
-ORDER BY step_index ASC;
+ORDER BY step_index ASC, step_order ASC;
 
 -- name: UpdateStepRun :one
 UPDATE step_runs
@@ -30,7 +32,13 @@ SET
     tokens_in = $7,
     tokens_out = $8,
     started_at = $9,
-    completed_at = $10
+    completed_at = $10,
+    approved_at = $11,
+    warnings = $12,
+    child_run_id = $13,
+    attempts = $14,
+    cache_hit = $15,
+    cost_usd = $16
 WHERE id = $1
 RETURNING *;
 
@@ -12,29 +12,27 @@ WHERE id = $1;
 
 -- name: GetWorkflowDefinitionByName :one
 SELECT * FROM workflow_definitions
-WHERE name = $1;
+WHERE name = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetWorkflowDefinitionVersion :one
+SELECT * FROM workflow_definitions
+WHERE name = $1 AND version = $2;
 
 -- name: ListWorkflowDefinitions :many
 SELECT * FROM workflow_definitions
 ORDER BY updated_at DESC
 LIMIT $1 OFFSET $2;
 
+-- name: ListWorkflowDefinitionVersions :many
+SELECT * FROM workflow_definitions
+WHERE name = $1
+ORDER BY created_at DESC;
+
 -- name: CountWorkflowDefinitions :one
 SELECT COUNT(*) FROM workflow_definitions;
 
--- name: UpdateWorkflowDefinition :one
-UPDATE workflow_definitions
-SET
-    name = $2,
-    version = $3,
-    description = $4,
-    config = $5,
-    checksum = $6,
-    metadata = $7,
-    updated_at = NOW()
-WHERE id = $1
-RETURNING *;
-
 -- name: DeleteWorkflowDefinition :exec
 DELETE FROM workflow_definitions
 WHERE id = $1;
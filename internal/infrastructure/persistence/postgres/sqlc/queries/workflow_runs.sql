@@ -1,10 +1,12 @@
 -- name: CreateWorkflowRun :one
 INSERT INTO workflow_runs (
     id, workflow_id, workflow_name, workflow_version, status,
-    current_step_index, context, triggered_by, trigger_data,
-    error, started_at, completed_at, created_at, updated_at
+    context, triggered_by, trigger_data,
+    error, started_at, completed_at, created_at, updated_at, rerun_of,
+    parent_run_id, parent_step, deadline, concurrency_group, budget
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
+    $15, $16, $17, $18, $19
 )
 RETURNING *;
 
@@ -12,6 +14,19 @@ RETURNING *;
 SELECT * FROM workflow_runs
 WHERE id = $1;
 
+-- name: GetWorkflowUsage :one
+SELECT
+    COALESCE(SUM(s.tokens_in + s.tokens_out), 0)::bigint AS tokens,
+    COALESCE(SUM(s.cost_usd), 0)::double precision AS cost_usd
+FROM step_runs s
+JOIN workflow_runs r ON r.id = s.run_id
+WHERE r.workflow_name = $1
+  AND NOT s.cache_hit
+  AND NOT EXISTS (
+    SELECT 1 FROM step_runs i
+    WHERE i.run_id = s.run_id AND i.parent_step = s.name
+  );
+
 -- name: ListWorkflowRuns :many
 SELECT * FROM workflow_runs
 WHERE workflow_id = $1
@@ -23,19 +38,55 @@ SELECT * FROM workflow_runs
 WHERE status NOT IN ('completed', 'failed', 'cancelled')
 ORDER BY created_at DESC;
 
+-- name: ListActiveWorkflowRunsInGroup :many
+SELECT * FROM workflow_runs
+WHERE concurrency_group = $1
+  AND status NOT IN ('completed', 'failed', 'cancelled')
+ORDER BY created_at;
+
+-- name: ListOrphanedWorkflowRuns :many
+SELECT * FROM workflow_runs
+WHERE status NOT IN ('completed', 'failed', 'cancelled')
+  AND lease_expires_at < NOW()
+ORDER BY created_at;
+
 -- name: UpdateWorkflowRun :one
 UPDATE workflow_runs
 SET
     status = $2,
-    current_step_index = $3,
-    context = $4,
-    error = $5,
-    started_at = $6,
-    completed_at = $7,
+    context = $3,
+    error = $4,
+    started_at = $5,
+    completed_at = $6,
+    pending_step = $7,
+    outputs = $8,
+    approval_deadline = $9,
+    cancel_reason = CASE WHEN sqlc.arg(clear_cancel)::boolean THEN NULL ELSE cancel_reason END,
     updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 
+-- name: AcquireWorkflowRunLease :one
+UPDATE workflow_runs
+SET
+    lease_owner = $2,
+    lease_expires_at = $3
+WHERE id = $1
+  AND (lease_owner IS NULL OR lease_owner = $2 OR lease_expires_at < NOW())
+RETURNING cancel_reason;
+
+-- name: ReleaseWorkflowRunLease :exec
+UPDATE workflow_runs
+SET
+    lease_owner = NULL,
+    lease_expires_at = NULL
+WHERE id = $1 AND lease_owner = $2;
+
+-- name: RequestWorkflowRunCancel :execrows
+UPDATE workflow_runs
+SET cancel_reason = $2
+WHERE id = $1;
+
 -- name: CountWorkflowRuns :one
 SELECT COUNT(*) FROM workflow_runs
 WHERE workflow_id = $1;
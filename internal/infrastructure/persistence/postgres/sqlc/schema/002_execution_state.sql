@@ -0,0 +1,56 @@
+-- Workflow versions, durable run state and the step cache
+
+-- Each version of a workflow is stored as its own definition
+ALTER TABLE workflow_definitions DROP CONSTRAINT IF EXISTS workflow_definitions_name_key;
+ALTER TABLE workflow_definitions ADD CONSTRAINT workflow_definitions_name_version_key UNIQUE (name, version);
+
+-- Workflow Runs Table
+ALTER TABLE workflow_runs
+    ADD COLUMN IF NOT EXISTS pending_step VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS lease_owner VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS cancel_reason TEXT,
+    ADD COLUMN IF NOT EXISTS rerun_of UUID REFERENCES workflow_runs(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS parent_run_id UUID REFERENCES workflow_runs(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS parent_step VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS concurrency_group VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS budget JSONB,
+    ADD COLUMN IF NOT EXISTS outputs JSONB,
+    ADD COLUMN IF NOT EXISTS deadline TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS approval_deadline TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_concurrency_group ON workflow_runs(concurrency_group) WHERE status NOT IN ('completed', 'failed', 'cancelled');
+
+-- Step Runs Table
+ALTER TABLE step_runs
+    ADD COLUMN IF NOT EXISTS attempts JSONB,
+    ADD COLUMN IF NOT EXISTS cost_usd DOUBLE PRECISION DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS warnings TEXT[] DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS approved_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS reused BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS cache_hit BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS parent_step VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS item JSONB,
+    ADD COLUMN IF NOT EXISTS child_run_id UUID REFERENCES workflow_runs(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS handler VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS handler_of VARCHAR(255);
+
+-- Fan-out items and failure handlers share the index of their step, names
+-- are unique within a run
+DROP INDEX IF EXISTS idx_step_runs_run_step;
+CREATE UNIQUE INDEX idx_step_runs_run_step ON step_runs(run_id, name);
+
+-- Step Cache Table
+CREATE TABLE IF NOT EXISTS step_cache (
+    key VARCHAR(64) PRIMARY KEY,
+    output JSONB NOT NULL DEFAULT '{}',
+    tokens_in INTEGER NOT NULL DEFAULT 0,
+    tokens_out INTEGER NOT NULL DEFAULT 0,
+    cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
+    run_id UUID,
+    step_name VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_step_cache_expires_at ON step_cache(expires_at);
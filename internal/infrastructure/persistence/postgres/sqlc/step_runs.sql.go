@@ -16,12 +16,14 @@ INSERT INTO step_runs (
     id, run_id, step_index, name, agent_id, status,
     input, output, requires_approval, timeout_seconds,
     max_retries, retry_count, error, tokens_in, tokens_out,
-    step_order, started_at, completed_at, created_at
+    step_order, started_at, completed_at, created_at, reused,
+    parent_step, item, child_run_id, handler, handler_of
 ) VALUES (
     $1, $2, $3, $4, $5, $6, $7, $8, $9, $10,
-    $11, $12, $13, $14, $15, $16, $17, $18, $19
+    $11, $12, $13, $14, $15, $16, $17, $18, $19, $20,
+    $21, $22, $23, $24, $25
 )
-RETURNING id, run_id, step_index, name, agent_id, status, input, output, requires_approval, timeout_seconds, max_retries, retry_count, error, tokens_in, tokens_out, step_order, started_at, completed_at, created_at
+RETURNING id, run_id, step_index, name, agent_id, status, input, output, requires_approval, timeout_seconds, max_retries, retry_count, attempts, error, tokens_in, tokens_out, cost_usd, warnings, step_order, approved_at, reused, cache_hit, parent_step, item, child_run_id, handler, handler_of, started_at, completed_at, created_at
 `
 
 type CreateStepRunParams struct {
@@ -44,6 +46,12 @@ type CreateStepRunParams struct {
 	StartedAt        pgtype.Timestamptz `json:"started_at"`
 	CompletedAt      pgtype.Timestamptz `json:"completed_at"`
 	CreatedAt        pgtype.Timestamptz `json:"created_at"`
+	Reused           bool               `json:"reused"`
+	ParentStep       *string            `json:"parent_step"`
+	Item             []byte             `json:"item"`
+	ChildRunID       *string            `json:"child_run_id"`
+	Handler          *string            `json:"handler"`
+	HandlerOf        *string            `json:"handler_of"`
 }
 
 func (q *Queries) CreateStepRun(ctx context.Context, arg CreateStepRunParams) (StepRun, error) {
@@ -67,6 +75,12 @@ func (q *Queries) CreateStepRun(ctx context.Context, arg CreateStepRunParams) (S
 		arg.StartedAt,
 		arg.CompletedAt,
 		arg.CreatedAt,
+		arg.Reused,
+		arg.ParentStep,
+		arg.Item,
+		arg.ChildRunID,
+		arg.Handler,
+		arg.HandlerOf,
 	)
 	var i StepRun
 	err := row.Scan(
@@ -82,10 +96,21 @@ func (q *Queries) CreateStepRun(ctx context.Context, arg CreateStepRunParams) (S
 		&i.TimeoutSeconds,
 		&i.MaxRetries,
 		&i.RetryCount,
+		&i.Attempts,
 		&i.Error,
 		&i.TokensIn,
 		&i.TokensOut,
+		&i.CostUsd,
+		&i.Warnings,
 		&i.StepOrder,
+		&i.ApprovedAt,
+		&i.Reused,
+		&i.CacheHit,
+		&i.ParentStep,
+		&i.Item,
+		&i.ChildRunID,
+		&i.Handler,
+		&i.HandlerOf,
 		&i.StartedAt,
 		&i.CompletedAt,
 		&i.CreatedAt,
@@ -104,7 +129,7 @@ func (q *Queries) DeleteStepRunsByRunID(ctx context.Context, runID string) error
 }
 
 const getStepRun = `-- name: GetStepRun :one
-SELECT id, run_id, step_index, name, agent_id, status, input, output, requires_approval, timeout_seconds, max_retries, retry_count, error, tokens_in, tokens_out, step_order, started_at, completed_at, created_at FROM step_runs
+SELECT id, run_id, step_index, name, agent_id, status, input, output, requires_approval, timeout_seconds, max_retries, retry_count, attempts, error, tokens_in, tokens_out, cost_usd, warnings, step_order, approved_at, reused, cache_hit, parent_step, item, child_run_id, handler, handler_of, started_at, completed_at, created_at FROM step_runs
 WHERE id = $1
 `
 
@@ -124,10 +149,21 @@ func (q *Queries) GetStepRun(ctx context.Context, id string) (StepRun, error) {
 		&i.TimeoutSeconds,
 		&i.MaxRetries,
 		&i.RetryCount,
+		&i.Attempts,
 		&i.Error,
 		&i.TokensIn,
 		&i.TokensOut,
+		&i.CostUsd,
+		&i.Warnings,
 		&i.StepOrder,
+		&i.ApprovedAt,
+		&i.Reused,
+		&i.CacheHit,
+		&i.ParentStep,
+		&i.Item,
+		&i.ChildRunID,
+		&i.Handler,
+		&i.HandlerOf,
 		&i.StartedAt,
 		&i.CompletedAt,
 		&i.CreatedAt,
@@ -136,9 +172,9 @@ func (q *Queries) GetStepRun(ctx context.Context, id string) (StepRun, error) {
 }
 
 const listStepRunsByRunID = `-- name: ListStepRunsByRunID :many
-SELECT id, run_id, step_index, name, agent_id, status, input, output, requires_approval, timeout_seconds, max_retries, retry_count, error, tokens_in, tokens_out, step_order, started_at, completed_at, created_at FROM step_runs
+SELECT id, run_id, step_index, name, agent_id, status, input, output, requires_approval, timeout_seconds, max_retries, retry_count, attempts, error, tokens_in, tokens_out, cost_usd, warnings, step_order, approved_at, reused, cache_hit, parent_step, item, child_run_id, handler, handler_of, started_at, completed_at, created_at FROM step_runs
 WHERE run_id = $1
-ORDER BY step_index ASC
+ORDER BY step_index ASC, step_order ASC
 `
 
 func (q *Queries) ListStepRunsByRunID(ctx context.Context, runID string) ([]StepRun, error) {
@@ -163,10 +199,21 @@ func (q *Queries) ListStepRunsByRunID(ctx context.Context, runID string) ([]Step
 			&i.TimeoutSeconds,
 			&i.MaxRetries,
 			&i.RetryCount,
+			&i.Attempts,
 			&i.Error,
 			&i.TokensIn,
 			&i.TokensOut,
+			&i.CostUsd,
+			&i.Warnings,
 			&i.StepOrder,
+			&i.ApprovedAt,
+			&i.Reused,
+			&i.CacheHit,
+			&i.ParentStep,
+			&i.Item,
+			&i.ChildRunID,
+			&i.Handler,
+			&i.HandlerOf,
 			&i.StartedAt,
 			&i.CompletedAt,
 			&i.CreatedAt,
@@ -192,9 +239,15 @@ SET
     tokens_in = $7,
     tokens_out = $8,
     started_at = $9,
-    completed_at = $10
+    completed_at = $10,
+    approved_at = $11,
+    warnings = $12,
+    child_run_id = $13,
+    attempts = $14,
+    cache_hit = $15,
+    cost_usd = $16
 WHERE id = $1
-RETURNING id, run_id, step_index, name, agent_id, status, input, output, requires_approval, timeout_seconds, max_retries, retry_count, error, tokens_in, tokens_out, step_order, started_at, completed_at, created_at
+RETURNING id, run_id, step_index, name, agent_id, status, input, output, requires_approval, timeout_seconds, max_retries, retry_count, attempts, error, tokens_in, tokens_out, cost_usd, warnings, step_order, approved_at, reused, cache_hit, parent_step, item, child_run_id, handler, handler_of, started_at, completed_at, created_at
 `
 
 type UpdateStepRunParams struct {
@@ -208,6 +261,12 @@ type UpdateStepRunParams struct {
 	TokensOut   *int32             `json:"tokens_out"`
 	StartedAt   pgtype.Timestamptz `json:"started_at"`
 	CompletedAt pgtype.Timestamptz `json:"completed_at"`
+	ApprovedAt  pgtype.Timestamptz `json:"approved_at"`
+	Warnings    []string           `json:"warnings"`
+	ChildRunID  *string            `json:"child_run_id"`
+	Attempts    []byte             `json:"attempts"`
+	CacheHit    bool               `json:"cache_hit"`
+	CostUsd     *float64           `json:"cost_usd"`
 }
 
 func (q *Queries) UpdateStepRun(ctx context.Context, arg UpdateStepRunParams) (StepRun, error) {
@@ -222,6 +281,12 @@ func (q *Queries) UpdateStepRun(ctx context.Context, arg UpdateStepRunParams) (S
 		arg.TokensOut,
 		arg.StartedAt,
 		arg.CompletedAt,
+		arg.ApprovedAt,
+		arg.Warnings,
+		arg.ChildRunID,
+		arg.Attempts,
+		arg.CacheHit,
+		arg.CostUsd,
 	)
 	var i StepRun
 	err := row.Scan(
@@ -237,10 +302,21 @@ func (q *Queries) UpdateStepRun(ctx context.Context, arg UpdateStepRunParams) (S
 		&i.TimeoutSeconds,
 		&i.MaxRetries,
 		&i.RetryCount,
+		&i.Attempts,
 		&i.Error,
 		&i.TokensIn,
 		&i.TokensOut,
+		&i.CostUsd,
+		&i.Warnings,
 		&i.StepOrder,
+		&i.ApprovedAt,
+		&i.Reused,
+		&i.CacheHit,
+		&i.ParentStep,
+		&i.Item,
+		&i.ChildRunID,
+		&i.Handler,
+		&i.HandlerOf,
 		&i.StartedAt,
 		&i.CompletedAt,
 		&i.CreatedAt,
@@ -105,6 +105,8 @@ func (q *Queries) GetWorkflowDefinition(ctx context.Context, id string) (Workflo
 const getWorkflowDefinitionByName = `-- name: GetWorkflowDefinitionByName :one
 SELECT id, name, version, description, config, checksum, metadata, created_at, updated_at FROM workflow_definitions
 WHERE name = $1
+ORDER BY created_at DESC
+LIMIT 1
 `
 
 func (q *Queries) GetWorkflowDefinitionByName(ctx context.Context, name string) (WorkflowDefinition, error) {
@@ -124,6 +126,69 @@ func (q *Queries) GetWorkflowDefinitionByName(ctx context.Context, name string)
 	return i, err
 }
 
+const getWorkflowDefinitionVersion = `-- name: GetWorkflowDefinitionVersion :one
+SELECT id, name, version, description, config, checksum, metadata, created_at, updated_at FROM workflow_definitions
+WHERE name = $1 AND version = $2
+`
+
+type GetWorkflowDefinitionVersionParams struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func (q *Queries) GetWorkflowDefinitionVersion(ctx context.Context, arg GetWorkflowDefinitionVersionParams) (WorkflowDefinition, error) {
+	row := q.db.QueryRow(ctx, getWorkflowDefinitionVersion, arg.Name, arg.Version)
+	var i WorkflowDefinition
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Version,
+		&i.Description,
+		&i.Config,
+		&i.Checksum,
+		&i.Metadata,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listWorkflowDefinitionVersions = `-- name: ListWorkflowDefinitionVersions :many
+SELECT id, name, version, description, config, checksum, metadata, created_at, updated_at FROM workflow_definitions
+WHERE name = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListWorkflowDefinitionVersions(ctx context.Context, name string) ([]WorkflowDefinition, error) {
+	rows, err := q.db.Query(ctx, listWorkflowDefinitionVersions, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WorkflowDefinition{}
+	for rows.Next() {
+		var i WorkflowDefinition
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Version,
+			&i.Description,
+			&i.Config,
+			&i.Checksum,
+			&i.Metadata,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listWorkflowDefinitions = `-- name: ListWorkflowDefinitions :many
 SELECT id, name, version, description, config, checksum, metadata, created_at, updated_at FROM workflow_definitions
 ORDER BY updated_at DESC
@@ -164,52 +229,3 @@ func (q *Queries) ListWorkflowDefinitions(ctx context.Context, arg ListWorkflowD
 	}
 	return items, nil
 }
-
-const updateWorkflowDefinition = `-- name: UpdateWorkflowDefinition :one
-UPDATE workflow_definitions
-SET
-    name = $2,
-    version = $3,
-    description = $4,
-    config = $5,
-    checksum = $6,
-    metadata = $7,
-    updated_at = NOW()
-WHERE id = $1
-RETURNING id, name, version, description, config, checksum, metadata, created_at, updated_at
-`
-
-type UpdateWorkflowDefinitionParams struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Version     string  `json:"version"`
-	Description *string `json:"description"`
-	Config      []byte  `json:"config"`
-	Checksum    *string `json:"checksum"`
-	Metadata    []byte  `json:"metadata"`
-}
-
-func (q *Queries) UpdateWorkflowDefinition(ctx context.Context, arg UpdateWorkflowDefinitionParams) (WorkflowDefinition, error) {
-	row := q.db.QueryRow(ctx, updateWorkflowDefinition,
-		arg.ID,
-		arg.Name,
-		arg.Version,
-		arg.Description,
-		arg.Config,
-		arg.Checksum,
-		arg.Metadata,
-	)
-	var i WorkflowDefinition
-	err := row.Scan(
-		&i.ID,
-		&i.Name,
-		&i.Version,
-		&i.Description,
-		&i.Config,
-		&i.Checksum,
-		&i.Metadata,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-	)
-	return i, err
-}
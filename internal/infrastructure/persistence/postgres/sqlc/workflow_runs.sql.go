@@ -11,6 +11,29 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const acquireWorkflowRunLease = `-- name: AcquireWorkflowRunLease :one
+UPDATE workflow_runs
+SET
+    lease_owner = $2,
+    lease_expires_at = $3
+WHERE id = $1
+  AND (lease_owner IS NULL OR lease_owner = $2 OR lease_expires_at < NOW())
+RETURNING cancel_reason
+`
+
+type AcquireWorkflowRunLeaseParams struct {
+	ID             string             `json:"id"`
+	LeaseOwner     *string            `json:"lease_owner"`
+	LeaseExpiresAt pgtype.Timestamptz `json:"lease_expires_at"`
+}
+
+func (q *Queries) AcquireWorkflowRunLease(ctx context.Context, arg AcquireWorkflowRunLeaseParams) (*string, error) {
+	row := q.db.QueryRow(ctx, acquireWorkflowRunLease, arg.ID, arg.LeaseOwner, arg.LeaseExpiresAt)
+	var cancel_reason *string
+	err := row.Scan(&cancel_reason)
+	return cancel_reason, err
+}
+
 const countActiveWorkflowRuns = `-- name: CountActiveWorkflowRuns :one
 SELECT COUNT(*) FROM workflow_runs
 WHERE status NOT IN ('completed', 'failed', 'cancelled')
@@ -38,12 +61,14 @@ func (q *Queries) CountWorkflowRuns(ctx context.Context, workflowID string) (int
 const createWorkflowRun = `-- name: CreateWorkflowRun :one
 INSERT INTO workflow_runs (
     id, workflow_id, workflow_name, workflow_version, status,
-    current_step_index, context, triggered_by, trigger_data,
-    error, started_at, completed_at, created_at, updated_at
+    context, triggered_by, trigger_data,
+    error, started_at, completed_at, created_at, updated_at, rerun_of,
+    parent_run_id, parent_step, deadline, concurrency_group, budget
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
+    $15, $16, $17, $18, $19
 )
-RETURNING id, workflow_id, workflow_name, workflow_version, status, current_step_index, context, triggered_by, trigger_data, error, started_at, completed_at, created_at, updated_at
+RETURNING id, workflow_id, workflow_name, workflow_version, status, current_step_index, context, triggered_by, trigger_data, error, pending_step, lease_owner, lease_expires_at, cancel_reason, rerun_of, parent_run_id, parent_step, concurrency_group, budget, outputs, deadline, approval_deadline, started_at, completed_at, created_at, updated_at
 `
 
 type CreateWorkflowRunParams struct {
@@ -52,7 +77,6 @@ type CreateWorkflowRunParams struct {
 	WorkflowName     string             `json:"workflow_name"`
 	WorkflowVersion  string             `json:"workflow_version"`
 	Status           string             `json:"status"`
-	CurrentStepIndex int32              `json:"current_step_index"`
 	Context          []byte             `json:"context"`
 	TriggeredBy      *string            `json:"triggered_by"`
 	TriggerData      []byte             `json:"trigger_data"`
@@ -61,6 +85,12 @@ type CreateWorkflowRunParams struct {
 	CompletedAt      pgtype.Timestamptz `json:"completed_at"`
 	CreatedAt        pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt        pgtype.Timestamptz `json:"updated_at"`
+	RerunOf          *string            `json:"rerun_of"`
+	ParentRunID      *string            `json:"parent_run_id"`
+	ParentStep       *string            `json:"parent_step"`
+	Deadline         pgtype.Timestamptz `json:"deadline"`
+	ConcurrencyGroup *string            `json:"concurrency_group"`
+	Budget           []byte             `json:"budget"`
 }
 
 func (q *Queries) CreateWorkflowRun(ctx context.Context, arg CreateWorkflowRunParams) (WorkflowRun, error) {
@@ -70,7 +100,6 @@ func (q *Queries) CreateWorkflowRun(ctx context.Context, arg CreateWorkflowRunPa
 		arg.WorkflowName,
 		arg.WorkflowVersion,
 		arg.Status,
-		arg.CurrentStepIndex,
 		arg.Context,
 		arg.TriggeredBy,
 		arg.TriggerData,
@@ -79,6 +108,12 @@ func (q *Queries) CreateWorkflowRun(ctx context.Context, arg CreateWorkflowRunPa
 		arg.CompletedAt,
 		arg.CreatedAt,
 		arg.UpdatedAt,
+		arg.RerunOf,
+		arg.ParentRunID,
+		arg.ParentStep,
+		arg.Deadline,
+		arg.ConcurrencyGroup,
+		arg.Budget,
 	)
 	var i WorkflowRun
 	err := row.Scan(
@@ -92,6 +127,18 @@ func (q *Queries) CreateWorkflowRun(ctx context.Context, arg CreateWorkflowRunPa
 		&i.TriggeredBy,
 		&i.TriggerData,
 		&i.Error,
+		&i.PendingStep,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+		&i.CancelReason,
+		&i.RerunOf,
+		&i.ParentRunID,
+		&i.ParentStep,
+		&i.ConcurrencyGroup,
+		&i.Budget,
+		&i.Outputs,
+		&i.Deadline,
+		&i.ApprovalDeadline,
 		&i.StartedAt,
 		&i.CompletedAt,
 		&i.CreatedAt,
@@ -101,7 +148,7 @@ func (q *Queries) CreateWorkflowRun(ctx context.Context, arg CreateWorkflowRunPa
 }
 
 const getWorkflowRun = `-- name: GetWorkflowRun :one
-SELECT id, workflow_id, workflow_name, workflow_version, status, current_step_index, context, triggered_by, trigger_data, error, started_at, completed_at, created_at, updated_at FROM workflow_runs
+SELECT id, workflow_id, workflow_name, workflow_version, status, current_step_index, context, triggered_by, trigger_data, error, pending_step, lease_owner, lease_expires_at, cancel_reason, rerun_of, parent_run_id, parent_step, concurrency_group, budget, outputs, deadline, approval_deadline, started_at, completed_at, created_at, updated_at FROM workflow_runs
 WHERE id = $1
 `
 
@@ -119,6 +166,18 @@ func (q *Queries) GetWorkflowRun(ctx context.Context, id string) (WorkflowRun, e
 		&i.TriggeredBy,
 		&i.TriggerData,
 		&i.Error,
+		&i.PendingStep,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+		&i.CancelReason,
+		&i.RerunOf,
+		&i.ParentRunID,
+		&i.ParentStep,
+		&i.ConcurrencyGroup,
+		&i.Budget,
+		&i.Outputs,
+		&i.Deadline,
+		&i.ApprovalDeadline,
 		&i.StartedAt,
 		&i.CompletedAt,
 		&i.CreatedAt,
@@ -127,8 +186,34 @@ func (q *Queries) GetWorkflowRun(ctx context.Context, id string) (WorkflowRun, e
 	return i, err
 }
 
+const getWorkflowUsage = `-- name: GetWorkflowUsage :one
+SELECT
+    COALESCE(SUM(s.tokens_in + s.tokens_out), 0)::bigint AS tokens,
+    COALESCE(SUM(s.cost_usd), 0)::double precision AS cost_usd
+FROM step_runs s
+JOIN workflow_runs r ON r.id = s.run_id
+WHERE r.workflow_name = $1
+  AND NOT s.cache_hit
+  AND NOT EXISTS (
+    SELECT 1 FROM step_runs i
+    WHERE i.run_id = s.run_id AND i.parent_step = s.name
+  )
+`
+
+type GetWorkflowUsageRow struct {
+	Tokens  int64   `json:"tokens"`
+	CostUsd float64 `json:"cost_usd"`
+}
+
+func (q *Queries) GetWorkflowUsage(ctx context.Context, workflowName string) (GetWorkflowUsageRow, error) {
+	row := q.db.QueryRow(ctx, getWorkflowUsage, workflowName)
+	var i GetWorkflowUsageRow
+	err := row.Scan(&i.Tokens, &i.CostUsd)
+	return i, err
+}
+
 const listActiveWorkflowRuns = `-- name: ListActiveWorkflowRuns :many
-SELECT id, workflow_id, workflow_name, workflow_version, status, current_step_index, context, triggered_by, trigger_data, error, started_at, completed_at, created_at, updated_at FROM workflow_runs
+SELECT id, workflow_id, workflow_name, workflow_version, status, current_step_index, context, triggered_by, trigger_data, error, pending_step, lease_owner, lease_expires_at, cancel_reason, rerun_of, parent_run_id, parent_step, concurrency_group, budget, outputs, deadline, approval_deadline, started_at, completed_at, created_at, updated_at FROM workflow_runs
 WHERE status NOT IN ('completed', 'failed', 'cancelled')
 ORDER BY created_at DESC
 `
@@ -153,6 +238,126 @@ func (q *Queries) ListActiveWorkflowRuns(ctx context.Context) ([]WorkflowRun, er
 			&i.TriggeredBy,
 			&i.TriggerData,
 			&i.Error,
+			&i.PendingStep,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
+			&i.CancelReason,
+			&i.RerunOf,
+			&i.ParentRunID,
+			&i.ParentStep,
+			&i.ConcurrencyGroup,
+			&i.Budget,
+			&i.Outputs,
+			&i.Deadline,
+			&i.ApprovalDeadline,
+			&i.StartedAt,
+			&i.CompletedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listActiveWorkflowRunsInGroup = `-- name: ListActiveWorkflowRunsInGroup :many
+SELECT id, workflow_id, workflow_name, workflow_version, status, current_step_index, context, triggered_by, trigger_data, error, pending_step, lease_owner, lease_expires_at, cancel_reason, rerun_of, parent_run_id, parent_step, concurrency_group, budget, outputs, deadline, approval_deadline, started_at, completed_at, created_at, updated_at FROM workflow_runs
+WHERE concurrency_group = $1
+  AND status NOT IN ('completed', 'failed', 'cancelled')
+ORDER BY created_at
+`
+
+func (q *Queries) ListActiveWorkflowRunsInGroup(ctx context.Context, concurrencyGroup *string) ([]WorkflowRun, error) {
+	rows, err := q.db.Query(ctx, listActiveWorkflowRunsInGroup, concurrencyGroup)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WorkflowRun{}
+	for rows.Next() {
+		var i WorkflowRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkflowID,
+			&i.WorkflowName,
+			&i.WorkflowVersion,
+			&i.Status,
+			&i.CurrentStepIndex,
+			&i.Context,
+			&i.TriggeredBy,
+			&i.TriggerData,
+			&i.Error,
+			&i.PendingStep,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
+			&i.CancelReason,
+			&i.RerunOf,
+			&i.ParentRunID,
+			&i.ParentStep,
+			&i.ConcurrencyGroup,
+			&i.Budget,
+			&i.Outputs,
+			&i.Deadline,
+			&i.ApprovalDeadline,
+			&i.StartedAt,
+			&i.CompletedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrphanedWorkflowRuns = `-- name: ListOrphanedWorkflowRuns :many
+SELECT id, workflow_id, workflow_name, workflow_version, status, current_step_index, context, triggered_by, trigger_data, error, pending_step, lease_owner, lease_expires_at, cancel_reason, rerun_of, parent_run_id, parent_step, concurrency_group, budget, outputs, deadline, approval_deadline, started_at, completed_at, created_at, updated_at FROM workflow_runs
+WHERE status NOT IN ('completed', 'failed', 'cancelled')
+  AND lease_expires_at < NOW()
+ORDER BY created_at
+`
+
+func (q *Queries) ListOrphanedWorkflowRuns(ctx context.Context) ([]WorkflowRun, error) {
+	rows, err := q.db.Query(ctx, listOrphanedWorkflowRuns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []WorkflowRun{}
+	for rows.Next() {
+		var i WorkflowRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.WorkflowID,
+			&i.WorkflowName,
+			&i.WorkflowVersion,
+			&i.Status,
+			&i.CurrentStepIndex,
+			&i.Context,
+			&i.TriggeredBy,
+			&i.TriggerData,
+			&i.Error,
+			&i.PendingStep,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
+			&i.CancelReason,
+			&i.RerunOf,
+			&i.ParentRunID,
+			&i.ParentStep,
+			&i.ConcurrencyGroup,
+			&i.Budget,
+			&i.Outputs,
+			&i.Deadline,
+			&i.ApprovalDeadline,
 			&i.StartedAt,
 			&i.CompletedAt,
 			&i.CreatedAt,
@@ -169,7 +374,7 @@ func (q *Queries) ListActiveWorkflowRuns(ctx context.Context) ([]WorkflowRun, er
 }
 
 const listWorkflowRuns = `-- name: ListWorkflowRuns :many
-SELECT id, workflow_id, workflow_name, workflow_version, status, current_step_index, context, triggered_by, trigger_data, error, started_at, completed_at, created_at, updated_at FROM workflow_runs
+SELECT id, workflow_id, workflow_name, workflow_version, status, current_step_index, context, triggered_by, trigger_data, error, pending_step, lease_owner, lease_expires_at, cancel_reason, rerun_of, parent_run_id, parent_step, concurrency_group, budget, outputs, deadline, approval_deadline, started_at, completed_at, created_at, updated_at FROM workflow_runs
 WHERE workflow_id = $1
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3
@@ -201,6 +406,18 @@ func (q *Queries) ListWorkflowRuns(ctx context.Context, arg ListWorkflowRunsPara
 			&i.TriggeredBy,
 			&i.TriggerData,
 			&i.Error,
+			&i.PendingStep,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
+			&i.CancelReason,
+			&i.RerunOf,
+			&i.ParentRunID,
+			&i.ParentStep,
+			&i.ConcurrencyGroup,
+			&i.Budget,
+			&i.Outputs,
+			&i.Deadline,
+			&i.ApprovalDeadline,
 			&i.StartedAt,
 			&i.CompletedAt,
 			&i.CreatedAt,
@@ -216,39 +433,85 @@ func (q *Queries) ListWorkflowRuns(ctx context.Context, arg ListWorkflowRunsPara
 	return items, nil
 }
 
+const releaseWorkflowRunLease = `-- name: ReleaseWorkflowRunLease :exec
+UPDATE workflow_runs
+SET
+    lease_owner = NULL,
+    lease_expires_at = NULL
+WHERE id = $1 AND lease_owner = $2
+`
+
+type ReleaseWorkflowRunLeaseParams struct {
+	ID         string  `json:"id"`
+	LeaseOwner *string `json:"lease_owner"`
+}
+
+func (q *Queries) ReleaseWorkflowRunLease(ctx context.Context, arg ReleaseWorkflowRunLeaseParams) error {
+	_, err := q.db.Exec(ctx, releaseWorkflowRunLease, arg.ID, arg.LeaseOwner)
+	return err
+}
+
+const requestWorkflowRunCancel = `-- name: RequestWorkflowRunCancel :execrows
+UPDATE workflow_runs
+SET cancel_reason = $2
+WHERE id = $1
+`
+
+type RequestWorkflowRunCancelParams struct {
+	ID           string  `json:"id"`
+	CancelReason *string `json:"cancel_reason"`
+}
+
+func (q *Queries) RequestWorkflowRunCancel(ctx context.Context, arg RequestWorkflowRunCancelParams) (int64, error) {
+	result, err := q.db.Exec(ctx, requestWorkflowRunCancel, arg.ID, arg.CancelReason)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateWorkflowRun = `-- name: UpdateWorkflowRun :one
 UPDATE workflow_runs
 SET
     status = $2,
-    current_step_index = $3,
-    context = $4,
-    error = $5,
-    started_at = $6,
-    completed_at = $7,
+    context = $3,
+    error = $4,
+    started_at = $5,
+    completed_at = $6,
+    pending_step = $7,
+    outputs = $8,
+    approval_deadline = $9,
+    cancel_reason = CASE WHEN $10::boolean THEN NULL ELSE cancel_reason END,
     updated_at = NOW()
 WHERE id = $1
-RETURNING id, workflow_id, workflow_name, workflow_version, status, current_step_index, context, triggered_by, trigger_data, error, started_at, completed_at, created_at, updated_at
+RETURNING id, workflow_id, workflow_name, workflow_version, status, current_step_index, context, triggered_by, trigger_data, error, pending_step, lease_owner, lease_expires_at, cancel_reason, rerun_of, parent_run_id, parent_step, concurrency_group, budget, outputs, deadline, approval_deadline, started_at, completed_at, created_at, updated_at
 `
 
 type UpdateWorkflowRunParams struct {
 	ID               string             `json:"id"`
 	Status           string             `json:"status"`
-	CurrentStepIndex int32              `json:"current_step_index"`
 	Context          []byte             `json:"context"`
 	Error            *string            `json:"error"`
 	StartedAt        pgtype.Timestamptz `json:"started_at"`
 	CompletedAt      pgtype.Timestamptz `json:"completed_at"`
+	PendingStep      *string            `json:"pending_step"`
+	Outputs          []byte             `json:"outputs"`
+	ApprovalDeadline pgtype.Timestamptz `json:"approval_deadline"`
+	ClearCancel      bool               `json:"clear_cancel"`
 }
 
 func (q *Queries) UpdateWorkflowRun(ctx context.Context, arg UpdateWorkflowRunParams) (WorkflowRun, error) {
 	row := q.db.QueryRow(ctx, updateWorkflowRun,
 		arg.ID,
 		arg.Status,
-		arg.CurrentStepIndex,
 		arg.Context,
 		arg.Error,
 		arg.StartedAt,
 		arg.CompletedAt,
+		arg.PendingStep,
+		arg.Outputs,
+		arg.ApprovalDeadline,
+		arg.ClearCancel,
 	)
 	var i WorkflowRun
 	err := row.Scan(
@@ -262,6 +525,18 @@ func (q *Queries) UpdateWorkflowRun(ctx context.Context, arg UpdateWorkflowRunPa
 		&i.TriggeredBy,
 		&i.TriggerData,
 		&i.Error,
+		&i.PendingStep,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+		&i.CancelReason,
+		&i.RerunOf,
+		&i.ParentRunID,
+		&i.ParentStep,
+		&i.ConcurrencyGroup,
+		&i.Budget,
+		&i.Outputs,
+		&i.Deadline,
+		&i.ApprovalDeadline,
 		&i.StartedAt,
 		&i.CompletedAt,
 		&i.CreatedAt,
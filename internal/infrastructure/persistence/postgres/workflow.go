@@ -3,18 +3,25 @@ package postgres
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/felixgeelhaar/bolt"
 	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/persistence/postgres/sqlc"
+	"github.com/felixgeelhaar/bridge/pkg/config"
 	"github.com/felixgeelhaar/bridge/pkg/types"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// uniqueViolation is the PostgreSQL error code of a unique constraint
+// violation.
+const uniqueViolation = "23505"
+
 // WorkflowRepository implements workflow.Repository using PostgreSQL.
 type WorkflowRepository struct {
 	pool    *pgxpool.Pool
@@ -55,6 +62,10 @@ func (r *WorkflowRepository) CreateDefinition(ctx context.Context, def *workflow
 		UpdatedAt:   timeToPgTimestamptzValue(def.UpdatedAt),
 	})
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return fmt.Errorf("%w: %s@%s", types.ErrWorkflowAlreadyExists, def.Name, def.Version)
+		}
 		return fmt.Errorf("failed to create workflow definition: %w", err)
 	}
 
@@ -71,7 +82,7 @@ func (r *WorkflowRepository) GetDefinition(ctx context.Context, id types.Workflo
 	row, err := r.queries.GetWorkflowDefinition(ctx, id.String())
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("workflow definition not found: %s", id)
+			return nil, fmt.Errorf("%w: %s", types.ErrWorkflowNotFound, id)
 		}
 		return nil, fmt.Errorf("failed to get workflow definition: %w", err)
 	}
@@ -79,12 +90,29 @@ func (r *WorkflowRepository) GetDefinition(ctx context.Context, id types.Workflo
 	return r.rowToDefinition(row)
 }
 
-// GetDefinitionByName retrieves a workflow definition by name.
+// GetDefinitionByName retrieves the latest version of a workflow
+// definition by name.
 func (r *WorkflowRepository) GetDefinitionByName(ctx context.Context, name string) (*workflow.WorkflowDefinition, error) {
 	row, err := r.queries.GetWorkflowDefinitionByName(ctx, name)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("workflow definition not found: %s", name)
+			return nil, fmt.Errorf("%w: %s", types.ErrWorkflowNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to get workflow definition: %w", err)
+	}
+
+	return r.rowToDefinition(row)
+}
+
+// GetDefinitionVersion retrieves a version of a workflow definition.
+func (r *WorkflowRepository) GetDefinitionVersion(ctx context.Context, name, version string) (*workflow.WorkflowDefinition, error) {
+	row, err := r.queries.GetWorkflowDefinitionVersion(ctx, sqlc.GetWorkflowDefinitionVersionParams{
+		Name:    name,
+		Version: version,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s@%s", types.ErrWorkflowNotFound, name, version)
 		}
 		return nil, fmt.Errorf("failed to get workflow definition: %w", err)
 	}
@@ -114,32 +142,23 @@ func (r *WorkflowRepository) ListDefinitions(ctx context.Context, limit, offset
 	return defs, nil
 }
 
-// UpdateDefinition updates a workflow definition.
-func (r *WorkflowRepository) UpdateDefinition(ctx context.Context, def *workflow.WorkflowDefinition) error {
-	config, err := r.marshalConfig(def)
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
-	}
-
-	metadata, err := json.Marshal(def.Metadata)
+// ListDefinitionVersions lists the versions of a workflow, the latest first.
+func (r *WorkflowRepository) ListDefinitionVersions(ctx context.Context, name string) ([]*workflow.WorkflowDefinition, error) {
+	rows, err := r.queries.ListWorkflowDefinitionVersions(ctx, name)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return nil, fmt.Errorf("failed to list workflow definition versions: %w", err)
 	}
 
-	_, err = r.queries.UpdateWorkflowDefinition(ctx, sqlc.UpdateWorkflowDefinitionParams{
-		ID:          def.ID.String(),
-		Name:        def.Name,
-		Version:     def.Version,
-		Description: strPtr(def.Description),
-		Config:      config,
-		Checksum:    strPtr(def.Checksum),
-		Metadata:    metadata,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to update workflow definition: %w", err)
+	defs := make([]*workflow.WorkflowDefinition, 0, len(rows))
+	for _, row := range rows {
+		def, err := r.rowToDefinition(row)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
 	}
 
-	return nil
+	return defs, nil
 }
 
 // DeleteDefinition deletes a workflow definition.
@@ -170,13 +189,19 @@ func (r *WorkflowRepository) CreateRun(ctx context.Context, run *workflow.Workfl
 		return fmt.Errorf("failed to marshal trigger data: %w", err)
 	}
 
+	var budget []byte
+	if run.Budget != nil {
+		if budget, err = json.Marshal(run.Budget); err != nil {
+			return fmt.Errorf("failed to marshal budget: %w", err)
+		}
+	}
+
 	_, err = qtx.CreateWorkflowRun(ctx, sqlc.CreateWorkflowRunParams{
 		ID:               run.ID.String(),
 		WorkflowID:       run.WorkflowID.String(),
 		WorkflowName:     run.WorkflowName,
 		WorkflowVersion:  run.WorkflowVersion,
 		Status:           string(run.Status),
-		CurrentStepIndex: int32(run.CurrentStepIdx),
 		Context:          runContext,
 		TriggeredBy:      strPtr(run.TriggeredBy),
 		TriggerData:      triggerData,
@@ -185,6 +210,12 @@ func (r *WorkflowRepository) CreateRun(ctx context.Context, run *workflow.Workfl
 		CompletedAt:      timeToPgTimestamptz(run.CompletedAt),
 		CreatedAt:        timeToPgTimestamptzValue(run.CreatedAt),
 		UpdatedAt:        timeToPgTimestamptzValue(run.UpdatedAt),
+		RerunOf:          strPtr(run.RerunOf.String()),
+		ParentRunID:      strPtr(run.ParentRunID.String()),
+		ParentStep:       strPtr(run.ParentStep),
+		Deadline:         timeToPgTimestamptz(run.Deadline),
+		ConcurrencyGroup: strPtr(run.ConcurrencyGroup),
+		Budget:           budget,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create workflow run: %w", err)
@@ -192,32 +223,8 @@ func (r *WorkflowRepository) CreateRun(ctx context.Context, run *workflow.Workfl
 
 	// Create step runs
 	for i, step := range run.Steps {
-		input, _ := json.Marshal(step.Input)
-		output, _ := json.Marshal(step.Output)
-
-		_, err = qtx.CreateStepRun(ctx, sqlc.CreateStepRunParams{
-			ID:               step.ID.String(),
-			RunID:            run.ID.String(),
-			StepIndex:        int32(step.StepIndex),
-			Name:             step.Name,
-			AgentID:          strPtr(step.AgentID),
-			Status:           string(step.Status),
-			Input:            input,
-			Output:           output,
-			RequiresApproval: step.RequiresApproval,
-			TimeoutSeconds:   int32Ptr(int32(step.Timeout.Seconds())),
-			MaxRetries:       int32Ptr(int32(step.MaxRetries)),
-			RetryCount:       int32Ptr(int32(step.RetryCount)),
-			Error:            strPtr(step.Error),
-			TokensIn:         int32Ptr(int32(step.TokensIn)),
-			TokensOut:        int32Ptr(int32(step.TokensOut)),
-			StepOrder:        int32(i),
-			StartedAt:        timeToPgTimestamptz(step.StartedAt),
-			CompletedAt:      timeToPgTimestamptz(step.CompletedAt),
-			CreatedAt:        timeToPgTimestamptzValue(step.CreatedAt),
-		})
-		if err != nil {
-			return fmt.Errorf("failed to create step run: %w", err)
+		if err := r.createStep(ctx, qtx, step, i); err != nil {
+			return err
 		}
 	}
 
@@ -238,7 +245,7 @@ func (r *WorkflowRepository) GetRun(ctx context.Context, id types.RunID) (*workf
 	row, err := r.queries.GetWorkflowRun(ctx, id.String())
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("workflow run not found: %s", id)
+			return nil, fmt.Errorf("%w: %s", types.ErrRunNotFound, id)
 		}
 		return nil, fmt.Errorf("failed to get workflow run: %w", err)
 	}
@@ -308,6 +315,55 @@ func (r *WorkflowRepository) ListActiveRuns(ctx context.Context) ([]*workflow.Wo
 	return runs, nil
 }
 
+// ListActiveRunsInGroup returns the active workflow runs in a concurrency
+// group.
+func (r *WorkflowRepository) ListActiveRunsInGroup(ctx context.Context, group string) ([]*workflow.WorkflowRun, error) {
+	rows, err := r.queries.ListActiveWorkflowRunsInGroup(ctx, &group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow runs in concurrency group: %w", err)
+	}
+
+	runs := make([]*workflow.WorkflowRun, 0, len(rows))
+	for _, row := range rows {
+		run, err := r.rowToRun(row)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+// ListOrphanedRuns returns the active workflow runs whose lease has expired.
+func (r *WorkflowRepository) ListOrphanedRuns(ctx context.Context) ([]*workflow.WorkflowRun, error) {
+	rows, err := r.queries.ListOrphanedWorkflowRuns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orphaned workflow runs: %w", err)
+	}
+
+	runs := make([]*workflow.WorkflowRun, 0, len(rows))
+	for _, row := range rows {
+		run, err := r.rowToRun(row)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+// WorkflowUsage returns the tokens and cost used by the runs of all versions
+// of the named workflow.
+func (r *WorkflowRepository) WorkflowUsage(ctx context.Context, name string) (workflow.Usage, error) {
+	row, err := r.queries.GetWorkflowUsage(ctx, name)
+	if err != nil {
+		return workflow.Usage{}, fmt.Errorf("failed to get workflow usage: %w", err)
+	}
+	return workflow.Usage{Tokens: int(row.Tokens), CostUSD: row.CostUsd}, nil
+}
+
 // UpdateRun updates a workflow run.
 func (r *WorkflowRepository) UpdateRun(ctx context.Context, run *workflow.WorkflowRun) error {
 	runContext, err := json.Marshal(run.Context)
@@ -315,14 +371,25 @@ func (r *WorkflowRepository) UpdateRun(ctx context.Context, run *workflow.Workfl
 		return fmt.Errorf("failed to marshal context: %w", err)
 	}
 
+	var outputs []byte
+	if run.Outputs != nil {
+		if outputs, err = json.Marshal(run.Outputs); err != nil {
+			return fmt.Errorf("failed to marshal outputs: %w", err)
+		}
+	}
+
 	_, err = r.queries.UpdateWorkflowRun(ctx, sqlc.UpdateWorkflowRunParams{
 		ID:               run.ID.String(),
 		Status:           string(run.Status),
-		CurrentStepIndex: int32(run.CurrentStepIdx),
 		Context:          runContext,
 		Error:            strPtr(run.Error),
 		StartedAt:        timeToPgTimestamptz(run.StartedAt),
 		CompletedAt:      timeToPgTimestamptz(run.CompletedAt),
+		PendingStep:      strPtr(run.PendingStep),
+		Outputs:          outputs,
+		ApprovalDeadline: timeToPgTimestamptz(run.ApprovalDeadline),
+		// A finished run has nothing left to cancel
+		ClearCancel: run.Status.IsTerminal(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to update workflow run: %w", err)
@@ -331,6 +398,60 @@ func (r *WorkflowRepository) UpdateRun(ctx context.Context, run *workflow.Workfl
 	return nil
 }
 
+// AcquireLease takes or renews the lease on a workflow run.
+func (r *WorkflowRepository) AcquireLease(ctx context.Context, id types.RunID, owner string, ttl time.Duration) error {
+	expiresAt := time.Now().Add(ttl)
+	cancelReason, err := r.queries.AcquireWorkflowRunLease(ctx, sqlc.AcquireWorkflowRunLeaseParams{
+		ID:             id.String(),
+		LeaseOwner:     strPtr(owner),
+		LeaseExpiresAt: timeToPgTimestamptz(&expiresAt),
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			// No row is updated for a missing run or one leased by another owner
+			if _, err := r.queries.GetWorkflowRun(ctx, id.String()); err == pgx.ErrNoRows {
+				return types.ErrRunNotFound
+			}
+			return types.ErrRunLeased
+		}
+		return fmt.Errorf("failed to acquire workflow run lease: %w", err)
+	}
+	if cancelReason != nil {
+		return fmt.Errorf("%w: %s", types.ErrRunCancelled, *cancelReason)
+	}
+
+	return nil
+}
+
+// ReleaseLease releases the lease on a workflow run held by owner.
+func (r *WorkflowRepository) ReleaseLease(ctx context.Context, id types.RunID, owner string) error {
+	err := r.queries.ReleaseWorkflowRunLease(ctx, sqlc.ReleaseWorkflowRunLeaseParams{
+		ID:         id.String(),
+		LeaseOwner: strPtr(owner),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release workflow run lease: %w", err)
+	}
+
+	return nil
+}
+
+// RequestCancel records a cancellation request for a workflow run.
+func (r *WorkflowRepository) RequestCancel(ctx context.Context, id types.RunID, reason string) error {
+	rows, err := r.queries.RequestWorkflowRunCancel(ctx, sqlc.RequestWorkflowRunCancelParams{
+		ID:           id.String(),
+		CancelReason: &reason,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to request workflow run cancellation: %w", err)
+	}
+	if rows == 0 {
+		return types.ErrRunNotFound
+	}
+
+	return nil
+}
+
 // GetStep retrieves a step run by ID.
 func (r *WorkflowRepository) GetStep(ctx context.Context, id types.StepID) (*workflow.StepRun, error) {
 	row, err := r.queries.GetStepRun(ctx, id.String())
@@ -344,6 +465,84 @@ func (r *WorkflowRepository) GetStep(ctx context.Context, id types.StepID) (*wor
 	return r.rowToStep(row)
 }
 
+// CreateSteps stores steps added to a run while it executes. Steps are
+// ordered by their position in the run.
+func (r *WorkflowRepository) CreateSteps(ctx context.Context, run *workflow.WorkflowRun, steps []*workflow.StepRun) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	qtx := r.queries.WithTx(tx)
+
+	for _, step := range steps {
+		order := 0
+		for i, s := range run.Steps {
+			if s == step {
+				order = i
+				break
+			}
+		}
+		if err := r.createStep(ctx, qtx, step, order); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// createStep inserts a step run at the given position within its run.
+func (r *WorkflowRepository) createStep(ctx context.Context, qtx *sqlc.Queries, step *workflow.StepRun, order int) error {
+	input, _ := json.Marshal(step.Input)
+	output, _ := json.Marshal(step.Output)
+
+	var item []byte
+	if step.Item != nil {
+		var err error
+		if item, err = json.Marshal(step.Item); err != nil {
+			return fmt.Errorf("failed to marshal item: %w", err)
+		}
+	}
+
+	_, err := qtx.CreateStepRun(ctx, sqlc.CreateStepRunParams{
+		ID:               step.ID.String(),
+		RunID:            step.RunID.String(),
+		StepIndex:        int32(step.StepIndex),
+		Name:             step.Name,
+		AgentID:          strPtr(step.AgentID),
+		Status:           string(step.Status),
+		Input:            input,
+		Output:           output,
+		RequiresApproval: step.RequiresApproval,
+		TimeoutSeconds:   int32Ptr(int32(step.Timeout.Seconds())),
+		MaxRetries:       int32Ptr(int32(step.MaxRetries)),
+		RetryCount:       int32Ptr(int32(step.RetryCount)),
+		Error:            strPtr(step.Error),
+		TokensIn:         int32Ptr(int32(step.TokensIn)),
+		TokensOut:        int32Ptr(int32(step.TokensOut)),
+		StepOrder:        int32(order),
+		StartedAt:        timeToPgTimestamptz(step.StartedAt),
+		CompletedAt:      timeToPgTimestamptz(step.CompletedAt),
+		CreatedAt:        timeToPgTimestamptzValue(step.CreatedAt),
+		Reused:           step.Reused,
+		ParentStep:       strPtr(step.Parent),
+		Item:             item,
+		ChildRunID:       strPtr(step.ChildRunID.String()),
+		Handler:          strPtr(string(step.Handler)),
+		HandlerOf:        strPtr(step.HandlerOf),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create step run: %w", err)
+	}
+
+	return nil
+}
+
 // UpdateStep updates a step run.
 func (r *WorkflowRepository) UpdateStep(ctx context.Context, step *workflow.StepRun) error {
 	input, err := json.Marshal(step.Input)
@@ -356,6 +555,11 @@ func (r *WorkflowRepository) UpdateStep(ctx context.Context, step *workflow.Step
 		return fmt.Errorf("failed to marshal output: %w", err)
 	}
 
+	attempts, err := json.Marshal(step.Attempts)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attempts: %w", err)
+	}
+
 	_, err = r.queries.UpdateStepRun(ctx, sqlc.UpdateStepRunParams{
 		ID:          step.ID.String(),
 		Status:      string(step.Status),
@@ -367,6 +571,12 @@ func (r *WorkflowRepository) UpdateStep(ctx context.Context, step *workflow.Step
 		TokensOut:   int32Ptr(int32(step.TokensOut)),
 		StartedAt:   timeToPgTimestamptz(step.StartedAt),
 		CompletedAt: timeToPgTimestamptz(step.CompletedAt),
+		ApprovedAt:  timeToPgTimestamptz(step.ApprovedAt),
+		Warnings:    step.Warnings,
+		ChildRunID:  strPtr(step.ChildRunID.String()),
+		Attempts:    attempts,
+		CacheHit:    step.CacheHit,
+		CostUsd:     &step.CostUSD,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to update step run: %w", err)
@@ -375,33 +585,125 @@ func (r *WorkflowRepository) UpdateStep(ctx context.Context, step *workflow.Step
 	return nil
 }
 
+// GetCachedResult retrieves the step result cached under a key.
+func (r *WorkflowRepository) GetCachedResult(ctx context.Context, key string) (*workflow.CachedResult, error) {
+	row, err := r.queries.GetCachedStepResult(ctx, key)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, types.ErrCacheMiss
+		}
+		return nil, fmt.Errorf("failed to get cached step result: %w", err)
+	}
+
+	var output map[string]any
+	if len(row.Output) > 0 {
+		if err := json.Unmarshal(row.Output, &output); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal cached output: %w", err)
+		}
+	}
+
+	return &workflow.CachedResult{
+		Key:       row.Key,
+		Output:    output,
+		TokensIn:  int(row.TokensIn),
+		TokensOut: int(row.TokensOut),
+		CostUSD:   row.CostUsd,
+		RunID:     types.RunID(ptrStr(row.RunID)),
+		Step:      ptrStr(row.StepName),
+		CreatedAt: pgTimestamptzToTime(row.CreatedAt),
+		ExpiresAt: pgTimestamptzToTime(row.ExpiresAt),
+	}, nil
+}
+
+// SaveCachedResult stores a step result, replacing any result cached under
+// the same key.
+func (r *WorkflowRepository) SaveCachedResult(ctx context.Context, result *workflow.CachedResult) error {
+	output, err := json.Marshal(result.Output)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached output: %w", err)
+	}
+
+	_, err = r.queries.UpsertCachedStepResult(ctx, sqlc.UpsertCachedStepResultParams{
+		Key:       result.Key,
+		Output:    output,
+		TokensIn:  int32(result.TokensIn),
+		TokensOut: int32(result.TokensOut),
+		CostUsd:   result.CostUSD,
+		RunID:     strPtr(result.RunID.String()),
+		StepName:  strPtr(result.Step),
+		CreatedAt: timeToPgTimestamptzValue(result.CreatedAt),
+		ExpiresAt: timeToPgTimestamptzValue(result.ExpiresAt),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save cached step result: %w", err)
+	}
+	return nil
+}
+
 // Helper methods
 
 func (r *WorkflowRepository) marshalConfig(def *workflow.WorkflowDefinition) ([]byte, error) {
 	config := map[string]any{
-		"steps":    def.Steps,
-		"triggers": def.Triggers,
-		"policies": def.Policies,
+		"inputs":             def.Inputs,
+		"steps":              def.Steps,
+		"on_failure":         def.OnFailure,
+		"finally":            def.Finally,
+		"outputs":            def.Outputs,
+		"max_parallel":       def.MaxParallel,
+		"timeout":            def.Timeout,
+		"approval_timeout":   def.ApprovalTimeout,
+		"concurrency_group":  def.ConcurrencyGroup,
+		"cancel_in_progress": def.CancelInProgress,
+		"budget":             def.Budget,
+		"triggers":           def.Triggers,
+		"policies":           def.Policies,
 	}
 	return json.Marshal(config)
 }
 
 func (r *WorkflowRepository) rowToDefinition(row sqlc.WorkflowDefinition) (*workflow.WorkflowDefinition, error) {
-	var steps []workflow.StepDefinition
+	var inputs map[string]config.InputConfig
+	var steps, onFailure, finally []workflow.StepDefinition
+	var outputs map[string]any
+	var maxParallel int
+	var timeout, approvalTimeout time.Duration
+	var concurrencyGroup string
+	var cancelInProgress bool
+	var budget *workflow.Budget
 	var triggers []workflow.Trigger
 	var policies []workflow.PolicyRef
 	var metadata map[string]any
 
 	if len(row.Config) > 0 {
 		var config struct {
-			Steps    []workflow.StepDefinition `json:"steps"`
-			Triggers []workflow.Trigger        `json:"triggers"`
-			Policies []workflow.PolicyRef      `json:"policies"`
+			Inputs           map[string]config.InputConfig `json:"inputs"`
+			Steps            []workflow.StepDefinition     `json:"steps"`
+			OnFailure        []workflow.StepDefinition     `json:"on_failure"`
+			Finally          []workflow.StepDefinition     `json:"finally"`
+			Outputs          map[string]any                `json:"outputs"`
+			MaxParallel      int                           `json:"max_parallel"`
+			Timeout          time.Duration                 `json:"timeout"`
+			ApprovalTimeout  time.Duration                 `json:"approval_timeout"`
+			ConcurrencyGroup string                        `json:"concurrency_group"`
+			CancelInProgress bool                          `json:"cancel_in_progress"`
+			Budget           *workflow.Budget              `json:"budget"`
+			Triggers         []workflow.Trigger            `json:"triggers"`
+			Policies         []workflow.PolicyRef          `json:"policies"`
 		}
 		if err := json.Unmarshal(row.Config, &config); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 		}
+		inputs = config.Inputs
 		steps = config.Steps
+		onFailure = config.OnFailure
+		finally = config.Finally
+		outputs = config.Outputs
+		maxParallel = config.MaxParallel
+		timeout = config.Timeout
+		approvalTimeout = config.ApprovalTimeout
+		concurrencyGroup = config.ConcurrencyGroup
+		cancelInProgress = config.CancelInProgress
+		budget = config.Budget
 		triggers = config.Triggers
 		policies = config.Policies
 	}
@@ -413,17 +715,27 @@ func (r *WorkflowRepository) rowToDefinition(row sqlc.WorkflowDefinition) (*work
 	}
 
 	return &workflow.WorkflowDefinition{
-		ID:          types.WorkflowID(row.ID),
-		Name:        row.Name,
-		Version:     row.Version,
-		Description: ptrStr(row.Description),
-		Steps:       steps,
-		Triggers:    triggers,
-		Policies:    policies,
-		Checksum:    ptrStr(row.Checksum),
-		Metadata:    metadata,
-		CreatedAt:   pgTimestamptzToTime(row.CreatedAt),
-		UpdatedAt:   pgTimestamptzToTime(row.UpdatedAt),
+		ID:               types.WorkflowID(row.ID),
+		Name:             row.Name,
+		Version:          row.Version,
+		Description:      ptrStr(row.Description),
+		Inputs:           inputs,
+		Steps:            steps,
+		OnFailure:        onFailure,
+		Finally:          finally,
+		Outputs:          outputs,
+		MaxParallel:      maxParallel,
+		Timeout:          timeout,
+		ApprovalTimeout:  approvalTimeout,
+		ConcurrencyGroup: concurrencyGroup,
+		CancelInProgress: cancelInProgress,
+		Budget:           budget,
+		Triggers:         triggers,
+		Policies:         policies,
+		Checksum:         ptrStr(row.Checksum),
+		Metadata:         metadata,
+		CreatedAt:        pgTimestamptzToTime(row.CreatedAt),
+		UpdatedAt:        pgTimestamptzToTime(row.UpdatedAt),
 	}, nil
 }
 
@@ -443,21 +755,45 @@ func (r *WorkflowRepository) rowToRun(row sqlc.WorkflowRun) (*workflow.WorkflowR
 		}
 	}
 
+	var outputs map[string]any
+	if len(row.Outputs) > 0 {
+		if err := json.Unmarshal(row.Outputs, &outputs); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal outputs: %w", err)
+		}
+	}
+
+	var budget *workflow.Budget
+	if len(row.Budget) > 0 {
+		if err := json.Unmarshal(row.Budget, &budget); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal budget: %w", err)
+		}
+	}
+
 	return &workflow.WorkflowRun{
-		ID:              types.RunID(row.ID),
-		WorkflowID:      types.WorkflowID(row.WorkflowID),
-		WorkflowName:    row.WorkflowName,
-		WorkflowVersion: row.WorkflowVersion,
-		Status:          workflow.RunStatus(row.Status),
-		CurrentStepIdx:  int(row.CurrentStepIndex),
-		Context:         runContext,
-		TriggeredBy:     ptrStr(row.TriggeredBy),
-		TriggerData:     triggerData,
-		Error:           ptrStr(row.Error),
-		StartedAt:       pgTimestamptzToTimePtr(row.StartedAt),
-		CompletedAt:     pgTimestamptzToTimePtr(row.CompletedAt),
-		CreatedAt:       pgTimestamptzToTime(row.CreatedAt),
-		UpdatedAt:       pgTimestamptzToTime(row.UpdatedAt),
+		ID:               types.RunID(row.ID),
+		WorkflowID:       types.WorkflowID(row.WorkflowID),
+		WorkflowName:     row.WorkflowName,
+		WorkflowVersion:  row.WorkflowVersion,
+		Status:           workflow.RunStatus(row.Status),
+		Context:          runContext,
+		TriggeredBy:      ptrStr(row.TriggeredBy),
+		TriggerData:      triggerData,
+		Error:            ptrStr(row.Error),
+		PendingStep:      ptrStr(row.PendingStep),
+		LeaseOwner:       ptrStr(row.LeaseOwner),
+		LeaseExpiresAt:   pgTimestamptzToTimePtr(row.LeaseExpiresAt),
+		RerunOf:          types.RunID(ptrStr(row.RerunOf)),
+		ParentRunID:      types.RunID(ptrStr(row.ParentRunID)),
+		ParentStep:       ptrStr(row.ParentStep),
+		ConcurrencyGroup: ptrStr(row.ConcurrencyGroup),
+		Budget:           budget,
+		Outputs:          outputs,
+		Deadline:         pgTimestamptzToTimePtr(row.Deadline),
+		ApprovalDeadline: pgTimestamptzToTimePtr(row.ApprovalDeadline),
+		StartedAt:        pgTimestamptzToTimePtr(row.StartedAt),
+		CompletedAt:      pgTimestamptzToTimePtr(row.CompletedAt),
+		CreatedAt:        pgTimestamptzToTime(row.CreatedAt),
+		UpdatedAt:        pgTimestamptzToTime(row.UpdatedAt),
 	}, nil
 }
 
@@ -477,11 +813,27 @@ func (r *WorkflowRepository) rowToStep(row sqlc.StepRun) (*workflow.StepRun, err
 		}
 	}
 
+	var item map[string]any
+	if len(row.Item) > 0 {
+		if err := json.Unmarshal(row.Item, &item); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+		}
+	}
+
+	var attempts []workflow.StepAttempt
+	if len(row.Attempts) > 0 {
+		if err := json.Unmarshal(row.Attempts, &attempts); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal attempts: %w", err)
+		}
+	}
+
 	return &workflow.StepRun{
 		ID:               types.StepID(row.ID),
 		RunID:            types.RunID(row.RunID),
 		StepIndex:        int(row.StepIndex),
 		Name:             row.Name,
+		Parent:           ptrStr(row.ParentStep),
+		Item:             item,
 		AgentID:          ptrStr(row.AgentID),
 		Status:           workflow.StepStatus(row.Status),
 		Input:            input,
@@ -490,9 +842,18 @@ func (r *WorkflowRepository) rowToStep(row sqlc.StepRun) (*workflow.StepRun, err
 		Timeout:          time.Duration(ptrInt32(row.TimeoutSeconds)) * time.Second,
 		MaxRetries:       int(ptrInt32(row.MaxRetries)),
 		RetryCount:       int(ptrInt32(row.RetryCount)),
+		Attempts:         attempts,
 		Error:            ptrStr(row.Error),
 		TokensIn:         int(ptrInt32(row.TokensIn)),
 		TokensOut:        int(ptrInt32(row.TokensOut)),
+		CostUSD:          ptrFloat64(row.CostUsd),
+		Warnings:         row.Warnings,
+		ApprovedAt:       pgTimestamptzToTimePtr(row.ApprovedAt),
+		Reused:           row.Reused,
+		CacheHit:         row.CacheHit,
+		ChildRunID:       types.RunID(ptrStr(row.ChildRunID)),
+		Handler:          workflow.HandlerKind(ptrStr(row.Handler)),
+		HandlerOf:        ptrStr(row.HandlerOf),
 		StartedAt:        pgTimestamptzToTimePtr(row.StartedAt),
 		CompletedAt:      pgTimestamptzToTimePtr(row.CompletedAt),
 		CreatedAt:        pgTimestamptzToTime(row.CreatedAt),
@@ -526,6 +887,13 @@ func ptrInt32(i *int32) int32 {
 	return *i
 }
 
+func ptrFloat64(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
+
 func timeToPgTimestamptz(t *time.Time) pgtype.Timestamptz {
 	if t == nil {
 		return pgtype.Timestamptz{Valid: false}
@@ -551,5 +919,8 @@ func pgTimestamptzToTime(t pgtype.Timestamptz) time.Time {
 	return t.Time
 }
 
-// Ensure WorkflowRepository implements workflow.Repository.
-var _ workflow.Repository = (*WorkflowRepository)(nil)
+// Ensure WorkflowRepository implements workflow.Repository and workflow.StepCache.
+var (
+	_ workflow.Repository = (*WorkflowRepository)(nil)
+	_ workflow.StepCache  = (*WorkflowRepository)(nil)
+)
@@ -0,0 +1,316 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/felixgeelhaar/bolt"
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newTestRepository returns a repository backed by a fresh schema in the
+// database at DATABASE_URL.
+func newTestRepository(t *testing.T) *WorkflowRepository {
+	t.Helper()
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(admin.Close)
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE") })
+
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema + ",public"
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	// Migrations are applied in the order of their names
+	migrations, err := filepath.Glob("sqlc/schema/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(migrations)
+	for _, migration := range migrations {
+		ddl, err := os.ReadFile(migration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pool.Exec(ctx, string(ddl)); err != nil {
+			t.Fatalf("apply %s: %v", migration, err)
+		}
+	}
+
+	logger := bolt.New(bolt.NewConsoleHandler(os.Stderr)).SetLevel(bolt.ERROR)
+	return NewWorkflowRepository(pool, logger)
+}
+
+// createTestRun stores a workflow and a run of it.
+func createTestRun(t *testing.T, repo *WorkflowRepository, cfg *config.WorkflowConfig) *workflow.WorkflowRun {
+	t.Helper()
+	ctx := context.Background()
+
+	def, err := workflow.NewWorkflowDefinition(cfg)
+	if err != nil {
+		t.Fatalf("NewWorkflowDefinition() error = %v", err)
+	}
+	if err := repo.CreateDefinition(ctx, def); err != nil {
+		t.Fatalf("CreateDefinition() error = %v", err)
+	}
+	run := workflow.NewWorkflowRun(def, "test", nil)
+	if err := repo.CreateRun(ctx, run); err != nil {
+		t.Fatalf("CreateRun() error = %v", err)
+	}
+	return run
+}
+
+func TestWorkflowRepository_CreateSteps_Expanded(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	run := createTestRun(t, repo, &config.WorkflowConfig{
+		Name:    "fan-out",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "review", Agent: "reviewer", Foreach: []any{"a.go", "b.go"}},
+			{Name: "post", Agent: "reviewer", DependsOn: []string{"review"}},
+		},
+	})
+
+	items := run.ExpandStep(run.Steps[0], []map[string]any{{"item": "a.go"}, {"item": "b.go"}})
+	if err := repo.CreateSteps(ctx, run, items); err != nil {
+		t.Fatalf("CreateSteps() error = %v", err)
+	}
+
+	stored, err := repo.GetRun(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	names := make([]string, 0, len(stored.Steps))
+	for _, step := range stored.Steps {
+		names = append(names, step.Name)
+	}
+	want := []string{"review", "review[0]", "review[1]", "post"}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("Steps = %v, want %v", names, want)
+	}
+	if item := stored.GetStepByName("review[1]"); item.Parent != "review" || item.Item["item"] != "b.go" {
+		t.Errorf("review[1] = parent %q, item %v", item.Parent, item.Item)
+	}
+}
+
+func TestWorkflowRepository_CreateSteps_Handlers(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	cfg := &config.WorkflowConfig{
+		Name:    "handlers",
+		Version: "1.0",
+		Steps: []config.StepConfig{
+			{Name: "label", Agent: "reviewer", OnFailure: []config.StepConfig{{Name: "unlabel", Agent: "reviewer"}}},
+			{Name: "post", Agent: "reviewer", DependsOn: []string{"label"}},
+		},
+		OnFailure: []config.StepConfig{{Name: "notify", Agent: "reviewer"}},
+		Finally:   []config.StepConfig{{Name: "cleanup", Agent: "reviewer"}},
+	}
+	run := createTestRun(t, repo, cfg)
+	def, err := workflow.NewWorkflowDefinition(cfg)
+	if err != nil {
+		t.Fatalf("NewWorkflowDefinition() error = %v", err)
+	}
+
+	// Step handlers share the index of the failing step
+	failure := map[string]any{"step": "label", "error": "boom"}
+	batches := [][]*workflow.StepRun{
+		run.AddHandlers(workflow.HandlerOnFailure, "label", def.GetStep("label").OnFailure, failure),
+		run.AddHandlers(workflow.HandlerOnFailure, "", def.OnFailure, failure),
+		run.AddHandlers(workflow.HandlerFinally, "", def.Finally, failure),
+	}
+	for _, handlers := range batches {
+		if err := repo.CreateSteps(ctx, run, handlers); err != nil {
+			t.Fatalf("CreateSteps() error = %v", err)
+		}
+	}
+
+	stored, err := repo.GetRun(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	names := make([]string, 0, len(stored.Steps))
+	for _, step := range stored.Steps {
+		names = append(names, step.Name)
+	}
+	want := []string{"label", "unlabel", "post", "notify", "cleanup"}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("Steps = %v, want %v", names, want)
+	}
+	if unlabel := stored.GetStepByName("unlabel"); unlabel.Handler != workflow.HandlerOnFailure || unlabel.HandlerOf != "label" {
+		t.Errorf("unlabel = handler %q of %q", unlabel.Handler, unlabel.HandlerOf)
+	}
+}
+
+func TestWorkflowRepository_AcquireLease(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	run := createTestRun(t, repo, &config.WorkflowConfig{
+		Name:    "leased",
+		Version: "1.0",
+		Steps:   []config.StepConfig{{Name: "review", Agent: "reviewer"}},
+	})
+
+	if err := repo.AcquireLease(ctx, run.ID, "worker-1", time.Minute); err != nil {
+		t.Fatalf("AcquireLease() error = %v", err)
+	}
+	if err := repo.AcquireLease(ctx, run.ID, "worker-2", time.Minute); err != types.ErrRunLeased {
+		t.Errorf("AcquireLease() by another owner error = %v, want ErrRunLeased", err)
+	}
+	if err := repo.AcquireLease(ctx, types.NewRunID(), "worker-1", time.Minute); err != types.ErrRunNotFound {
+		t.Errorf("AcquireLease() of a missing run error = %v, want ErrRunNotFound", err)
+	}
+	if _, err := repo.GetRun(ctx, types.NewRunID()); !errors.Is(err, types.ErrRunNotFound) {
+		t.Errorf("GetRun() of a missing run error = %v, want ErrRunNotFound", err)
+	}
+}
+
+func TestWorkflowRepository_ListOrphanedRuns(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	orphaned := createTestRun(t, repo, &config.WorkflowConfig{
+		Name:    "orphaned",
+		Version: "1.0",
+		Steps:   []config.StepConfig{{Name: "review", Agent: "reviewer"}},
+	})
+	leased := createTestRun(t, repo, &config.WorkflowConfig{
+		Name:    "leased",
+		Version: "1.0",
+		Steps:   []config.StepConfig{{Name: "review", Agent: "reviewer"}},
+	})
+
+	if err := repo.AcquireLease(ctx, orphaned.ID, "worker-1", -time.Second); err != nil {
+		t.Fatalf("AcquireLease() error = %v", err)
+	}
+	if err := repo.AcquireLease(ctx, leased.ID, "worker-1", time.Minute); err != nil {
+		t.Fatalf("AcquireLease() error = %v", err)
+	}
+
+	runs, err := repo.ListOrphanedRuns(ctx)
+	if err != nil {
+		t.Fatalf("ListOrphanedRuns() error = %v", err)
+	}
+	if len(runs) != 1 || runs[0].ID != orphaned.ID {
+		t.Errorf("ListOrphanedRuns() = %d runs, want only the run with an expired lease", len(runs))
+	}
+}
+
+func TestWorkflowRepository_RequestCancel(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	run := createTestRun(t, repo, &config.WorkflowConfig{
+		Name:    "cancelled",
+		Version: "1.0",
+		Steps:   []config.StepConfig{{Name: "review", Agent: "reviewer"}},
+	})
+
+	if err := repo.RequestCancel(ctx, run.ID, "superseded"); err != nil {
+		t.Fatalf("RequestCancel() error = %v", err)
+	}
+	if err := repo.AcquireLease(ctx, run.ID, "worker-1", time.Minute); !errors.Is(err, types.ErrRunCancelled) {
+		t.Errorf("AcquireLease() error = %v, want ErrRunCancelled", err)
+	}
+
+	// The request is cleared once the run is cancelled
+	run.Cancel("superseded")
+	if err := repo.UpdateRun(ctx, run); err != nil {
+		t.Fatalf("UpdateRun() error = %v", err)
+	}
+	if err := repo.AcquireLease(ctx, run.ID, "worker-1", time.Minute); err != nil {
+		t.Errorf("AcquireLease() after cancellation error = %v", err)
+	}
+}
+
+func TestWorkflowRepository_CachedResult(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	step := &workflow.StepRun{
+		RunID:     types.NewRunID(),
+		Name:      "review",
+		Output:    map[string]any{"content": "looks good"},
+		TokensIn:  10,
+		TokensOut: 5,
+		CostUSD:   0.25,
+	}
+	if err := repo.SaveCachedResult(ctx, workflow.NewCachedResult("abc123", step, time.Hour)); err != nil {
+		t.Fatalf("SaveCachedResult() error = %v", err)
+	}
+
+	got, err := repo.GetCachedResult(ctx, "abc123")
+	if err != nil {
+		t.Fatalf("GetCachedResult() error = %v", err)
+	}
+	if got.Output["content"] != "looks good" || got.TokensIn != 10 || got.TokensOut != 5 || got.CostUSD != 0.25 {
+		t.Errorf("GetCachedResult() = %+v, want the saved result", got)
+	}
+	if _, err := repo.GetCachedResult(ctx, "def456"); !errors.Is(err, types.ErrCacheMiss) {
+		t.Errorf("GetCachedResult() of a missing key error = %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestWorkflowRepository_WorkflowUsage(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	cfg := &config.WorkflowConfig{
+		Name:    "budgeted",
+		Version: "1.0",
+		Steps:   []config.StepConfig{{Name: "review", Agent: "reviewer"}, {Name: "summarize", Agent: "reviewer"}},
+	}
+	run := createTestRun(t, repo, cfg)
+	run.Steps[0].Complete(nil, 10, 5)
+	run.Steps[0].CostUSD = 0.5
+	run.Steps[1].Complete(nil, 20, 10)
+	run.Steps[1].CacheHit = true
+	for _, step := range run.Steps {
+		if err := repo.UpdateStep(ctx, step); err != nil {
+			t.Fatalf("UpdateStep() error = %v", err)
+		}
+	}
+
+	// Cache hits made no agent calls
+	usage, err := repo.WorkflowUsage(ctx, "budgeted")
+	if err != nil {
+		t.Fatalf("WorkflowUsage() error = %v", err)
+	}
+	if want := (workflow.Usage{Tokens: 15, CostUSD: 0.5}); usage != want {
+		t.Errorf("WorkflowUsage() = %+v, want %+v", usage, want)
+	}
+}
@@ -123,6 +123,19 @@ func (e *Engine) evaluateRule(ctx context.Context, rule governance.PolicyRule, i
 		"context":       input.Context,
 		"metadata":      input.Metadata,
 	}
+	if input.Budget != nil {
+		budget := map[string]any{
+			"tokens_used": input.Budget.TokensUsed,
+			"cost_usd":    input.Budget.CostUSD,
+		}
+		if input.Budget.TokensRemaining != nil {
+			budget["tokens_remaining"] = *input.Budget.TokensRemaining
+		}
+		if input.Budget.CostRemainingUSD != nil {
+			budget["cost_remaining_usd"] = *input.Budget.CostRemainingUSD
+		}
+		inputMap["budget"] = budget
+	}
 
 	// Evaluate "allowed" query
 	allowedQuery, err := rego.New(
@@ -188,6 +201,27 @@ func (e *Engine) evaluateRule(ctx context.Context, rule governance.PolicyRule, i
 		}
 	}
 
+	// Evaluate "warning" query
+	warningQuery, err := rego.New(
+		rego.Query("data.bridge.policy.warning"),
+		rego.Module("policy.rego", rule.Rego),
+	).PrepareForEval(ctx)
+
+	if err == nil {
+		warningResults, err := warningQuery.Eval(ctx, rego.EvalInput(inputMap))
+		if err == nil && len(warningResults) > 0 && len(warningResults[0].Expressions) > 0 {
+			if warnings, ok := warningResults[0].Expressions[0].Value.([]interface{}); ok {
+				for _, w := range warnings {
+					result.Warnings = append(result.Warnings, governance.Violation{
+						Rule:     rule.Name,
+						Message:  fmt.Sprintf("%v", w),
+						Severity: governance.SeverityWarning,
+					})
+				}
+			}
+		}
+	}
+
 	return result, nil
 }
 
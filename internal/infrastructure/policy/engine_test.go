@@ -229,6 +229,60 @@ requires_approval if {
 	}
 }
 
+func TestEngine_Evaluate_Budget(t *testing.T) {
+	logger := newTestLogger()
+	engine := policy.NewEngine(logger)
+
+	bundle := governance.NewPolicyBundle("test", "1.0", "Test bundle")
+	bundle.AddRule(governance.PolicyRule{
+		Name:        "approval_for_low_budget",
+		Description: "Require approval when little budget is left",
+		Enabled:     true,
+		Severity:    governance.SeverityWarning,
+		Rego: `
+package bridge.policy
+
+default allowed = true
+default requires_approval = false
+
+requires_approval if {
+    input.budget.cost_remaining_usd < 1
+}
+`,
+	})
+
+	remaining := func(cost float64) *float64 { return &cost }
+	tests := []struct {
+		name         string
+		budget       *governance.BudgetInput
+		wantApproval bool
+	}{
+		{"low budget", &governance.BudgetInput{CostUSD: 4.5, CostRemainingUSD: remaining(0.5)}, true},
+		{"enough budget", &governance.BudgetInput{CostUSD: 1, CostRemainingUSD: remaining(4)}, false},
+		{"no cost limit", &governance.BudgetInput{CostUSD: 10}, false},
+		{"no budget", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &governance.PolicyInput{
+				WorkflowID: "wf-123",
+				RunID:      "run-456",
+				Budget:     tt.budget,
+			}
+
+			result, err := engine.Evaluate(context.Background(), bundle, input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if result.RequiresApproval != tt.wantApproval {
+				t.Errorf("requires_approval = %v, want %v", result.RequiresApproval, tt.wantApproval)
+			}
+		})
+	}
+}
+
 func TestEngine_Evaluate_DisabledRule(t *testing.T) {
 	logger := newTestLogger()
 	engine := policy.NewEngine(logger)
@@ -481,6 +535,50 @@ violation contains msg if {
 	}
 }
 
+func TestEngine_Evaluate_WarningRule(t *testing.T) {
+	logger := newTestLogger()
+	engine := policy.NewEngine(logger)
+
+	bundle := governance.NewPolicyBundle("test", "1.0", "Test bundle")
+	bundle.AddRule(governance.PolicyRule{
+		Name:     "file_write_warning",
+		Enabled:  true,
+		Severity: governance.SeverityError,
+		Rego: `
+package bridge.policy
+
+default allowed = true
+
+warning contains msg if {
+    input.capabilities[_] == "file-write"
+    msg := "File write operation detected - changes will be audited"
+}
+`,
+	})
+
+	input := &governance.PolicyInput{
+		WorkflowID:   "wf-123",
+		RunID:        "run-456",
+		StepName:     "apply-fix",
+		Capabilities: []string{"file-write"},
+	}
+
+	result, err := engine.Evaluate(context.Background(), bundle, input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.IsBlocking() {
+		t.Error("expected warnings not to block")
+	}
+	if len(result.Warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %d", len(result.Warnings))
+	}
+	if result.Warnings[0].Severity != governance.SeverityWarning {
+		t.Errorf("warning severity = %v, want %v", result.Warnings[0].Severity, governance.SeverityWarning)
+	}
+}
+
 func TestEngine_ValidateRego_Valid(t *testing.T) {
 	logger := newTestLogger()
 	engine := policy.NewEngine(logger)
@@ -17,6 +17,10 @@ func NewApp() *cli.App {
 			commands.RunCommand(),
 			commands.StatusCommand(),
 			commands.ApproveCommand(),
+			commands.RespondCommand(),
+			commands.CancelCommand(),
+			commands.RerunCommand(),
+			commands.WorkflowsCommand(),
 		},
 		Flags: []cli.Flag{
 			&cli.StringFlag{
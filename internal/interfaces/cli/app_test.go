@@ -24,7 +24,7 @@ func TestNewApp(t *testing.T) {
 func TestNewApp_HasCommands(t *testing.T) {
 	app := cli.NewApp()
 
-	expectedCommands := []string{"init", "validate", "run", "status", "approve"}
+	expectedCommands := []string{"init", "validate", "run", "status", "approve", "respond", "cancel", "rerun", "workflows"}
 
 	if len(app.Commands) != len(expectedCommands) {
 		t.Errorf("expected %d commands, got %d", len(expectedCommands), len(app.Commands))
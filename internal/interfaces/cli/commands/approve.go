@@ -11,8 +11,8 @@ import (
 	"github.com/felixgeelhaar/bridge/internal/domain/governance"
 	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/llm"
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/mcp"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/messaging/eventbus"
-	"github.com/felixgeelhaar/bridge/internal/infrastructure/persistence/memory"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/policy"
 	"github.com/felixgeelhaar/bridge/internal/interfaces/cli/output"
 	"github.com/felixgeelhaar/bridge/pkg/types"
@@ -70,8 +70,16 @@ func runApprove(c *cli.Context) error {
 	logLevel := c.String("log-level")
 	logger := setupApproveLogger(logLevel)
 
+	// Open the run store shared between commands
+	workflowRepo, closeStore, err := openWorkflowRepository(ctx, logger)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to initialize: %v", err))
+		return err
+	}
+	defer closeStore()
+
 	// Create orchestrator
-	orch, auditLogger, err := createApprovalOrchestrator(logger)
+	orch, auditLogger, err := createApprovalOrchestrator(logger, workflowRepo)
 	if err != nil {
 		formatter.Error(fmt.Sprintf("Failed to initialize: %v", err))
 		return err
@@ -80,7 +88,7 @@ func runApprove(c *cli.Context) error {
 	// Get run
 	run, err := orch.GetRun(ctx, types.RunID(id.String()))
 	if err != nil {
-		formatter.Error(fmt.Sprintf("Run not found: %s", runID))
+		runNotFound(formatter, runID)
 		return err
 	}
 
@@ -95,8 +103,10 @@ func runApprove(c *cli.Context) error {
 
 	if reject {
 		// Reject the run
-		run.Fail("Rejected by " + approver + ": " + comment)
-		auditService.LogWorkflowFailed(ctx, run.WorkflowID.String(), run.ID.String(), "Rejected by "+approver)
+		if err := orch.RejectWorkflow(ctx, run, approver, comment); err != nil {
+			formatter.Error(fmt.Sprintf("Failed to reject workflow: %v", err))
+			return err
+		}
 		formatter.ApprovalStatus(run.ID.String(), "rejected", approver)
 		return nil
 	}
@@ -106,7 +116,12 @@ func runApprove(c *cli.Context) error {
 	_ = auditService.LogApprovalGranted(ctx, run.ID.String(), run.ID.String(), approver)
 
 	// Resume workflow execution
-	err = orch.ResumeWorkflow(ctx, run)
+	err = orch.ResumeWorkflow(ctx, run, approver)
+	if err != nil && run.Status == workflow.RunStatusAwaitingInput {
+		formatter.ApprovalStatus(run.ID.String(), "approved", approver)
+		printAwaiting(formatter, run)
+		return nil
+	}
 	if err != nil {
 		formatter.Error(fmt.Sprintf("Failed to resume workflow: %v", err))
 		return err
@@ -136,7 +151,7 @@ func setupApproveLogger(level string) *bolt.Logger {
 	return bolt.New(handler).SetLevel(logLevel)
 }
 
-func createApprovalOrchestrator(logger *bolt.Logger) (*orchestrator.Orchestrator, governance.AuditLogger, error) {
+func createApprovalOrchestrator(logger *bolt.Logger, workflowRepo workflow.Repository) (*orchestrator.Orchestrator, governance.AuditLogger, error) {
 	llmRegistry := llm.NewRegistry()
 
 	// Setup providers
@@ -149,7 +164,11 @@ func createApprovalOrchestrator(logger *bolt.Logger) (*orchestrator.Orchestrator
 		agentRegistry.Register(agent)
 	}
 
-	workflowRepo := memory.NewWorkflowRepository()
+	// Expose the sandboxed file and git tools to agents
+	toolSet := agents.NewToolSet()
+	toolRegistry := mcp.NewToolRegistry(logger, []string{"."})
+	mcp.RegisterAgentTools(toolSet, toolRegistry, false)
+
 	eventPublisher := eventbus.New()
 	policyEngine := policy.NewEngine(logger)
 	auditLogger := governance.NewInMemoryAuditLogger()
@@ -162,6 +181,9 @@ func createApprovalOrchestrator(logger *bolt.Logger) (*orchestrator.Orchestrator
 		AuditLogger:     auditLogger,
 		LLMRegistry:     llmRegistry,
 		AgentRegistry:   agentRegistry,
+		Tools:           toolSet,
+		Actions:         newActionSet(logger, toolRegistry),
+		StepCache:       newStepCache(),
 	})
 
 	return orch, auditLogger, err
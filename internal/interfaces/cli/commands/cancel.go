@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/felixgeelhaar/bolt"
+	"github.com/felixgeelhaar/bridge/internal/interfaces/cli/output"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+)
+
+// CancelCommand returns the cancel command.
+func CancelCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "cancel",
+		Usage:     "Cancel an active workflow run",
+		ArgsUsage: "<run-id>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "reason",
+				Aliases: []string{"m"},
+				Usage:   "Reason for cancelling the run",
+			},
+		},
+		Action: runCancel,
+	}
+}
+
+func runCancel(c *cli.Context) error {
+	formatter := output.NewFormatter(c.String("output"))
+
+	runID := c.Args().First()
+	if runID == "" {
+		formatter.Error("Run ID required")
+		return fmt.Errorf("run id required")
+	}
+
+	// Parse run ID
+	id, err := uuid.Parse(runID)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Invalid run ID: %s", runID))
+		return err
+	}
+
+	reason := c.String("reason")
+	if reason == "" {
+		reason = "cancelled by " + getCurrentUser()
+	}
+
+	// Setup minimal infrastructure
+	ctx := context.Background()
+	handler := bolt.NewConsoleHandler(os.Stderr)
+	logger := bolt.New(handler).SetLevel(bolt.ERROR)
+
+	// Open the run store shared between commands
+	workflowRepo, closeStore, err := openWorkflowRepository(ctx, logger)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to initialize: %v", err))
+		return err
+	}
+	defer closeStore()
+
+	// Create orchestrator
+	orch, err := createMinimalOrchestrator(logger, workflowRepo)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to initialize: %v", err))
+		return err
+	}
+
+	// Runs executing in another process are cancelled by that process
+	if err := orch.CancelRun(ctx, types.RunID(id.String()), reason); err != nil {
+		if errors.Is(err, types.ErrRunNotFound) {
+			runNotFound(formatter, runID)
+			return err
+		}
+		formatter.Error(fmt.Sprintf("Failed to cancel run: %v", err))
+		return err
+	}
+
+	run, err := orch.GetRun(ctx, types.RunID(id.String()))
+	if err != nil {
+		runNotFound(formatter, runID)
+		return err
+	}
+
+	if run.Status.IsTerminal() {
+		formatter.Success("Workflow run cancelled")
+	} else {
+		formatter.Info("Cancellation requested, the run stops at its next lease renewal")
+	}
+	formatter.WorkflowRun(run)
+
+	return nil
+}
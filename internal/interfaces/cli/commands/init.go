@@ -217,8 +217,8 @@ triggers:
 
 steps:
   - name: fetch-changes
-    agent: code-reviewer
-    description: Fetch and analyze PR changes
+    uses: github.get_pr_files
+    description: Fetch the changed files and diff
     input:
       pr_number: ${{ trigger.pr.number }}
       repo: ${{ trigger.repo.full_name }}
@@ -238,13 +238,13 @@ steps:
       security_findings: ${{ steps.security-scan.output }}
 
   - name: post-review
-    agent: github-commenter
-    description: Post review comments
+    uses: github.create_review
+    description: Post the review
     requires_approval: true
     input:
+      repo: ${{ trigger.repo.full_name }}
       pr_number: ${{ trigger.pr.number }}
-      review: ${{ steps.code-review.output }}
-      security: ${{ steps.security-scan.output }}
+      body: ${{ steps.code-review.output.content }}
 
 policies:
   - name: require-human-approval
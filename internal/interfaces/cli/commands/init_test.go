@@ -170,21 +170,38 @@ func TestApproveCommand(t *testing.T) {
 	}
 }
 
+func TestRespondCommand(t *testing.T) {
+	cmd := commands.RespondCommand()
+	if cmd == nil {
+		t.Fatal("expected non-nil command")
+	}
+
+	if cmd.Name != "respond" {
+		t.Errorf("expected name 'respond', got %s", cmd.Name)
+	}
+}
+
 func TestValidateCommand_HasFlags(t *testing.T) {
 	cmd := commands.ValidateCommand()
 
-	// Should have workflow flag
+	// Should have workflow and print-resolved flags
 	hasWorkflowFlag := false
+	hasPrintResolvedFlag := false
 	for _, flag := range cmd.Flags {
-		if flag.Names()[0] == "workflow" {
+		switch flag.Names()[0] {
+		case "workflow":
 			hasWorkflowFlag = true
-			break
+		case "print-resolved":
+			hasPrintResolvedFlag = true
 		}
 	}
 
 	if !hasWorkflowFlag {
 		t.Error("expected workflow flag")
 	}
+	if !hasPrintResolvedFlag {
+		t.Error("expected print-resolved flag")
+	}
 }
 
 func TestRunCommand_HasFlags(t *testing.T) {
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/internal/interfaces/cli/output"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+)
+
+// RerunCommand returns the rerun command.
+func RerunCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "rerun",
+		Usage:     "Re-run a finished workflow run, reusing completed step outputs",
+		ArgsUsage: "<run-id>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "from-step",
+				Usage: "Re-run from this step, reusing the outputs of earlier steps",
+			},
+			&cli.BoolFlag{
+				Name:  "only-failed",
+				Usage: "Re-run only the steps that did not succeed",
+			},
+		},
+		Action: runRerun,
+	}
+}
+
+func runRerun(c *cli.Context) error {
+	formatter := output.NewFormatter(c.String("output"))
+
+	runID := c.Args().First()
+	if runID == "" {
+		formatter.Error("Run ID required")
+		return fmt.Errorf("run id required")
+	}
+
+	// Parse run ID
+	id, err := uuid.Parse(runID)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Invalid run ID: %s", runID))
+		return err
+	}
+
+	opts := workflow.RerunOptions{
+		FromStep:   c.String("from-step"),
+		OnlyFailed: c.Bool("only-failed"),
+	}
+
+	// Setup infrastructure
+	ctx := context.Background()
+	logger := setupLogger(c.String("log-level"))
+
+	// Open the run store shared between commands
+	workflowRepo, closeStore, err := openWorkflowRepository(ctx, logger)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to initialize: %v", err))
+		return err
+	}
+	defer closeStore()
+
+	// Create orchestrator
+	orch, _, err := createApprovalOrchestrator(logger, workflowRepo)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to initialize: %v", err))
+		return err
+	}
+
+	// Create the new run linked to the original
+	run, err := orch.CreateRerun(ctx, types.RunID(id.String()), getCurrentUser(), opts)
+	if errors.Is(err, types.ErrRunNotFound) {
+		runNotFound(formatter, runID)
+		return err
+	}
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to create rerun: %v", err))
+		return err
+	}
+
+	formatter.Info(fmt.Sprintf("Run ID: %s (rerun of %s)", run.ID.String(), runID))
+
+	// Execute the remaining steps
+	err = orch.ExecuteWorkflow(ctx, run)
+	if err != nil {
+		if run.Status == workflow.RunStatusAwaitingApproval || run.Status == workflow.RunStatusAwaitingInput {
+			printAwaiting(formatter, run)
+			return nil
+		}
+
+		formatter.Error(fmt.Sprintf("Workflow execution failed: %v", err))
+		formatter.WorkflowRun(run)
+		return err
+	}
+
+	formatter.Success("Workflow completed successfully")
+	formatter.WorkflowRun(run)
+
+	return nil
+}
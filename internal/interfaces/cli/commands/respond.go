@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/internal/interfaces/cli/output"
+	"github.com/felixgeelhaar/bridge/pkg/types"
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+)
+
+// RespondCommand returns the respond command.
+func RespondCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "respond",
+		Usage:     "Answer the human input step a workflow run is awaiting",
+		ArgsUsage: "<run-id>",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:    "field",
+				Aliases: []string{"f"},
+				Usage:   "Answer fields (key=value)",
+			},
+			&cli.StringFlag{
+				Name:  "responder",
+				Usage: "Responder identity",
+				Value: getCurrentUser(),
+			},
+		},
+		Action: runRespond,
+	}
+}
+
+func runRespond(c *cli.Context) error {
+	formatter := output.NewFormatter(c.String("output"))
+	responder := c.String("responder")
+
+	runID := c.Args().First()
+	if runID == "" {
+		formatter.Error("Run ID required")
+		return fmt.Errorf("run id required")
+	}
+
+	// Parse run ID
+	id, err := uuid.Parse(runID)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Invalid run ID: %s", runID))
+		return err
+	}
+
+	// Build the answer from fields
+	fields := make(map[string]any)
+	for _, field := range c.StringSlice("field") {
+		key, value := parseInput(field)
+		if key != "" {
+			fields[key] = value
+		}
+	}
+
+	// Setup infrastructure
+	ctx := context.Background()
+	logger := setupApproveLogger(c.String("log-level"))
+
+	// Open the run store shared between commands
+	workflowRepo, closeStore, err := openWorkflowRepository(ctx, logger)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to initialize: %v", err))
+		return err
+	}
+	defer closeStore()
+
+	// Create orchestrator
+	orch, _, err := createApprovalOrchestrator(logger, workflowRepo)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to initialize: %v", err))
+		return err
+	}
+
+	// Get run
+	run, err := orch.GetRun(ctx, types.RunID(id.String()))
+	if err != nil {
+		runNotFound(formatter, runID)
+		return err
+	}
+
+	// Check status
+	if run.Status != workflow.RunStatusAwaitingInput {
+		formatter.Error(fmt.Sprintf("Run is not awaiting input (status: %s)", run.Status))
+		return fmt.Errorf("run not awaiting input")
+	}
+
+	formatter.Info(fmt.Sprintf("Answering step %s of run %s...", run.PendingStep, runID[:8]))
+
+	// Answer and resume workflow execution
+	err = orch.RespondToInput(ctx, run, responder, fields)
+	if errors.Is(err, types.ErrAnswerInvalid) {
+		formatter.Error(fmt.Sprintf("Invalid answer: %v", err))
+		return err
+	}
+	if err != nil {
+		if run.Status == workflow.RunStatusAwaitingApproval || run.Status == workflow.RunStatusAwaitingInput {
+			printAwaiting(formatter, run)
+			return nil
+		}
+		formatter.Error(fmt.Sprintf("Failed to resume workflow: %v", err))
+		formatter.WorkflowRun(run)
+		return err
+	}
+
+	formatter.Success("Workflow resumed successfully")
+	formatter.WorkflowRun(run)
+
+	return nil
+}
+
+// printAwaiting tells how to continue a run that paused for approval or
+// input.
+func printAwaiting(formatter *output.Formatter, run *workflow.WorkflowRun) {
+	if run.Status == workflow.RunStatusAwaitingInput {
+		formatter.Warning("Workflow is awaiting input")
+		formatter.Info(fmt.Sprintf("To respond: bridge respond %s --field key=value", run.ID.String()))
+	} else {
+		formatter.Warning("Workflow is awaiting approval")
+		formatter.Info(fmt.Sprintf("To approve: bridge approve %s", run.ID.String()))
+	}
+	formatter.WorkflowRun(run)
+}
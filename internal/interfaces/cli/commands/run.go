@@ -4,19 +4,25 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/felixgeelhaar/bolt"
 	"github.com/felixgeelhaar/bridge/internal/application/orchestrator"
 	"github.com/felixgeelhaar/bridge/internal/domain/agents"
 	"github.com/felixgeelhaar/bridge/internal/domain/governance"
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/actions"
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/github"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/llm"
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/mcp"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/messaging/eventbus"
-	"github.com/felixgeelhaar/bridge/internal/infrastructure/persistence/memory"
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/persistence/filecache"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/policy"
 	"github.com/felixgeelhaar/bridge/internal/interfaces/cli/output"
 	"github.com/felixgeelhaar/bridge/pkg/config"
 	"github.com/urfave/cli/v2"
-	"gopkg.in/yaml.v3"
 )
 
 // RunCommand returns the run command.
@@ -48,11 +54,44 @@ func RunCommand() *cli.Command {
 				Usage: "Wait for workflow to complete",
 				Value: true,
 			},
+			&cli.BoolFlag{
+				Name:  "no-cache",
+				Usage: "Ignore cached step results",
+			},
+			&cli.IntFlag{
+				Name:  "max-tokens",
+				Usage: "Fail the run once its agent calls used this many tokens",
+			},
+			&cli.Float64Flag{
+				Name:  "max-cost-usd",
+				Usage: "Fail the run once its agent calls cost this many US dollars",
+			},
+			&cli.BoolFlag{
+				Name:  "recover",
+				Usage: "Resume runs interrupted by a previous process while the workflow runs",
+			},
 		},
 		Action: runWorkflow,
 	}
 }
 
+// runBudget returns the budget of the run set by flags, nil without one.
+func runBudget(c *cli.Context) (*workflow.Budget, error) {
+	maxTokens := c.Int("max-tokens")
+	maxCost := c.Float64("max-cost-usd")
+	if maxTokens < 0 || maxCost < 0 {
+		return nil, fmt.Errorf("--max-tokens and --max-cost-usd cannot be negative")
+	}
+	if maxTokens == 0 && maxCost == 0 {
+		return nil, nil
+	}
+	return &workflow.Budget{
+		MaxTokens:  maxTokens,
+		MaxCostUSD: maxCost,
+		OnExceeded: config.BudgetOnExceededFail,
+	}, nil
+}
+
 func runWorkflow(c *cli.Context) error {
 	formatter := output.NewFormatter(c.String("output"))
 	workflowPath := c.String("workflow")
@@ -70,15 +109,15 @@ func runWorkflow(c *cli.Context) error {
 		return err
 	}
 
-	// Parse YAML
-	var cfg config.WorkflowConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		formatter.Error(fmt.Sprintf("Failed to parse workflow YAML: %v", err))
+	// Resolve includes and step templates
+	cfg, err := config.ResolveWorkflowConfig(data, workflowPath)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to resolve workflow: %v", err))
 		return err
 	}
 
 	// Validate first
-	errors, warnings := validateWorkflow(&cfg, false)
+	errors, warnings := validateWorkflow(cfg, false)
 	if len(errors) > 0 {
 		formatter.ValidationResult(false, errors, warnings)
 		return fmt.Errorf("validation failed")
@@ -88,12 +127,6 @@ func runWorkflow(c *cli.Context) error {
 		formatter.Warning(w)
 	}
 
-	if dryRun {
-		formatter.Success("Dry run: workflow is valid")
-		formatter.WorkflowDefinition(nil) // Would need to create definition first
-		return nil
-	}
-
 	// Build trigger data from inputs
 	triggerData := make(map[string]any)
 	for _, input := range inputs {
@@ -102,6 +135,26 @@ func runWorkflow(c *cli.Context) error {
 			triggerData[key] = value
 		}
 	}
+	if err := checkInputNames(cfg.Inputs, triggerData); err != nil {
+		formatter.Error(fmt.Sprintf("Invalid inputs: %v", err))
+		return err
+	}
+
+	budget, err := runBudget(c)
+	if err != nil {
+		formatter.Error(err.Error())
+		return err
+	}
+
+	if dryRun {
+		if _, err := config.ResolveInputs(cfg.Inputs, triggerData); err != nil {
+			formatter.Error(fmt.Sprintf("Invalid inputs: %v", err))
+			return err
+		}
+		formatter.Success("Dry run: workflow is valid")
+		formatter.WorkflowDefinition(nil) // Would need to create definition first
+		return nil
+	}
 
 	// Initialize infrastructure
 	ctx := context.Background()
@@ -120,8 +173,18 @@ func runWorkflow(c *cli.Context) error {
 		agentRegistry.Register(agent)
 	}
 
-	// Create repositories
-	workflowRepo := memory.NewWorkflowRepository()
+	// Expose the sandboxed file and git tools to agents
+	toolSet := agents.NewToolSet()
+	toolRegistry := mcp.NewToolRegistry(logger, []string{"."})
+	mcp.RegisterAgentTools(toolSet, toolRegistry, false)
+
+	// Open the run store shared between commands
+	workflowRepo, closeStore, err := openWorkflowRepository(ctx, logger)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to open run store: %v", err))
+		return err
+	}
+	defer closeStore()
 	eventPublisher := eventbus.New()
 
 	// Create policy engine
@@ -153,15 +216,40 @@ func runWorkflow(c *cli.Context) error {
 		AuditLogger:     auditLogger,
 		LLMRegistry:     llmRegistry,
 		AgentRegistry:   agentRegistry,
+		Tools:           toolSet,
+		Actions:         newActionSet(logger, toolRegistry),
+		StepCache:       newStepCache(),
+		NoCache:         c.Bool("no-cache"),
+		RunBudget:       budget,
 	})
 	if err != nil {
 		formatter.Error(fmt.Sprintf("Failed to create orchestrator: %v", err))
 		return err
 	}
 
+	// Time out runs that passed their deadline while no process ran them
+	expired, err := orch.ExpireRuns(ctx)
+	if err != nil {
+		formatter.Warning(fmt.Sprintf("Failed to expire timed out runs: %v", err))
+	}
+	if len(expired) > 0 {
+		formatter.Info(fmt.Sprintf("Timed out %d expired run(s)", len(expired)))
+	}
+
+	// Resume runs interrupted by a previous process alongside this run
+	if c.Bool("recover") {
+		defer recoverRuns(ctx, orch, formatter)()
+	}
+
+	// Create the workflows that steps run as sub-workflows
+	if err := createSubWorkflows(ctx, orch, filepath.Dir(workflowPath), cfg); err != nil {
+		formatter.Error(fmt.Sprintf("Failed to create sub-workflows: %v", err))
+		return err
+	}
+
 	// Create workflow definition
 	formatter.Info(fmt.Sprintf("Creating workflow: %s", cfg.Name))
-	def, err := orch.CreateWorkflow(ctx, &cfg)
+	def, err := orch.CreateWorkflow(ctx, cfg)
 	if err != nil {
 		formatter.Error(fmt.Sprintf("Failed to create workflow: %v", err))
 		return err
@@ -180,11 +268,9 @@ func runWorkflow(c *cli.Context) error {
 	// Execute workflow
 	err = orch.ExecuteWorkflow(ctx, run)
 	if err != nil {
-		// Check if it's awaiting approval
-		if run.Status == "awaiting_approval" {
-			formatter.Warning("Workflow is awaiting approval")
-			formatter.Info(fmt.Sprintf("To approve: bridge approve %s", run.ID.String()))
-			formatter.WorkflowRun(run)
+		// Check if it's awaiting approval or input
+		if run.Status == workflow.RunStatusAwaitingApproval || run.Status == workflow.RunStatusAwaitingInput {
+			printAwaiting(formatter, run)
 			return nil
 		}
 
@@ -199,6 +285,81 @@ func runWorkflow(c *cli.Context) error {
 	return nil
 }
 
+// recoverRuns resumes runs interrupted by a previous process in the
+// background. The returned function waits for the recovered runs to finish
+// and reports them.
+func recoverRuns(ctx context.Context, orch *orchestrator.Orchestrator, formatter *output.Formatter) func() {
+	type result struct {
+		runs []*workflow.WorkflowRun
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		runs, err := orch.RecoverRuns(ctx)
+		done <- result{runs, err}
+	}()
+
+	return func() {
+		recovered := <-done
+		if recovered.err != nil {
+			formatter.Warning(fmt.Sprintf("Failed to recover interrupted runs: %v", recovered.err))
+		}
+		if len(recovered.runs) > 0 {
+			formatter.Info(fmt.Sprintf("Recovered %d interrupted run(s)", len(recovered.runs)))
+		}
+	}
+}
+
+// createSubWorkflows creates the workflows that steps of cfg use, and the
+// workflows those use in turn, from the workflow files in dir.
+func createSubWorkflows(ctx context.Context, orch *orchestrator.Orchestrator, dir string, cfg *config.WorkflowConfig) error {
+	available := make(map[string]*config.WorkflowConfig)
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		paths, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			// Other YAML files may live next to workflows
+			if sub, err := config.LoadWorkflow(path); err == nil {
+				available[sub.Name] = sub
+			}
+		}
+	}
+
+	created := map[string]bool{cfg.Name: true}
+	var create func(cfg *config.WorkflowConfig) error
+	create = func(cfg *config.WorkflowConfig) error {
+		for _, step := range append(cfg.HandlerSteps(), cfg.Steps...) {
+			if !strings.HasPrefix(step.Uses, config.WorkflowScheme) {
+				continue
+			}
+			ref, err := config.ParseWorkflowRef(step.Uses)
+			if err != nil {
+				return fmt.Errorf("step %q: %w", step.Name, err)
+			}
+			if created[ref.Name] {
+				continue
+			}
+
+			sub, ok := available[ref.Name]
+			if !ok {
+				return fmt.Errorf("step %q: workflow %s not found in %s", step.Name, ref.Name, dir)
+			}
+			created[ref.Name] = true
+			if _, err := orch.CreateWorkflow(ctx, sub); err != nil {
+				return err
+			}
+			if err := create(sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return create(cfg)
+}
+
 func setupLogger(level string) *bolt.Logger {
 	var logLevel bolt.Level
 	switch level {
@@ -287,3 +448,43 @@ func parseInput(input string) (string, string) {
 	}
 	return input, ""
 }
+
+// checkInputNames rejects inputs a workflow does not declare. Workflows that
+// declare no inputs take any input.
+func checkInputNames(declared map[string]config.InputConfig, values map[string]any) error {
+	if len(declared) == 0 {
+		return nil
+	}
+
+	unknown := make([]string, 0)
+	for name := range values {
+		if _, ok := declared[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown input %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// newStepCache returns the step cache shared by CLI invocations. Workflow
+// runs are not persisted between invocations, but cached step results are.
+func newStepCache() *filecache.StepCache {
+	return filecache.NewStepCache(filepath.Join(".bridge", "cache", "steps"))
+}
+
+// newActionSet returns the built-in actions available to steps. GitHub
+// actions authenticate with GITHUB_TOKEN when it is set.
+func newActionSet(logger *bolt.Logger, tools *mcp.ToolRegistry) *workflow.ActionSet {
+	set := workflow.NewActionSet()
+
+	githubConfig := github.DefaultConfig()
+	githubConfig.Token = os.Getenv("GITHUB_TOKEN")
+	github.RegisterActions(set, github.NewClient(logger, githubConfig))
+	mcp.RegisterActions(set, tools)
+	actions.Register(set, actions.NewClient())
+
+	return set
+}
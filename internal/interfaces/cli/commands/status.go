@@ -9,9 +9,9 @@ import (
 	"github.com/felixgeelhaar/bridge/internal/application/orchestrator"
 	"github.com/felixgeelhaar/bridge/internal/domain/agents"
 	"github.com/felixgeelhaar/bridge/internal/domain/governance"
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/llm"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/messaging/eventbus"
-	"github.com/felixgeelhaar/bridge/internal/infrastructure/persistence/memory"
 	"github.com/felixgeelhaar/bridge/internal/infrastructure/policy"
 	"github.com/felixgeelhaar/bridge/internal/interfaces/cli/output"
 	"github.com/felixgeelhaar/bridge/pkg/types"
@@ -49,8 +49,16 @@ func runStatus(c *cli.Context) error {
 	handler := bolt.NewConsoleHandler(os.Stderr)
 	logger := bolt.New(handler).SetLevel(bolt.ERROR) // Quiet for status
 
+	// Open the run store shared between commands
+	workflowRepo, closeStore, err := openWorkflowRepository(ctx, logger)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to initialize: %v", err))
+		return err
+	}
+	defer closeStore()
+
 	// Create orchestrator
-	orch, err := createMinimalOrchestrator(logger)
+	orch, err := createMinimalOrchestrator(logger, workflowRepo)
 	if err != nil {
 		formatter.Error(fmt.Sprintf("Failed to initialize: %v", err))
 		return err
@@ -85,7 +93,7 @@ func runStatus(c *cli.Context) error {
 
 	run, err := orch.GetRun(ctx, types.RunID(id.String()))
 	if err != nil {
-		formatter.Error(fmt.Sprintf("Run not found: %s", runID))
+		runNotFound(formatter, runID)
 		return err
 	}
 
@@ -94,10 +102,9 @@ func runStatus(c *cli.Context) error {
 	return nil
 }
 
-func createMinimalOrchestrator(logger *bolt.Logger) (*orchestrator.Orchestrator, error) {
+func createMinimalOrchestrator(logger *bolt.Logger, workflowRepo workflow.Repository) (*orchestrator.Orchestrator, error) {
 	llmRegistry := llm.NewRegistry()
 	agentRegistry := agents.NewAgentRegistry()
-	workflowRepo := memory.NewWorkflowRepository()
 	eventPublisher := eventbus.New()
 	policyEngine := policy.NewEngine(logger)
 	auditLogger := governance.NewInMemoryAuditLogger()
@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/felixgeelhaar/bolt"
+	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/persistence/memory"
+	"github.com/felixgeelhaar/bridge/internal/infrastructure/persistence/postgres"
+	"github.com/felixgeelhaar/bridge/internal/interfaces/cli/output"
+)
+
+// databaseURLEnv names the environment variable holding the PostgreSQL
+// connection string of the shared run store.
+const databaseURLEnv = "DATABASE_URL"
+
+// openWorkflowRepository opens the store that commands share workflows and
+// runs through. With DATABASE_URL set, runs are kept in PostgreSQL, so that
+// a run started by one command can be inspected, cancelled or resumed by
+// another. Without it, runs only live in the memory of the process that
+// created them. The returned function closes the store.
+func openWorkflowRepository(ctx context.Context, logger *bolt.Logger) (workflow.Repository, func(), error) {
+	url := os.Getenv(databaseURLEnv)
+	if url == "" {
+		return memory.NewWorkflowRepository(), func() {}, nil
+	}
+
+	pool, err := postgres.NewPoolFromURL(ctx, url, logger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to run store: %w", err)
+	}
+	return postgres.NewWorkflowRepository(pool.Pool(), logger), pool.Close, nil
+}
+
+// runNotFound reports a run that is not in the store. Without a shared
+// store, runs of other processes are never found.
+func runNotFound(formatter *output.Formatter, runID string) {
+	formatter.Error(fmt.Sprintf("Run not found: %s", runID))
+	if os.Getenv(databaseURLEnv) == "" {
+		formatter.Info(fmt.Sprintf("Runs are only shared between commands when %s is set", databaseURLEnv))
+	}
+}
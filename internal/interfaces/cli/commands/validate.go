@@ -3,12 +3,13 @@ package commands
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
 	"github.com/felixgeelhaar/bridge/internal/interfaces/cli/output"
 	"github.com/felixgeelhaar/bridge/pkg/config"
+	"github.com/felixgeelhaar/bridge/pkg/expression"
 	"github.com/urfave/cli/v2"
-	"gopkg.in/yaml.v3"
 )
 
 // ValidateCommand returns the validate command.
@@ -27,6 +28,10 @@ func ValidateCommand() *cli.Command {
 				Name:  "strict",
 				Usage: "Enable strict validation",
 			},
+			&cli.BoolFlag{
+				Name:  "print-resolved",
+				Usage: "Print the workflow with its includes and step templates resolved",
+			},
 		},
 		Action: runValidate,
 	}
@@ -44,15 +49,24 @@ func runValidate(c *cli.Context) error {
 		return err
 	}
 
-	// Parse YAML
-	var cfg config.WorkflowConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		formatter.Error(fmt.Sprintf("Failed to parse workflow YAML: %v", err))
+	// Resolve includes and step templates
+	cfg, err := config.ResolveWorkflowConfig(data, workflowPath)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to resolve workflow: %v", err))
 		return err
 	}
 
+	if c.Bool("print-resolved") {
+		resolved, err := config.ResolveWorkflow(data, workflowPath)
+		if err != nil {
+			formatter.Error(fmt.Sprintf("Failed to resolve workflow: %v", err))
+			return err
+		}
+		formatter.ResolvedWorkflow(resolved)
+	}
+
 	// Validate workflow
-	errors, warnings := validateWorkflow(&cfg, strict)
+	errors, warnings := validateWorkflow(cfg, strict)
 
 	// Print results
 	valid := len(errors) == 0
@@ -63,7 +77,7 @@ func runValidate(c *cli.Context) error {
 	}
 
 	// Try to create workflow definition to validate further
-	_, err = workflow.NewWorkflowDefinition(&cfg)
+	_, err = workflow.NewWorkflowDefinition(cfg)
 	if err != nil {
 		formatter.Error(fmt.Sprintf("Failed to create workflow definition: %v", err))
 		return err
@@ -89,9 +103,18 @@ func validateWorkflow(cfg *config.WorkflowConfig, strict bool) ([]string, []stri
 		errors = append(errors, "at least one step is required")
 	}
 
+	// Validate inputs
+	for name, input := range cfg.Inputs {
+		if err := input.Validate(); err != nil {
+			errors = append(errors, fmt.Sprintf("input '%s': %v", name, err))
+		}
+	}
+
 	// Validate steps
 	stepNames := make(map[string]bool)
 	for i, step := range cfg.Steps {
+		errStart, warnStart := len(errors), len(warnings)
+
 		if step.Name == "" {
 			errors = append(errors, fmt.Sprintf("step %d: name is required", i+1))
 		} else {
@@ -101,23 +124,120 @@ func validateWorkflow(cfg *config.WorkflowConfig, strict bool) ([]string, []stri
 			stepNames[step.Name] = true
 		}
 
-		if step.Agent == "" {
+		if err := step.ValidateHumanInput(); err != nil {
+			errors = append(errors, fmt.Sprintf("step '%s': %v", step.Name, err))
+		}
+		if step.Uses != "" {
+			if err := step.ValidateUses(); err != nil {
+				errors = append(errors, fmt.Sprintf("step '%s': %v", step.Name, err))
+			}
+		} else if step.Agent == "" && !step.IsHumanInput() {
 			errors = append(errors, fmt.Sprintf("step '%s': agent is required", step.Name))
 		}
 
-		// Validate step references in input
-		if step.Input != nil {
-			for key, value := range step.Input {
-				if str, ok := value.(string); ok {
-					refs := extractStepRefs(str)
-					for _, ref := range refs {
-						if !stepNames[ref] && ref != "trigger" {
-							errors = append(errors, fmt.Sprintf("step '%s': input '%s' references unknown step '%s'", step.Name, key, ref))
-						}
+		// Validate step references in the prompt of human input steps
+		if refs, err := expression.References(step.Prompt); err == nil {
+			for _, ref := range refs {
+				if name, ok := stepReference(ref); ok && !stepNames[name] {
+					errors = append(errors, fmt.Sprintf("step '%s': prompt references unknown step '%s'", step.Name, name))
+				}
+			}
+		}
+
+		// Validate expressions and step references in input
+		for key, value := range step.Input {
+			refs, err := expression.ReferencesIn(value)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("step '%s': input '%s': %v", step.Name, key, err))
+				continue
+			}
+			for _, ref := range refs {
+				if name, ok := stepReference(ref); ok && !stepNames[name] {
+					errors = append(errors, fmt.Sprintf("step '%s': input '%s' references unknown step '%s'", step.Name, key, name))
+				}
+				if name, ok := inputReference(ref); ok && len(cfg.Inputs) > 0 {
+					if _, declared := cfg.Inputs[name]; !declared {
+						warnings = append(warnings, fmt.Sprintf("step '%s': input '%s' references undeclared input '%s'", step.Name, key, name))
 					}
 				}
 			}
 		}
+
+		// Validate step references in fan-out lists
+		for _, fanOut := range []struct {
+			field string
+			value any
+		}{{"foreach", step.Foreach}, {"matrix", step.Matrix}} {
+			if fanOut.value == nil {
+				continue
+			}
+			refs, err := expression.ReferencesIn(fanOut.value)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("step '%s': %s: %v", step.Name, fanOut.field, err))
+				continue
+			}
+			for _, ref := range refs {
+				if name, ok := stepReference(ref); ok && !stepNames[name] {
+					errors = append(errors, fmt.Sprintf("step '%s': %s references unknown step '%s'", step.Name, fanOut.field, name))
+				}
+			}
+		}
+
+		// Validate output schema
+		if err := step.ValidateOutputSchema(); err != nil {
+			errors = append(errors, fmt.Sprintf("step '%s': %v", step.Name, err))
+		}
+
+		// Validate condition expression
+		if step.Condition != "" {
+			refs, err := expression.ReferencesExpr(step.Condition)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("step '%s': condition: %v", step.Name, err))
+			}
+			for _, ref := range refs {
+				if name, ok := stepReference(ref); ok && !stepNames[name] {
+					errors = append(errors, fmt.Sprintf("step '%s': condition references unknown step '%s'", step.Name, name))
+				}
+			}
+		}
+
+		locate(errors, errStart, step)
+		locate(warnings, warnStart, step)
+	}
+
+	// Validate failure handlers, which share the step namespace
+	for _, step := range cfg.HandlerSteps() {
+		errStart := len(errors)
+
+		if step.Name == "" {
+			errors = append(errors, "failure handler: name is required")
+			locate(errors, errStart, step)
+			continue
+		}
+		if stepNames[step.Name] {
+			errors = append(errors, fmt.Sprintf("failure handler '%s': duplicate step name", step.Name))
+		}
+		stepNames[step.Name] = true
+
+		if step.Agent == "" && step.Uses == "" {
+			errors = append(errors, fmt.Sprintf("failure handler '%s': agent or uses is required", step.Name))
+		}
+
+		locate(errors, errStart, step)
+	}
+
+	// Validate workflow outputs
+	for name, value := range cfg.Outputs {
+		refs, err := expression.ReferencesIn(value)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("output '%s': %v", name, err))
+			continue
+		}
+		for _, ref := range refs {
+			if step, ok := stepReference(ref); ok && !stepNames[step] {
+				errors = append(errors, fmt.Sprintf("output '%s' references unknown step '%s'", name, step))
+			}
+		}
 	}
 
 	// Validate triggers
@@ -150,6 +270,7 @@ func validateWorkflow(cfg *config.WorkflowConfig, strict bool) ([]string, []stri
 		for _, step := range cfg.Steps {
 			if step.Timeout == "" {
 				warnings = append(warnings, fmt.Sprintf("step '%s': timeout is recommended", step.Name))
+				locate(warnings, len(warnings)-1, step)
 			}
 		}
 	}
@@ -157,34 +278,31 @@ func validateWorkflow(cfg *config.WorkflowConfig, strict bool) ([]string, []stri
 	return errors, warnings
 }
 
-// extractStepRefs extracts step references from a string like "${{ steps.name.output }}"
-func extractStepRefs(s string) []string {
-	var refs []string
-	// Simple extraction - look for ${{ steps.NAME.
-	start := 0
-	for {
-		idx := indexOf(s[start:], "${{ steps.")
-		if idx == -1 {
-			break
-		}
-		idx += start + len("${{ steps.")
-		end := idx
-		for end < len(s) && s[end] != '.' && s[end] != ' ' && s[end] != '}' {
-			end++
-		}
-		if end > idx {
-			refs = append(refs, s[idx:end])
-		}
-		start = end
+// locate prefixes the messages from index start on with the location of the
+// step they are about, when it is known.
+func locate(messages []string, start int, step config.StepConfig) {
+	if step.Source == "" {
+		return
+	}
+	for i := start; i < len(messages); i++ {
+		messages[i] = step.Source + ": " + messages[i]
 	}
-	return refs
 }
 
-func indexOf(s, substr string) int {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return i
-		}
+// stepReference returns the step name of a "steps.<name>..." reference.
+func stepReference(ref string) (string, bool) {
+	parts := strings.SplitN(ref, ".", 3)
+	if len(parts) < 2 || parts[0] != "steps" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// inputReference returns the input name of an "inputs.<name>..." reference.
+func inputReference(ref string) (string, bool) {
+	parts := strings.SplitN(ref, ".", 3)
+	if len(parts) < 2 || parts[0] != "inputs" {
+		return "", false
 	}
-	return -1
+	return parts[1], true
 }
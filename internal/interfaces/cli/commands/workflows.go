@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/felixgeelhaar/bolt"
+	"github.com/felixgeelhaar/bridge/internal/interfaces/cli/output"
+	"github.com/urfave/cli/v2"
+)
+
+// WorkflowsCommand returns the workflows command.
+func WorkflowsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "workflows",
+		Usage: "Inspect registered workflows",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "history",
+				Usage:     "Show the registered versions of a workflow",
+				ArgsUsage: "<name>",
+				Action:    runWorkflowsHistory,
+			},
+		},
+	}
+}
+
+func runWorkflowsHistory(c *cli.Context) error {
+	formatter := output.NewFormatter(c.String("output"))
+
+	name := c.Args().First()
+	if name == "" {
+		formatter.Error("Workflow name required")
+		return fmt.Errorf("workflow name required")
+	}
+
+	// Definitions are only kept across commands by the shared store
+	if os.Getenv(databaseURLEnv) == "" {
+		formatter.Error(fmt.Sprintf("Workflow history requires the run store set by %s", databaseURLEnv))
+		return fmt.Errorf("%s not set", databaseURLEnv)
+	}
+
+	// Setup minimal infrastructure
+	ctx := context.Background()
+	handler := bolt.NewConsoleHandler(os.Stderr)
+	logger := bolt.New(handler).SetLevel(bolt.ERROR)
+
+	// Open the run store shared between commands
+	workflowRepo, closeStore, err := openWorkflowRepository(ctx, logger)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to initialize: %v", err))
+		return err
+	}
+	defer closeStore()
+
+	// Create orchestrator
+	orch, err := createMinimalOrchestrator(logger, workflowRepo)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to initialize: %v", err))
+		return err
+	}
+
+	defs, err := orch.ListWorkflowVersions(ctx, name)
+	if err != nil {
+		formatter.Error(fmt.Sprintf("Failed to list versions: %v", err))
+		return err
+	}
+
+	formatter.WorkflowHistory(name, defs)
+	return nil
+}
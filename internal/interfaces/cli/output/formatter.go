@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/felixgeelhaar/bridge/internal/domain/workflow"
+	"gopkg.in/yaml.v3"
 )
 
 // Format represents the output format.
@@ -96,6 +98,7 @@ func (f *Formatter) WorkflowDefinition(def *workflow.WorkflowDefinition) {
 			"description": def.Description,
 			"steps":       len(def.Steps),
 			"triggers":    len(def.Triggers),
+			"checksum":    def.Checksum,
 		})
 		return
 	}
@@ -105,6 +108,7 @@ func (f *Formatter) WorkflowDefinition(def *workflow.WorkflowDefinition) {
 	_, _ = fmt.Fprintf(f.writer, "  Description: %s\n", def.Description)
 	_, _ = fmt.Fprintf(f.writer, "  Steps:       %d\n", len(def.Steps))
 	_, _ = fmt.Fprintf(f.writer, "  Triggers:    %d\n", len(def.Triggers))
+	_, _ = fmt.Fprintf(f.writer, "  Checksum:    %s\n", def.Checksum)
 }
 
 // WorkflowRun prints workflow run details.
@@ -128,6 +132,74 @@ func (f *Formatter) WorkflowRun(run *workflow.WorkflowRun) {
 		if run.Error != "" {
 			data["error"] = run.Error
 		}
+		if run.Deadline != nil {
+			data["deadline"] = run.Deadline.Format(time.RFC3339)
+		}
+		if run.ApprovalDeadline != nil {
+			data["approval_deadline"] = run.ApprovalDeadline.Format(time.RFC3339)
+		}
+		if run.PendingStep != "" {
+			data["pending_step"] = run.PendingStep
+		}
+		if run.RerunOf != "" {
+			data["rerun_of"] = run.RerunOf.String()
+		}
+		if run.ParentRunID != "" {
+			data["parent_run_id"] = run.ParentRunID.String()
+			data["parent_step"] = run.ParentStep
+		}
+		if run.Outputs != nil {
+			data["outputs"] = run.Outputs
+		}
+		usage := run.Usage("")
+		data["tokens_used"] = usage.Tokens
+		data["cost_usd"] = usage.CostUSD
+		if run.Budget != nil {
+			data["budget"] = run.Budget
+		}
+		if len(run.Steps) > 0 {
+			steps := make([]map[string]any, len(run.Steps))
+			for i, step := range run.Steps {
+				steps[i] = map[string]any{
+					"name":   step.Name,
+					"status": string(step.Status),
+				}
+				if step.Error != "" {
+					steps[i]["error"] = step.Error
+				}
+				if len(step.Warnings) > 0 {
+					steps[i]["warnings"] = step.Warnings
+				}
+				if step.Reused {
+					steps[i]["reused"] = true
+				}
+				if step.CacheHit {
+					steps[i]["cache_hit"] = true
+				}
+				if len(step.Attempts) > 0 {
+					steps[i]["attempts"] = step.Attempts
+				}
+				if step.Parent != "" {
+					steps[i]["parent"] = step.Parent
+				}
+				if step.ChildRunID != "" {
+					steps[i]["child_run_id"] = step.ChildRunID.String()
+				}
+				if step.Handler != "" {
+					steps[i]["handler"] = string(step.Handler)
+					if step.HandlerOf != "" {
+						steps[i]["handler_of"] = step.HandlerOf
+					}
+				}
+				if step.IsAwaitingApproval() && step.Output != nil {
+					steps[i]["output"] = step.Output
+				}
+				if step.IsAwaitingInput() {
+					steps[i]["prompt"] = step.Input["prompt"]
+				}
+			}
+			data["steps"] = steps
+		}
 		f.printJSON(data)
 		return
 	}
@@ -136,6 +208,12 @@ func (f *Formatter) WorkflowRun(run *workflow.WorkflowRun) {
 	_, _ = fmt.Fprintf(f.writer, "  Workflow:     %s\n", run.WorkflowName)
 	_, _ = fmt.Fprintf(f.writer, "  Status:       %s\n", f.statusIcon(run.Status))
 	_, _ = fmt.Fprintf(f.writer, "  Triggered by: %s\n", run.TriggeredBy)
+	if run.RerunOf != "" {
+		_, _ = fmt.Fprintf(f.writer, "  Rerun of:     %s\n", run.RerunOf)
+	}
+	if run.ParentRunID != "" {
+		_, _ = fmt.Fprintf(f.writer, "  Parent run:   %s (step %s)\n", run.ParentRunID, run.ParentStep)
+	}
 	_, _ = fmt.Fprintf(f.writer, "  Created:      %s\n", run.CreatedAt.Format(time.RFC3339))
 
 	if run.StartedAt != nil {
@@ -145,23 +223,103 @@ func (f *Formatter) WorkflowRun(run *workflow.WorkflowRun) {
 		_, _ = fmt.Fprintf(f.writer, "  Completed:    %s\n", run.CompletedAt.Format(time.RFC3339))
 		_, _ = fmt.Fprintf(f.writer, "  Duration:     %s\n", run.Duration())
 	}
+	if run.Deadline != nil && !run.Status.IsTerminal() {
+		_, _ = fmt.Fprintf(f.writer, "  Deadline:     %s\n", run.Deadline.Format(time.RFC3339))
+	}
+	if run.ApprovalDeadline != nil {
+		_, _ = fmt.Fprintf(f.writer, "  Approve by:   %s\n", run.ApprovalDeadline.Format(time.RFC3339))
+	}
+	if usage := run.Usage(""); usage.Tokens > 0 {
+		_, _ = fmt.Fprintf(f.writer, "  Usage:        %d tokens, $%.4f\n", usage.Tokens, usage.CostUSD)
+	}
+	if run.Budget != nil {
+		_, _ = fmt.Fprintf(f.writer, "  Budget:       %s\n", run.Budget)
+	}
 	if run.Error != "" {
 		_, _ = fmt.Fprintf(f.writer, "  Error:        %s\n", run.Error)
 	}
+	if run.PendingStep != "" {
+		_, _ = fmt.Fprintf(f.writer, "  Pending step: %s\n", run.PendingStep)
+	}
 
 	// Print steps
 	if len(run.Steps) > 0 {
 		_, _ = fmt.Fprintf(f.writer, "\n  Steps:\n")
 		for _, step := range run.Steps {
-			_, _ = fmt.Fprintf(f.writer, "    %s %s (%s)\n",
+			status := string(step.Status)
+			if step.Reused {
+				status += ", reused"
+			}
+			if step.CacheHit {
+				status += ", cached"
+			}
+			if step.Handler != "" {
+				status += ", " + string(step.Handler)
+				if step.HandlerOf != "" {
+					status += " of " + step.HandlerOf
+				}
+			}
+			// Fan-out items are listed under their step
+			prefix := "    "
+			if step.Parent != "" {
+				prefix += "  "
+			}
+			_, _ = fmt.Fprintf(f.writer, "%s%s %s (%s)\n",
+				prefix,
 				f.stepStatusIcon(step.Status),
 				step.Name,
-				step.Status,
+				status,
 			)
+			if step.ChildRunID != "" {
+				_, _ = fmt.Fprintf(f.writer, "%s  Child run: %s\n", prefix, step.ChildRunID)
+			}
+			if (step.Status == workflow.StepStatusSkipped || step.Status == workflow.StepStatusCancelled) && step.Error != "" {
+				_, _ = fmt.Fprintf(f.writer, "      Reason: %s\n", step.Error)
+			}
+			for _, warning := range step.Warnings {
+				_, _ = fmt.Fprintf(f.writer, "      Warning: %s\n", warning)
+			}
+			for _, attempt := range step.Attempts {
+				_, _ = fmt.Fprintf(f.writer, "      Attempt %d failed (%s, retried after %s): %s\n",
+					attempt.Attempt, attempt.Class, attempt.Backoff, attempt.Error)
+			}
+			// Show the output a reviewer is asked to approve
+			if content, ok := step.Output["content"].(string); ok && step.IsAwaitingApproval() {
+				_, _ = fmt.Fprintf(f.writer, "      Output:\n%s\n", indent(content, "        "))
+			}
+			// Show the question a user is asked to answer
+			if prompt, ok := step.Input["prompt"].(string); ok && step.IsAwaitingInput() {
+				_, _ = fmt.Fprintf(f.writer, "      Prompt:\n%s\n", indent(prompt, "        "))
+			}
+		}
+	}
+
+	if len(run.Outputs) > 0 {
+		_, _ = fmt.Fprintf(f.writer, "\n  Outputs:\n")
+		names := make([]string, 0, len(run.Outputs))
+		for name := range run.Outputs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			_, _ = fmt.Fprintf(f.writer, "    %s: %s\n", name, formatValue(run.Outputs[name]))
 		}
 	}
 }
 
+// formatValue formats an output value for text output, with strings as is
+// and other values as JSON.
+func formatValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
 // RunList prints a list of workflow runs.
 func (f *Formatter) RunList(runs []*workflow.WorkflowRun) {
 	if f.format == FormatJSON {
@@ -196,6 +354,67 @@ func (f *Formatter) RunList(runs []*workflow.WorkflowRun) {
 	_ = w.Flush()
 }
 
+// WorkflowHistory prints the registered versions of a workflow, the latest
+// first.
+func (f *Formatter) WorkflowHistory(name string, defs []*workflow.WorkflowDefinition) {
+	if f.format == FormatJSON {
+		list := make([]map[string]any, len(defs))
+		for i, def := range defs {
+			list[i] = map[string]any{
+				"id":          def.ID.String(),
+				"version":     def.Version,
+				"description": def.Description,
+				"steps":       len(def.Steps),
+				"checksum":    def.Checksum,
+				"created_at":  def.CreatedAt.Format(time.RFC3339),
+			}
+		}
+		f.printJSON(map[string]any{"name": name, "versions": list})
+		return
+	}
+
+	if len(defs) == 0 {
+		_, _ = fmt.Fprintf(f.writer, "No versions of workflow %s\n", name)
+		return
+	}
+
+	w := tabwriter.NewWriter(f.writer, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tCHECKSUM\tSTEPS\tREGISTERED")
+	for _, def := range defs {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\n",
+			def.Version,
+			shortChecksum(def.Checksum),
+			len(def.Steps),
+			def.CreatedAt.Format("2006-01-02 15:04:05"),
+		)
+	}
+	_ = w.Flush()
+}
+
+// shortChecksum abbreviates a checksum for tables.
+func shortChecksum(checksum string) string {
+	if len(checksum) > 12 {
+		return checksum[:12]
+	}
+	return checksum
+}
+
+// ResolvedWorkflow prints a workflow with its includes and step templates
+// resolved.
+func (f *Formatter) ResolvedWorkflow(data []byte) {
+	if f.format == FormatJSON {
+		var resolved map[string]any
+		if err := yaml.Unmarshal(data, &resolved); err != nil {
+			f.Error(fmt.Sprintf("Failed to parse resolved workflow: %v", err))
+			return
+		}
+		f.printJSON(map[string]any{"resolved": resolved})
+		return
+	}
+
+	_, _ = f.writer.Write(data)
+}
+
 // ValidationResult prints validation results.
 func (f *Formatter) ValidationResult(valid bool, errors []string, warnings []string) {
 	if f.format == FormatJSON {
@@ -263,6 +482,8 @@ func (f *Formatter) statusIcon(status workflow.RunStatus) string {
 		return "⊘ cancelled"
 	case workflow.RunStatusAwaitingApproval:
 		return "⏸ awaiting approval"
+	case workflow.RunStatusAwaitingInput:
+		return "⏸ awaiting input"
 	default:
 		return string(status)
 	}
@@ -280,11 +501,20 @@ func (f *Formatter) stepStatusIcon(status workflow.StepStatus) string {
 		return "✗"
 	case workflow.StepStatusSkipped:
 		return "⊘"
+	case workflow.StepStatusCancelled:
+		return "⊗"
+	case workflow.StepStatusAwaitingApproval, workflow.StepStatusAwaitingInput:
+		return "⏸"
 	default:
 		return "?"
 	}
 }
 
+// indent prefixes every line of s.
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n"+prefix)
+}
+
 func (f *Formatter) printJSON(data any) {
 	enc := json.NewEncoder(f.writer)
 	enc.SetIndent("", "  ")
@@ -207,6 +207,53 @@ func TestFormatter_WorkflowRun_AllStatuses(t *testing.T) {
 	}
 }
 
+func TestFormatter_WorkflowRun_AwaitingStepApproval(t *testing.T) {
+	for _, format := range []string{"text", "json"} {
+		f := output.NewFormatter(format)
+		started := time.Now()
+
+		run := &workflow.WorkflowRun{
+			ID:           types.NewRunID(),
+			WorkflowID:   types.NewWorkflowID(),
+			WorkflowName: "test-workflow",
+			Status:       workflow.RunStatusAwaitingApproval,
+			PendingStep:  "review",
+			TriggeredBy:  "user",
+			CreatedAt:    started,
+			StartedAt:    &started,
+			Steps: []*workflow.StepRun{
+				{
+					Name:      "review",
+					Status:    workflow.StepStatusAwaitingApproval,
+					Output:    map[string]any{"content": "Looks good.\nOne nit."},
+					Warnings:  []string{"File write operation detected - changes will be audited"},
+					StartedAt: &started,
+				},
+			},
+		}
+		f.WorkflowRun(run)
+	}
+}
+
+func TestFormatter_WorkflowRun_Outputs(t *testing.T) {
+	for _, format := range []string{"text", "json"} {
+		f := output.NewFormatter(format)
+		completed := time.Now()
+
+		run := &workflow.WorkflowRun{
+			ID:           types.NewRunID(),
+			WorkflowID:   types.NewWorkflowID(),
+			WorkflowName: "test-workflow",
+			Status:       workflow.RunStatusCompleted,
+			TriggeredBy:  "user",
+			CreatedAt:    completed,
+			CompletedAt:  &completed,
+			Outputs:      map[string]any{"verdict": "approve", "issues": []any{"nit"}},
+		}
+		f.WorkflowRun(run)
+	}
+}
+
 func TestFormatter_RunList_Text(t *testing.T) {
 	f := output.NewFormatter("text")
 
@@ -250,6 +297,33 @@ func TestFormatter_RunList_JSON(t *testing.T) {
 	f.RunList(runs)
 }
 
+func TestFormatter_WorkflowHistory_Text(t *testing.T) {
+	f := output.NewFormatter("text")
+
+	now := time.Now()
+	defs := []*workflow.WorkflowDefinition{
+		{ID: types.NewWorkflowID(), Name: "review", Version: "2.0", Checksum: "4f8c2e1a9b7d6c5e", CreatedAt: now},
+		{ID: types.NewWorkflowID(), Name: "review", Version: "1.0", Checksum: "a1b2", CreatedAt: now.Add(-time.Hour)},
+	}
+
+	f.WorkflowHistory("review", defs)
+}
+
+func TestFormatter_WorkflowHistory_Empty(t *testing.T) {
+	f := output.NewFormatter("text")
+	f.WorkflowHistory("review", nil)
+}
+
+func TestFormatter_WorkflowHistory_JSON(t *testing.T) {
+	f := output.NewFormatter("json")
+
+	defs := []*workflow.WorkflowDefinition{
+		{ID: types.NewWorkflowID(), Name: "review", Version: "1.0", Checksum: "a1b2", CreatedAt: time.Now()},
+	}
+
+	f.WorkflowHistory("review", defs)
+}
+
 func TestFormatter_ValidationResult_Valid_Text(t *testing.T) {
 	f := output.NewFormatter("text")
 	f.ValidationResult(true, nil, nil)
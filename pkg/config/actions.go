@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/felixgeelhaar/bridge/pkg/expression"
+	"github.com/felixgeelhaar/bridge/pkg/jq"
+)
+
+// Built-in actions steps can use in place of an agent.
+const (
+	ActionGitHubGetPRFiles   = "github.get_pr_files"
+	ActionGitHubCreateReview = "github.create_review"
+	ActionGitHubAddLabels    = "github.add_labels"
+	ActionHTTPRequest        = "http.request"
+	ActionTransformJQ        = "transform.jq"
+	ActionFileRead           = "file.read"
+)
+
+// actionInputs lists the inputs each built-in action requires.
+var actionInputs = map[string][]string{
+	ActionGitHubGetPRFiles:   {"repo", "pr_number"},
+	ActionGitHubCreateReview: {"repo", "pr_number"},
+	ActionGitHubAddLabels:    {"repo", "pr_number", "labels"},
+	ActionHTTPRequest:        {"url"},
+	ActionTransformJQ:        {"filter"},
+	ActionFileRead:           {"path"},
+}
+
+// IsAction reports whether uses names a built-in action.
+func IsAction(uses string) bool {
+	_, ok := actionInputs[uses]
+	return ok
+}
+
+// Actions returns the names of the built-in actions, sorted.
+func Actions() []string {
+	names := make([]string, 0, len(actionInputs))
+	for name := range actionInputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateUses validates the uses setting of a step, which names a built-in
+// action with its required inputs or references a workflow.
+func (s *StepConfig) ValidateUses() error {
+	if s.Agent != "" {
+		return fmt.Errorf("agent and uses cannot be combined")
+	}
+
+	if !IsAction(s.Uses) {
+		if !strings.HasPrefix(s.Uses, WorkflowScheme) {
+			return fmt.Errorf("uses: %q must be a built-in action (%s) or reference a workflow as %sname@version",
+				s.Uses, strings.Join(Actions(), ", "), WorkflowScheme)
+		}
+		if _, err := ParseWorkflowRef(s.Uses); err != nil {
+			return fmt.Errorf("uses: %w", err)
+		}
+		return nil
+	}
+
+	for _, name := range actionInputs[s.Uses] {
+		if _, ok := s.Input[name]; !ok {
+			return fmt.Errorf("%s: input %q is required", s.Uses, name)
+		}
+	}
+
+	// Filters without expressions are checked before the run
+	if filter, ok := s.Input["filter"].(string); ok && s.Uses == ActionTransformJQ && !expression.IsTemplate(filter) {
+		if _, err := jq.Parse(filter); err != nil {
+			return fmt.Errorf("%s: %w", s.Uses, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,464 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/felixgeelhaar/bridge/pkg/expression"
+	"gopkg.in/yaml.v3"
+)
+
+// StepTemplate is a step shared between workflows. Steps reference the file
+// of a template with extends and set its params with with. The step of the
+// template may use the params as ${{ params.<name> }}; other expressions are
+// left for the run to resolve.
+type StepTemplate struct {
+	Name        string                 `yaml:"name,omitempty"`
+	Description string                 `yaml:"description,omitempty"`
+	Params      map[string]InputConfig `yaml:"params,omitempty"`
+	Step        yaml.Node              `yaml:"step"`
+}
+
+// ResolveWorkflow resolves the includes and step templates of the workflow
+// in data and returns the flattened workflow. Paths are relative to the
+// directory of path, the file data was read from, or to the working
+// directory when path is empty. Resolved steps are annotated with the file
+// they came from.
+//
+// Included files are merged into the workflow and templates into the steps
+// extending them: mappings are merged key by key, lists are concatenated
+// with the included items first, and other values of the including workflow
+// or step take precedence.
+func ResolveWorkflow(data []byte, path string) ([]byte, error) {
+	root, _, err := resolveWorkflow(data, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, fmt.Errorf("failed to encode resolved workflow: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode resolved workflow: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ResolveWorkflowConfig resolves the includes and step templates of the
+// workflow in data like ResolveWorkflow and decodes the flattened workflow.
+// The Source of each step is the location it is defined at.
+func ResolveWorkflowConfig(data []byte, path string) (*WorkflowConfig, error) {
+	root, sources, err := resolveWorkflow(data, path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeWorkflow(root, sources)
+}
+
+// resolveWorkflow parses the workflow in data and resolves its includes and
+// step templates. It returns the locations the steps are defined at.
+func resolveWorkflow(data []byte, path string) (*yaml.Node, map[*yaml.Node]string, error) {
+	root, err := parseDocument(data, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := &resolver{sources: make(map[*yaml.Node]string)}
+	if path != "" {
+		if err := r.enter(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := r.document(root, path); err != nil {
+		return nil, nil, err
+	}
+	return root, r.sources, nil
+}
+
+// decodeWorkflow decodes a resolved workflow and sets the Source of its
+// steps from the locations the steps are defined at.
+func decodeWorkflow(root *yaml.Node, sources map[*yaml.Node]string) (*WorkflowConfig, error) {
+	var config WorkflowConfig
+	if err := root.Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow YAML: %w", err)
+	}
+
+	locateSteps(root, sources, config.Steps, config.OnFailure, config.Finally)
+	return &config, nil
+}
+
+// locateSteps sets the Source of the steps and handlers decoded from the
+// steps, on_failure and finally lists of n.
+func locateSteps(n *yaml.Node, sources map[*yaml.Node]string, steps, onFailure, finally []StepConfig) {
+	for i, list := range [][]StepConfig{steps, onFailure, finally} {
+		nodes := mappingValue(n, []string{"steps", "on_failure", "finally"}[i])
+		if nodes == nil || len(nodes.Content) != len(list) {
+			continue
+		}
+		for j, node := range nodes.Content {
+			list[j].Source = sources[node]
+			locateSteps(node, sources, nil, list[j].OnFailure, list[j].Finally)
+		}
+	}
+}
+
+// resolver resolves the includes and step templates of a workflow.
+type resolver struct {
+	files   []string              // Files being resolved, to detect cycles
+	sources map[*yaml.Node]string // Locations the steps are defined at
+}
+
+// enter records that the file at path is being resolved. It returns an
+// error when the file is already being resolved, as it includes or extends
+// itself.
+func (r *resolver) enter(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	for i, file := range r.files {
+		if file == abs {
+			cycle := make([]string, 0, len(r.files)-i+1)
+			for _, f := range r.files[i:] {
+				cycle = append(cycle, relPath(f))
+			}
+			return fmt.Errorf("include cycle: %s -> %s", strings.Join(cycle, " -> "), relPath(abs))
+		}
+	}
+	r.files = append(r.files, abs)
+	return nil
+}
+
+// leave records that the last file entered is resolved.
+func (r *resolver) leave() {
+	r.files = r.files[:len(r.files)-1]
+}
+
+// load reads and parses a file referenced by value, a node of the file at
+// from. The file is entered and must be left by the caller.
+func (r *resolver) load(value *yaml.Node, from string) (*yaml.Node, string, error) {
+	if value.Kind != yaml.ScalarNode || value.Value == "" {
+		return nil, "", fmt.Errorf("%s: expected a file path", location(from, value))
+	}
+
+	path := value.Value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(from), path)
+	}
+	if err := r.enter(path); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", location(from, value), err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		r.leave()
+		return nil, "", fmt.Errorf("%s: %w", location(from, value), err)
+	}
+	root, err := parseDocument(data, path)
+	if err != nil {
+		r.leave()
+		return nil, "", fmt.Errorf("%s: %w", location(from, value), err)
+	}
+	return root, path, nil
+}
+
+// document resolves the steps and includes of a workflow or included file.
+func (r *resolver) document(root *yaml.Node, path string) error {
+	includes := takeKey(root, "include")
+
+	if err := r.steps(root, path); err != nil {
+		return err
+	}
+	if includes == nil {
+		return nil
+	}
+
+	values := []*yaml.Node{includes}
+	if includes.Kind == yaml.SequenceNode {
+		values = includes.Content
+	}
+
+	// Merge the included files in order, then the workflow over them
+	included := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, value := range values {
+		fragment, file, err := r.load(value, path)
+		if err != nil {
+			return err
+		}
+		err = r.document(fragment, file)
+		r.leave()
+		if err != nil {
+			return err
+		}
+
+		for _, key := range []string{"steps", "on_failure", "finally"} {
+			if steps := mappingValue(fragment, key); steps != nil && steps.Kind == yaml.SequenceNode {
+				for _, step := range steps.Content {
+					annotate(step, "included from "+relPath(file))
+				}
+			}
+		}
+		mergeNodes(included, fragment, false)
+	}
+	mergeNodes(root, included, true)
+	return nil
+}
+
+// steps resolves the step templates of the steps and handlers of a
+// workflow.
+func (r *resolver) steps(root *yaml.Node, path string) error {
+	for _, key := range []string{"steps", "on_failure", "finally"} {
+		steps := mappingValue(root, key)
+		if steps == nil {
+			continue
+		}
+		if steps.Kind != yaml.SequenceNode {
+			return fmt.Errorf("%s: %s must be a list", location(path, steps), key)
+		}
+		for _, step := range steps.Content {
+			r.sources[step] = location(path, step)
+			if err := r.step(step, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// step merges the template a step extends into the step.
+func (r *resolver) step(step *yaml.Node, path string) error {
+	if step.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: step must be a mapping", location(path, step))
+	}
+
+	// Handlers of the step resolve relative to the step's file
+	if err := r.steps(step, path); err != nil {
+		return err
+	}
+
+	extends := takeKey(step, "extends")
+	with := takeKey(step, "with")
+	if extends == nil {
+		if with != nil {
+			return fmt.Errorf("%s: with requires extends", location(path, with))
+		}
+		return nil
+	}
+
+	root, file, err := r.load(extends, path)
+	if err != nil {
+		return err
+	}
+	defer r.leave()
+
+	var tmpl StepTemplate
+	if err := root.Decode(&tmpl); err != nil {
+		return fmt.Errorf("%s: %w", relPath(file), err)
+	}
+	if tmpl.Step.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: template step is required", location(file, root))
+	}
+
+	params, err := templateParams(&tmpl, step, with, path, file)
+	if err != nil {
+		return err
+	}
+	if err := renderParams(&tmpl.Step, expression.Scope{"params": params}, file); err != nil {
+		return err
+	}
+
+	// Templates may extend other templates
+	if err := r.step(&tmpl.Step, file); err != nil {
+		return err
+	}
+
+	mergeNodes(step, &tmpl.Step, true)
+	annotate(step, "extends "+relPath(file))
+	return nil
+}
+
+// templateParams returns the params of a template, with the values set by
+// the with node of a step of the file at path.
+func templateParams(tmpl *StepTemplate, step, with *yaml.Node, path, file string) (map[string]any, error) {
+	names := make([]string, 0, len(tmpl.Params))
+	for name := range tmpl.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := tmpl.Params[name].Validate(); err != nil {
+			return nil, fmt.Errorf("%s: param %q: %w", relPath(file), name, err)
+		}
+	}
+
+	values := make(map[string]any)
+	if with != nil {
+		if err := with.Decode(&values); err != nil {
+			return nil, fmt.Errorf("%s: with: %w", location(path, with), err)
+		}
+	}
+	for name := range values {
+		if _, ok := tmpl.Params[name]; !ok {
+			return nil, fmt.Errorf("%s: unknown param %q of template %s", location(path, with), name, relPath(file))
+		}
+	}
+	for _, name := range names {
+		if _, ok := values[name]; !ok && tmpl.Params[name].Required {
+			return nil, fmt.Errorf("%s: missing param %q of template %s", location(path, step), name, relPath(file))
+		}
+	}
+
+	params, err := ResolveInputs(tmpl.Params, values)
+	if err != nil {
+		if with != nil {
+			step = with
+		}
+		return nil, fmt.Errorf("%s: template %s: %w", location(path, step), relPath(file), err)
+	}
+	return params, nil
+}
+
+// renderParams resolves the params references in the values of a node of
+// the template at file.
+func renderParams(n *yaml.Node, scope expression.Scope, file string) error {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if err := renderParams(n.Content[i], scope, file); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			if err := renderParams(item, scope, file); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if n.Tag != "!!str" || !expression.IsTemplate(n.Value) {
+			return nil
+		}
+		v, err := expression.RenderPartial(n.Value, scope)
+		if err != nil {
+			return fmt.Errorf("%s: %w", location(file, n), err)
+		}
+		if s, ok := v.(string); ok {
+			n.Value = s
+			return nil
+		}
+
+		line, column := n.Line, n.Column
+		if err := n.Encode(v); err != nil {
+			return fmt.Errorf("%s: %w", location(file, n), err)
+		}
+		n.Line, n.Column = line, column
+	}
+	return nil
+}
+
+// parseDocument parses a YAML document that must be a mapping.
+func parseDocument(data []byte, path string) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		if path == "" {
+			return nil, fmt.Errorf("failed to parse workflow YAML: %w", err)
+		}
+		return nil, fmt.Errorf("%s: failed to parse YAML: %w", relPath(path), err)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: document must be a mapping", location(path, root))
+	}
+	return root, nil
+}
+
+// mergeNodes merges src into dst. Mappings are merged key by key and
+// sequences are concatenated, with the items of src first when prepend is
+// set. Other values of dst take precedence.
+func mergeNodes(dst, src *yaml.Node, prepend bool) {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, value := src.Content[i], src.Content[i+1]
+			if existing := mappingValue(dst, key.Value); existing != nil {
+				mergeNodes(existing, value, prepend)
+				continue
+			}
+			dst.Content = append(dst.Content, key, value)
+		}
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		if prepend {
+			dst.Content = append(append([]*yaml.Node{}, src.Content...), dst.Content...)
+		} else {
+			dst.Content = append(dst.Content, src.Content...)
+		}
+	}
+}
+
+// mappingValue returns the value of key in a mapping node, nil if it is
+// not set.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// takeKey removes key from a mapping node and returns its value, nil if it
+// is not set.
+func takeKey(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			value := m.Content[i+1]
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return value
+		}
+	}
+	return nil
+}
+
+// annotate notes where a resolved step came from, unless it already has a
+// comment.
+func annotate(step *yaml.Node, source string) {
+	if step.HeadComment == "" {
+		step.HeadComment = "# " + source
+	}
+}
+
+// location formats the position of a node in the file at path.
+func location(path string, n *yaml.Node) string {
+	if path == "" {
+		return fmt.Sprintf("line %d, column %d", n.Line, n.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", relPath(path), n.Line, n.Column)
+}
+
+// relPath returns path relative to the working directory when it is below
+// it, so that errors show the paths as users wrote them.
+func relPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
@@ -0,0 +1,314 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes files to a temporary directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const reviewTemplate = `
+name: review-step
+params:
+  focus:
+    required: true
+  max_files:
+    type: number
+    default: 20
+step:
+  agent: code-reviewer
+  timeout: 5m
+  input:
+    prompt: "Review the ${{ params.focus }} of PR ${{ inputs.pr }}"
+    files: ${{ steps.fetch-pr.output.files }}
+    max_files: ${{ params.max_files }}
+`
+
+func TestLoadWorkflow_Includes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"workflow.yaml": `
+name: review
+version: "1.0"
+include:
+  - common/fetch.yaml
+inputs:
+  pr:
+    type: number
+steps:
+  - name: review
+    agent: code-reviewer
+    depends_on: [fetch-pr]
+`,
+		"common/fetch.yaml": `
+version: "0.1"
+inputs:
+  pr:
+    required: true
+  repo:
+    default: owner/repo
+steps:
+  - name: fetch-pr
+    uses: github.get_pr_files
+    input:
+      repo: ${{ inputs.repo }}
+      pr_number: ${{ inputs.pr }}
+`,
+	})
+
+	cfg, err := LoadWorkflow(filepath.Join(dir, "workflow.yaml"))
+	if err != nil {
+		t.Fatalf("LoadWorkflow() error = %v", err)
+	}
+
+	if cfg.Version != "1.0" {
+		t.Errorf("Version = %v, want 1.0", cfg.Version)
+	}
+	names := make([]string, 0, len(cfg.Steps))
+	for _, step := range cfg.Steps {
+		names = append(names, step.Name)
+	}
+	if want := []string{"fetch-pr", "review"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Steps = %v, want %v", names, want)
+	}
+	want := map[string]InputConfig{
+		"pr":   {Type: InputNumber, Required: true},
+		"repo": {Default: "owner/repo"},
+	}
+	if !reflect.DeepEqual(cfg.Inputs, want) {
+		t.Errorf("Inputs = %v, want %v", cfg.Inputs, want)
+	}
+}
+
+func TestLoadWorkflow_Extends(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"workflow.yaml": `
+name: review
+version: "1.0"
+steps:
+  - name: fetch-pr
+    agent: fetcher
+  - name: security-review
+    extends: templates/review-step.yaml
+    with:
+      focus: security
+    depends_on: [fetch-pr]
+    timeout: 10m
+    input:
+      language: go
+  - name: post-review
+    extends: templates/post-review.yaml
+    depends_on: [security-review]
+`,
+		"templates/review-step.yaml": reviewTemplate,
+		"templates/post-review.yaml": `
+params:
+  event:
+    default: COMMENT
+step:
+  extends: review-step.yaml
+  with:
+    focus: ${{ params.event }} summary
+    max_files: 5
+`,
+	})
+
+	cfg, err := LoadWorkflow(filepath.Join(dir, "workflow.yaml"))
+	if err != nil {
+		t.Fatalf("LoadWorkflow() error = %v", err)
+	}
+
+	review := cfg.Steps[1]
+	if review.Agent != "code-reviewer" {
+		t.Errorf("Agent = %v, want code-reviewer", review.Agent)
+	}
+	if review.Timeout != "10m" {
+		t.Errorf("Timeout = %v, want 10m", review.Timeout)
+	}
+	wantInput := map[string]any{
+		"prompt":    "Review the security of PR ${{ inputs.pr }}",
+		"files":     "${{ steps.fetch-pr.output.files }}",
+		"max_files": 20,
+		"language":  "go",
+	}
+	if !reflect.DeepEqual(review.Input, wantInput) {
+		t.Errorf("Input = %v, want %v", review.Input, wantInput)
+	}
+
+	post := cfg.Steps[2]
+	if post.Input["prompt"] != "Review the COMMENT summary of PR ${{ inputs.pr }}" {
+		t.Errorf("Input prompt = %v", post.Input["prompt"])
+	}
+	if post.Input["max_files"] != 5 {
+		t.Errorf("Input max_files = %v, want 5", post.Input["max_files"])
+	}
+	if !reflect.DeepEqual(post.DependsOn, []string{"security-review"}) {
+		t.Errorf("DependsOn = %v, want [security-review]", post.DependsOn)
+	}
+}
+
+func TestLoadWorkflow_ResolveErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"workflow.yaml": "name: test\nversion: \"1.0\"\ninclude: [a.yaml]\nsteps:\n  - name: s\n    agent: a\n",
+				"a.yaml":        "include: [b.yaml]\n",
+				"b.yaml":        "include: [a.yaml]\n",
+			},
+			want: "b.yaml:1:11: include cycle: ",
+		},
+		{
+			name: "template cycle",
+			files: map[string]string{
+				"workflow.yaml": "name: test\nversion: \"1.0\"\nsteps:\n  - name: s\n    extends: t.yaml\n",
+				"t.yaml":        "step:\n  extends: t.yaml\n",
+			},
+			want: "t.yaml -> ",
+		},
+		{
+			name: "missing include",
+			files: map[string]string{
+				"workflow.yaml": "name: test\nversion: \"1.0\"\ninclude: missing.yaml\nsteps:\n  - name: s\n    agent: a\n",
+			},
+			want: "workflow.yaml:3:10: ",
+		},
+		{
+			name: "missing param",
+			files: map[string]string{
+				"workflow.yaml":    "name: test\nversion: \"1.0\"\nsteps:\n  - name: s\n    extends: review-step.yaml\n",
+				"review-step.yaml": reviewTemplate,
+			},
+			want: `workflow.yaml:4:5: missing param "focus"`,
+		},
+		{
+			name: "unknown param",
+			files: map[string]string{
+				"workflow.yaml":    "name: test\nversion: \"1.0\"\nsteps:\n  - name: s\n    extends: review-step.yaml\n    with:\n      focus: style\n      depth: 2\n",
+				"review-step.yaml": reviewTemplate,
+			},
+			want: `workflow.yaml:7:7: unknown param "depth"`,
+		},
+		{
+			name: "params combined with run data",
+			files: map[string]string{
+				"workflow.yaml": "name: test\nversion: \"1.0\"\nsteps:\n  - name: s\n    extends: t.yaml\n",
+				"t.yaml":        "step:\n  agent: a\n  condition: ${{ params.enabled && inputs.enabled }}\n",
+			},
+			want: "t.yaml:3:14: ",
+		},
+		{
+			name: "with without extends",
+			files: map[string]string{
+				"workflow.yaml": "name: test\nversion: \"1.0\"\nsteps:\n  - name: s\n    agent: a\n    with:\n      focus: style\n",
+			},
+			want: "workflow.yaml:7:7: with requires extends",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			_, err := LoadWorkflow(filepath.Join(dir, "workflow.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadWorkflow() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveWorkflow(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"review-step.yaml": reviewTemplate,
+	})
+	data := []byte("name: test\nversion: \"1.0\"\nsteps:\n  - name: review\n    extends: review-step.yaml\n    with:\n      focus: style\n")
+
+	resolved, err := ResolveWorkflow(data, filepath.Join(dir, "workflow.yaml"))
+	if err != nil {
+		t.Fatalf("ResolveWorkflow() error = %v", err)
+	}
+
+	for _, want := range []string{"# extends ", "agent: code-reviewer", "Review the style of PR ${{ inputs.pr }}"} {
+		if !strings.Contains(string(resolved), want) {
+			t.Errorf("ResolveWorkflow() = %s, want %q", resolved, want)
+		}
+	}
+	if strings.Contains(string(resolved), "extends:") || strings.Contains(string(resolved), "with:") {
+		t.Errorf("ResolveWorkflow() = %s, want templates resolved", resolved)
+	}
+
+	// The resolved workflow parses to the same steps
+	cfg, err := ParseWorkflow(resolved)
+	if err != nil {
+		t.Fatalf("ParseWorkflow() error = %v", err)
+	}
+	if cfg.Steps[0].Agent != "code-reviewer" {
+		t.Errorf("Agent = %v, want code-reviewer", cfg.Steps[0].Agent)
+	}
+}
+
+func TestResolveWorkflowConfig_Sources(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"workflow.yaml": `
+name: review
+version: "1.0"
+include:
+  - fetch.yaml
+steps:
+  - name: review
+    extends: review-step.yaml
+    with:
+      focus: style
+finally:
+  - name: notify
+    agent: notifier
+`,
+		"fetch.yaml": `
+steps:
+  - name: fetch-pr
+    uses: github.get_pr_files
+`,
+		"review-step.yaml": reviewTemplate,
+	})
+	path := filepath.Join(dir, "workflow.yaml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := ResolveWorkflowConfig(data, path)
+	if err != nil {
+		t.Fatalf("ResolveWorkflowConfig() error = %v", err)
+	}
+
+	want := map[string]string{
+		"fetch-pr": "fetch.yaml:3:5",
+		"review":   "workflow.yaml:7:5",
+		"notify":   "workflow.yaml:12:5",
+	}
+	for _, step := range append(cfg.Steps, cfg.Finally...) {
+		if !strings.HasSuffix(step.Source, string(filepath.Separator)+want[step.Name]) {
+			t.Errorf("step %s Source = %q, want %q", step.Name, step.Source, want[step.Name])
+		}
+	}
+}
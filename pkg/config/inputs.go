@@ -0,0 +1,230 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Input types of workflow inputs.
+const (
+	InputString = "string"
+	InputNumber = "number"
+	InputBool   = "bool"
+	InputList   = "list"
+	InputObject = "object"
+)
+
+// InputConfig declares an input a workflow accepts.
+type InputConfig struct {
+	Type        string `yaml:"type,omitempty"` // string (default), number, bool, list or object
+	Description string `yaml:"description,omitempty"`
+	Required    bool   `yaml:"required,omitempty"`
+	Default     any    `yaml:"default,omitempty"`
+	Enum        []any  `yaml:"enum,omitempty"` // Allowed values
+}
+
+// Validate validates the input declaration.
+func (i InputConfig) Validate() error {
+	switch i.Type {
+	case "", InputString, InputNumber, InputBool, InputList, InputObject:
+	default:
+		return fmt.Errorf("type must be one of string, number, bool, list or object")
+	}
+
+	for _, value := range i.Enum {
+		if _, err := i.coerce(value); err != nil {
+			return fmt.Errorf("enum: %w", err)
+		}
+	}
+
+	if i.Default != nil {
+		if i.Required {
+			return fmt.Errorf("required inputs cannot have a default")
+		}
+		if _, err := i.Coerce(i.Default); err != nil {
+			return fmt.Errorf("default: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Coerce converts a value to the input type and checks it against the
+// allowed values. Strings, as passed on the command line, are parsed:
+// numbers and booleans as literals, lists as JSON arrays or comma-separated
+// values, and objects as JSON.
+func (i InputConfig) Coerce(value any) (any, error) {
+	v, err := i.coerce(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(i.Enum) > 0 {
+		for _, allowed := range i.Enum {
+			if a, err := i.coerce(allowed); err == nil && equalValues(a, v) {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("must be one of %v", i.Enum)
+	}
+
+	return v, nil
+}
+
+func (i InputConfig) coerce(value any) (any, error) {
+	switch i.Type {
+	case InputNumber:
+		if s, ok := value.(string); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return nil, fmt.Errorf("expected number, got %q", s)
+			}
+			return f, nil
+		}
+		if f, ok := toFloat(value); ok {
+			return f, nil
+		}
+		return nil, fmt.Errorf("expected number, got %T", value)
+
+	case InputBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("expected bool, got %q", v)
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected bool, got %T", value)
+
+	case InputList:
+		switch v := value.(type) {
+		case []any:
+			return v, nil
+		case []string:
+			list := make([]any, len(v))
+			for i, s := range v {
+				list[i] = s
+			}
+			return list, nil
+		case string:
+			if strings.HasPrefix(strings.TrimSpace(v), "[") {
+				var list []any
+				if err := json.Unmarshal([]byte(v), &list); err != nil {
+					return nil, fmt.Errorf("expected list: %w", err)
+				}
+				return list, nil
+			}
+			list := make([]any, 0)
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			return list, nil
+		}
+		return nil, fmt.Errorf("expected list, got %T", value)
+
+	case InputObject:
+		switch v := value.(type) {
+		case map[string]any:
+			return v, nil
+		case string:
+			var obj map[string]any
+			if err := json.Unmarshal([]byte(v), &obj); err != nil {
+				return nil, fmt.Errorf("expected object: %w", err)
+			}
+			return obj, nil
+		}
+		return nil, fmt.Errorf("expected object, got %T", value)
+
+	default:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+		if f, ok := toFloat(value); ok {
+			return strconv.FormatFloat(f, 'f', -1, 64), nil
+		}
+		return nil, fmt.Errorf("expected string, got %T", value)
+	}
+}
+
+// ResolveInputs validates values against the declared inputs. It returns the
+// declared inputs coerced to their types, with defaults filled in. Values
+// that are not declared are left out, as trigger payloads carry more data
+// than a workflow declares.
+func ResolveInputs(inputs map[string]InputConfig, values map[string]any) (map[string]any, error) {
+	resolved := make(map[string]any, len(inputs))
+
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		input := inputs[name]
+		value, ok := values[name]
+		if !ok || value == nil {
+			switch {
+			case input.Default != nil:
+				value = input.Default
+			case input.Required:
+				errs = append(errs, fmt.Errorf("input %q is required", name))
+				continue
+			default:
+				continue
+			}
+		}
+
+		v, err := input.Coerce(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("input %q: %w", name, err))
+			continue
+		}
+		resolved[name] = v
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return resolved, nil
+}
+
+// toFloat converts the numeric types produced by JSON and YAML decoding.
+func toFloat(value any) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func equalValues(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
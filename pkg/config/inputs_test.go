@@ -0,0 +1,115 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInputConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   InputConfig
+		wantErr bool
+	}{
+		{"string by default", InputConfig{}, false},
+		{"typed with default", InputConfig{Type: InputNumber, Default: 3}, false},
+		{"enum", InputConfig{Enum: []any{"low", "high"}, Default: "low"}, false},
+		{"unknown type", InputConfig{Type: "text"}, true},
+		{"default of wrong type", InputConfig{Type: InputBool, Default: "maybe"}, true},
+		{"default not in enum", InputConfig{Enum: []any{"low", "high"}, Default: "medium"}, true},
+		{"enum of wrong type", InputConfig{Type: InputNumber, Enum: []any{"one"}}, true},
+		{"required with default", InputConfig{Required: true, Default: "x"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.input.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveInputs(t *testing.T) {
+	inputs := map[string]InputConfig{
+		"pr":       {Type: InputNumber, Required: true},
+		"strict":   {Type: InputBool, Default: false},
+		"labels":   {Type: InputList},
+		"options":  {Type: InputObject},
+		"severity": {Enum: []any{"low", "high"}, Default: "low"},
+	}
+
+	tests := []struct {
+		name    string
+		values  map[string]any
+		want    map[string]any
+		wantErr []string
+	}{
+		{
+			name:   "coerces strings",
+			values: map[string]any{"pr": "42", "strict": "true", "labels": "bug, ui", "options": `{"depth":2}`},
+			want: map[string]any{
+				"pr":       float64(42),
+				"strict":   true,
+				"labels":   []any{"bug", "ui"},
+				"options":  map[string]any{"depth": float64(2)},
+				"severity": "low",
+			},
+		},
+		{
+			name:   "typed values and undeclared keys",
+			values: map[string]any{"pr": 7, "labels": []any{"bug"}, "severity": "high", "repo": "owner/repo"},
+			want: map[string]any{
+				"pr":       float64(7),
+				"strict":   false,
+				"labels":   []any{"bug"},
+				"severity": "high",
+			},
+		},
+		{
+			name:   "list as JSON",
+			values: map[string]any{"pr": 1, "labels": `["a,b", "c"]`},
+			want: map[string]any{
+				"pr":       float64(1),
+				"strict":   false,
+				"labels":   []any{"a,b", "c"},
+				"severity": "low",
+			},
+		},
+		{
+			name:    "missing required",
+			values:  map[string]any{},
+			wantErr: []string{`input "pr" is required`},
+		},
+		{
+			name:    "invalid values",
+			values:  map[string]any{"pr": "forty-two", "strict": "maybe", "severity": "medium"},
+			wantErr: []string{`input "pr": expected number`, `input "severity": must be one of`, `input "strict": expected bool`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveInputs(inputs, tt.values)
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("ResolveInputs() = %v, want error", got)
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("ResolveInputs() error = %v, want %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveInputs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolveInputs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Error classes a step retry can be limited to.
+const (
+	RetryOnTransient     = "transient"      // Timeouts, rate limits, unavailable agents and retryable provider errors
+	RetryOnTimeout       = "timeout"        // The step or an agent call timed out
+	RetryOnRateLimited   = "rate_limited"   // The LLM provider rate limited the call
+	RetryOnServerError   = "server_error"   // The LLM provider failed with a 5xx status
+	RetryOnOutputInvalid = "output_invalid" // The output did not match the step's output schema
+	RetryOnAny           = "any"            // Every failure except cancellation, policy violations and rejected requests
+)
+
+// RetryConfig configures how a failed step is retried.
+type RetryConfig struct {
+	MaxAttempts int      `yaml:"max_attempts"`          // Attempts including the first
+	Backoff     string   `yaml:"backoff,omitempty"`     // Delay before the first retry, default 1s
+	MaxBackoff  string   `yaml:"max_backoff,omitempty"` // Upper bound of the delay, default 1m
+	Multiplier  float64  `yaml:"multiplier,omitempty"`  // Growth of the delay per retry, default 2
+	Jitter      float64  `yaml:"jitter,omitempty"`      // Fraction of the delay randomized, 0 to 1
+	RetryOn     []string `yaml:"retry_on,omitempty"`    // Error classes retried, default transient
+}
+
+// Validate validates the retry configuration.
+func (r RetryConfig) Validate() error {
+	if r.MaxAttempts < 1 {
+		return fmt.Errorf("max_attempts must be at least 1")
+	}
+
+	var backoff, maxBackoff time.Duration
+	for _, d := range []struct {
+		field string
+		value string
+		dst   *time.Duration
+	}{
+		{"backoff", r.Backoff, &backoff},
+		{"max_backoff", r.MaxBackoff, &maxBackoff},
+	} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.field, err)
+		}
+		if v < 0 {
+			return fmt.Errorf("%s must not be negative", d.field)
+		}
+		*d.dst = v
+	}
+	if r.Backoff != "" && r.MaxBackoff != "" && maxBackoff < backoff {
+		return fmt.Errorf("max_backoff must not be less than backoff")
+	}
+
+	if r.Multiplier != 0 && r.Multiplier < 1 {
+		return fmt.Errorf("multiplier must be at least 1")
+	}
+	if r.Jitter < 0 || r.Jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+
+	for _, class := range r.RetryOn {
+		switch class {
+		case RetryOnTransient, RetryOnTimeout, RetryOnRateLimited, RetryOnServerError, RetryOnOutputInvalid, RetryOnAny:
+		default:
+			return fmt.Errorf("retry_on: unknown error class %q", class)
+		}
+	}
+
+	return nil
+}
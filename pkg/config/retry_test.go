@@ -0,0 +1,31 @@
+package config
+
+import "testing"
+
+func TestRetryConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   RetryConfig
+		wantErr bool
+	}{
+		{"attempts only", RetryConfig{MaxAttempts: 3}, false},
+		{"full", RetryConfig{MaxAttempts: 5, Backoff: "500ms", MaxBackoff: "30s", Multiplier: 2, Jitter: 0.2, RetryOn: []string{RetryOnRateLimited, RetryOnServerError}}, false},
+		{"immediate", RetryConfig{MaxAttempts: 2, Backoff: "0s"}, false},
+		{"no attempts", RetryConfig{}, true},
+		{"invalid backoff", RetryConfig{MaxAttempts: 2, Backoff: "soon"}, true},
+		{"negative backoff", RetryConfig{MaxAttempts: 2, Backoff: "-1s"}, true},
+		{"max below backoff", RetryConfig{MaxAttempts: 2, Backoff: "10s", MaxBackoff: "1s"}, true},
+		{"shrinking multiplier", RetryConfig{MaxAttempts: 2, Multiplier: 0.5}, true},
+		{"jitter above 1", RetryConfig{MaxAttempts: 2, Jitter: 1.5}, true},
+		{"unknown class", RetryConfig{MaxAttempts: 2, RetryOn: []string{"bad_request"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.retry.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
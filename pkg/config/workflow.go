@@ -3,19 +3,32 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/felixgeelhaar/bridge/pkg/expression"
+	"github.com/felixgeelhaar/bridge/pkg/jsonschema"
 	"gopkg.in/yaml.v3"
 )
 
 // WorkflowConfig represents a workflow definition loaded from YAML.
 type WorkflowConfig struct {
-	Name        string            `yaml:"name"`
-	Version     string            `yaml:"version"`
-	Description string            `yaml:"description,omitempty"`
-	Triggers    []TriggerConfig   `yaml:"triggers,omitempty"`
-	Steps       []StepConfig      `yaml:"steps"`
-	Policies    []PolicyRefConfig `yaml:"policies,omitempty"`
-	Metadata    map[string]any    `yaml:"metadata,omitempty"`
+	Name            string                 `yaml:"name"`
+	Version         string                 `yaml:"version"`
+	Description     string                 `yaml:"description,omitempty"`
+	Inputs          map[string]InputConfig `yaml:"inputs,omitempty"`
+	Triggers        []TriggerConfig        `yaml:"triggers,omitempty"`
+	Steps           []StepConfig           `yaml:"steps"`
+	OnFailure       []StepConfig           `yaml:"on_failure,omitempty"` // Steps run when the run fails
+	Finally         []StepConfig           `yaml:"finally,omitempty"`    // Steps run when the run finishes
+	Outputs         map[string]any         `yaml:"outputs,omitempty"`    // Run results, evaluated on completion
+	MaxParallel     int                    `yaml:"max_parallel,omitempty"`
+	Timeout         string                 `yaml:"timeout,omitempty"`          // Maximum run duration, including approval waits
+	ApprovalTimeout string                 `yaml:"approval_timeout,omitempty"` // Maximum wait for each approval
+	Concurrency     *ConcurrencyConfig     `yaml:"concurrency,omitempty"`      // Group runs execute one at a time in
+	Budget          *BudgetConfig          `yaml:"budget,omitempty"`           // Usage limit of all runs together
+	Policies        []PolicyRefConfig      `yaml:"policies,omitempty"`
+	Metadata        map[string]any         `yaml:"metadata,omitempty"`
 }
 
 // TriggerConfig defines when a workflow should be triggered.
@@ -26,17 +39,102 @@ type TriggerConfig struct {
 	Filter string   `yaml:"filter,omitempty"`
 }
 
+// ConcurrencyConfig limits the runs of a workflow to one executing run per
+// group. Later runs in the group are queued until earlier runs finish, or
+// cancel them when CancelInProgress is set.
+type ConcurrencyConfig struct {
+	Group            string `yaml:"group"` // Group name, may use ${{ trigger.* }}, ${{ inputs.* }} and ${{ metadata.* }}
+	CancelInProgress bool   `yaml:"cancel_in_progress,omitempty"`
+}
+
+// Validate validates the concurrency settings. The group is resolved when a
+// run is created, so it cannot reference step outputs.
+func (c *ConcurrencyConfig) Validate() error {
+	if strings.TrimSpace(c.Group) == "" {
+		return fmt.Errorf("concurrency: group is required")
+	}
+	refs, err := expression.References(c.Group)
+	if err != nil {
+		return fmt.Errorf("concurrency: group: %w", err)
+	}
+	for _, ref := range refs {
+		if ref == "steps" || strings.HasPrefix(ref, "steps.") {
+			return fmt.Errorf("concurrency: group cannot reference step outputs: %s", ref)
+		}
+	}
+	return nil
+}
+
+// StepTypeHumanInput is the type of steps that pause the run until a user
+// answers their prompt.
+const StepTypeHumanInput = "human_input"
+
 // StepConfig defines a single step in a workflow.
 type StepConfig struct {
-	Name             string         `yaml:"name"`
-	Agent            string         `yaml:"agent"`
-	Input            map[string]any `yaml:"input,omitempty"`
-	Output           string         `yaml:"output,omitempty"`
-	RequiresApproval bool           `yaml:"requires_approval,omitempty"`
-	Timeout          string         `yaml:"timeout,omitempty"`
-	Retries          int            `yaml:"retries,omitempty"`
-	Condition        string         `yaml:"condition,omitempty"`
-	DependsOn        []string       `yaml:"depends_on,omitempty"`
+	Name             string                 `yaml:"name"`
+	Type             string                 `yaml:"type,omitempty"` // human_input, empty for agent, action and sub-workflow steps
+	Agent            string                 `yaml:"agent"`
+	Uses             string                 `yaml:"uses,omitempty"` // Built-in action or workflow run as the step, such as workflow://name@version
+	Input            map[string]any         `yaml:"input,omitempty"`
+	Output           string                 `yaml:"output,omitempty"`
+	Prompt           string                 `yaml:"prompt,omitempty"` // Question a human_input step asks
+	Form             map[string]InputConfig `yaml:"form,omitempty"`   // Fields of the answer to a human_input step, free-form without
+	RequiresApproval bool                   `yaml:"requires_approval,omitempty"`
+	ApprovalTiming   string                 `yaml:"approval_timing,omitempty"` // before (default) or after
+	Timeout          string                 `yaml:"timeout,omitempty"`
+	Retries          int                    `yaml:"retries,omitempty"` // Shorthand for retry.max_attempts of retries+1
+	Retry            *RetryConfig           `yaml:"retry,omitempty"`
+	Condition        string                 `yaml:"condition,omitempty"`
+	DependsOn        []string               `yaml:"depends_on,omitempty"`
+	OutputSchema     map[string]any         `yaml:"output_schema,omitempty"`
+	Foreach          any                    `yaml:"foreach,omitempty"`      // List or expression, one run per item
+	Matrix           map[string]any         `yaml:"matrix,omitempty"`       // Named lists, one run per combination
+	MaxParallel      int                    `yaml:"max_parallel,omitempty"` // Concurrent item runs, 0 for unlimited
+	Cache            *CacheConfig           `yaml:"cache,omitempty"`        // Reuse results of identical earlier calls
+	Budget           *BudgetConfig          `yaml:"budget,omitempty"`       // Usage limit of the step in a run
+	OnFailure        []StepConfig           `yaml:"on_failure,omitempty"`   // Steps run when this step fails
+	Finally          []StepConfig           `yaml:"finally,omitempty"`      // Steps run when this step finishes
+
+	Source string `yaml:"-"` // Where the step is defined, such as workflow.yaml:12:5, set by ResolveWorkflowConfig
+}
+
+// CacheConfig configures caching of a step's results. Results are cached
+// under a hash of the agent configuration, prompt and tools of the step.
+type CacheConfig struct {
+	TTL string `yaml:"ttl"` // How long a result is reused, such as 24h
+}
+
+// Actions taken when a budget is used up.
+const (
+	BudgetOnExceededFail    = "fail"    // Fail the step
+	BudgetOnExceededApprove = "approve" // Pause the step until it is approved
+)
+
+// BudgetConfig limits the tokens and cost agent calls may use. Budgets are
+// checked before each agent call against the usage accumulated so far.
+type BudgetConfig struct {
+	MaxTokens  int     `yaml:"max_tokens,omitempty"`   // Input and output tokens
+	MaxCostUSD float64 `yaml:"max_cost_usd,omitempty"` // Cost in US dollars, from the model's price
+	OnExceeded string  `yaml:"on_exceeded,omitempty"`  // fail (default) or approve
+}
+
+// Validate validates the budget configuration.
+func (b *BudgetConfig) Validate() error {
+	if b.MaxTokens < 0 {
+		return fmt.Errorf("budget: max_tokens must not be negative")
+	}
+	if b.MaxCostUSD < 0 {
+		return fmt.Errorf("budget: max_cost_usd must not be negative")
+	}
+	if b.MaxTokens == 0 && b.MaxCostUSD == 0 {
+		return fmt.Errorf("budget: max_tokens or max_cost_usd is required")
+	}
+	switch b.OnExceeded {
+	case "", BudgetOnExceededFail, BudgetOnExceededApprove:
+	default:
+		return fmt.Errorf("budget: on_exceeded must be %q or %q", BudgetOnExceededFail, BudgetOnExceededApprove)
+	}
+	return nil
 }
 
 // PolicyRefConfig references a policy to apply to the workflow.
@@ -46,28 +144,35 @@ type PolicyRefConfig struct {
 	Params map[string]any `yaml:"params,omitempty"`
 }
 
-// LoadWorkflow loads a workflow configuration from a YAML file.
+// LoadWorkflow loads a workflow configuration from a YAML file. Includes
+// and step templates are resolved relative to the file.
 func LoadWorkflow(path string) (*WorkflowConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read workflow file: %w", err)
 	}
 
-	return ParseWorkflow(data)
+	return parseWorkflow(data, path)
 }
 
-// ParseWorkflow parses workflow configuration from YAML bytes.
+// ParseWorkflow parses workflow configuration from YAML bytes. Includes and
+// step templates are resolved relative to the working directory.
 func ParseWorkflow(data []byte) (*WorkflowConfig, error) {
-	var config WorkflowConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse workflow YAML: %w", err)
+	return parseWorkflow(data, "")
+}
+
+// parseWorkflow parses the workflow configuration read from path.
+func parseWorkflow(data []byte, path string) (*WorkflowConfig, error) {
+	config, err := ResolveWorkflowConfig(data, path)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
-	return &config, nil
+	return config, nil
 }
 
 // Validate validates the workflow configuration.
@@ -84,6 +189,33 @@ func (c *WorkflowConfig) Validate() error {
 		return fmt.Errorf("workflow must have at least one step")
 	}
 
+	if c.MaxParallel < 0 {
+		return fmt.Errorf("max_parallel must not be negative")
+	}
+
+	if err := validateTimeout("timeout", c.Timeout); err != nil {
+		return err
+	}
+	if err := validateTimeout("approval_timeout", c.ApprovalTimeout); err != nil {
+		return err
+	}
+	if c.Concurrency != nil {
+		if err := c.Concurrency.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Budget != nil {
+		if err := c.Budget.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for name, input := range c.Inputs {
+		if err := input.Validate(); err != nil {
+			return fmt.Errorf("input %q: %w", name, err)
+		}
+	}
+
 	stepNames := make(map[string]bool)
 	for i, step := range c.Steps {
 		if step.Name == "" {
@@ -95,7 +227,14 @@ func (c *WorkflowConfig) Validate() error {
 		}
 		stepNames[step.Name] = true
 
-		if step.Agent == "" {
+		if err := step.ValidateHumanInput(); err != nil {
+			return fmt.Errorf("step %q: %w", step.Name, err)
+		}
+		if step.Uses != "" {
+			if err := step.ValidateUses(); err != nil {
+				return fmt.Errorf("step %q: %w", step.Name, err)
+			}
+		} else if step.Agent == "" && !step.IsHumanInput() {
 			return fmt.Errorf("step %q: agent is required", step.Name)
 		}
 
@@ -105,11 +244,361 @@ func (c *WorkflowConfig) Validate() error {
 				return fmt.Errorf("step %q: depends_on references unknown step %q", step.Name, dep)
 			}
 		}
+
+		switch step.ApprovalTiming {
+		case "", "before", "after":
+		default:
+			return fmt.Errorf("step %q: approval_timing must be \"before\" or \"after\"", step.Name)
+		}
+
+		if err := step.ValidateOutputSchema(); err != nil {
+			return fmt.Errorf("step %q: %w", step.Name, err)
+		}
+
+		if err := step.validateFanOut(); err != nil {
+			return fmt.Errorf("step %q: %w", step.Name, err)
+		}
+
+		if err := step.validateRetry(); err != nil {
+			return fmt.Errorf("step %q: %w", step.Name, err)
+		}
+
+		if err := step.validateCache(); err != nil {
+			return fmt.Errorf("step %q: %w", step.Name, err)
+		}
+
+		if err := step.validateBudget(); err != nil {
+			return fmt.Errorf("step %q: %w", step.Name, err)
+		}
+	}
+
+	for name, value := range c.Outputs {
+		if _, err := expression.ReferencesIn(value); err != nil {
+			return fmt.Errorf("output %q: %w", name, err)
+		}
+	}
+
+	// Handler names share the namespace of steps
+	if err := validateHandlers("on_failure", c.OnFailure, stepNames); err != nil {
+		return err
+	}
+	if err := validateHandlers("finally", c.Finally, stepNames); err != nil {
+		return err
+	}
+	for _, step := range c.Steps {
+		if err := validateHandlers(fmt.Sprintf("step %q: on_failure", step.Name), step.OnFailure, stepNames); err != nil {
+			return err
+		}
+		if err := validateHandlers(fmt.Sprintf("step %q: finally", step.Name), step.Finally, stepNames); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// HandlerSteps returns the failure handler steps of the workflow, followed
+// by those of each step.
+func (c *WorkflowConfig) HandlerSteps() []StepConfig {
+	handlers := make([]StepConfig, 0, len(c.OnFailure)+len(c.Finally))
+	handlers = append(handlers, c.OnFailure...)
+	handlers = append(handlers, c.Finally...)
+	for _, step := range c.Steps {
+		handlers = append(handlers, step.OnFailure...)
+		handlers = append(handlers, step.Finally...)
+	}
+	return handlers
+}
+
+// validateHandlers validates failure handler steps. Handlers run outside the
+// step graph, one after another, so they cannot depend on other steps, fan
+// out, wait for approval or have handlers of their own.
+func validateHandlers(block string, handlers []StepConfig, names map[string]bool) error {
+	for i, step := range handlers {
+		if step.Name == "" {
+			return fmt.Errorf("%s: step %d: name is required", block, i)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("%s: duplicate step name %q", block, step.Name)
+		}
+		names[step.Name] = true
+
+		if err := step.ValidateHumanInput(); err != nil {
+			return fmt.Errorf("%s: step %q: %w", block, step.Name, err)
+		}
+		if step.IsHumanInput() {
+			return fmt.Errorf("%s: step %q: handlers cannot wait for input", block, step.Name)
+		}
+		if step.Uses != "" {
+			if err := step.ValidateUses(); err != nil {
+				return fmt.Errorf("%s: step %q: %w", block, step.Name, err)
+			}
+		} else if step.Agent == "" {
+			return fmt.Errorf("%s: step %q: agent is required", block, step.Name)
+		}
+
+		switch {
+		case len(step.DependsOn) > 0:
+			return fmt.Errorf("%s: step %q: handlers cannot have depends_on", block, step.Name)
+		case step.Condition != "":
+			return fmt.Errorf("%s: step %q: handlers cannot have a condition", block, step.Name)
+		case step.RequiresApproval:
+			return fmt.Errorf("%s: step %q: handlers cannot require approval", block, step.Name)
+		case step.Foreach != nil || step.Matrix != nil:
+			return fmt.Errorf("%s: step %q: handlers cannot fan out", block, step.Name)
+		case len(step.OnFailure) > 0 || len(step.Finally) > 0:
+			return fmt.Errorf("%s: step %q: handlers cannot have handlers", block, step.Name)
+		}
+
+		if err := step.ValidateOutputSchema(); err != nil {
+			return fmt.Errorf("%s: step %q: %w", block, step.Name, err)
+		}
+
+		if err := step.validateRetry(); err != nil {
+			return fmt.Errorf("%s: step %q: %w", block, step.Name, err)
+		}
+
+		if err := step.validateCache(); err != nil {
+			return fmt.Errorf("%s: step %q: %w", block, step.Name, err)
+		}
+
+		if err := step.validateBudget(); err != nil {
+			return fmt.Errorf("%s: step %q: %w", block, step.Name, err)
+		}
+		if step.Budget != nil && step.Budget.OnExceeded == BudgetOnExceededApprove {
+			return fmt.Errorf("%s: step %q: handlers cannot wait for approval of their budget", block, step.Name)
+		}
+	}
+	return nil
+}
+
+// IsHumanInput returns true if the step waits for a user to answer its
+// prompt.
+func (s *StepConfig) IsHumanInput() bool {
+	return s.Type == StepTypeHumanInput
+}
+
+// ValidateHumanInput validates the type of a step and the prompt and form
+// of a human_input step. The answer becomes the output of the step, so
+// human_input steps do not call agents and run exactly once.
+func (s *StepConfig) ValidateHumanInput() error {
+	switch s.Type {
+	case "":
+		if s.Prompt != "" || s.Form != nil {
+			return fmt.Errorf("prompt and form require type %q", StepTypeHumanInput)
+		}
+		return nil
+	case StepTypeHumanInput:
+	default:
+		return fmt.Errorf("unknown type %q, must be %q", s.Type, StepTypeHumanInput)
+	}
+
+	switch {
+	case strings.TrimSpace(s.Prompt) == "":
+		return fmt.Errorf("prompt is required")
+	case s.Agent != "" || s.Uses != "":
+		return fmt.Errorf("human_input steps cannot have agent or uses")
+	case s.Input != nil:
+		return fmt.Errorf("human_input steps cannot have input, ask in the prompt")
+	case s.OutputSchema != nil:
+		return fmt.Errorf("human_input steps cannot have output_schema, describe the answer with form")
+	case s.RequiresApproval:
+		return fmt.Errorf("human_input steps cannot require approval")
+	case s.Foreach != nil || s.Matrix != nil:
+		return fmt.Errorf("human_input steps cannot fan out")
+	case s.Retries > 0 || s.Retry != nil:
+		return fmt.Errorf("human_input steps cannot be retried")
+	case s.Cache != nil || s.Budget != nil:
+		return fmt.Errorf("human_input steps cannot have cache or budget")
+	case s.Timeout != "":
+		return fmt.Errorf("human_input steps cannot have timeout, set the workflow timeout")
+	}
+
+	if _, err := expression.References(s.Prompt); err != nil {
+		return fmt.Errorf("prompt: %w", err)
+	}
+	for name, field := range s.Form {
+		if err := field.Validate(); err != nil {
+			return fmt.Errorf("form: field %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// validateTimeout validates an optional positive duration.
+func validateTimeout(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", field, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("%s must be positive", field)
+	}
+	return nil
+}
+
+// validateRetry validates the retries and retry settings of a step.
+func (s *StepConfig) validateRetry() error {
+	if s.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	if s.Retry == nil {
+		return nil
+	}
+	if s.Retries > 0 {
+		return fmt.Errorf("retries and retry cannot be combined")
+	}
+	if err := s.Retry.Validate(); err != nil {
+		return fmt.Errorf("retry: %w", err)
+	}
+	return nil
+}
+
+// validateCache validates the cache settings of a step. Only agent steps
+// are cached: actions are cheap or have side effects, and sub-workflow
+// steps run the steps of their workflow.
+func (s *StepConfig) validateCache() error {
+	if s.Cache == nil {
+		return nil
+	}
+	if s.Uses != "" {
+		return fmt.Errorf("cache is not supported for steps with uses")
+	}
+	if s.Cache.TTL == "" {
+		return fmt.Errorf("cache: ttl is required")
+	}
+	return validateTimeout("cache: ttl", s.Cache.TTL)
+}
+
+// validateBudget validates the budget of a step. Actions do not call
+// agents, so only agent and sub-workflow steps have a budget.
+func (s *StepConfig) validateBudget() error {
+	if s.Budget == nil {
+		return nil
+	}
+	if IsAction(s.Uses) {
+		return fmt.Errorf("budget is not supported for action steps")
+	}
+	return s.Budget.Validate()
+}
+
+// validateFanOut validates the foreach and matrix settings of a step.
+func (s *StepConfig) validateFanOut() error {
+	if s.Foreach != nil && s.Matrix != nil {
+		return fmt.Errorf("foreach and matrix cannot be combined")
+	}
+	if s.MaxParallel < 0 {
+		return fmt.Errorf("max_parallel must not be negative")
+	}
+
+	if s.Foreach != nil && !isListValue(s.Foreach) {
+		return fmt.Errorf("foreach must be a list or an expression")
+	}
+	if s.Matrix != nil && len(s.Matrix) == 0 {
+		return fmt.Errorf("matrix must not be empty")
+	}
+	for name, values := range s.Matrix {
+		if !isListValue(values) {
+			return fmt.Errorf("matrix %q must be a list or an expression", name)
+		}
+	}
+
+	return nil
+}
+
+// isListValue reports whether value is a list or an expression that may
+// evaluate to one.
+func isListValue(value any) bool {
+	switch v := value.(type) {
+	case []any:
+		return true
+	case string:
+		return expression.IsTemplate(v)
+	}
+	return false
+}
+
+// WorkflowScheme prefixes step references to other workflows.
+const WorkflowScheme = "workflow://"
+
+// WorkflowRef references a workflow definition by name and version.
+type WorkflowRef struct {
+	Name    string
+	Version string // Empty for any version
+}
+
+// String returns the reference in workflow://name@version form.
+func (r WorkflowRef) String() string {
+	if r.Version == "" {
+		return WorkflowScheme + r.Name
+	}
+	return WorkflowScheme + r.Name + "@" + r.Version
+}
+
+// ParseWorkflowRef parses a workflow://name@version reference. The version
+// may be omitted.
+func ParseWorkflowRef(uses string) (WorkflowRef, error) {
+	ref, ok := strings.CutPrefix(uses, WorkflowScheme)
+	if !ok {
+		return WorkflowRef{}, fmt.Errorf("%q must reference a workflow as %sname@version", uses, WorkflowScheme)
+	}
+
+	name, version, hasVersion := strings.Cut(ref, "@")
+	if name == "" {
+		return WorkflowRef{}, fmt.Errorf("%q: workflow name is required", uses)
+	}
+	if hasVersion && version == "" {
+		return WorkflowRef{}, fmt.Errorf("%q: version must not be empty", uses)
+	}
+
+	return WorkflowRef{Name: name, Version: version}, nil
+}
+
+// agentOutputFields are the fields the executor adds to the output of an
+// agent step. The fields of its output schema are merged next to them.
+var agentOutputFields = []string{
+	"content", "tokens_in", "tokens_out", "cost_usd", "duration_ms",
+	"model", "finish_reason", "tool_calls", "transcript",
+}
+
+// ValidateOutputSchema validates the output schema of a step. The output
+// schema of an agent step cannot declare the fields reserved for its
+// metadata.
+func (s *StepConfig) ValidateOutputSchema() error {
+	if s.OutputSchema == nil {
+		return nil
+	}
+	if err := CheckOutputSchema(s.OutputSchema); err != nil {
+		return fmt.Errorf("output_schema: %w", err)
+	}
+	if s.Uses != "" {
+		return nil
+	}
+
+	properties, _ := s.OutputSchema["properties"].(map[string]any)
+	for _, name := range agentOutputFields {
+		if _, ok := properties[name]; ok {
+			return fmt.Errorf("output_schema: property %q is reserved for step metadata", name)
+		}
+	}
+	return nil
+}
+
+// CheckOutputSchema validates a step output schema. Output schemas must
+// describe an object so that its fields can be referenced as step outputs.
+func CheckOutputSchema(schema map[string]any) error {
+	if err := jsonschema.Check(schema); err != nil {
+		return err
+	}
+	if schema["type"] != "object" {
+		return fmt.Errorf("type must be \"object\"")
+	}
+	return nil
+}
+
 // ToYAML converts the workflow configuration to YAML bytes.
 func (c *WorkflowConfig) ToYAML() ([]byte, error) {
 	return yaml.Marshal(c)
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/felixgeelhaar/bridge/pkg/expression"
 )
 
 func TestParseWorkflow(t *testing.T) {
@@ -167,6 +171,23 @@ steps:
 				}
 			},
 		},
+		{
+			name: "workflow with max parallel",
+			yaml: `
+name: parallel
+version: "1.0"
+max_parallel: 2
+steps:
+  - name: step1
+    agent: agent1
+`,
+			wantErr: false,
+			check: func(t *testing.T, cfg *WorkflowConfig) {
+				if cfg.MaxParallel != 2 {
+					t.Errorf("MaxParallel = %v, want 2", cfg.MaxParallel)
+				}
+			},
+		},
 		{
 			name: "workflow with policies",
 			yaml: `
@@ -348,6 +369,503 @@ func TestWorkflowConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "negative max parallel",
+			cfg: WorkflowConfig{
+				Name:        "test",
+				Version:     "1.0",
+				MaxParallel: -1,
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid output schema",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", OutputSchema: map[string]any{
+						"type":       "object",
+						"properties": map[string]any{"recommendation": map[string]any{"type": "string"}},
+					}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "output schema not an object",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", OutputSchema: map[string]any{"type": "string"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed output schema",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", OutputSchema: map[string]any{"type": "object", "required": "recommendation"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "output schema with reserved field",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", OutputSchema: map[string]any{
+						"type":       "object",
+						"properties": map[string]any{"content": map[string]any{"type": "string"}},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "action output schema with reserved field",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Uses: ActionFileRead, Input: map[string]any{"path": "README.md"}, OutputSchema: map[string]any{
+						"type":       "object",
+						"properties": map[string]any{"content": map[string]any{"type": "string"}},
+					}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid approval timing",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", RequiresApproval: true, ApprovalTiming: "during"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sub-workflow step",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Uses: "workflow://security-scan@1.2"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "agent and uses",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", Uses: "workflow://security-scan"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid uses",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Uses: "security-scan@1.2"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "built-in action",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "fetch", Uses: ActionGitHubGetPRFiles, Input: map[string]any{"repo": "owner/repo", "pr_number": 1}},
+					{Name: "names", Uses: ActionTransformJQ, Input: map[string]any{"input": "${{ steps.fetch.output.files }}", "filter": "map(.filename)"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "action without required input",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "fetch", Uses: ActionHTTPRequest, Input: map[string]any{"method": "GET"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid jq filter",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "names", Uses: ActionTransformJQ, Input: map[string]any{"filter": "map(.filename"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown action",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Uses: "github.merge_pr", Input: map[string]any{"repo": "owner/repo"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "foreach and matrix",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", Foreach: []any{"a"}, Matrix: map[string]any{"os": []any{"linux"}}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "foreach expression",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", Foreach: "${{ trigger.files }}", MaxParallel: 2},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "foreach not a list",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", Foreach: "files"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "matrix value not a list",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", Matrix: map[string]any{"os": "linux"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max parallel",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", Foreach: []any{"a"}, MaxParallel: -1},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "failure handlers",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", OnFailure: []StepConfig{{Name: "undo", Agent: "agent1"}}},
+				},
+				Finally: []StepConfig{{Name: "cleanup", Uses: "workflow://cleanup"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "handler with depends_on",
+			cfg: WorkflowConfig{
+				Name:      "test",
+				Version:   "1.0",
+				Steps:     []StepConfig{{Name: "step1", Agent: "agent1"}},
+				OnFailure: []StepConfig{{Name: "notify", Agent: "agent1", DependsOn: []string{"step1"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "handler name clashes with step",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", Finally: []StepConfig{{Name: "step1", Agent: "agent1"}}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "nested handlers",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps:   []StepConfig{{Name: "step1", Agent: "agent1"}},
+				Finally: []StepConfig{{
+					Name:      "cleanup",
+					Agent:     "agent1",
+					OnFailure: []StepConfig{{Name: "undo", Agent: "agent1"}},
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "retries and retry combined",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps:   []StepConfig{{Name: "step1", Agent: "agent1", Retries: 2, Retry: &RetryConfig{MaxAttempts: 3}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid handler retry",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps:   []StepConfig{{Name: "step1", Agent: "agent1"}},
+				Finally: []StepConfig{{Name: "cleanup", Agent: "agent1", Retry: &RetryConfig{MaxAttempts: 2, RetryOn: []string{"never"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cached step",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps:   []StepConfig{{Name: "step1", Agent: "agent1", Cache: &CacheConfig{TTL: "24h"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "cache without ttl",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps:   []StepConfig{{Name: "step1", Agent: "agent1", Cache: &CacheConfig{}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cached sub-workflow",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps:   []StepConfig{{Name: "step1", Uses: "workflow://review", Cache: &CacheConfig{TTL: "1h"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "timeouts",
+			cfg: WorkflowConfig{
+				Name:            "test",
+				Version:         "1.0",
+				Timeout:         "30m",
+				ApprovalTimeout: "4h",
+				Steps:           []StepConfig{{Name: "step1", Agent: "agent1"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid timeout",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Timeout: "half an hour",
+				Steps:   []StepConfig{{Name: "step1", Agent: "agent1"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative approval timeout",
+			cfg: WorkflowConfig{
+				Name:            "test",
+				Version:         "1.0",
+				ApprovalTimeout: "-1h",
+				Steps:           []StepConfig{{Name: "step1", Agent: "agent1"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "concurrency group",
+			cfg: WorkflowConfig{
+				Name:        "test",
+				Version:     "1.0",
+				Concurrency: &ConcurrencyConfig{Group: "${{ trigger.repo.full_name }}-${{ trigger.pr.number }}", CancelInProgress: true},
+				Steps:       []StepConfig{{Name: "step1", Agent: "agent1"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "concurrency without group",
+			cfg: WorkflowConfig{
+				Name:        "test",
+				Version:     "1.0",
+				Concurrency: &ConcurrencyConfig{CancelInProgress: true},
+				Steps:       []StepConfig{{Name: "step1", Agent: "agent1"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "concurrency group referencing steps",
+			cfg: WorkflowConfig{
+				Name:        "test",
+				Version:     "1.0",
+				Concurrency: &ConcurrencyConfig{Group: "${{ steps.step1.output.id }}"},
+				Steps:       []StepConfig{{Name: "step1", Agent: "agent1"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "budgets",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Budget:  &BudgetConfig{MaxTokens: 200000, MaxCostUSD: 5},
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1", Budget: &BudgetConfig{MaxCostUSD: 1, OnExceeded: "approve"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "budget without limit",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Budget:  &BudgetConfig{OnExceeded: "fail"},
+				Steps:   []StepConfig{{Name: "step1", Agent: "agent1"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "budget with invalid on_exceeded",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Budget:  &BudgetConfig{MaxTokens: 1000, OnExceeded: "warn"},
+				Steps:   []StepConfig{{Name: "step1", Agent: "agent1"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "budget on action step",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps:   []StepConfig{{Name: "step1", Uses: ActionTransformJQ, Budget: &BudgetConfig{MaxTokens: 1000}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "handler budget awaiting approval",
+			cfg: WorkflowConfig{
+				Name:      "test",
+				Version:   "1.0",
+				Steps:     []StepConfig{{Name: "step1", Agent: "agent1"}},
+				OnFailure: []StepConfig{{Name: "notify", Agent: "agent1", Budget: &BudgetConfig{MaxTokens: 1000, OnExceeded: "approve"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "human input step",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{
+					{Name: "step1", Agent: "agent1"},
+					{Name: "ask", Type: StepTypeHumanInput, Prompt: "Ship ${{ steps.step1.output.version }}?", Form: map[string]InputConfig{
+						"ship":  {Type: InputBool, Required: true},
+						"notes": {},
+					}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "human input step without prompt",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps:   []StepConfig{{Name: "ask", Type: StepTypeHumanInput}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "human input step with agent",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps:   []StepConfig{{Name: "ask", Type: StepTypeHumanInput, Prompt: "Ship?", Agent: "agent1"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "human input step with invalid form field",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps: []StepConfig{{Name: "ask", Type: StepTypeHumanInput, Prompt: "Ship?", Form: map[string]InputConfig{
+					"ship": {Type: "yesno"},
+				}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "prompt without human input type",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps:   []StepConfig{{Name: "step1", Agent: "agent1", Prompt: "Ship?"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown step type",
+			cfg: WorkflowConfig{
+				Name:    "test",
+				Version: "1.0",
+				Steps:   []StepConfig{{Name: "step1", Type: "webhook", Agent: "agent1"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "human input handler",
+			cfg: WorkflowConfig{
+				Name:      "test",
+				Version:   "1.0",
+				Steps:     []StepConfig{{Name: "step1", Agent: "agent1"}},
+				OnFailure: []StepConfig{{Name: "ask", Type: StepTypeHumanInput, Prompt: "Retry?"}},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -360,6 +878,97 @@ func TestWorkflowConfig_Validate(t *testing.T) {
 	}
 }
 
+func TestLoadWorkflow_Example(t *testing.T) {
+	cfg, err := LoadWorkflow("../../examples/pr-review/workflow.yaml")
+	if err != nil {
+		t.Fatalf("LoadWorkflow() error = %v", err)
+	}
+
+	schemas := make(map[string]map[string]any)
+	for _, step := range cfg.Steps {
+		if step.OutputSchema != nil {
+			schemas[step.Name] = step.OutputSchema
+		}
+	}
+
+	// Fields read from steps with an output schema must be declared by it
+	for _, step := range cfg.Steps {
+		refs, err := expression.ReferencesIn(step.Input)
+		if err != nil {
+			t.Fatalf("step %s: input: %v", step.Name, err)
+		}
+		condRefs, err := expression.ReferencesExpr(step.Condition)
+		if err != nil && step.Condition != "" {
+			t.Fatalf("step %s: condition: %v", step.Name, err)
+		}
+		for _, ref := range append(refs, condRefs...) {
+			parts := strings.Split(ref, ".")
+			if len(parts) < 4 || parts[0] != "steps" || parts[2] != "output" {
+				continue
+			}
+			schema, ok := schemas[parts[1]]
+			if !ok {
+				continue
+			}
+			properties, _ := schema["properties"].(map[string]any)
+			if _, declared := properties[parts[3]]; !declared {
+				t.Errorf("step %s reads %s, not declared by the output schema of %s", step.Name, ref, parts[1])
+			}
+		}
+	}
+
+	review := schemas["generate-review"]
+	if review == nil {
+		t.Fatal("generate-review has no output schema")
+	}
+	for _, field := range []string{"recommendation", "should_post"} {
+		if !slices.Contains(toStrings(review["required"]), field) {
+			t.Errorf("generate-review output schema does not require %s", field)
+		}
+	}
+}
+
+// toStrings converts a YAML list to strings.
+func toStrings(value any) []string {
+	list, _ := value.([]any)
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+func TestParseWorkflowRef(t *testing.T) {
+	tests := []struct {
+		uses    string
+		want    WorkflowRef
+		wantErr bool
+	}{
+		{"workflow://security-scan@1.2", WorkflowRef{Name: "security-scan", Version: "1.2"}, false},
+		{"workflow://security-scan", WorkflowRef{Name: "security-scan"}, false},
+		{"workflow://@1.2", WorkflowRef{}, true},
+		{"workflow://security-scan@", WorkflowRef{}, true},
+		{"security-scan", WorkflowRef{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uses, func(t *testing.T) {
+			got, err := ParseWorkflowRef(tt.uses)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWorkflowRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseWorkflowRef() = %v, want %v", got, tt.want)
+			}
+			if err == nil && got.String() != tt.uses {
+				t.Errorf("String() = %v, want %v", got.String(), tt.uses)
+			}
+		})
+	}
+}
+
 func TestWorkflowConfig_ToYAML(t *testing.T) {
 	cfg := &WorkflowConfig{
 		Name:        "test",
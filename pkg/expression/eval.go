@@ -0,0 +1,292 @@
+package expression
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// eval evaluates an expression tree against a scope.
+func eval(n node, scope Scope) (any, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return n.value, nil
+
+	case *identNode:
+		v, ok := scope[n.name]
+		if !ok {
+			return nil, &ReferenceError{Path: n.path()}
+		}
+		return v, nil
+
+	case *memberNode:
+		obj, err := eval(n.object, scope)
+		if err != nil {
+			return nil, err
+		}
+		v, ok := lookup(obj, n.name)
+		if !ok {
+			return nil, &ReferenceError{Path: n.path()}
+		}
+		return v, nil
+
+	case *indexNode:
+		obj, err := eval(n.object, scope)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := eval(n.index, scope)
+		if err != nil {
+			return nil, err
+		}
+		v, ok := lookup(obj, toString(idx))
+		if !ok {
+			return nil, &ReferenceError{Path: n.path()}
+		}
+		return v, nil
+
+	case *unaryNode:
+		v, err := eval(n.operand, scope)
+		if err != nil {
+			return nil, err
+		}
+		return !Truthy(v), nil
+
+	case *binaryNode:
+		return evalBinary(n, scope)
+
+	case *callNode:
+		args := make([]any, len(n.args))
+		for i, arg := range n.args {
+			v, err := eval(arg, scope)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return callFunction(n.name, args)
+	}
+
+	return nil, &SyntaxError{Msg: "unsupported expression node"}
+}
+
+// evalBinary evaluates logical and comparison operators. Logical operators
+// short-circuit and return the deciding operand, so "a || 'default'"
+// yields a fallback value.
+func evalBinary(n *binaryNode, scope Scope) (any, error) {
+	left, err := eval(n.left, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "&&":
+		if !Truthy(left) {
+			return left, nil
+		}
+		return eval(n.right, scope)
+	case "||":
+		if Truthy(left) {
+			return left, nil
+		}
+		return eval(n.right, scope)
+	}
+
+	right, err := eval(n.right, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	}
+
+	cmp, err := compare(left, right)
+	if err != nil {
+		return nil, fmt.Errorf("cannot evaluate %q: %w", n.path(), err)
+	}
+
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operator %q", n.op)
+}
+
+// Truthy reports whether a value is considered true in a condition.
+// False, null, zero, the empty string and empty collections are false.
+func Truthy(v any) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ""
+	case []any:
+		return len(val) > 0
+	case map[string]any:
+		return len(val) > 0
+	}
+
+	if f, ok := toNumber(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+// equal compares two values. Numbers are compared by value regardless of
+// their Go type; other values are compared structurally.
+func equal(a, b any) bool {
+	if fa, ok := toNumber(a); ok {
+		if fb, ok := toNumber(b); ok {
+			return fa == fb
+		}
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare orders two numbers or two strings.
+func compare(a, b any) (int, error) {
+	if fa, ok := toNumber(a); ok {
+		if fb, ok := toNumber(b); ok {
+			switch {
+			case fa < fb:
+				return -1, nil
+			case fa > fb:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+
+	sa, okA := a.(string)
+	sb, okB := b.(string)
+	if okA && okB {
+		return strings.Compare(sa, sb), nil
+	}
+
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+// toNumber converts numeric values to float64.
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// lookup resolves a key on a map, slice or struct value.
+func lookup(obj any, key string) (any, bool) {
+	switch o := obj.(type) {
+	case nil:
+		return nil, false
+	case map[string]any:
+		v, ok := o[key]
+		return v, ok
+	case map[string]string:
+		v, ok := o[key]
+		return v, ok
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(o) {
+			return nil, false
+		}
+		return o[i], true
+	}
+
+	rv := reflect.ValueOf(obj)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		v := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))
+		if !v.IsValid() {
+			return nil, false
+		}
+		return v.Interface(), true
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= rv.Len() {
+			return nil, false
+		}
+		return rv.Index(i).Interface(), true
+	case reflect.Struct, reflect.Pointer:
+		// Structs (e.g. webhook trigger payloads) are addressed by their
+		// JSON field names, so normalize them through JSON first.
+		normalized, ok := normalize(obj)
+		if !ok {
+			return nil, false
+		}
+		return lookup(normalized, key)
+	}
+
+	return nil, false
+}
+
+// normalize converts an arbitrary value into its generic JSON representation.
+func normalize(v any) (any, bool) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// toString converts a value to its string form for interpolation.
+// Lists and maps are rendered as JSON.
+func toString(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case bool:
+		return strconv.FormatBool(val)
+	case int:
+		return strconv.Itoa(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case json.Number:
+		return val.String()
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,387 @@
+// Package expression implements the ${{ }} expression language used in
+// workflow definitions to reference trigger data, inputs and step outputs.
+//
+// Expressions support property and index access (steps.review.output.files[0]),
+// string, number, boolean and null literals, the operators ! && || == != < <= > >=,
+// and the functions contains, startsWith, endsWith, toJSON and fromJSON.
+package expression
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	templateOpen  = "${{"
+	templateClose = "}}"
+)
+
+// ErrUnresolvedReference is returned when an expression references a value
+// that does not exist in the evaluation scope.
+var ErrUnresolvedReference = errors.New("unresolved reference")
+
+// Scope holds the root values available to expressions, such as
+// "steps", "trigger", "inputs" and "metadata".
+type Scope map[string]any
+
+// SyntaxError describes a malformed expression.
+type SyntaxError struct {
+	Expr string
+	Pos  int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid expression %q at position %d: %s", e.Expr, e.Pos, e.Msg)
+}
+
+// ReferenceError describes a reference that could not be resolved.
+type ReferenceError struct {
+	Path string
+}
+
+func (e *ReferenceError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrUnresolvedReference, e.Path)
+}
+
+// Is reports whether the target is ErrUnresolvedReference.
+func (e *ReferenceError) Is(target error) bool {
+	return target == ErrUnresolvedReference
+}
+
+// Evaluate evaluates a single expression against the scope. The expression
+// may optionally be wrapped in ${{ }} delimiters.
+func Evaluate(expr string, scope Scope) (any, error) {
+	expr = strings.TrimSpace(expr)
+	if inner, ok := unwrap(expr); ok {
+		expr = inner
+	}
+
+	n, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return eval(n, scope)
+}
+
+// EvaluateCondition evaluates a step condition and reports whether it is
+// truthy. An empty condition is always true.
+func EvaluateCondition(expr string, scope Scope) (bool, error) {
+	if strings.TrimSpace(expr) == "" {
+		return true, nil
+	}
+
+	v, err := Evaluate(expr, scope)
+	if err != nil {
+		return false, err
+	}
+	return Truthy(v), nil
+}
+
+// IsTemplate returns true if the string contains at least one ${{ }} expression.
+func IsTemplate(s string) bool {
+	return strings.Contains(s, templateOpen)
+}
+
+// Render resolves all ${{ }} expressions in a value. Maps and slices are
+// rendered recursively. A string consisting of exactly one expression
+// evaluates to the typed result (lists and maps are preserved); expressions
+// embedded in surrounding text are interpolated as strings.
+func Render(value any, scope Scope) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return RenderString(v, scope)
+
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			rendered, err := Render(item, scope)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			out[key] = rendered
+		}
+		return out, nil
+
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			rendered, err := Render(item, scope)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	}
+
+	return value, nil
+}
+
+// RenderMap resolves all expressions in a map of values.
+func RenderMap(values map[string]any, scope Scope) (map[string]any, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	rendered, err := Render(values, scope)
+	if err != nil {
+		return nil, err
+	}
+	return rendered.(map[string]any), nil
+}
+
+// RenderString resolves expressions within a single string.
+func RenderString(s string, scope Scope) (any, error) {
+	if !IsTemplate(s) {
+		return s, nil
+	}
+
+	// A string that is exactly one expression keeps the typed result.
+	if inner, ok := unwrap(strings.TrimSpace(s)); ok {
+		return Evaluate(inner, scope)
+	}
+
+	spans, err := findTemplates(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, sp := range spans {
+		sb.WriteString(s[last:sp.start])
+		v, err := Evaluate(sp.expr, scope)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(toString(v))
+		last = sp.end
+	}
+	sb.WriteString(s[last:])
+
+	return sb.String(), nil
+}
+
+// RenderPartial resolves the expressions within a string that only
+// reference roots of the scope and leaves other expressions in place, so
+// that they can be rendered later against another scope. An expression that
+// references roots of the scope as well as other values cannot be resolved
+// and returns an error.
+func RenderPartial(s string, scope Scope) (any, error) {
+	spans, err := findTemplates(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, sp := range spans {
+		n, err := parse(sp.expr)
+		if err != nil {
+			return nil, err
+		}
+
+		inScope, other := 0, ""
+		for _, ref := range collectReferences(n, make([]string, 0)) {
+			root, _, _ := strings.Cut(ref, ".")
+			if _, ok := scope[root]; ok {
+				inScope++
+			} else {
+				other = ref
+			}
+		}
+		if inScope == 0 {
+			continue
+		}
+		if other != "" {
+			return nil, fmt.Errorf("expression %q cannot reference %s", sp.expr, other)
+		}
+
+		v, err := eval(n, scope)
+		if err != nil {
+			return nil, err
+		}
+		// A string that is exactly one expression keeps the typed result.
+		if sp.start == 0 && sp.end == len(s) {
+			return v, nil
+		}
+
+		sb.WriteString(s[last:sp.start])
+		sb.WriteString(toString(v))
+		last = sp.end
+	}
+	sb.WriteString(s[last:])
+
+	return sb.String(), nil
+}
+
+// References returns the static reference paths used by all expressions in
+// a string, e.g. "steps.fetch-changes.output.files".
+func References(s string) ([]string, error) {
+	spans, err := findTemplates(s)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]string, 0)
+	for _, sp := range spans {
+		n, err := parse(sp.expr)
+		if err != nil {
+			return nil, err
+		}
+		refs = collectReferences(n, refs)
+	}
+	return refs, nil
+}
+
+// ReferencesIn returns the static reference paths used by all expressions
+// in a value, descending into nested maps and lists.
+func ReferencesIn(value any) ([]string, error) {
+	refs := make([]string, 0)
+	switch v := value.(type) {
+	case string:
+		return References(v)
+	case map[string]any:
+		for _, item := range v {
+			r, err := ReferencesIn(item)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, r...)
+		}
+	case []any:
+		for _, item := range v {
+			r, err := ReferencesIn(item)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, r...)
+		}
+	}
+	return refs, nil
+}
+
+// ReferencesExpr returns the static reference paths used by a bare
+// expression (with or without ${{ }} delimiters).
+func ReferencesExpr(expr string) ([]string, error) {
+	expr = strings.TrimSpace(expr)
+	if inner, ok := unwrap(expr); ok {
+		expr = inner
+	}
+
+	n, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return collectReferences(n, make([]string, 0)), nil
+}
+
+// collectReferences walks the tree and appends the static path of every
+// accessor chain rooted at an identifier.
+func collectReferences(n node, refs []string) []string {
+	switch n := n.(type) {
+	case *identNode:
+		return append(refs, n.name)
+	case *memberNode:
+		if p, ok := staticPath(n); ok {
+			return append(refs, p)
+		}
+		return collectReferences(n.object, refs)
+	case *indexNode:
+		refs = collectReferences(n.object, refs)
+		return collectReferences(n.index, refs)
+	case *unaryNode:
+		return collectReferences(n.operand, refs)
+	case *binaryNode:
+		refs = collectReferences(n.left, refs)
+		return collectReferences(n.right, refs)
+	case *callNode:
+		for _, arg := range n.args {
+			refs = collectReferences(arg, refs)
+		}
+	}
+	return refs
+}
+
+// staticPath returns the dotted path of a chain of member accesses on an identifier.
+func staticPath(n node) (string, bool) {
+	switch n := n.(type) {
+	case *identNode:
+		return n.name, true
+	case *memberNode:
+		p, ok := staticPath(n.object)
+		if !ok {
+			return "", false
+		}
+		return p + "." + n.name, true
+	}
+	return "", false
+}
+
+// span locates a ${{ }} expression within a string.
+type span struct {
+	start int
+	end   int
+	expr  string
+}
+
+// findTemplates locates all ${{ }} expressions in a string. Closing
+// delimiters inside quoted string literals are ignored.
+func findTemplates(s string) ([]span, error) {
+	spans := make([]span, 0)
+	pos := 0
+	for {
+		idx := strings.Index(s[pos:], templateOpen)
+		if idx == -1 {
+			return spans, nil
+		}
+		start := pos + idx
+		exprStart := start + len(templateOpen)
+
+		end := -1
+		var quote byte
+		for i := exprStart; i < len(s); i++ {
+			c := s[i]
+			if quote != 0 {
+				if c == quote {
+					quote = 0
+				}
+				continue
+			}
+			if c == '\'' || c == '"' {
+				quote = c
+				continue
+			}
+			if strings.HasPrefix(s[i:], templateClose) {
+				end = i
+				break
+			}
+		}
+		if end == -1 {
+			return nil, &SyntaxError{Expr: s, Pos: start, Msg: "unterminated ${{ expression"}
+		}
+
+		spans = append(spans, span{
+			start: start,
+			end:   end + len(templateClose),
+			expr:  strings.TrimSpace(s[exprStart:end]),
+		})
+		pos = end + len(templateClose)
+	}
+}
+
+// unwrap returns the inner expression if s consists of exactly one ${{ }} block.
+func unwrap(s string) (string, bool) {
+	if !strings.HasPrefix(s, templateOpen) || !strings.HasSuffix(s, templateClose) {
+		return "", false
+	}
+
+	spans, err := findTemplates(s)
+	if err != nil || len(spans) != 1 || spans[0].start != 0 || spans[0].end != len(s) {
+		return "", false
+	}
+	return spans[0].expr, true
+}
@@ -0,0 +1,342 @@
+package expression
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testScope() Scope {
+	return Scope{
+		"steps": map[string]any{
+			"fetch-changes": map[string]any{
+				"status": "completed",
+				"output": map[string]any{
+					"files": []any{"main.go", "README.md"},
+					"diff":  "+ added line",
+					"stats": map[string]any{"additions": 10},
+				},
+			},
+		},
+		"trigger": map[string]any{
+			"pr":   map[string]any{"number": 42, "title": "Fix bug"},
+			"repo": map[string]any{"full_name": "owner/repo"},
+		},
+		"inputs":   map[string]any{"env": "staging"},
+		"metadata": map[string]any{"owner": "platform-team"},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	scope := testScope()
+
+	tests := []struct {
+		name string
+		expr string
+		want any
+	}{
+		{"trigger number", "trigger.pr.number", 42},
+		{"wrapped expression", "${{ trigger.repo.full_name }}", "owner/repo"},
+		{"hyphenated step name", "steps.fetch-changes.output.diff", "+ added line"},
+		{"list preserved", "steps.fetch-changes.output.files", []any{"main.go", "README.md"}},
+		{"index access", "steps.fetch-changes.output.files[1]", "README.md"},
+		{"string index", "steps['fetch-changes'].status", "completed"},
+		{"inputs", "inputs.env", "staging"},
+		{"metadata", "metadata.owner", "platform-team"},
+		{"string literal", "'hello'", "hello"},
+		{"number literal", "3", 3},
+		{"bool literal", "true", true},
+		{"null literal", "null", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Evaluate(tt.expr, scope)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) error = %v", tt.expr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_Errors(t *testing.T) {
+	scope := testScope()
+
+	tests := []struct {
+		name       string
+		expr       string
+		unresolved bool
+	}{
+		{"unknown root", "unknown.value", true},
+		{"unknown step", "steps.missing.output", true},
+		{"unknown field", "steps.fetch-changes.output.nope", true},
+		{"index out of range", "steps.fetch-changes.output.files[5]", true},
+		{"empty", "", false},
+		{"trailing dot", "trigger.", false},
+		{"unterminated string", "'abc", false},
+		{"unbalanced bracket", "trigger[", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Evaluate(tt.expr, scope)
+			if err == nil {
+				t.Fatalf("Evaluate(%q) expected error", tt.expr)
+			}
+			if got := errors.Is(err, ErrUnresolvedReference); got != tt.unresolved {
+				t.Errorf("errors.Is(ErrUnresolvedReference) = %v, want %v (err: %v)", got, tt.unresolved, err)
+			}
+		})
+	}
+}
+
+func TestEvaluate_UnresolvedPath(t *testing.T) {
+	_, err := Evaluate("steps.fetch-changes.output.nope", testScope())
+
+	var refErr *ReferenceError
+	if !errors.As(err, &refErr) {
+		t.Fatalf("expected ReferenceError, got %v", err)
+	}
+	if refErr.Path != "steps.fetch-changes.output.nope" {
+		t.Errorf("Path = %v, want steps.fetch-changes.output.nope", refErr.Path)
+	}
+}
+
+func TestEvaluate_Struct(t *testing.T) {
+	type pr struct {
+		Number int `json:"number"`
+	}
+	scope := Scope{"trigger": map[string]any{"pr": &pr{Number: 7}}}
+
+	got, err := Evaluate("trigger.pr.number", scope)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	// Struct fields are normalized through JSON
+	if got != float64(7) {
+		t.Errorf("Evaluate() = %#v, want 7", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	scope := testScope()
+
+	input := map[string]any{
+		"files":     "${{ steps.fetch-changes.output.files }}",
+		"pr_number": "${{ trigger.pr.number }}",
+		"title":     "PR #${{ trigger.pr.number }}: ${{ trigger.pr.title }}",
+		"plain":     "no expressions here",
+		"count":     5,
+		"context": map[string]any{
+			"repo": "${{ trigger.repo.full_name }}",
+		},
+		"list": []any{"${{ inputs.env }}", "static"},
+		"json": "stats=${{ steps.fetch-changes.output.stats }}",
+	}
+
+	got, err := RenderMap(input, scope)
+	if err != nil {
+		t.Fatalf("RenderMap() error = %v", err)
+	}
+
+	want := map[string]any{
+		"files":     []any{"main.go", "README.md"},
+		"pr_number": 42,
+		"title":     "PR #42: Fix bug",
+		"plain":     "no expressions here",
+		"count":     5,
+		"context": map[string]any{
+			"repo": "owner/repo",
+		},
+		"list": []any{"staging", "static"},
+		"json": `stats={"additions":10}`,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RenderMap() = %#v, want %#v", got, want)
+	}
+}
+
+func TestRender_Errors(t *testing.T) {
+	scope := testScope()
+
+	_, err := RenderMap(map[string]any{
+		"nested": map[string]any{"value": "${{ steps.missing.output }}"},
+	}, scope)
+	if !errors.Is(err, ErrUnresolvedReference) {
+		t.Fatalf("expected unresolved reference error, got %v", err)
+	}
+	if got := err.Error(); got != "nested: value: unresolved reference: steps.missing" {
+		t.Errorf("error = %q", got)
+	}
+
+	_, err = RenderString("${{ trigger.pr.number", scope)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("expected SyntaxError for unterminated template, got %v", err)
+	}
+}
+
+func TestRenderPartial(t *testing.T) {
+	scope := Scope{"params": map[string]any{"focus": "security", "depth": 2}}
+
+	tests := []struct {
+		name    string
+		s       string
+		want    any
+		wantErr bool
+	}{
+		{"typed value", "${{ params.depth }}", 2, false},
+		{"interpolated", "Review ${{ params.focus }} of PR ${{ inputs.pr }}", "Review security of PR ${{ inputs.pr }}", false},
+		{"other roots only", "${{ steps.fetch.output }}", "${{ steps.fetch.output }}", false},
+		{"plain", "no expressions here", "no expressions here", false},
+		{"mixed roots", "${{ params.focus == inputs.focus }}", nil, true},
+		{"unresolved", "${{ params.missing }}", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderPartial(tt.s, scope)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderPartial() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RenderPartial() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMap_Nil(t *testing.T) {
+	got, err := RenderMap(nil, testScope())
+	if err != nil {
+		t.Fatalf("RenderMap(nil) error = %v", err)
+	}
+	if got != nil {
+		t.Errorf("RenderMap(nil) = %v, want nil", got)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	refs, err := References("${{ steps.fetch-changes.output.files[0] }} and ${{ trigger.pr.number }}")
+	if err != nil {
+		t.Fatalf("References() error = %v", err)
+	}
+
+	want := []string{"steps.fetch-changes.output.files", "trigger.pr.number"}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("References() = %v, want %v", refs, want)
+	}
+}
+
+func TestIsTemplate(t *testing.T) {
+	if !IsTemplate("${{ trigger.pr }}") {
+		t.Error("IsTemplate should be true for expression")
+	}
+	if IsTemplate("plain text") {
+		t.Error("IsTemplate should be false for plain text")
+	}
+}
+
+func TestEvaluate_Operators(t *testing.T) {
+	scope := testScope()
+
+	tests := []struct {
+		expr string
+		want any
+	}{
+		{"trigger.pr.number == 42", true},
+		{"trigger.pr.number != 42", false},
+		{"trigger.pr.number > 10 && trigger.pr.number <= 42", true},
+		{"trigger.pr.number < 10 || inputs.env == 'staging'", true},
+		{"!(inputs.env == 'production')", true},
+		{"!steps.fetch-changes.output.files", false},
+		{"'abc' < 'abd'", true},
+		{"steps.fetch-changes.output.stats.additions >= 10.0", true},
+		{"inputs.env || 'default'", "staging"},
+		{"null || 'default'", "default"},
+		{"true && false || true", true},
+		{"startsWith(trigger.repo.full_name, 'owner/')", true},
+		{"endsWith(trigger.repo.full_name, '/other')", false},
+		{"contains(steps.fetch-changes.output.files, 'main.go')", true},
+		{"contains(trigger.pr.title, 'bug')", true},
+		{"contains(steps.fetch-changes.output.files, 'missing.go')", false},
+		{"fromJSON('{\"should_post\": true}').should_post", true},
+		{"toJSON(steps.fetch-changes.output.stats)", `{"additions":10}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := Evaluate(tt.expr, scope)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) error = %v", tt.expr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_OperatorErrors(t *testing.T) {
+	scope := testScope()
+
+	tests := []string{
+		"trigger.pr.number >",
+		"unknownFn(trigger.pr)",
+		"startsWith(trigger.pr.title)",
+		"trigger.pr.number = 42",
+		"trigger.pr < 10",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := Evaluate(expr, scope); err == nil {
+				t.Errorf("Evaluate(%q) expected error", expr)
+			}
+		})
+	}
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	scope := testScope()
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"${{ inputs.env == 'staging' }}", true},
+		{"inputs.env == 'production'", false},
+		{"steps.fetch-changes.output.files", true},
+		{"''", false},
+		{"0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := EvaluateCondition(tt.expr, scope)
+			if err != nil {
+				t.Fatalf("EvaluateCondition(%q) error = %v", tt.expr, err)
+			}
+			if got != tt.want {
+				t.Errorf("EvaluateCondition(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferencesExpr(t *testing.T) {
+	refs, err := ReferencesExpr("contains(steps.lint.output.files, 'x') && !steps.test.output.failed")
+	if err != nil {
+		t.Fatalf("ReferencesExpr() error = %v", err)
+	}
+
+	want := []string{"steps.lint.output.files", "steps.test.output.failed"}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("ReferencesExpr() = %v, want %v", refs, want)
+	}
+}
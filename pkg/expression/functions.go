@@ -0,0 +1,85 @@
+package expression
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// function is a built-in function callable from expressions.
+type function struct {
+	arity int
+	call  func(args []any) (any, error)
+}
+
+// functions lists the built-in functions available to expressions.
+var functions = map[string]function{
+	"contains":   {arity: 2, call: fnContains},
+	"startsWith": {arity: 2, call: fnStartsWith},
+	"endsWith":   {arity: 2, call: fnEndsWith},
+	"toJSON":     {arity: 1, call: fnToJSON},
+	"fromJSON":   {arity: 1, call: fnFromJSON},
+}
+
+// callFunction invokes a built-in function with evaluated arguments.
+func callFunction(name string, args []any) (any, error) {
+	fn, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	if len(args) != fn.arity {
+		return nil, fmt.Errorf("%s expects %d arguments, got %d", name, fn.arity, len(args))
+	}
+	return fn.call(args)
+}
+
+// fnContains reports whether a string contains a substring, or a list
+// contains an element.
+func fnContains(args []any) (any, error) {
+	switch haystack := args[0].(type) {
+	case []any:
+		for _, item := range haystack {
+			if equal(item, args[1]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case []string:
+		needle := toString(args[1])
+		for _, item := range haystack {
+			if item == needle {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return strings.Contains(toString(args[0]), toString(args[1])), nil
+}
+
+func fnStartsWith(args []any) (any, error) {
+	return strings.HasPrefix(toString(args[0]), toString(args[1])), nil
+}
+
+func fnEndsWith(args []any) (any, error) {
+	return strings.HasSuffix(toString(args[0]), toString(args[1])), nil
+}
+
+func fnToJSON(args []any) (any, error) {
+	data, err := json.Marshal(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("toJSON: %w", err)
+	}
+	return string(data), nil
+}
+
+// fnFromJSON parses a JSON string, e.g. structured content returned by an agent.
+func fnFromJSON(args []any) (any, error) {
+	var out any
+	if err := json.Unmarshal([]byte(toString(args[0])), &out); err != nil {
+		return nil, fmt.Errorf("fromJSON: %w", err)
+	}
+	if f, ok := out.(float64); ok {
+		return normalizeNumber(f), nil
+	}
+	return out, nil
+}
@@ -0,0 +1,188 @@
+package expression
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tokenKind identifies the type of a lexical token.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenDot
+	tokenLBracket
+	tokenRBracket
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenOperator
+)
+
+// token is a single lexical token within an expression.
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// lexer splits an expression into tokens.
+type lexer struct {
+	input string
+	pos   int
+}
+
+func newLexer(input string) *lexer {
+	return &lexer{input: input}
+}
+
+// tokenize returns all tokens of the input, terminated by tokenEOF.
+func (l *lexer) tokenize() ([]token, error) {
+	tokens := make([]token, 0)
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.kind == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipWhitespace()
+
+	if l.pos >= len(l.input) {
+		return token{kind: tokenEOF, pos: l.pos}, nil
+	}
+
+	start := l.pos
+	c := l.input[l.pos]
+
+	switch {
+	case c == '.':
+		l.pos++
+		return token{kind: tokenDot, value: ".", pos: start}, nil
+	case c == '[':
+		l.pos++
+		return token{kind: tokenLBracket, value: "[", pos: start}, nil
+	case c == ']':
+		l.pos++
+		return token{kind: tokenRBracket, value: "]", pos: start}, nil
+	case c == '(':
+		l.pos++
+		return token{kind: tokenLParen, value: "(", pos: start}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokenRParen, value: ")", pos: start}, nil
+	case c == ',':
+		l.pos++
+		return token{kind: tokenComma, value: ",", pos: start}, nil
+	case c == '\'' || c == '"':
+		return l.readString(c)
+	case strings.ContainsRune("=!<>&|", rune(c)):
+		return l.readOperator()
+	case isDigit(c):
+		return l.readNumber(), nil
+	case isIdentStart(rune(c)):
+		return l.readIdent(), nil
+	}
+
+	return token{}, &SyntaxError{Expr: l.input, Pos: start, Msg: fmt.Sprintf("unexpected character %q", c)}
+}
+
+func (l *lexer) skipWhitespace() {
+	for l.pos < len(l.input) && unicode.IsSpace(rune(l.input[l.pos])) {
+		l.pos++
+	}
+}
+
+func (l *lexer) readString(quote byte) (token, error) {
+	start := l.pos
+	l.pos++ // opening quote
+
+	var sb strings.Builder
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		if c == quote {
+			// A doubled quote is an escaped quote, e.g. 'it''s'
+			if l.pos+1 < len(l.input) && l.input[l.pos+1] == quote {
+				sb.WriteByte(quote)
+				l.pos += 2
+				continue
+			}
+			l.pos++
+			return token{kind: tokenString, value: sb.String(), pos: start}, nil
+		}
+		sb.WriteByte(c)
+		l.pos++
+	}
+
+	return token{}, &SyntaxError{Expr: l.input, Pos: start, Msg: "unterminated string literal"}
+}
+
+// readOperator reads a comparison or logical operator.
+func (l *lexer) readOperator() (token, error) {
+	start := l.pos
+	for _, op := range operators {
+		if strings.HasPrefix(l.input[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokenOperator, value: op, pos: start}, nil
+		}
+	}
+	return token{}, &SyntaxError{Expr: l.input, Pos: start, Msg: fmt.Sprintf("unexpected character %q", l.input[start])}
+}
+
+// operators lists the supported operators, longest first so that "<="
+// is matched before "<".
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!"}
+
+func (l *lexer) readNumber() token {
+	start := l.pos
+	for l.pos < len(l.input) && (isDigit(l.input[l.pos]) || l.input[l.pos] == '.') {
+		// Stop at a dot that is not followed by a digit so that member
+		// access on numeric indexes remains possible.
+		if l.input[l.pos] == '.' && (l.pos+1 >= len(l.input) || !isDigit(l.input[l.pos+1])) {
+			break
+		}
+		l.pos++
+	}
+	return token{kind: tokenNumber, value: l.input[start:l.pos], pos: start}
+}
+
+// readIdent reads an identifier. Identifiers may contain hyphens so that
+// step names such as "fetch-changes" can be referenced directly.
+func (l *lexer) readIdent() token {
+	start := l.pos
+	for l.pos < len(l.input) {
+		r := rune(l.input[l.pos])
+		if isIdentPart(r) {
+			l.pos++
+			continue
+		}
+		if r == '-' && l.pos+1 < len(l.input) && isIdentPart(rune(l.input[l.pos+1])) {
+			l.pos++
+			continue
+		}
+		break
+	}
+	return token{kind: tokenIdent, value: l.input[start:l.pos], pos: start}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,304 @@
+package expression
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// node is a node of a parsed expression tree.
+type node interface {
+	// path returns the textual reference path of the node, used in error messages.
+	path() string
+}
+
+// literalNode is a constant value.
+type literalNode struct {
+	value any
+}
+
+func (n *literalNode) path() string { return fmt.Sprintf("%v", n.value) }
+
+// identNode is a root-level reference such as "steps" or "trigger".
+type identNode struct {
+	name string
+}
+
+func (n *identNode) path() string { return n.name }
+
+// memberNode is a property access such as "output.files".
+type memberNode struct {
+	object node
+	name   string
+}
+
+func (n *memberNode) path() string { return n.object.path() + "." + n.name }
+
+// indexNode is an index access such as "files[0]" or "output['key']".
+type indexNode struct {
+	object node
+	index  node
+}
+
+func (n *indexNode) path() string { return n.object.path() + "[" + n.index.path() + "]" }
+
+// unaryNode is a logical negation such as "!steps.lint.output.passed".
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+func (n *unaryNode) path() string { return n.op + n.operand.path() }
+
+// binaryNode is a comparison or logical operation such as "a == b".
+type binaryNode struct {
+	op    string
+	left  node
+	right node
+}
+
+func (n *binaryNode) path() string { return n.left.path() + " " + n.op + " " + n.right.path() }
+
+// callNode is a function call such as "startsWith(trigger.ref, 'refs/tags/')".
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n *callNode) path() string { return n.name + "()" }
+
+// parser builds an expression tree from tokens.
+type parser struct {
+	input  string
+	tokens []token
+	pos    int
+}
+
+// parse parses a complete expression.
+func parse(input string) (node, error) {
+	tokens, err := newLexer(input).tokenize()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{input: input, tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, &SyntaxError{Expr: input, Pos: 0, Msg: "empty expression"}
+	}
+
+	n, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "unexpected token %q", tok.value)
+	}
+
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.advance()
+	if tok.kind != kind {
+		return tok, p.errorf(tok, "expected %s", what)
+	}
+	return tok, nil
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	return &SyntaxError{Expr: p.input, Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// binaryLevels lists the binary operators by increasing precedence.
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+}
+
+func (p *parser) parseExpression() (node, error) {
+	return p.parseBinary(0)
+}
+
+// parseBinary parses a left-associative chain of the operators at the given
+// precedence level.
+func (p *parser) parseBinary(level int) (node, error) {
+	if level >= len(binaryLevels) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		if tok.kind != tokenOperator || !containsOp(binaryLevels[level], tok.value) {
+			return left, nil
+		}
+		p.advance()
+
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: tok.value, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if tok := p.peek(); tok.kind == tokenOperator && tok.value == "!" {
+		p.advance()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: tok.value, operand: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func containsOp(ops []string, op string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePostfix parses a primary expression followed by any number of
+// member or index accessors.
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.peek().kind {
+		case tokenDot:
+			p.advance()
+			tok := p.advance()
+			switch tok.kind {
+			case tokenIdent, tokenNumber:
+				n = &memberNode{object: n, name: tok.value}
+			default:
+				return nil, p.errorf(tok, "expected property name after '.'")
+			}
+		case tokenLBracket:
+			p.advance()
+			index, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokenRBracket, "']'"); err != nil {
+				return nil, err
+			}
+			n = &indexNode{object: n, index: index}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.advance()
+
+	switch tok.kind {
+	case tokenNumber:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid number %q", tok.value)
+		}
+		return &literalNode{value: normalizeNumber(f)}, nil
+	case tokenString:
+		return &literalNode{value: tok.value}, nil
+	case tokenIdent:
+		switch tok.value {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if p.peek().kind == tokenLParen {
+			return p.parseCall(tok)
+		}
+		return &identNode{name: tok.value}, nil
+	case tokenLParen:
+		n, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case tokenEOF:
+		return nil, p.errorf(tok, "unexpected end of expression")
+	}
+
+	return nil, p.errorf(tok, "unexpected token %q", tok.value)
+}
+
+// parseCall parses the argument list of a function call.
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.value]
+	if !ok {
+		return nil, p.errorf(name, "unknown function %q", name.value)
+	}
+
+	p.advance() // (
+	args := make([]node, 0)
+	if p.peek().kind == tokenRParen {
+		p.advance()
+	} else {
+		for done := false; !done; {
+			arg, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			tok := p.advance()
+			switch tok.kind {
+			case tokenComma:
+			case tokenRParen:
+				done = true
+			default:
+				return nil, p.errorf(tok, "expected ',' or ')' in call to %s", name.value)
+			}
+		}
+	}
+
+	if len(args) != fn.arity {
+		return nil, p.errorf(name, "%s expects %d arguments, got %d", name.value, fn.arity, len(args))
+	}
+
+	return &callNode{name: name.value, args: args}, nil
+}
+
+// normalizeNumber returns integral values as int so they compare and
+// render naturally (e.g. "42" rather than "42.000000").
+func normalizeNumber(f float64) any {
+	if f == float64(int64(f)) {
+		return int(f)
+	}
+	return f
+}
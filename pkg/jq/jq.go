@@ -0,0 +1,119 @@
+// Package jq runs the jq filters of the transform.jq workflow action to
+// reshape JSON values. Filters are evaluated by gojq, which implements the
+// jq language. Filters cannot read the environment of the process: env and
+// $ENV are empty, and modules cannot be imported.
+package jq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/itchyny/gojq"
+)
+
+// SyntaxError describes a malformed filter or one that calls an undefined
+// function.
+type SyntaxError struct {
+	Filter string
+	Pos    int // Byte offset of the error, -1 when it is not known
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Pos < 0 {
+		return fmt.Sprintf("invalid filter %q: %s", e.Filter, e.Msg)
+	}
+	return fmt.Sprintf("invalid filter %q at position %d: %s", e.Filter, e.Pos, e.Msg)
+}
+
+// Query is a compiled filter.
+type Query struct {
+	filter string
+	code   *gojq.Code
+}
+
+// Parse parses and compiles a filter.
+func Parse(filter string) (*Query, error) {
+	if filter == "" {
+		return nil, &SyntaxError{Filter: filter, Pos: 0, Msg: "empty filter"}
+	}
+
+	parsed, err := gojq.Parse(filter)
+	if err != nil {
+		var parseErr *gojq.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &SyntaxError{Filter: filter, Pos: parseErr.Offset, Msg: parseErr.Error()}
+		}
+		return nil, &SyntaxError{Filter: filter, Pos: -1, Msg: err.Error()}
+	}
+
+	code, err := gojq.Compile(parsed)
+	if err != nil {
+		return nil, &SyntaxError{Filter: filter, Pos: -1, Msg: err.Error()}
+	}
+	return &Query{filter: filter, code: code}, nil
+}
+
+// String returns the filter the query was parsed from.
+func (q *Query) String() string {
+	return q.filter
+}
+
+// Run applies the query to a value and returns all values it produces.
+// Values are converted to their JSON representation, so numbers are
+// produced as float64 and structs as maps. Evaluation stops with the error
+// of ctx once it is done.
+func (q *Query) Run(ctx context.Context, input any) ([]any, error) {
+	v, err := normalize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []any{}
+	iter := q.code.RunWithContext(ctx, v)
+	for {
+		result, ok := iter.Next()
+		if !ok {
+			return results, nil
+		}
+		if err, ok := result.(error); ok {
+			var halt *gojq.HaltError
+			if errors.As(err, &halt) && halt.Value() == nil {
+				return results, nil
+			}
+			return nil, err
+		}
+		if result, err = normalize(result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+}
+
+// Run parses a filter and applies it to a value.
+func Run(ctx context.Context, filter string, input any) ([]any, error) {
+	q, err := Parse(filter)
+	if err != nil {
+		return nil, err
+	}
+	return q.Run(ctx, input)
+}
+
+// normalize converts a value into its generic JSON representation.
+func normalize(v any) (any, error) {
+	switch v.(type) {
+	case nil, bool, float64, string:
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("value is not JSON: %w", err)
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("value is not JSON: %w", err)
+	}
+	return out, nil
+}
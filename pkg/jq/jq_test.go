@@ -0,0 +1,114 @@
+package jq
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	input := map[string]any{
+		"pr": map[string]any{"number": 42, "title": "Fix login"},
+		"files": []any{
+			map[string]any{"filename": "main.go", "status": "modified", "additions": 10},
+			map[string]any{"filename": "README.md", "status": "added", "additions": 3},
+			map[string]any{"filename": "old.go", "status": "removed", "additions": 0},
+		},
+		"labels": []any{"bug", "ui", "bug"},
+		"empty":  nil,
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		want   []any
+	}{
+		{"identity", ".pr.number", []any{float64(42)}},
+		{"quoted field", `."pr"["title"]`, []any{"Fix login"}},
+		{"missing field", ".pr.missing", []any{nil}},
+		{"null field", ".empty.field", []any{nil}},
+		{"index", ".files[0].filename", []any{"main.go"}},
+		{"negative index", ".files[-1].filename", []any{"old.go"}},
+		{"slice", ".labels[1:]", []any{[]any{"ui", "bug"}}},
+		{"iterate", ".files[].filename", []any{"main.go", "README.md", "old.go"}},
+		{"pipe and comma", ".pr | .number, .title", []any{float64(42), "Fix login"}},
+		{"array construction", "[.files[] | .filename]", []any{[]any{"main.go", "README.md", "old.go"}}},
+		{"select", `[.files[] | select(.status != "removed") | .filename]`, []any{[]any{"main.go", "README.md"}}},
+		{"map", ".files | map(.additions) | add", []any{float64(13)}},
+		{"object construction", "{number: .pr.number, title: .pr.title}", []any{map[string]any{"number": float64(42), "title": "Fix login"}}},
+		{"object shorthand and computed key", `.pr | {title, (.title | ascii_downcase): true}`, []any{map[string]any{"title": "Fix login", "fix login": true}}},
+		{"length", ".files | length", []any{float64(3)}},
+		{"unique", ".labels | unique", []any{[]any{"bug", "ui"}}},
+		{"join", `.labels | join(", ")`, []any{"bug, ui, bug"}},
+		{"sort_by", "[.files | sort_by(.additions)[] | .filename]", []any{[]any{"old.go", "README.md", "main.go"}}},
+		{"group_by", "[.files | group_by(.additions > 0)[] | length]", []any{[]any{float64(1), float64(2)}}},
+		{"arithmetic", ".pr.number * 2 + 1", []any{float64(85)}},
+		{"alternative", `.empty // "default"`, []any{"default"}},
+		{"logical", ".pr.number > 10 and (.labels | length) == 3", []any{true}},
+		{"if", `if .pr.number > 100 then "large" elif .pr.number > 10 then "medium" else "small" end`, []any{"medium"}},
+		{"optional", ".pr.number[]?", []any{}},
+		{"contains", `.labels | contains(["ui"])`, []any{true}},
+		{"test", `.pr.title | test("^Fix")`, []any{true}},
+		{"with_entries", `.pr | with_entries(select(.key == "number"))`, []any{map[string]any{"number": float64(42)}}},
+		{"string concatenation", `"#" + (.pr.number | tostring)`, []any{"#42"}},
+		{"empty", "empty", []any{}},
+		{"reduce", "reduce .files[] as $f (0; . + $f.additions)", []any{float64(13)}},
+		{"variables", `.pr.number as $n | [.labels[] | "\($n):\(.)"] | first`, []any{"42:bug"}},
+		{"environment is not exposed", "env, $ENV", []any{map[string]any{}, map[string]any{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Run(context.Background(), tt.filter, input)
+			if err != nil {
+				t.Fatalf("Run(%q) error = %v", tt.filter, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Run(%q) = %#v, want %#v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     string
+		wantSyntax bool
+	}{
+		{"empty filter", "", true},
+		{"unterminated string", `."name`, true},
+		{"unknown function", "frobnicate", true},
+		{"wrong arity", "map", true},
+		{"unclosed bracket", ".files[0", true},
+		{"missing end", "if . then 1 else 2", true},
+		{"import", `import "lib" as lib; .`, true},
+		{"index object with number", ".[0]", false},
+		{"iterate number", ".count[]", false},
+		{"add string and number", `.name + 1`, false},
+	}
+
+	input := map[string]any{"name": "bridge", "count": 1}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Run(context.Background(), tt.filter, input)
+			if err == nil {
+				t.Fatalf("Run(%q) error = nil, want error", tt.filter)
+			}
+			var syntaxErr *SyntaxError
+			if errors.As(err, &syntaxErr) != tt.wantSyntax {
+				t.Errorf("Run(%q) error = %v, want syntax error %v", tt.filter, err, tt.wantSyntax)
+			}
+		})
+	}
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Run(ctx, "range(infinite)", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,399 @@
+// Package jsonschema validates decoded JSON values against JSON Schema
+// documents, as used by step output schemas in workflow definitions.
+//
+// The supported subset covers the keywords models are commonly asked to
+// follow: type, enum, const, properties, required, additionalProperties,
+// items, minItems, maxItems, minLength, maxLength, pattern, minimum and
+// maximum. Annotations such as title and description are allowed; any other
+// keyword makes the schema invalid rather than being silently ignored.
+package jsonschema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidSchema is returned when a schema document is malformed.
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// Violation describes a single validation failure.
+type Violation struct {
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// ValidationError lists every violation found in a value.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return "value does not match schema: " + strings.Join(msgs, "; ")
+}
+
+var types = map[string]bool{
+	"object":  true,
+	"array":   true,
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"boolean": true,
+	"null":    true,
+}
+
+// keywords lists the supported keywords. Annotations do not affect
+// validation.
+var keywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"properties": true, "required": true, "additionalProperties": true,
+	"items": true, "minItems": true, "maxItems": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"minimum": true, "maximum": true,
+	"$schema": true, "$id": true, "$comment": true,
+	"title": true, "description": true, "default": true, "examples": true,
+}
+
+// numeric lists the keywords whose value must be a number.
+var numeric = []string{"minItems", "maxItems", "minLength", "maxLength", "minimum", "maximum"}
+
+// Check reports whether schema is a well-formed schema document using only
+// supported keywords.
+func Check(schema map[string]any) error {
+	return check(schema, "$")
+}
+
+func check(schema map[string]any, path string) error {
+	for _, keyword := range sortedKeys(schema) {
+		if !keywords[keyword] {
+			return fmt.Errorf("%w: %s: unsupported keyword %q", ErrInvalidSchema, path, keyword)
+		}
+	}
+	for _, keyword := range numeric {
+		if value, ok := schema[keyword]; ok {
+			if _, ok := number(value); !ok {
+				return fmt.Errorf("%w: %s: %s must be a number", ErrInvalidSchema, path, keyword)
+			}
+		}
+	}
+
+	if t, ok := schema["type"]; ok {
+		names, err := typeNames(t)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidSchema, path, err)
+		}
+		for _, name := range names {
+			if !types[name] {
+				return fmt.Errorf("%w: %s: unknown type %q", ErrInvalidSchema, path, name)
+			}
+		}
+	}
+
+	if props, ok := schema["properties"]; ok {
+		m, ok := props.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: %s: properties must be an object", ErrInvalidSchema, path)
+		}
+		for _, name := range sortedKeys(m) {
+			sub, ok := m[name].(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w: %s.%s: schema must be an object", ErrInvalidSchema, path, name)
+			}
+			if err := check(sub, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if req, ok := schema["required"]; ok {
+		if _, err := stringList(req); err != nil {
+			return fmt.Errorf("%w: %s: required: %v", ErrInvalidSchema, path, err)
+		}
+	}
+
+	if items, ok := schema["items"]; ok {
+		sub, ok := items.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: %s: items must be an object", ErrInvalidSchema, path)
+		}
+		if err := check(sub, path+"[]"); err != nil {
+			return err
+		}
+	}
+
+	if additional, ok := schema["additionalProperties"]; ok {
+		switch a := additional.(type) {
+		case bool:
+		case map[string]any:
+			if err := check(a, path+".*"); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: %s: additionalProperties must be a boolean or an object", ErrInvalidSchema, path)
+		}
+	}
+
+	if pattern, ok := schema["pattern"]; ok {
+		s, ok := pattern.(string)
+		if !ok {
+			return fmt.Errorf("%w: %s: pattern must be a string", ErrInvalidSchema, path)
+		}
+		if _, err := regexp.Compile(s); err != nil {
+			return fmt.Errorf("%w: %s: pattern: %v", ErrInvalidSchema, path, err)
+		}
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		if _, ok := enum.([]any); !ok {
+			return fmt.Errorf("%w: %s: enum must be a list", ErrInvalidSchema, path)
+		}
+	}
+
+	return nil
+}
+
+// Validate validates a decoded JSON value against schema. It returns a
+// *ValidationError listing all violations, or nil when the value matches.
+func Validate(schema map[string]any, value any) error {
+	if err := Check(schema); err != nil {
+		return err
+	}
+
+	var violations []Violation
+	validate(schema, value, "$", &violations)
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+func validate(schema map[string]any, value any, path string, violations *[]Violation) {
+	report := func(format string, args ...any) {
+		*violations = append(*violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if t, ok := schema["type"]; ok {
+		names, _ := typeNames(t)
+		matched := false
+		for _, name := range names {
+			if hasType(value, name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report("expected %s, got %s", strings.Join(names, " or "), typeOf(value))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			if equal(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report("must be one of %v", enum)
+		}
+	}
+
+	if c, ok := schema["const"]; ok && !equal(c, value) {
+		report("must be %v", c)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		validateObject(schema, v, path, violations, report)
+	case []any:
+		if n, ok := number(schema["minItems"]); ok && float64(len(v)) < n {
+			report("must contain at least %v items", n)
+		}
+		if n, ok := number(schema["maxItems"]); ok && float64(len(v)) > n {
+			report("must contain at most %v items", n)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validate(items, item, fmt.Sprintf("%s[%d]", path, i), violations)
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if n, ok := number(schema["minLength"]); ok && float64(length) < n {
+			report("must be at least %v characters", n)
+		}
+		if n, ok := number(schema["maxLength"]); ok && float64(length) > n {
+			report("must be at most %v characters", n)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if !regexp.MustCompile(pattern).MatchString(v) {
+				report("must match pattern %q", pattern)
+			}
+		}
+	default:
+		if f, ok := number(value); ok {
+			if n, ok := number(schema["minimum"]); ok && f < n {
+				report("must be >= %v", n)
+			}
+			if n, ok := number(schema["maximum"]); ok && f > n {
+				report("must be <= %v", n)
+			}
+		}
+	}
+}
+
+func validateObject(schema map[string]any, obj map[string]any, path string, violations *[]Violation, report func(string, ...any)) {
+	required, _ := stringList(schema["required"])
+	for _, name := range required {
+		if _, ok := obj[name]; !ok {
+			report("missing required property %q", name)
+		}
+	}
+
+	props, _ := schema["properties"].(map[string]any)
+
+	// Iterate in a stable order so that violations are reproducible
+	for _, name := range sortedKeys(obj) {
+		if sub, ok := props[name].(map[string]any); ok {
+			validate(sub, obj[name], path+"."+name, violations)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				report("unexpected property %q", name)
+			}
+		case map[string]any:
+			validate(additional, obj[name], path+"."+name, violations)
+		}
+	}
+}
+
+// typeNames returns the type names of a "type" keyword, which may be a
+// single name or a list of names.
+func typeNames(t any) ([]string, error) {
+	switch v := t.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		return stringList(v)
+	}
+	return nil, fmt.Errorf("type must be a string or a list of strings")
+}
+
+func stringList(value any) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	list, ok := value.([]any)
+	if !ok {
+		if s, ok := value.([]string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("must be a list of strings")
+	}
+	out := make([]string, len(list))
+	for i, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a list of strings")
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+func hasType(value any, name string) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := number(value)
+		return ok
+	case "integer":
+		f, ok := number(value)
+		return ok && f == float64(int64(f))
+	}
+	return false
+}
+
+func typeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if _, ok := number(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// number converts the numeric types produced by JSON and YAML decoding.
+func number(value any) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func equal(a, b any) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
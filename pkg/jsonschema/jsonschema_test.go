@@ -0,0 +1,132 @@
+package jsonschema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func reviewSchema() map[string]any {
+	return map[string]any{
+		"type":     "object",
+		"required": []any{"recommendation", "score"},
+		"properties": map[string]any{
+			"recommendation": map[string]any{
+				"type": "string",
+				"enum": []any{"approve", "request_changes", "comment"},
+			},
+			"score":   map[string]any{"type": "integer", "minimum": 0, "maximum": 10},
+			"summary": map[string]any{"type": "string", "maxLength": 20},
+			"issues": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string"},
+			},
+		},
+		"additionalProperties": false,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  []string
+	}{
+		{
+			name:  "valid",
+			value: map[string]any{"recommendation": "approve", "score": float64(8), "issues": []any{"nit"}},
+		},
+		{
+			name:  "missing required",
+			value: map[string]any{"recommendation": "approve"},
+			want:  []string{`$: missing required property "score"`},
+		},
+		{
+			name:  "wrong type",
+			value: []any{"approve"},
+			want:  []string{"$: expected object, got array"},
+		},
+		{
+			name:  "enum and range",
+			value: map[string]any{"recommendation": "merge", "score": float64(11)},
+			want:  []string{"$.recommendation: must be one of", "$.score: must be <= 10"},
+		},
+		{
+			name:  "integer",
+			value: map[string]any{"recommendation": "comment", "score": 2.5},
+			want:  []string{"$.score: expected integer, got number"},
+		},
+		{
+			name:  "array items",
+			value: map[string]any{"recommendation": "comment", "score": float64(1), "issues": []any{"a", float64(1)}},
+			want:  []string{"$.issues[1]: expected string, got number"},
+		},
+		{
+			name:  "string length",
+			value: map[string]any{"recommendation": "comment", "score": float64(1), "summary": strings.Repeat("x", 21)},
+			want:  []string{"$.summary: must be at most 20 characters"},
+		},
+		{
+			name:  "additional properties",
+			value: map[string]any{"recommendation": "comment", "score": float64(1), "extra": true},
+			want:  []string{`$: unexpected property "extra"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(reviewSchema(), tt.value)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() error = %v, want *ValidationError", err)
+			}
+			if len(verr.Violations) != len(tt.want) {
+				t.Fatalf("Validate() violations = %v, want %v", verr.Violations, tt.want)
+			}
+			for i, want := range tt.want {
+				if got := verr.Violations[i].String(); !strings.HasPrefix(got, want) {
+					t.Errorf("violation %d = %q, want prefix %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  map[string]any
+		wantErr bool
+	}{
+		{"valid", reviewSchema(), false},
+		{"type list", map[string]any{"type": []any{"string", "null"}}, false},
+		{"unknown type", map[string]any{"type": "text"}, true},
+		{"properties not object", map[string]any{"properties": []any{"a"}}, true},
+		{"nested unknown type", map[string]any{"properties": map[string]any{"a": map[string]any{"type": "text"}}}, true},
+		{"required not list", map[string]any{"required": "a"}, true},
+		{"invalid pattern", map[string]any{"pattern": "("}, true},
+		{"annotations", map[string]any{"type": "string", "title": "Body", "description": "Review body"}, false},
+		{"unsupported keyword", map[string]any{"type": "string", "format": "email"}, true},
+		{"nested unsupported keyword", map[string]any{"properties": map[string]any{"a": map[string]any{"oneOf": []any{}}}}, true},
+		{"minimum not number", map[string]any{"type": "number", "minimum": "1"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.schema)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSchema) {
+				t.Errorf("Check() error = %v, want ErrInvalidSchema", err)
+			}
+		})
+	}
+}
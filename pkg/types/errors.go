@@ -8,20 +8,31 @@ import (
 // Domain errors
 var (
 	// Workflow errors
-	ErrWorkflowNotFound      = errors.New("workflow not found")
-	ErrWorkflowAlreadyExists = errors.New("workflow already exists")
-	ErrWorkflowInvalid       = errors.New("workflow definition is invalid")
+	ErrWorkflowNotFound        = errors.New("workflow not found")
+	ErrWorkflowAlreadyExists   = errors.New("workflow already exists")
+	ErrWorkflowInvalid         = errors.New("workflow definition is invalid")
+	ErrWorkflowVersionConflict = errors.New("workflow version already registered with different content")
 
 	// Run errors
 	ErrRunNotFound       = errors.New("workflow run not found")
 	ErrRunAlreadyStarted = errors.New("workflow run already started")
 	ErrRunCompleted      = errors.New("workflow run already completed")
 	ErrRunCancelled      = errors.New("workflow run was cancelled")
+	ErrRunLeased         = errors.New("workflow run is leased by another process")
+	ErrRunInputInvalid   = errors.New("workflow run inputs are invalid")
+	ErrRunTimedOut       = errors.New("workflow run timed out")
 
 	// Step errors
-	ErrStepNotFound = errors.New("step not found")
-	ErrStepFailed   = errors.New("step execution failed")
-	ErrStepTimeout  = errors.New("step execution timed out")
+	ErrStepNotFound      = errors.New("step not found")
+	ErrStepFailed        = errors.New("step execution failed")
+	ErrStepTimeout       = errors.New("step execution timed out")
+	ErrStepOutputInvalid = errors.New("step output does not match schema")
+
+	// Budget errors
+	ErrBudgetExceeded = errors.New("budget exceeded")
+
+	// Cache errors
+	ErrCacheMiss = errors.New("step result not cached")
 
 	// Policy errors
 	ErrPolicyNotFound  = errors.New("policy not found")
@@ -35,10 +46,19 @@ var (
 	ErrApprovalExpired  = errors.New("approval expired")
 	ErrApprovalPending  = errors.New("approval pending")
 
+	// Human input errors
+	ErrInputRequired = errors.New("input required")
+	ErrAnswerInvalid = errors.New("answer is invalid")
+
 	// Agent errors
 	ErrAgentNotFound    = errors.New("agent not found")
 	ErrAgentUnavailable = errors.New("agent unavailable")
 	ErrAgentTimeout     = errors.New("agent call timed out")
+	ErrAgentToolLimit   = errors.New("agent exceeded maximum tool iterations")
+
+	// Action errors
+	ErrActionNotFound     = errors.New("action not found")
+	ErrActionInputInvalid = errors.New("action input is invalid")
 
 	// LLM errors
 	ErrLLMProviderNotFound = errors.New("LLM provider not found")
@@ -18,6 +18,8 @@ func TestDomainErrors(t *testing.T) {
 		{"ErrRunAlreadyStarted", ErrRunAlreadyStarted},
 		{"ErrRunCompleted", ErrRunCompleted},
 		{"ErrRunCancelled", ErrRunCancelled},
+		{"ErrRunInputInvalid", ErrRunInputInvalid},
+		{"ErrRunTimedOut", ErrRunTimedOut},
 		{"ErrStepNotFound", ErrStepNotFound},
 		{"ErrStepFailed", ErrStepFailed},
 		{"ErrStepTimeout", ErrStepTimeout},
@@ -29,6 +31,8 @@ func TestDomainErrors(t *testing.T) {
 		{"ErrApprovalRejected", ErrApprovalRejected},
 		{"ErrApprovalExpired", ErrApprovalExpired},
 		{"ErrApprovalPending", ErrApprovalPending},
+		{"ErrInputRequired", ErrInputRequired},
+		{"ErrAnswerInvalid", ErrAnswerInvalid},
 		{"ErrAgentNotFound", ErrAgentNotFound},
 		{"ErrAgentUnavailable", ErrAgentUnavailable},
 		{"ErrAgentTimeout", ErrAgentTimeout},